@@ -0,0 +1,9 @@
+// Command pypi-attestations converts, inspects and verifies PyPI
+// attestations (PEP 740).
+package main
+
+import "github.com/carabiner-dev/pypi-attestations/internal/cli"
+
+func main() {
+	cli.Execute()
+}
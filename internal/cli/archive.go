@@ -0,0 +1,490 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/archive"
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/spf13/cobra"
+)
+
+type exportOptions struct {
+	Out         string
+	IndexURL    string
+	TrustedRoot string
+}
+
+// AddFlags adds the export flags to the command.
+func (o *exportOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Out, "out", "o", "", `archive file to write, or "-" for standard output`)
+	cmd.Flags().StringVar(&o.IndexURL, "index-url", pypi.DefaultURL, "base URL of the package index")
+	cmd.Flags().StringVar(&o.TrustedRoot, "trusted-root", "", "Sigstore trusted root JSON to pin (default: embedded public good instance root)")
+}
+
+// Validate checks the flag values.
+func (o *exportOptions) Validate() error {
+	if o.Out == "" {
+		return fmt.Errorf("the archive must be set with --out")
+	}
+	return nil
+}
+
+func addExport(parent *cobra.Command) {
+	opts := &exportOptions{}
+	cmd := &cobra.Command{
+		Use:   "export --out ARCHIVE [DIST|PROJECT==VERSION...]",
+		Short: "Package distributions and attestations for an offline network",
+		Long: `Export writes a gzipped tarball to verify distributions on a network
+without access to the index or Sigstore. It holds:
+
+  - the distribution files passed and their DIST.*.attestation siblings
+  - the provenance objects of the files of PROJECT==VERSION releases,
+    along with the digest the index publishes for each file
+  - a pinned trusted root, the embedded public good instance root unless
+    --trusted-root is set
+  - a manifest.json listing the sha256 of every member
+
+Release files are not downloaded, pass the local files to include them.
+The archive is checked and verified with import or verify --from-archive.`,
+		Example: `  pypi-attestations export --out transfer.tar.gz dist/*
+  pypi-attestations export --out transfer.tar.gz --trusted-root trusted_root.json sampleproject==4.0.0`,
+		Args: cobra.MinimumNArgs(1),
+		PreRunE: func(*cobra.Command, []string) error {
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExport(cmd, opts, args)
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+func runExport(cmd *cobra.Command, opts *exportOptions, args []string) error {
+	var files []string
+	var packages []pypi.Package
+	for _, arg := range args {
+		if strings.Contains(arg, "==") {
+			pkg, err := parseRequirement(arg)
+			if err != nil {
+				return err
+			}
+			packages = append(packages, pkg)
+			continue
+		}
+		files = append(files, arg)
+	}
+
+	var dists []string
+	if len(files) > 0 {
+		var err error
+		if dists, err = distributionArgs(files); err != nil {
+			return err
+		}
+	}
+	var releases []pypi.PackageProvenance
+	if len(packages) > 0 {
+		client, err := newIndexClient(cmd, pypi.WithURL(opts.IndexURL))
+		if err != nil {
+			return err
+		}
+		if releases, err = client.FetchAll(cmd.Context(), packages); err != nil {
+			return err
+		}
+	}
+
+	trustedRoot, err := pinnedTrustedRoot(opts.TrustedRoot)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = cmd.OutOrStdout()
+	if opts.Out != stdio {
+		f, err := os.Create(opts.Out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	aw := archive.NewWriter(w)
+	if err := aw.Add(archive.Entry{Path: archive.TrustedRootName, Type: archive.TypeTrustedRoot}, trustedRoot); err != nil {
+		return err
+	}
+	for _, dist := range dists {
+		if err := exportDistribution(aw, dist); err != nil {
+			return err
+		}
+	}
+	for _, release := range releases {
+		if err := exportRelease(cmd, aw, release); err != nil {
+			return err
+		}
+	}
+	if err := aw.Close(); err != nil {
+		return err
+	}
+
+	if opts.Out != stdio {
+		m := aw.Manifest()
+		fmt.Fprintf(cmd.OutOrStdout(), "%s (%d files)\n", opts.Out, len(m.Entries))
+	}
+	return nil
+}
+
+// pinnedTrustedRoot reads the trusted root at path, the embedded public
+// good instance root when path is empty.
+func pinnedTrustedRoot(path string) ([]byte, error) {
+	if path == "" {
+		return verify.EmbeddedTrustedRootJSON(verify.InstanceProduction)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := verify.New(verify.WithTrustedRootJSON(data)); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
+}
+
+// exportDistribution adds a distribution and its attestations.
+func exportDistribution(aw *archive.Writer, dist string) error {
+	name := filepath.Base(dist)
+	if err := aw.AddFile(archive.Entry{Path: "dist/" + name, Type: archive.TypeDistribution}, dist); err != nil {
+		return err
+	}
+	paths, err := siblingAttestationPaths(dist)
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		entry := archive.Entry{Path: "dist/" + filepath.Base(p), Type: archive.TypeAttestation, Distribution: name}
+		if err := aw.AddFile(entry, p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// exportRelease adds the provenance objects of the files of a release.
+func exportRelease(cmd *cobra.Command, aw *archive.Writer, release pypi.PackageProvenance) error {
+	if release.Error != nil {
+		return release.Error
+	}
+	for _, f := range release.Files {
+		if f.Error != nil {
+			fmt.Fprintf(cmd.ErrOrStderr(), "skipping %s: %v\n", f.Filename, f.Error)
+			continue
+		}
+		entry := archive.Entry{
+			Path:               "provenance/" + f.Filename + ".provenance.json",
+			Type:               archive.TypeProvenance,
+			Distribution:       f.Filename,
+			DistributionSHA256: f.SHA256,
+		}
+		if err := aw.Add(entry, f.Provenance); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type importOptions struct {
+	Dir         string
+	PolicyFile  string
+	TrustedRoot string
+	Format      string
+}
+
+// AddFlags adds the import flags to the command.
+func (o *importOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Dir, "dir", "d", "", "directory to extract the archive to, it must not exist")
+	cmd.Flags().StringVar(&o.PolicyFile, "policy", "", "trusted publisher policy file the attestations must satisfy")
+	cmd.Flags().StringVar(&o.TrustedRoot, "trusted-root", "", "Sigstore trusted root JSON to verify with (default: embedded public good instance root)")
+	addFormatFlag(cmd, &o.Format, formatText, "output format", formatText, formatJSON)
+}
+
+// Validate checks the flag values.
+func (o *importOptions) Validate() error {
+	if o.Dir == "" {
+		return fmt.Errorf("the target directory must be set with --dir")
+	}
+	if _, err := os.Stat(o.Dir); err == nil {
+		return fmt.Errorf("%s already exists", o.Dir)
+	}
+	return validateFormat(o.Format, formatText, formatJSON)
+}
+
+func addImport(parent *cobra.Command) {
+	opts := &importOptions{}
+	cmd := &cobra.Command{
+		Use:   "import ARCHIVE|- --dir DIR",
+		Short: "Check and extract an archive written by export",
+		Long: `Import checks every member of an archive written by export against
+its manifest and verifies the attestations and provenance objects it
+holds offline. They are verified with the embedded public good instance
+root or the one set with --trusted-root, never with the root pinned in
+the archive: the import fails when the pinned root is a different one.
+The files are moved to --dir only when everything verifies.
+
+Without --policy or the publishers of the configuration file, any
+signing identity is accepted. The command exits with 0 when the archive
+verifies, 1 when a verification fails and 2 on usage or input errors.`,
+		Example: `  pypi-attestations import transfer.tar.gz --dir transfer --policy publishers.yaml`,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: func(*cobra.Command, []string) error {
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runImport(cmd, opts, args[0])
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+func runImport(cmd *cobra.Command, opts *importOptions, path string) error {
+	dir := filepath.Clean(opts.Dir)
+	if err := os.MkdirAll(filepath.Dir(dir), 0o755); err != nil {
+		return err
+	}
+	tmp, err := os.MkdirTemp(filepath.Dir(dir), "."+filepath.Base(dir)+"-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	var funcs []verify.FnOption
+	if opts.PolicyFile != "" {
+		funcs = append(funcs, verify.WithPolicyFile(opts.PolicyFile))
+	} else if policy := configPolicy(cmd); policy != nil {
+		funcs = append(funcs, verify.WithPolicy(policy))
+	}
+	m, entries, err := checkArchive(cmd, path, tmp, opts.TrustedRoot, funcs)
+	if err != nil {
+		return err
+	}
+	if err := writeArchiveResults(cmd, opts.Format, entries); err != nil {
+		return err
+	}
+	if failed := countFailed(entries); failed > 0 {
+		return verificationFailed(fmt.Errorf("%d of %d files of the archive are unattested or do not verify", failed, len(entries)))
+	}
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(filepath.Join(tmp, archive.ManifestName), data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, dir)
+}
+
+// checkArchive extracts the archive at path to dir and verifies its
+// contents with the trusted root at trustedRoot (the embedded one when
+// empty) and the verifier options. The root pinned in the archive is only
+// checked to be the same.
+func checkArchive(cmd *cobra.Command, path, dir, trustedRoot string, funcs []verify.FnOption) (*archive.Manifest, []reportEntry, error) {
+	rootJSON, err := pinnedTrustedRoot(trustedRoot)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := openPath(cmd, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	m, err := archive.ExtractContext(cmd.Context(), f, dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(m.Find(archive.TypeTrustedRoot)) != 1 {
+		return nil, nil, fmt.Errorf("archive must pin exactly one trusted root")
+	}
+	pinned, err := os.ReadFile(filepath.Join(dir, archive.TrustedRootName))
+	if err != nil {
+		return nil, nil, err
+	}
+	same, err := sameTrustedRoot(pinned, rootJSON)
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading pinned trusted root: %w", err)
+	}
+	if !same {
+		return nil, nil, verificationFailed(fmt.Errorf("the trusted root pinned in the archive is not the one used to verify it"))
+	}
+
+	funcs = append(funcs,
+		verify.WithTrustedRootJSON(rootJSON),
+		verify.WithOffline(true),
+	)
+	v, err := verify.New(funcs...)
+	if err != nil {
+		return nil, nil, err
+	}
+	entries, err := verifyArchive(cmd.Context(), v, dir, m)
+	if err != nil {
+		return nil, nil, err
+	}
+	return m, entries, nil
+}
+
+// sameTrustedRoot reports whether two trusted_root.json documents hold
+// the same trusted root, regardless of their formatting.
+func sameTrustedRoot(a, b []byte) (bool, error) {
+	ca, err := canonicalTrustedRoot(a)
+	if err != nil {
+		return false, err
+	}
+	cb, err := canonicalTrustedRoot(b)
+	if err != nil {
+		return false, err
+	}
+	return bytes.Equal(ca, cb), nil
+}
+
+// canonicalTrustedRoot parses a trusted root and encodes it back compacted.
+func canonicalTrustedRoot(data []byte) ([]byte, error) {
+	tr, err := root.NewTrustedRootFromJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	out, err := tr.MarshalJSON()
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, out); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// verifyArchive verifies the distributions of an extracted archive
+// against their attestations, and the provenance objects against the
+// local file or the digest published by the index.
+func verifyArchive(ctx context.Context, v *verify.Verifier, dir string, m *archive.Manifest) ([]reportEntry, error) {
+	local := func(p string) string { return filepath.Join(dir, filepath.FromSlash(p)) }
+	dists := map[string]string{}
+	for _, e := range m.Find(archive.TypeDistribution) {
+		dists[path.Base(e.Path)] = e.Path
+	}
+
+	var entries []reportEntry
+	for _, e := range m.Find(archive.TypeDistribution) {
+		name := path.Base(e.Path)
+		entry := reportEntry{Target: name, Filename: name, Location: e.Path}
+		for _, a := range m.Find(archive.TypeAttestation) {
+			if a.Distribution != name {
+				continue
+			}
+			entry.Attested = true
+			result, err := verifyArchiveAttestation(ctx, v, local(a.Path), name, local(e.Path))
+			if err != nil {
+				entry.Verified, entry.Error = false, fmt.Sprintf("%s: %v", path.Base(a.Path), err)
+				break
+			}
+			entry.Verified, entry.Identity = true, result.Identity
+		}
+		if !entry.Attested {
+			entry.Error = "no attestations"
+		}
+		entries = append(entries, entry)
+	}
+
+	for _, e := range m.Find(archive.TypeProvenance) {
+		entry := reportEntry{Target: e.Distribution, Filename: e.Distribution, Location: e.Path, Attested: true}
+		digest, err := hex.DecodeString(e.DistributionSHA256)
+		if p, ok := dists[e.Distribution]; ok {
+			digest, err = fileSHA256(local(p))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", e.Path, err)
+		}
+
+		data, err := os.ReadFile(local(e.Path))
+		if err != nil {
+			return nil, err
+		}
+		report, err := pypi.VerifyProvenance(ctx, v, data, e.Distribution, digest)
+		switch {
+		case err != nil:
+			entry.Error = err.Error()
+		case !report.Passed():
+			entry.Error = "attestations do not verify"
+			for _, a := range report.Attestations {
+				if a.Error != "" {
+					entry.Error = a.Error
+					break
+				}
+			}
+		default:
+			entry.Verified = true
+			entry.Identity = report.Attestations[0].Verification.Identity
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+func verifyArchiveAttestation(ctx context.Context, v *verify.Verifier, attestationPath, name, distPath string) (*verify.VerificationResult, error) {
+	data, err := os.ReadFile(attestationPath)
+	if err != nil {
+		return nil, err
+	}
+	dist, err := os.Open(distPath)
+	if err != nil {
+		return nil, err
+	}
+	defer dist.Close()
+	return verifyDistribution(ctx, v, data, name, dist)
+}
+
+// fileSHA256 computes the sha256 digest of the file at path.
+func fileSHA256(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
+
+// archiveOutput is the JSON output of the archive verification.
+type archiveOutput struct {
+	schemaHeader
+
+	Files []reportEntry `json:"files"`
+}
+
+// writeArchiveResults writes a line per verified file, or the JSON
+// document.
+func writeArchiveResults(cmd *cobra.Command, format string, entries []reportEntry) error {
+	if format == formatJSON {
+		return writeJSON(cmd.OutOrStdout(), archiveOutput{schemaHeader: newSchemaHeader("ArchiveVerification"), Files: entries})
+	}
+	for _, e := range entries {
+		if e.Verified {
+			fmt.Fprintf(cmd.OutOrStdout(), "OK   %s (%s)\n", e.Location, e.Identity)
+			continue
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "FAIL %s: %s\n", e.Location, e.Error)
+	}
+	return nil
+}
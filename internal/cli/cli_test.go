@@ -0,0 +1,865 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+var (
+	testAttestation = filepath.Join("..", "..", "testdata", "pypi.attestation.json")
+	testProvenance  = filepath.Join("..", "..", "testdata", "pypi.provenance.json")
+)
+
+// run executes the tool with args, returning its standard output.
+func run(t *testing.T, stdin []byte, args ...string) (string, error) {
+	t.Helper()
+	cmd := New()
+	var out bytes.Buffer
+	cmd.SetArgs(args)
+	cmd.SetIn(bytes.NewReader(stdin))
+	cmd.SetOut(&out)
+	cmd.SetErr(&bytes.Buffer{})
+	err := cmd.Execute()
+	return out.String(), err
+}
+
+func TestConvert(t *testing.T) {
+	out, err := run(t, nil, "convert", testAttestation)
+	if err != nil {
+		t.Fatalf("Failed to convert attestation: %v", err)
+	}
+	if kind, err := convert.Detect([]byte(out)); err != nil || kind != convert.KindBundle {
+		t.Fatalf("Expected a bundle, got %s: %v", kind, err)
+	}
+
+	back, err := run(t, []byte(out), "convert")
+	if err != nil {
+		t.Fatalf("Failed to convert bundle from stdin: %v", err)
+	}
+	if kind, err := convert.Detect([]byte(back)); err != nil || kind != convert.KindAttestation {
+		t.Errorf("Expected an attestation, got %s: %v", kind, err)
+	}
+
+	piped, err := run(t, []byte(out), "convert", "--out", "-", "-")
+	if err != nil || piped != back {
+		t.Errorf("Expected the same conversion with explicit standard input and output: %v", err)
+	}
+	if _, err := run(t, nil, "convert", "-", testAttestation); err == nil {
+		t.Error("Expected error combining standard input with files")
+	}
+
+	if _, err := run(t, nil, "convert", "--to", "attestation", testAttestation); err == nil {
+		t.Error("Expected error converting to the same format")
+	}
+	if _, err := run(t, nil, "convert", "--to", "cyclonedx", testAttestation); err == nil {
+		t.Error("Expected error for an unknown format")
+	}
+
+	out, err = run(t, nil, "convert", testProvenance)
+	if err != nil {
+		t.Fatalf("Failed to convert provenance: %v", err)
+	}
+	bundles, err := convert.UnmarshalBundles([]byte(out))
+	if err != nil || len(bundles) == 0 {
+		t.Errorf("Expected bundles from provenance: %d, %v", len(bundles), err)
+	}
+}
+
+func TestConvertBatch(t *testing.T) {
+	src := t.TempDir()
+	data, err := os.ReadFile(testAttestation)
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	for _, name := range []string{"a-1.0.tar.gz.publish.attestation", "b-1.0.tar.gz.publish.attestation"} {
+		if err := os.WriteFile(filepath.Join(src, name), data, 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	dest := filepath.Join(t.TempDir(), "bundles")
+	out, err := run(t, nil, "convert", "--out", dest, filepath.Join(src, "*.publish.attestation"))
+	if err != nil {
+		t.Fatalf("Failed to convert files: %v", err)
+	}
+	if n := strings.Count(out, "->"); n != 2 {
+		t.Errorf("Expected 2 converted files, got %d: %s", n, out)
+	}
+	for _, name := range []string{"a-1.0.tar.gz.sigstore.json", "b-1.0.tar.gz.sigstore.json"} {
+		if _, err := os.Stat(filepath.Join(dest, name)); err != nil {
+			t.Errorf("Expected %s to be written: %v", name, err)
+		}
+	}
+
+	if _, err := run(t, nil, "convert", filepath.Join(src, "*.missing")); err == nil {
+		t.Error("Expected error for a glob without matches")
+	}
+}
+
+func TestVerify(t *testing.T) {
+	dist := filepath.Join(t.TempDir(), "pypi_attestations-0.0.28.tar.gz")
+	if err := os.WriteFile(dist, []byte("not the attested file"), 0o600); err != nil {
+		t.Fatalf("Failed to write distribution: %v", err)
+	}
+	trustedRoot := filepath.Join("..", "..", "testdata", "trusted_root.json")
+
+	out, err := run(t, nil, "verify", dist, "--attestation", testAttestation,
+		"--repository", "pypi/pypi-attestations", "--trusted-root", trustedRoot, "--format", "json")
+	if exitCode(err) != exitFailed {
+		t.Fatalf("Expected verification failure exit code, got %d: %v", exitCode(err), err)
+	}
+	var report verifyReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if report.Verified || report.Error == "" {
+		t.Errorf("Expected a failed report, got %+v", report)
+	}
+
+	attestation, err := os.ReadFile(testAttestation)
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	for _, tc := range []struct {
+		stdin []byte
+		args  []string
+	}{
+		{[]byte("not the attested file"), []string{"verify", "-", "--filename", filepath.Base(dist), "--attestation", testAttestation}},
+		{attestation, []string{"verify", dist, "--attestation", "-"}},
+	} {
+		args := append(tc.args, "--repository", "pypi/pypi-attestations", "--trusted-root", trustedRoot)
+		if _, err := run(t, tc.stdin, args...); exitCode(err) != exitFailed {
+			t.Errorf("Expected verification failure exit code for %v, got %d: %v", tc.args, exitCode(err), err)
+		}
+	}
+
+	malformed := filepath.Join(t.TempDir(), "malformed.json")
+	if err := os.WriteFile(malformed, []byte("{"), 0o600); err != nil {
+		t.Fatalf("Failed to write attestation: %v", err)
+	}
+	for _, args := range [][]string{
+		{"verify", dist, "--attestation", testAttestation},
+		{"verify", dist, "--attestation", malformed, "--repository", "a/b"},
+		{"verify", "-", "--attestation", testAttestation, "--repository", "a/b"},
+		{"verify", "-", "--filename", filepath.Base(dist), "--attestation", "-", "--repository", "a/b"},
+		{"verify", dist, "--attestation", testAttestation, "--repository", "a/b", "--format", "xml"},
+		{"verify", dist + ".missing", "--repository", "a/b"},
+		{"verify", dist, "--repository", "a/b"},
+	} {
+		if _, err := run(t, nil, args...); exitCode(err) != exitError {
+			t.Errorf("Expected usage error exit code for %v, got %d: %v", args, exitCode(err), err)
+		}
+	}
+}
+
+func TestInspect(t *testing.T) {
+	out, err := run(t, nil, "inspect", testAttestation)
+	if err != nil {
+		t.Fatalf("Failed to inspect attestation: %v", err)
+	}
+	for _, want := range []string{
+		"https://github.com/pypi/pypi-attestations/.github/workflows/release.yml@refs/tags/v0.0.28",
+		"https://docs.pypi.org/attestations/publish/v1",
+		"pypi_attestations-0.0.28.tar.gz",
+		"613501255",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected table output to contain %q:\n%s", want, out)
+		}
+	}
+
+	bundle, err := run(t, nil, "convert", testAttestation)
+	if err != nil {
+		t.Fatalf("Failed to convert attestation: %v", err)
+	}
+	out, err = run(t, []byte(bundle), "inspect", "--format", "json")
+	if err != nil {
+		t.Fatalf("Failed to inspect bundle: %v", err)
+	}
+	var output inspectOutput
+	if err := json.Unmarshal([]byte(out), &output); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if output.SchemaVersion != schemaVersion || output.Kind != "Inspection" || len(output.Documents) != 1 {
+		t.Fatalf("Unexpected inspect output: %s", out)
+	}
+	if ins := output.Documents[0]; ins.Kind != convert.KindBundle || ins.MediaType != "application/vnd.dev.sigstore.bundle.v0.3+json" || len(ins.LogEntries) != 1 {
+		t.Errorf("Unexpected bundle inspection: %+v", ins)
+	}
+
+	out, err = run(t, nil, "inspect", "--format", "yaml", testProvenance)
+	if err != nil {
+		t.Fatalf("Failed to inspect provenance: %v", err)
+	}
+	if !strings.Contains(out, "schemaVersion: v1") || !strings.Contains(out, "publisher:") {
+		t.Errorf("Expected a versioned YAML document with publishers:\n%s", out)
+	}
+
+	if _, err := run(t, nil, "inspect", "--format", "xml", testAttestation); err == nil {
+		t.Error("Expected error for an unknown format")
+	}
+}
+
+func TestFetch(t *testing.T) {
+	provenance, err := os.ReadFile(testProvenance)
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const filename = "pypi_attestations-0.0.28.tar.gz"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pypi/pypi-attestations/0.0.28/json":
+			fmt.Fprintf(w, `{"urls": [{"filename": %q, "digests": {"sha256": %q}}, {"filename": "other.whl", "digests": {}}]}`,
+				filename, "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f")
+		case "/integrity/pypi-attestations/0.0.28/" + filename + "/provenance":
+			w.Write(provenance)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	out, err := run(t, nil, "fetch", "pypi-attestations==0.0.28", "--index-url", srv.URL, "--out", dir)
+	if err != nil {
+		t.Fatalf("Failed to fetch: %v", err)
+	}
+	for _, name := range []string{".provenance.json", ".publish.attestation", ".publish.sigstore.json"} {
+		if !strings.Contains(out, filename+name) {
+			t.Errorf("Expected %s to be reported:\n%s", filename+name, out)
+		}
+		if _, err := os.Stat(filepath.Join(dir, filename+name)); err != nil {
+			t.Errorf("Expected %s to be written: %v", filename+name, err)
+		}
+	}
+
+	out, err = run(t, nil, "fetch", "pypi-attestations==0.0.28", "--index-url", srv.URL, "--out", dir, "--output", "json")
+	if err != nil {
+		t.Fatalf("Failed to fetch with JSON output: %v", err)
+	}
+	var fetched fetchOutput
+	if err := json.Unmarshal([]byte(out), &fetched); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v\n%s", err, out)
+	}
+	if fetched.SchemaVersion != schemaVersion || fetched.Kind != "FetchResult" || len(fetched.Files) != 2 ||
+		!fetched.Files[0].Attested || len(fetched.Files[0].Written) != 3 || fetched.Files[1].Attested {
+		t.Errorf("Unexpected fetch output:\n%s", out)
+	}
+
+	out, err = run(t, nil, "fetch", "pypi-attestations==0.0.28", "--index-url", srv.URL, "--out", "-")
+	if err != nil {
+		t.Fatalf("Failed to fetch to standard output: %v", err)
+	}
+	if kind, err := convert.Detect([]byte(out)); err != nil || kind != convert.KindProvenance || strings.Count(out, "\n") != 1 {
+		t.Errorf("Expected a provenance object line, got %s: %v\n%s", kind, err, out)
+	}
+
+	if _, err := run(t, nil, "fetch", "pypi-attestations==0.0.28", "--index-url", srv.URL, "--out", dir, "--filename", "missing.whl"); err == nil {
+		t.Error("Expected error for a file not in the release")
+	}
+	if _, err := run(t, nil, "fetch", "pypi-attestations", "--index-url", srv.URL); err == nil {
+		t.Error("Expected error for a release without version")
+	}
+}
+
+func TestSign(t *testing.T) {
+	dir := t.TempDir()
+	dist := filepath.Join(dir, "sampleproject-1.0.tar.gz")
+	for _, path := range []string{dist, dist + convert.AttestationSuffix, filepath.Join(dir, "README.md")} {
+		if err := os.WriteFile(path, []byte("data"), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+
+	dists, err := distributionArgs([]string{filepath.Join(dir, "*")})
+	if err != nil || len(dists) != 1 || dists[0] != dist {
+		t.Errorf("Expected only the distribution, got %v: %v", dists, err)
+	}
+
+	for _, args := range [][]string{
+		{"sign", filepath.Join(dir, "README.md")},
+		{"sign", dist, "--identity-token", "token"},
+		{"sign", dist, "--key", "key.pem"},
+		{"sign", dist, "--oauth-flow", "carrier-pigeon"},
+	} {
+		if _, err := run(t, nil, args...); err == nil {
+			t.Errorf("Expected error for %v", args)
+		}
+	}
+}
+
+func TestUpload(t *testing.T) {
+	attestation, err := os.ReadFile(testAttestation)
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"sampleproject-1.0.tar.gz":                             []byte("sdist"),
+		"sampleproject-1.0.tar.gz" + convert.AttestationSuffix: attestation,
+		"sampleproject-1.0-py3-none-any.whl":                   []byte("wheel"),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	uploads := map[string]int{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pass, _ := r.BasicAuth(); pass != "pypi-token" {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var attestations []json.RawMessage
+		if v := r.FormValue("attestations"); v != "" {
+			if err := json.Unmarshal([]byte(v), &attestations); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		_, fh, err := r.FormFile("content")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		uploads[fh.Filename] = len(attestations)
+	}))
+	defer srv.Close()
+
+	if _, err := run(t, nil, "upload", filepath.Join(dir, "*"), "--repository-url", srv.URL, "--password", "pypi-token"); err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	if len(uploads) != 2 || uploads["sampleproject-1.0.tar.gz"] != 1 || uploads["sampleproject-1.0-py3-none-any.whl"] != 0 {
+		t.Errorf("Unexpected uploads: %v", uploads)
+	}
+
+	uploads = map[string]int{}
+	if _, err := run(t, nil, "upload", filepath.Join(dir, "*"), "--repository-url", srv.URL, "--password", "pypi-token", "--attestations-only"); err != nil {
+		t.Fatalf("Failed to upload attestations: %v", err)
+	}
+	if len(uploads) != 1 || uploads["sampleproject-1.0.tar.gz"] != 1 {
+		t.Errorf("Expected only the attested file to be uploaded: %v", uploads)
+	}
+
+	if _, err := run(t, nil, "upload", filepath.Join(dir, "*"), "--repository-url", srv.URL, "--password", "wrong"); err == nil {
+		t.Error("Expected error for rejected credentials")
+	}
+}
+
+func TestVerifyRequirements(t *testing.T) {
+	provenance, err := os.ReadFile(testProvenance)
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const digest = "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"
+	const other = "1111111111111111111111111111111111111111111111111111111111111111"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pypi/pypi-attestations/0.0.28/json":
+			fmt.Fprintf(w, `{"urls": [{"filename": "pypi_attestations-0.0.28.tar.gz", "digests": {"sha256": %q}}]}`, digest)
+		case "/integrity/pypi-attestations/0.0.28/pypi_attestations-0.0.28.tar.gz/provenance":
+			w.Write(provenance)
+		case "/pypi/unattested/1.0/json":
+			fmt.Fprintf(w, `{"urls": [{"filename": "unattested-1.0.tar.gz", "digests": {"sha256": %q}}]}`, other)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "requirements.txt")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write requirements: %v", err)
+		}
+		return path
+	}
+	args := func(path string) []string {
+		return []string{
+			"verify-requirements", path, "--index-url", srv.URL,
+			"--trusted-root", filepath.Join("..", "..", "testdata", "trusted_root.json"),
+		}
+	}
+
+	out, err := run(t, nil, args(write("pypi-attestations==0.0.28 --hash=sha256:"+digest+"\n"))...)
+	if err != nil {
+		t.Fatalf("Expected requirements to verify: %v\n%s", err, out)
+	}
+	if !strings.Contains(out, "OK   pypi-attestations==0.0.28") {
+		t.Errorf("Unexpected output:\n%s", out)
+	}
+
+	stdin := []byte("pypi-attestations==0.0.28 --hash=sha256:" + digest + "\n")
+	if out, err := run(t, stdin, args("-")...); err != nil || !strings.Contains(out, "OK   pypi-attestations==0.0.28") {
+		t.Errorf("Expected requirements from standard input to verify: %v\n%s", err, out)
+	}
+
+	out, err = run(t, nil, args(write(`pypi-attestations==0.0.28 --hash=sha256:`+digest+`
+unattested==1.0 --hash=sha256:`+other+`
+unhashed==2.0
+`))...)
+	if exitCode(err) != exitFailed {
+		t.Fatalf("Expected verification failure, got %d: %v", exitCode(err), err)
+	}
+	for _, want := range []string{"FAIL unattested==1.0", "file has no attestations", "FAIL unhashed==2.0", "no sha256 hashes"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected output to contain %q:\n%s", want, out)
+		}
+	}
+
+	if _, err := run(t, nil, args(write("requests>=2\n"))...); exitCode(err) != exitError {
+		t.Errorf("Expected input error for an unpinned requirement, got %v", err)
+	}
+}
+
+func TestReport(t *testing.T) {
+	provenance, err := os.ReadFile(testProvenance)
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const digest = "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"
+	const other = "1111111111111111111111111111111111111111111111111111111111111111"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pypi/pypi-attestations/0.0.28/json":
+			fmt.Fprintf(w, `{"urls": [{"filename": "pypi_attestations-0.0.28.tar.gz", "digests": {"sha256": %q}}]}`, digest)
+		case "/integrity/pypi-attestations/0.0.28/pypi_attestations-0.0.28.tar.gz/provenance":
+			w.Write(provenance)
+		case "/pypi/unattested/1.0/json":
+			fmt.Fprintf(w, `{"urls": [{"filename": "unattested-1.0.tar.gz", "digests": {"sha256": %q}}]}`, other)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	reqs := filepath.Join(t.TempDir(), "requirements.txt")
+	if err := os.WriteFile(reqs, []byte("pypi-attestations==0.0.28 --hash=sha256:"+digest+"\nunattested==1.0 --hash=sha256:"+other+"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write requirements: %v", err)
+	}
+	args := func(format string, extra ...string) []string {
+		return append([]string{
+			"report", "-r", reqs, "--format", format, "--index-url", srv.URL,
+			"--trusted-root", filepath.Join("..", "..", "testdata", "trusted_root.json"),
+		}, extra...)
+	}
+
+	out, err := run(t, nil, args("markdown")...)
+	if err != nil {
+		t.Fatalf("Expected report without --fail to succeed: %v", err)
+	}
+	for _, want := range []string{"1 of 2 files verified", "| ✅ verified | pypi-attestations==0.0.28 | pypi_attestations-0.0.28.tar.gz |", "| ⚠️ unattested | unattested==1.0 |"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected markdown to contain %q:\n%s", want, out)
+		}
+	}
+
+	out, err = run(t, nil, args("sarif", "--fail")...)
+	if exitCode(err) != exitFailed {
+		t.Fatalf("Expected verification failure with --fail, got %d: %v", exitCode(err), err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal([]byte(out), &log); err != nil {
+		t.Fatalf("Failed to parse SARIF: %v\n%s", err, out)
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || len(log.Runs[0].Results) != 1 {
+		t.Fatalf("Unexpected SARIF log:\n%s", out)
+	}
+	res := log.Runs[0].Results[0]
+	if res.RuleID != ruleUnattested || len(res.Locations) != 1 || res.Locations[0].PhysicalLocation.Region.StartLine != 2 {
+		t.Errorf("Unexpected SARIF result: %+v", res)
+	}
+
+	out, err = run(t, nil, args("json")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []reportEntry
+	if err := json.Unmarshal([]byte(out), &entries); err != nil {
+		t.Fatalf("Failed to parse JSON report: %v", err)
+	}
+	if len(entries) != 2 || !entries[0].Verified || entries[0].Identity == "" || entries[1].Verified {
+		t.Errorf("Unexpected entries: %+v", entries)
+	}
+
+	if _, err := run(t, nil, "report"); exitCode(err) != exitError {
+		t.Errorf("Expected usage error without targets, got %v", err)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv(configEnv, "")
+	dir := t.TempDir()
+	dist := filepath.Join(dir, "pypi_attestations-0.0.28.tar.gz")
+	if err := os.WriteFile(dist, []byte("not the attested file"), 0o600); err != nil {
+		t.Fatalf("Failed to write distribution: %v", err)
+	}
+	trustedRoot, err := filepath.Abs(filepath.Join("..", "..", "testdata", "trusted_root.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	write := func(content string) string {
+		path := filepath.Join(dir, "config.yaml")
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+		return path
+	}
+	verifyArgs := []string{"verify", dist, "--attestation", testAttestation}
+
+	// Without a publisher the command is misused
+	if _, err := run(t, nil, verifyArgs...); exitCode(err) != exitError {
+		t.Fatalf("Expected usage error without publishers, got %v", err)
+	}
+
+	// The publishers and trusted root of the config make it verify
+	cfg := write("trusted-root: " + trustedRoot + "\npublishers:\n  - repository: pypi/pypi-attestations\n")
+	if _, err := run(t, nil, append(verifyArgs, "--config", cfg)...); exitCode(err) != exitFailed {
+		t.Errorf("Expected verification failure with the config publishers, got %v", err)
+	}
+
+	// The environment overrides the config, flags override both
+	t.Setenv(configEnv, cfg)
+	t.Setenv(envName("trusted-root"), filepath.Join(dir, "missing.json"))
+	if _, err := run(t, nil, verifyArgs...); exitCode(err) != exitError || !strings.Contains(err.Error(), "missing.json") {
+		t.Errorf("Expected the trusted root of the environment to be used, got %v", err)
+	}
+	if _, err := run(t, nil, append(verifyArgs, "--trusted-root", trustedRoot)...); exitCode(err) != exitFailed {
+		t.Errorf("Expected the trusted root flag to take precedence, got %v", err)
+	}
+
+	for _, content := range []string{
+		"index_url: https://example.com\n",
+		"policy: policy.yaml\npublishers:\n  - repository: a/b\n",
+		"publishers:\n  - workflow: release.yml\n",
+	} {
+		if _, err := loadConfig(write(content), true); err == nil {
+			t.Errorf("Expected error for config %q", content)
+		}
+	}
+
+	loaded, err := loadConfig(write("policy: policy.yaml\n"), true)
+	if err != nil || loaded.Policy != filepath.Join(dir, "policy.yaml") {
+		t.Errorf("Expected the policy path to be relative to the config, got %+v: %v", loaded, err)
+	}
+	if _, err := loadConfig(filepath.Join(dir, "missing.yaml"), false); err != nil {
+		t.Errorf("Expected a missing optional config to be ignored: %v", err)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	bundle, err := run(t, nil, "convert", testAttestation)
+	if err != nil {
+		t.Fatalf("Failed to convert attestation: %v", err)
+	}
+	out, err := run(t, []byte(bundle), "diff", testAttestation, "-")
+	if err != nil || !strings.Contains(out, "no differences") {
+		t.Fatalf("Expected an attestation and its bundle to match: %v\n%s", err, out)
+	}
+
+	data, err := os.ReadFile(testAttestation)
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := convert.UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to parse attestation: %v", err)
+	}
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, attestation.GetEnvelope().GetStatement(), "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	attestation.Envelope.Statement = indented.Bytes()
+	attestation.VerificationMaterial.TransparencyEntries[0].Fields["logIndex"] = structpb.NewStringValue("1")
+	modified, err := convert.MarshalAttestation(attestation)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	out, err = run(t, modified, "diff", "--format", "json", testAttestation, "-")
+	if exitCode(err) != exitFailed {
+		t.Fatalf("Expected differences exit code, got %d: %v", exitCode(err), err)
+	}
+	var diffs []difference
+	if err := json.Unmarshal([]byte(out), &diffs); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	var fields []string
+	for _, d := range diffs {
+		fields = append(fields, d.Field)
+	}
+	if want := []string{"statement", "logEntry[0].logIndex"}; !reflect.DeepEqual(fields, want) {
+		t.Errorf("Expected differences in %v, got %v", want, fields)
+	}
+
+	if _, err := run(t, nil, "diff", "-", "-"); exitCode(err) != exitError {
+		t.Errorf("Expected usage error reading both documents from standard input, got %v", err)
+	}
+}
+
+func TestOutputContract(t *testing.T) {
+	dist := filepath.Join(t.TempDir(), "pypi_attestations-0.0.28.tar.gz")
+	if err := os.WriteFile(dist, []byte("not the attested file"), 0o600); err != nil {
+		t.Fatalf("Failed to write distribution: %v", err)
+	}
+	args := []string{
+		"verify", dist, "--attestation", testAttestation, "--repository", "pypi/pypi-attestations",
+		"--trusted-root", filepath.Join("..", "..", "testdata", "trusted_root.json"),
+	}
+
+	out, err := run(t, nil, append(args, "--output", "json")...)
+	if exitCode(err) != exitFailed {
+		t.Fatalf("Expected verification failure, got %v", err)
+	}
+	var report verifyReport
+	if err := json.Unmarshal([]byte(out), &report); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if report.SchemaVersion != schemaVersion || report.Kind != "Verification" {
+		t.Errorf("Expected a versioned document, got %+v", report.schemaHeader)
+	}
+
+	out, err = run(t, nil, append(args, "--quiet")...)
+	if exitCode(err) != exitFailed || out != "" {
+		t.Errorf("Expected a silent verification failure, got %d: %q", exitCode(err), out)
+	}
+	if out, err := run(t, nil, "inspect", "-q", testAttestation); err != nil || out != "" {
+		t.Errorf("Expected a silent inspection, got %v: %q", err, out)
+	}
+
+	out, err = run(t, nil, "__complete", "inspect", "--format", "")
+	if err != nil {
+		t.Fatalf("Failed to complete: %v", err)
+	}
+	for _, want := range []string{"table", "json", "yaml"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Expected %q to be completed:\n%s", want, out)
+		}
+	}
+}
+
+func TestArchive(t *testing.T) {
+	provenance, err := os.ReadFile(testProvenance)
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	const digest = "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pypi/pypi-attestations/0.0.28/json":
+			fmt.Fprintf(w, `{"urls": [{"filename": "pypi_attestations-0.0.28.tar.gz", "digests": {"sha256": %q}}]}`, digest)
+		case "/integrity/pypi-attestations/0.0.28/pypi_attestations-0.0.28.tar.gz/provenance":
+			w.Write(provenance)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	trustedRoot := filepath.Join("..", "..", "testdata", "trusted_root.json")
+	tmp := t.TempDir()
+
+	release := filepath.Join(tmp, "release.tar.gz")
+	if _, err := run(t, nil, "export", "--out", release, "--index-url", srv.URL,
+		"--trusted-root", trustedRoot, "pypi-attestations==0.0.28"); err != nil {
+		t.Fatalf("Failed to export release: %v", err)
+	}
+	dir := filepath.Join(tmp, "release")
+	otherRoot := filepath.Join("..", "..", "testdata", "trusted_root.rekor-v2.json")
+	if _, err := run(t, nil, "import", release, "--dir", dir, "--trusted-root", otherRoot); exitCode(err) != exitFailed {
+		t.Errorf("Expected a pinned root other than --trusted-root to be refused, got %v", err)
+	}
+	if _, err := os.Stat(dir); err == nil {
+		t.Error("Expected a refused import to leave no directory")
+	}
+	out, err := run(t, nil, "import", release, "--dir", dir, "--trusted-root", trustedRoot, "--format", "json")
+	if err != nil {
+		t.Fatalf("Failed to import release: %v\n%s", err, out)
+	}
+	var result archiveOutput
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("Failed to parse JSON output: %v", err)
+	}
+	if result.Kind != "ArchiveVerification" || len(result.Files) != 1 || !result.Files[0].Verified {
+		t.Errorf("Unexpected import result: %+v", result)
+	}
+	for _, name := range []string{"manifest.json", "trusted_root.json", "provenance/pypi_attestations-0.0.28.tar.gz.provenance.json"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("Expected %s to be imported: %v", name, err)
+		}
+	}
+	if _, err := run(t, nil, "import", release, "--dir", dir, "--trusted-root", trustedRoot); exitCode(err) != exitError {
+		t.Errorf("Expected usage error importing to an existing directory, got %v", err)
+	}
+
+	out, err = run(t, nil, "verify", "--from-archive", release, "--trusted-root", trustedRoot, "--repository", "pypi/pypi-attestations")
+	if err != nil || !strings.Contains(out, "OK   provenance/pypi_attestations-0.0.28.tar.gz.provenance.json") {
+		t.Errorf("Expected archive to verify: %v\n%s", err, out)
+	}
+	if _, err := run(t, nil, "verify", "--from-archive", release, "--trusted-root", otherRoot, "--repository", "pypi/pypi-attestations"); exitCode(err) != exitFailed {
+		t.Errorf("Expected a pinned root other than --trusted-root to be refused, got %v", err)
+	}
+	if _, err := run(t, nil, "verify", "--from-archive", release, "--trusted-root", trustedRoot, "--repository", "other/repository"); exitCode(err) != exitFailed {
+		t.Errorf("Expected policy failure, got %v", err)
+	}
+
+	// The attestation does not attest this file
+	dist := filepath.Join(tmp, "pypi_attestations-0.0.28.tar.gz")
+	if err := os.WriteFile(dist, []byte("not the attested file"), 0o600); err != nil {
+		t.Fatalf("Failed to write distribution: %v", err)
+	}
+	attestation, err := os.ReadFile(testAttestation)
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	if err := os.WriteFile(dist+convert.AttestationSuffix, attestation, 0o600); err != nil {
+		t.Fatalf("Failed to write attestation: %v", err)
+	}
+	archive, err := run(t, nil, "export", "--out", "-", "--trusted-root", trustedRoot, dist)
+	if err != nil {
+		t.Fatalf("Failed to export distribution: %v", err)
+	}
+	out, err = run(t, []byte(archive), "import", "-", "--dir", filepath.Join(tmp, "dist"), "--trusted-root", trustedRoot)
+	if exitCode(err) != exitFailed || !strings.Contains(out, "FAIL dist/pypi_attestations-0.0.28.tar.gz") {
+		t.Errorf("Expected verification failure, got %v\n%s", err, out)
+	}
+	if _, err := os.Stat(filepath.Join(tmp, "dist")); err == nil {
+		t.Error("Expected a failed import to leave no directory")
+	}
+
+	tampered := []byte(archive)
+	tampered[len(tampered)/2] ^= 0xff
+	if _, err := run(t, tampered, "verify", "--from-archive", "-", "--trusted-root", trustedRoot, "--repository", "pypi/pypi-attestations"); exitCode(err) != exitError {
+		t.Errorf("Expected integrity error, got %v", err)
+	}
+	if _, err := run(t, nil, "verify", dist, "--from-archive", release, "--repository", "a/b"); exitCode(err) != exitError {
+		t.Errorf("Expected usage error combining DIST and --from-archive, got %v", err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir := t.TempDir()
+	attestation, err := os.ReadFile(testAttestation)
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	write := func(name string, data []byte) {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Errorf("Failed to write %s: %v", name, err)
+		}
+	}
+	write("present-1.0.tar.gz", []byte("present"))
+	write("present-1.0.tar.gz"+convert.AttestationSuffix, attestation)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		write("pypi_attestations-0.0.28.tar.gz", []byte("not the attested file"))
+		write("pypi_attestations-0.0.28.tar.gz"+convert.AttestationSuffix, attestation)
+		write("notes.txt", []byte("not a distribution"))
+	}()
+
+	cmd := New()
+	var stderr bytes.Buffer
+	cmd.SetArgs([]string{
+		"watch", dir, "--verify", "--interval", "50ms", "--repository", "pypi/pypi-attestations",
+		"--trusted-root", filepath.Join("..", "..", "testdata", "trusted_root.json"),
+	})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&stderr)
+	if err := cmd.ExecuteContext(ctx); err != nil {
+		t.Fatalf("Expected the watch to stop cleanly: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "FAIL pypi_attestations-0.0.28.tar.gz: ") {
+		t.Errorf("Expected only the new distribution to be verified, got:\n%s", stderr.String())
+	}
+
+	for _, args := range [][]string{
+		{"watch", dir},
+		{"watch", dir, "--sign", "--verify"},
+		{"watch", dir, "--verify"},
+		{"watch", dir, "--sign", "--interval", "0s"},
+		{"watch", filepath.Join(dir, "missing"), "--verify", "--repository", "a/b"},
+	} {
+		if _, err := run(t, nil, args...); exitCode(err) != exitError {
+			t.Errorf("Expected usage error for %v, got %v", args, err)
+		}
+	}
+}
+
+func TestServe(t *testing.T) {
+	for _, args := range [][]string{
+		{"serve"},
+		{"serve", "--grpc", "127.0.0.1:0", "--policy", filepath.Join(t.TempDir(), "missing.yaml")},
+		{"serve", "--grpc", "not an address"},
+		{"serve", "--http", "127.0.0.1:0", "--max-request-size", "0"},
+	} {
+		if _, err := run(t, nil, args...); exitCode(err) != exitError {
+			t.Errorf("Expected usage error for %v, got %v", args, err)
+		}
+	}
+}
+
+func TestLogging(t *testing.T) {
+	dist := filepath.Join(t.TempDir(), "pypi_attestations-0.0.28.tar.gz")
+	if err := os.WriteFile(dist, []byte("not the attested file"), 0o600); err != nil {
+		t.Fatalf("Failed to write distribution: %v", err)
+	}
+	trustedRoot := filepath.Join("..", "..", "testdata", "trusted_root.json")
+
+	cmd := New()
+	var stderr bytes.Buffer
+	cmd.SetArgs([]string{"verify", dist, "--attestation", testAttestation, "--repository", "pypi/pypi-attestations",
+		"--trusted-root", trustedRoot, "--log-level", "debug", "--log-format", "json"})
+	cmd.SetOut(&bytes.Buffer{})
+	cmd.SetErr(&stderr)
+	if err := cmd.Execute(); exitCode(err) != exitFailed {
+		t.Fatalf("Expected verification failure exit code, got %d: %v", exitCode(err), err)
+	}
+	var messages []string
+	for _, line := range strings.Split(stderr.String(), "\n") {
+		record := struct {
+			Level string `json:"level"`
+			Msg   string `json:"msg"`
+		}{}
+		if json.Unmarshal([]byte(line), &record) == nil && record.Level == "DEBUG" {
+			messages = append(messages, record.Msg)
+		}
+	}
+	for _, want := range []string{"checking attestation subject", "verification failed"} {
+		if !slices.Contains(messages, want) {
+			t.Errorf("Expected %q to be logged, got %v", want, messages)
+		}
+	}
+
+	for _, args := range [][]string{
+		{"inspect", testAttestation, "--log-level", "verbose"},
+		{"inspect", testAttestation, "--log-format", "xml"},
+	} {
+		if _, err := run(t, nil, args...); exitCode(err) != exitError {
+			t.Errorf("Expected usage error exit code for %v, got %d: %v", args, exitCode(err), err)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/carabiner-dev/pypi-attestations/internal/transport"
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is the prefix of the environment variables overriding the
+// configuration file.
+const envPrefix = "PYPI_ATTESTATIONS_"
+
+// configEnv names the configuration file when --config is not set.
+const configEnv = envPrefix + "CONFIG"
+
+// config is the configuration file of the tool. Settings are applied to
+// the flags of the same name not set on the command line, after the
+// PYPI_ATTESTATIONS_* environment variables.
+type config struct {
+	IndexURL    string `yaml:"index-url"`
+	TrustedRoot string `yaml:"trusted-root"`
+	Proxy       string `yaml:"proxy"`
+	LogLevel    string `yaml:"log-level"`
+	LogFormat   string `yaml:"log-format"`
+
+	// Policy is the path of a trusted publisher policy file.
+	Policy string `yaml:"policy"`
+
+	// Publishers is an inline trusted publisher policy, used when no
+	// policy file is set.
+	Publishers []verify.PublisherPolicy `yaml:"publishers"`
+}
+
+// defaultConfigPath returns the path of the configuration file in the user
+// configuration directory, ~/.config/pypi-attestations/config.yaml on Linux.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, appName, "config.yaml")
+}
+
+// configPathHelp describes the default configuration file in the flag help.
+func configPathHelp() string {
+	if path := defaultConfigPath(); path != "" {
+		return "$" + configEnv + " or " + path
+	}
+	return "$" + configEnv
+}
+
+// loadConfig reads the configuration file at path. A missing file is not
+// an error unless required is set. Relative paths in the file are
+// resolved against its directory.
+func loadConfig(path string, required bool) (*config, error) {
+	cfg := &config{}
+	if path == "" {
+		return cfg, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !required && errors.Is(err, fs.ErrNotExist) {
+			return cfg, nil
+		}
+		return nil, fmt.Errorf("reading config: %w", err)
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing config %s: %w", path, err)
+	}
+	if cfg.Policy != "" && len(cfg.Publishers) > 0 {
+		return nil, fmt.Errorf("config %s: policy and publishers cannot be combined", path)
+	}
+	if len(cfg.Publishers) > 0 {
+		if err := cfg.policy().Validate(); err != nil {
+			return nil, fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+
+	dir := filepath.Dir(path)
+	for _, p := range []*string{&cfg.TrustedRoot, &cfg.Policy} {
+		if *p != "" && !filepath.IsAbs(*p) {
+			*p = filepath.Join(dir, *p)
+		}
+	}
+	return cfg, nil
+}
+
+// policy returns the inline publisher policy, nil when there is none.
+func (c *config) policy() *verify.Policy {
+	if len(c.Publishers) == 0 {
+		return nil
+	}
+	return &verify.Policy{Publishers: c.Publishers}
+}
+
+// envName returns the environment variable overriding a flag.
+func envName(flag string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flag, "-", "_"))
+}
+
+type configKey struct{}
+
+// applyConfig loads the configuration file and sets the flags of cmd not
+// set on the command line from the environment or the file, in that
+// order. The configuration is stored in the context of the command.
+func applyConfig(cmd *cobra.Command) error {
+	path, required := defaultConfigPath(), false
+	if f := cmd.Flags().Lookup("config"); f != nil && f.Changed {
+		path, required = f.Value.String(), true
+	} else if env := os.Getenv(configEnv); env != "" {
+		path, required = env, true
+	}
+	cfg, err := loadConfig(path, required)
+	if err != nil {
+		return err
+	}
+
+	settings := map[string]string{
+		"index-url":    cfg.IndexURL,
+		"trusted-root": cfg.TrustedRoot,
+		"proxy":        cfg.Proxy,
+		"policy":       cfg.Policy,
+		"log-level":    cfg.LogLevel,
+		"log-format":   cfg.LogFormat,
+	}
+	for name, value := range settings {
+		f := cmd.Flags().Lookup(name)
+		if f == nil || f.Changed {
+			continue
+		}
+		// A publisher set on the command line replaces any policy
+		if name == "policy" && cmd.Flags().Changed("repository") {
+			continue
+		}
+		if env := os.Getenv(envName(name)); env != "" {
+			value = env
+		}
+		if value == "" {
+			continue
+		}
+		if err := cmd.Flags().Set(name, value); err != nil {
+			return fmt.Errorf("setting --%s from the configuration: %w", name, err)
+		}
+	}
+
+	cmd.SetContext(context.WithValue(cmd.Context(), configKey{}, cfg))
+	return nil
+}
+
+// configPolicy returns the inline publisher policy of the configuration
+// file, nil when it has none.
+func configPolicy(cmd *cobra.Command) *verify.Policy {
+	if cmd.Context() == nil {
+		return nil
+	}
+	cfg, ok := cmd.Context().Value(configKey{}).(*config)
+	if !ok {
+		return nil
+	}
+	return cfg.policy()
+}
+
+// proxyURL returns the value of the --proxy flag.
+func proxyURL(cmd *cobra.Command) string {
+	proxy, err := cmd.Flags().GetString("proxy")
+	if err != nil {
+		return ""
+	}
+	return proxy
+}
+
+// newIndexClient returns a package index client honoring --proxy.
+func newIndexClient(cmd *cobra.Command, funcs ...pypi.FnOption) (*pypi.Client, error) {
+	if proxy := proxyURL(cmd); proxy != "" {
+		funcs = append(funcs, pypi.WithProxy(proxy))
+	}
+	return pypi.NewClient(funcs...)
+}
+
+// newVerifier returns a verifier honoring --proxy to fetch the trusted
+// root.
+func newVerifier(cmd *cobra.Command, funcs []verify.FnOption) (*verify.Verifier, error) {
+	if proxy := proxyURL(cmd); proxy != "" {
+		tc := &transport.Config{Proxy: proxy}
+		client, err := tc.Client()
+		if err != nil {
+			return nil, err
+		}
+		funcs = append(funcs, verify.WithHTTPClient(client))
+	}
+	return verify.New(funcs...)
+}
@@ -0,0 +1,279 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/spf13/cobra"
+)
+
+type convertOptions struct {
+	To            string
+	Out           string
+	BundleVersion string
+	AllowLossy    bool
+}
+
+// AddFlags adds the convert flags to the command.
+func (o *convertOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.To, "to", "", "format to convert to: attestation or bundle (default: the other format)")
+	cmd.Flags().StringVarP(&o.Out, "out", "o", "", "output file, or directory when converting several files")
+	cmd.Flags().StringVar(&o.BundleVersion, "bundle-version", "v0.3", "media type version of the produced bundles")
+	cmd.Flags().BoolVar(&o.AllowLossy, "allow-lossy", false, "drop data the target format cannot represent instead of failing")
+}
+
+// Validate checks the flag values.
+func (o *convertOptions) Validate() error {
+	switch convert.Kind(o.To) {
+	case "", convert.KindAttestation, convert.KindBundle:
+	default:
+		return fmt.Errorf("invalid --to format %q, must be attestation or bundle", o.To)
+	}
+	return nil
+}
+
+// convertOptions returns the options of the conversion functions.
+func (o *convertOptions) convertOptions() []convert.ConvertOption {
+	return []convert.ConvertOption{
+		convert.WithBundleVersion(o.BundleVersion),
+		convert.WithAllowLossy(o.AllowLossy),
+	}
+}
+
+func addConvert(parent *cobra.Command) {
+	opts := &convertOptions{}
+	cmd := &cobra.Command{
+		Use:   "convert [FILE|GLOB...]",
+		Short: "Convert between PEP 740 attestations and Sigstore bundles",
+		Long: `Convert reads PEP 740 attestations, Sigstore bundles or PEP 740
+provenance objects, detecting the format of each file, and writes them in
+the other format. Provenance objects are converted to newline delimited
+bundles or attestations.
+
+With no arguments, or "-", the document is read from standard input. A
+single file is written to standard output unless --out is set to a file. When
+several files or a glob are passed, each converted file is written next
+to its source, or under the --out directory, with the .publish.attestation
+and .sigstore.json suffixes swapped.`,
+		Example: `  pypi-attestations convert sampleproject-1.0.tar.gz.publish.attestation
+  pypi-attestations convert --to bundle --out dist/bundles 'dist/*.publish.attestation'
+  curl -s $URL | pypi-attestations convert - > provenance.sigstore.jsonl`,
+		PreRunE: func(*cobra.Command, []string) error {
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConvert(cmd, opts, args)
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+func runConvert(cmd *cobra.Command, opts *convertOptions, args []string) error {
+	if len(args) == 0 || (len(args) == 1 && args[0] == stdio) {
+		data, err := readPath(cmd, stdio)
+		if err != nil {
+			return err
+		}
+		out, _, err := convertDocument(data, convert.Kind(opts.To), opts.convertOptions())
+		if err != nil {
+			return err
+		}
+		return writeOutput(cmd.OutOrStdout(), opts.Out, out)
+	}
+
+	paths, batch, err := expandArgs(args)
+	if err != nil {
+		return err
+	}
+
+	if batch && opts.Out == stdio {
+		return fmt.Errorf("several files cannot be converted to standard output, --out must be a directory")
+	}
+
+	if !batch {
+		data, err := os.ReadFile(paths[0])
+		if err != nil {
+			return err
+		}
+		out, _, err := convertDocument(data, convert.Kind(opts.To), opts.convertOptions())
+		if err != nil {
+			return fmt.Errorf("%s: %w", paths[0], err)
+		}
+		return writeOutput(cmd.OutOrStdout(), opts.Out, out)
+	}
+
+	var errs []error
+	for _, path := range paths {
+		target, err := convertFile(path, opts)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s -> %s\n", path, target)
+	}
+	return errors.Join(errs...)
+}
+
+// expandArgs expands the glob arguments. batch is true when more than a
+// file, or a glob, was passed.
+func expandArgs(args []string) (paths []string, batch bool, err error) {
+	for _, arg := range args {
+		if arg == stdio && len(args) > 1 {
+			return nil, false, fmt.Errorf("standard input cannot be combined with other files")
+		}
+		if !strings.ContainsAny(arg, "*?[") {
+			paths = append(paths, arg)
+			continue
+		}
+		batch = true
+		matches, err := filepath.Glob(arg)
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid glob %q: %w", arg, err)
+		}
+		if len(matches) == 0 {
+			return nil, false, fmt.Errorf("no files match %q", arg)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, batch || len(paths) > 1, nil
+}
+
+// convertFile converts the file at path, writing the result next to it or
+// under the output directory. It returns the path of the written file.
+func convertFile(path string, opts *convertOptions) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	from, err := convert.Detect(data)
+	if err != nil {
+		return "", err
+	}
+	out, to, err := convertDocument(data, convert.Kind(opts.To), opts.convertOptions())
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Dir(path)
+	if opts.Out != "" {
+		dir = opts.Out
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return "", fmt.Errorf("creating output directory: %w", err)
+		}
+	}
+	target := filepath.Join(dir, outputName(filepath.Base(path), from, to))
+	if err := os.WriteFile(target, out, 0o644); err != nil {
+		return "", fmt.Errorf("writing file: %w", err)
+	}
+	return target, nil
+}
+
+// outputName returns the name of the file converted from name.
+func outputName(name string, from, to convert.Kind) string {
+	if from != convert.KindProvenance {
+		return convert.DefaultRename(name, to)
+	}
+	name = strings.TrimSuffix(name, filepath.Ext(name))
+	if to == convert.KindBundle {
+		return name + ".sigstore.jsonl"
+	}
+	return name + ".attestations.jsonl"
+}
+
+// convertDocument detects the format of data and converts it to the target
+// format, the other format when to is empty.
+func convertDocument(data []byte, to convert.Kind, funcs []convert.ConvertOption) ([]byte, convert.Kind, error) {
+	from, err := convert.Detect(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if to == "" {
+		to = convert.KindBundle
+		if from == convert.KindBundle {
+			to = convert.KindAttestation
+		}
+	}
+	if from == to {
+		return nil, "", fmt.Errorf("document is already a %s", to)
+	}
+
+	var out []byte
+	switch from {
+	case convert.KindAttestation:
+		out, err = attestationToBundle(data, funcs)
+	case convert.KindBundle:
+		out, err = bundleToAttestation(data, funcs)
+	case convert.KindProvenance:
+		out, err = convertProvenance(data, to, funcs)
+	default:
+		err = fmt.Errorf("cannot convert %s documents", from)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return out, to, nil
+}
+
+func attestationToBundle(data []byte, funcs []convert.ConvertOption) ([]byte, error) {
+	attestation, err := convert.UnmarshalAttestation(data, funcs...)
+	if err != nil {
+		return nil, err
+	}
+	b, err := convert.ToBundle(attestation, funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return convert.MarshalBundle(b, funcs...)
+}
+
+func bundleToAttestation(data []byte, funcs []convert.ConvertOption) ([]byte, error) {
+	b, err := convert.UnmarshalBundle(data, funcs...)
+	if err != nil {
+		return nil, err
+	}
+	attestation, err := convert.FromBundle(b, funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return convert.MarshalAttestation(attestation, funcs...)
+}
+
+// convertProvenance flattens a provenance object into newline delimited
+// bundles or attestations.
+func convertProvenance(data []byte, to convert.Kind, funcs []convert.ConvertOption) ([]byte, error) {
+	provenance, err := convert.UnmarshalProvenance(data, funcs...)
+	if err != nil {
+		return nil, err
+	}
+
+	if to == convert.KindBundle {
+		bundles, _, err := convert.ProvenanceToBundles(provenance)
+		if err != nil {
+			return nil, err
+		}
+		return convert.MarshalBundles(bundles)
+	}
+
+	var out bytes.Buffer
+	for i, ab := range provenance.GetAttestationBundles() {
+		for j, attestation := range ab.GetAttestations() {
+			line, err := convert.MarshalAttestation(attestation, funcs...)
+			if err != nil {
+				return nil, fmt.Errorf("attestation %d of bundle %d: %w", j, i, err)
+			}
+			if err := json.Compact(&out, line); err != nil {
+				return nil, err
+			}
+			out.WriteByte('\n')
+		}
+	}
+	return out.Bytes(), nil
+}
@@ -0,0 +1,338 @@
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/certinfo"
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/statement"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/spf13/cobra"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+type diffOptions struct {
+	Format string
+}
+
+// AddFlags adds the diff flags to the command.
+func (o *diffOptions) AddFlags(cmd *cobra.Command) {
+	addFormatFlag(cmd, &o.Format, formatText, "output format", formatText, formatJSON)
+}
+
+// Validate checks the flag values.
+func (o *diffOptions) Validate() error {
+	return validateFormat(o.Format, formatText, formatJSON)
+}
+
+func addDiff(parent *cobra.Command) {
+	opts := &diffOptions{}
+	cmd := &cobra.Command{
+		Use:   "diff A B",
+		Short: "Compare two attestations or bundles",
+		Long: `Diff reports the semantic differences between two PEP 740
+attestations, two Sigstore bundles or an attestation and a bundle: the
+signing identity and certificate, the statement predicate type, subjects
+and predicate, the signature and the transparency log entries. Documents
+are compared after converting bundles to attestations, so an attestation
+and its bundle conversion have no differences.
+
+Either document is read from standard input when it is "-". Like diff(1),
+the command exits with 0 when the documents match, 1 when they differ and
+2 on usage or input errors.`,
+		Example: `  pypi-attestations diff sampleproject-1.0.tar.gz.publish.attestation sampleproject-1.0.tar.gz.sigstore.json
+  pypi-attestations convert a.sigstore.json | pypi-attestations diff --format json - a.publish.attestation`,
+		Args: cobra.ExactArgs(2),
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			if args[0] == stdio && args[1] == stdio {
+				return fmt.Errorf("only one of the documents can be read from standard input")
+			}
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDiff(cmd, opts, args[0], args[1])
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+// difference is a field whose value differs between the documents.
+type difference struct {
+	Field string `json:"field"`
+	A     string `json:"a"`
+	B     string `json:"b"`
+}
+
+func runDiff(cmd *cobra.Command, opts *diffOptions, pathA, pathB string) error {
+	a, err := diffInput(cmd, pathA)
+	if err != nil {
+		return fmt.Errorf("%s: %w", pathA, err)
+	}
+	b, err := diffInput(cmd, pathB)
+	if err != nil {
+		return fmt.Errorf("%s: %w", pathB, err)
+	}
+
+	diffs, err := diffAttestations(a, b)
+	if err != nil {
+		return err
+	}
+
+	if opts.Format == formatJSON {
+		if diffs == nil {
+			diffs = []difference{}
+		}
+		if err := writeJSON(cmd.OutOrStdout(), diffs); err != nil {
+			return err
+		}
+	} else {
+		printDifferences(cmd.OutOrStdout(), diffs)
+	}
+
+	if len(diffs) > 0 {
+		return &exitCodeError{code: exitFailed, err: fmt.Errorf("documents have %d differences", len(diffs))}
+	}
+	return nil
+}
+
+// diffInput reads an attestation, a bundle or a provenance object holding
+// a single attestation and returns it as an attestation.
+func diffInput(cmd *cobra.Command, path string) (*pb.Attestation, error) {
+	data, err := readPath(cmd, path)
+	if err != nil {
+		return nil, err
+	}
+	parsed, err := convert.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsed.Kind {
+	case convert.KindAttestation:
+		return parsed.Attestation, nil
+	case convert.KindBundle:
+		return convert.FromBundle(parsed.Bundle, convert.WithStrict(false))
+	case convert.KindProvenance:
+		var attestations []*pb.Attestation
+		for _, ab := range parsed.Provenance.GetAttestationBundles() {
+			attestations = append(attestations, ab.GetAttestations()...)
+		}
+		if len(attestations) != 1 {
+			return nil, fmt.Errorf("provenance object has %d attestations, extract one with convert first", len(attestations))
+		}
+		return attestations[0], nil
+	}
+	return nil, fmt.Errorf("cannot compare %s documents", parsed.Kind)
+}
+
+// differ collects the differences of the compared fields.
+type differ []difference
+
+func (d *differ) compare(field, a, b string) {
+	if a != b {
+		*d = append(*d, difference{Field: field, A: a, B: b})
+	}
+}
+
+func (d *differ) compareBytes(field string, a, b []byte) {
+	if !bytes.Equal(a, b) {
+		*d = append(*d, difference{Field: field, A: bytesSummary(a), B: bytesSummary(b)})
+	}
+}
+
+// bytesSummary describes binary data by its size and digest.
+func bytesSummary(data []byte) string {
+	if len(data) == 0 {
+		return "(none)"
+	}
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("%d bytes sha256:%s", len(data), hex.EncodeToString(sum[:]))
+}
+
+// diffAttestations returns the differences between two attestations.
+func diffAttestations(a, b *pb.Attestation) ([]difference, error) {
+	var d differ
+	d.compare("version", strconv.Itoa(int(a.GetVersion())), strconv.Itoa(int(b.GetVersion())))
+
+	if err := d.certificates(a.GetVerificationMaterial(), b.GetVerificationMaterial()); err != nil {
+		return nil, err
+	}
+	if err := d.statements(a.GetEnvelope().GetStatement(), b.GetEnvelope().GetStatement()); err != nil {
+		return nil, err
+	}
+
+	d.compare("signature", base64.StdEncoding.EncodeToString(a.GetEnvelope().GetSignature()), base64.StdEncoding.EncodeToString(b.GetEnvelope().GetSignature()))
+	d.compare("additionalSignatures", strconv.Itoa(len(a.GetEnvelope().GetAdditionalSignatures())), strconv.Itoa(len(b.GetEnvelope().GetAdditionalSignatures())))
+
+	if err := d.logEntries(a.GetVerificationMaterial().GetTransparencyEntries(), b.GetVerificationMaterial().GetTransparencyEntries()); err != nil {
+		return nil, err
+	}
+	d.compare("rfc3161Timestamps", strconv.Itoa(len(a.GetVerificationMaterial().GetRfc3161Timestamps())), strconv.Itoa(len(b.GetVerificationMaterial().GetRfc3161Timestamps())))
+	return d, nil
+}
+
+// certificates compares the signing identities and certificates.
+func (d *differ) certificates(a, b *pb.VerificationMaterial) error {
+	d.compare("intermediateCertificates", strconv.Itoa(len(a.GetIntermediateCertificates())), strconv.Itoa(len(b.GetIntermediateCertificates())))
+	if bytes.Equal(a.GetCertificate(), b.GetCertificate()) {
+		return nil
+	}
+
+	infoA, err := certinfo.Parse(a.GetCertificate())
+	if err != nil {
+		return fmt.Errorf("first certificate: %w", err)
+	}
+	infoB, err := certinfo.Parse(b.GetCertificate())
+	if err != nil {
+		return fmt.Errorf("second certificate: %w", err)
+	}
+
+	before := len(*d)
+	d.compare("identity", infoA.SubjectAlternativeName, infoB.SubjectAlternativeName)
+	d.compare("issuer", infoA.Issuer(), infoB.Issuer())
+	d.compare("repository", infoA.Fulcio.SourceRepositoryURI, infoB.Fulcio.SourceRepositoryURI)
+	d.compare("ref", infoA.SourceRef(), infoB.SourceRef())
+	d.compare("commit", infoA.SHA(), infoB.SHA())
+	d.compare("buildConfig", infoA.Fulcio.BuildConfigURI, infoB.Fulcio.BuildConfigURI)
+	d.compare("run", infoA.Fulcio.RunInvocationURI, infoB.Fulcio.RunInvocationURI)
+	d.compare("certificate.serialNumber", infoA.SerialNumber, infoB.SerialNumber)
+	if len(*d) == before {
+		d.compareBytes("certificate", a.GetCertificate(), b.GetCertificate())
+	}
+	return nil
+}
+
+// statements compares the in-toto statements field by field, reporting an
+// encoding difference when they match but their bytes do not.
+func (d *differ) statements(a, b []byte) error {
+	if bytes.Equal(a, b) {
+		return nil
+	}
+	sa, err := statement.ParseStatement(a)
+	if err != nil {
+		return fmt.Errorf("first statement: %w", err)
+	}
+	sb, err := statement.ParseStatement(b)
+	if err != nil {
+		return fmt.Errorf("second statement: %w", err)
+	}
+
+	before := len(*d)
+	d.compare("predicateType", sa.GetPredicateType(), sb.GetPredicateType())
+	d.compare("subjects", strconv.Itoa(len(sa.GetSubject())), strconv.Itoa(len(sb.GetSubject())))
+	for i := range min(len(sa.GetSubject()), len(sb.GetSubject())) {
+		subA, subB := sa.GetSubject()[i], sb.GetSubject()[i]
+		field := fmt.Sprintf("subject[%d]", i)
+		d.compare(field+".name", subA.GetName(), subB.GetName())
+		for _, alg := range unionKeys(subA.GetDigest(), subB.GetDigest()) {
+			d.compare(field+".digest."+alg, subA.GetDigest()[alg], subB.GetDigest()[alg])
+		}
+	}
+
+	fieldsA, fieldsB := sa.GetPredicate().GetFields(), sb.GetPredicate().GetFields()
+	for _, key := range unionKeys(fieldsA, fieldsB) {
+		if !proto.Equal(fieldsA[key], fieldsB[key]) {
+			d.compare("predicate."+key, valueSummary(fieldsA[key]), valueSummary(fieldsB[key]))
+		}
+	}
+
+	if len(*d) == before {
+		d.compareBytes("statement", a, b)
+	}
+	return nil
+}
+
+// unionKeys returns the sorted keys of both maps.
+func unionKeys[V any](a, b map[string]V) []string {
+	seen := map[string]bool{}
+	for k := range a {
+		seen[k] = true
+	}
+	for k := range b {
+		seen[k] = true
+	}
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// valueSummary describes a predicate value, printing short ones in full.
+func valueSummary(v proto.Message) string {
+	if v == nil || !v.ProtoReflect().IsValid() {
+		return "(none)"
+	}
+	data, err := protojson.Marshal(v)
+	if err != nil {
+		return "(invalid)"
+	}
+	if len(data) > 80 {
+		return bytesSummary(data)
+	}
+	return string(data)
+}
+
+// logEntries compares the transparency log entries in order.
+func (d *differ) logEntries(a, b []*structpb.Struct) error {
+	d.compare("logEntries", strconv.Itoa(len(a)), strconv.Itoa(len(b)))
+	for i := range min(len(a), len(b)) {
+		ea, err := convert.TransparencyEntryFromStruct(a[i])
+		if err != nil {
+			return fmt.Errorf("first transparency entry %d: %w", i, err)
+		}
+		eb, err := convert.TransparencyEntryFromStruct(b[i])
+		if err != nil {
+			return fmt.Errorf("second transparency entry %d: %w", i, err)
+		}
+		d.logEntry(fmt.Sprintf("logEntry[%d]", i), ea, eb)
+	}
+	return nil
+}
+
+func (d *differ) logEntry(field string, a, b *protorekor.TransparencyLogEntry) {
+	d.compare(field+".logIndex", strconv.FormatInt(a.GetLogIndex(), 10), strconv.FormatInt(b.GetLogIndex(), 10))
+	d.compare(field+".logId", hex.EncodeToString(a.GetLogId().GetKeyId()), hex.EncodeToString(b.GetLogId().GetKeyId()))
+	d.compare(field+".kind", a.GetKindVersion().GetKind()+"/"+a.GetKindVersion().GetVersion(), b.GetKindVersion().GetKind()+"/"+b.GetKindVersion().GetVersion())
+	d.compare(field+".integratedTime", integratedTime(a), integratedTime(b))
+	d.compareBytes(field+".canonicalizedBody", a.GetCanonicalizedBody(), b.GetCanonicalizedBody())
+	d.compareBytes(field+".inclusionPromise", a.GetInclusionPromise().GetSignedEntryTimestamp(), b.GetInclusionPromise().GetSignedEntryTimestamp())
+
+	proofA, proofB := a.GetInclusionProof(), b.GetInclusionProof()
+	d.compare(field+".inclusionProof.treeSize", strconv.FormatInt(proofA.GetTreeSize(), 10), strconv.FormatInt(proofB.GetTreeSize(), 10))
+	d.compare(field+".inclusionProof.rootHash", hex.EncodeToString(proofA.GetRootHash()), hex.EncodeToString(proofB.GetRootHash()))
+	d.compare(field+".inclusionProof.checkpoint", proofA.GetCheckpoint().GetEnvelope(), proofB.GetCheckpoint().GetEnvelope())
+}
+
+// integratedTime formats the integration time of an entry.
+func integratedTime(e *protorekor.TransparencyLogEntry) string {
+	if e.GetIntegratedTime() == 0 {
+		return "(none)"
+	}
+	return time.Unix(e.GetIntegratedTime(), 0).UTC().Format(time.RFC3339)
+}
+
+// printDifferences writes the differences as a field, then the value of
+// each document.
+func printDifferences(w io.Writer, diffs []difference) {
+	if len(diffs) == 0 {
+		fmt.Fprintln(w, "no differences")
+		return
+	}
+	for _, diff := range diffs {
+		fmt.Fprintf(w, "%s\n  - %s\n  + %s\n", diff.Field, diff.A, diff.B)
+	}
+}
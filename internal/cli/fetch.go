@@ -0,0 +1,249 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	"github.com/carabiner-dev/pypi-attestations/pkg/statement"
+	"github.com/spf13/cobra"
+)
+
+type fetchOptions struct {
+	Filename  string
+	Out       string
+	IndexURL  string
+	NoBundles bool
+	Format    string
+}
+
+// AddFlags adds the fetch flags to the command.
+func (o *fetchOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.Filename, "filename", "", "only fetch the provenance of this distribution file")
+	cmd.Flags().StringVarP(&o.Out, "out", "o", ".", `directory where the files are written, or "-" to write the provenance objects to standard output`)
+	cmd.Flags().StringVar(&o.IndexURL, "index-url", pypi.DefaultURL, "base URL of the package index")
+	cmd.Flags().BoolVar(&o.NoBundles, "no-bundles", false, "do not write the attestations as Sigstore bundles")
+	addFormatFlag(cmd, &o.Format, formatText, "format of the list of written files", formatText, formatJSON)
+}
+
+// Validate checks the flag values.
+func (o *fetchOptions) Validate() error {
+	if o.Out == "" {
+		return fmt.Errorf("output directory cannot be empty")
+	}
+	if o.Out == stdio && o.Format != formatText {
+		return fmt.Errorf("--format cannot be set when writing the provenance to standard output")
+	}
+	return validateFormat(o.Format, formatText, formatJSON)
+}
+
+func addFetch(parent *cobra.Command) {
+	opts := &fetchOptions{}
+	cmd := &cobra.Command{
+		Use:   "fetch PROJECT==VERSION...",
+		Short: "Download the provenance of a release from PyPI",
+		Long: `Fetch downloads the PEP 740 provenance of the distribution files of
+releases through the Integrity API of the index, checking the attestation
+subjects match the digests the index publishes.
+
+For each file the provenance object is written as FILE.provenance.json
+and every attestation it contains as FILE.publish.attestation (or another
+label for other predicate types), along with its Sigstore bundle
+conversion FILE.publish.sigstore.json.
+
+With --out -, the provenance objects are written to standard output
+instead, one compact JSON document per line.`,
+		Example: `  pypi-attestations fetch sampleproject==4.0.0 --out provenance/
+  pypi-attestations fetch sampleproject==4.0.0 --filename sampleproject-4.0.0.tar.gz
+  pypi-attestations fetch sampleproject==4.0.0 --filename sampleproject-4.0.0.tar.gz --out - | pypi-attestations convert --to bundle -`,
+		Args: cobra.MinimumNArgs(1),
+		PreRunE: func(_ *cobra.Command, args []string) error {
+			if opts.Filename != "" && len(args) > 1 {
+				return fmt.Errorf("--filename can only be used with a single release")
+			}
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFetch(cmd, opts, args)
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+// parseRequirement parses a PROJECT==VERSION release specifier.
+func parseRequirement(spec string) (pypi.Package, error) {
+	name, version, ok := strings.Cut(spec, "==")
+	name, version = strings.TrimSpace(name), strings.TrimSpace(version)
+	if !ok || name == "" || version == "" {
+		return pypi.Package{}, fmt.Errorf("invalid release %q, expected PROJECT==VERSION", spec)
+	}
+	return pypi.Package{Name: name, Version: version}, nil
+}
+
+func runFetch(cmd *cobra.Command, opts *fetchOptions, args []string) error {
+	packages := make([]pypi.Package, 0, len(args))
+	for _, arg := range args {
+		pkg, err := parseRequirement(arg)
+		if err != nil {
+			return err
+		}
+		packages = append(packages, pkg)
+	}
+
+	client, err := newIndexClient(cmd, pypi.WithURL(opts.IndexURL))
+	if err != nil {
+		return err
+	}
+	results, err := client.FetchAll(cmd.Context(), packages)
+	if err != nil {
+		return err
+	}
+	if opts.Out != stdio {
+		if err := os.MkdirAll(opts.Out, 0o755); err != nil {
+			return fmt.Errorf("creating output directory: %w", err)
+		}
+	}
+
+	var errs []error
+	output := fetchOutput{schemaHeader: newSchemaHeader("FetchResult"), Files: []fetchedFile{}}
+	found := false
+	for _, res := range results {
+		if res.Error != nil {
+			errs = append(errs, res.Error)
+			output.Files = append(output.Files, fetchedFile{Project: res.Package.Name, Version: res.Package.Version, Error: res.Error.Error()})
+			continue
+		}
+		for _, f := range res.Files {
+			if opts.Filename != "" && f.Filename != opts.Filename {
+				continue
+			}
+			found = true
+			fetched := fetchedFile{Project: res.Package.Name, Version: res.Package.Version, Filename: f.Filename, Attested: f.Error == nil}
+			switch {
+			case errors.Is(f.Error, pypi.ErrNotFound) && opts.Filename == "":
+				if opts.Format == formatText {
+					fmt.Fprintf(cmd.ErrOrStderr(), "%s has no attestations\n", f.Filename)
+				}
+			case f.Error != nil:
+				errs = append(errs, fmt.Errorf("%s: %w", f.Filename, f.Error))
+				fetched.Error = f.Error.Error()
+			case opts.Out == stdio:
+				if err := writeCompact(cmd.OutOrStdout(), f.Provenance); err != nil {
+					return err
+				}
+			default:
+				written, err := writeProvenance(opts.Out, f.Filename, f.Provenance, !opts.NoBundles)
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %w", f.Filename, err))
+					fetched.Error = err.Error()
+				}
+				fetched.Written = written
+				if opts.Format == formatText {
+					for _, path := range written {
+						fmt.Fprintln(cmd.OutOrStdout(), path)
+					}
+				}
+			}
+			output.Files = append(output.Files, fetched)
+		}
+	}
+	if opts.Filename != "" && !found && len(errs) == 0 {
+		return fmt.Errorf("release %s %s has no file %s", packages[0].Name, packages[0].Version, opts.Filename)
+	}
+	if opts.Format == formatJSON {
+		if err := writeJSON(cmd.OutOrStdout(), output); err != nil {
+			return err
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fetchOutput is the JSON output of the fetch command.
+type fetchOutput struct {
+	schemaHeader
+
+	Files []fetchedFile `json:"files"`
+}
+
+// fetchedFile describes the provenance fetched for a distribution file.
+type fetchedFile struct {
+	Project  string   `json:"project"`
+	Version  string   `json:"version"`
+	Filename string   `json:"filename,omitempty"`
+	Attested bool     `json:"attested"`
+	Written  []string `json:"written,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// writeProvenance writes the provenance object of a file and each of its
+// attestations under dir, returning the paths written.
+func writeProvenance(dir, filename string, data []byte, bundles bool) ([]string, error) {
+	provenance, err := convert.UnmarshalProvenance(data)
+	if err != nil {
+		return nil, err
+	}
+
+	written := []string{filepath.Join(dir, filename+".provenance.json")}
+	if err := os.WriteFile(written[0], data, 0o644); err != nil {
+		return nil, err
+	}
+
+	labels := map[string]bool{}
+	for _, ab := range provenance.GetAttestationBundles() {
+		for _, attestation := range ab.GetAttestations() {
+			label := attestationLabel(attestation.GetEnvelope().GetStatement(), len(labels), labels)
+			labels[label] = true
+
+			out, err := convert.MarshalAttestation(attestation)
+			if err != nil {
+				return written, err
+			}
+			path := filepath.Join(dir, fmt.Sprintf("%s.%s.attestation", filename, label))
+			if err := os.WriteFile(path, out, 0o644); err != nil {
+				return written, err
+			}
+			written = append(written, path)
+
+			if !bundles {
+				continue
+			}
+			b, err := convert.ToBundle(attestation)
+			if err != nil {
+				return written, err
+			}
+			if out, err = convert.MarshalBundle(b); err != nil {
+				return written, err
+			}
+			path = strings.TrimSuffix(path, ".attestation") + convert.BundleSuffix
+			if err := os.WriteFile(path, out, 0o644); err != nil {
+				return written, err
+			}
+			written = append(written, path)
+		}
+	}
+	return written, nil
+}
+
+// attestationLabel names an attestation file after the predicate type of
+// its statement, falling back to its index. Labels are unique among used.
+func attestationLabel(data []byte, index int, used map[string]bool) string {
+	label := strconv.Itoa(index)
+	if s, err := statement.ParseStatement(data); err == nil {
+		switch {
+		case statement.IsPublish(s):
+			label = "publish"
+		case statement.IsSLSAProvenance(s):
+			label = "slsa"
+		}
+	}
+	if used[label] {
+		label += "-" + strconv.Itoa(index)
+	}
+	return label
+}
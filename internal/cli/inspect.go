@@ -0,0 +1,254 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/certinfo"
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/mediatype"
+	"github.com/carabiner-dev/pypi-attestations/pkg/statement"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/spf13/cobra"
+)
+
+type inspectOptions struct {
+	Format string
+}
+
+// AddFlags adds the inspect flags to the command.
+func (o *inspectOptions) AddFlags(cmd *cobra.Command) {
+	addFormatFlag(cmd, &o.Format, formatTable, "output format", formatTable, formatJSON, formatYAML)
+}
+
+// Validate checks the flag values.
+func (o *inspectOptions) Validate() error {
+	return validateFormat(o.Format, formatTable, formatJSON, formatYAML)
+}
+
+func addInspect(parent *cobra.Command) {
+	opts := &inspectOptions{}
+	cmd := &cobra.Command{
+		Use:   "inspect [FILE]",
+		Short: "Print the contents of an attestation, bundle or provenance",
+		Long: `Inspect decodes a PEP 740 attestation, Sigstore bundle or PEP 740
+provenance object and prints the signing certificate identity and its
+Fulcio extensions, the statement predicate type and subjects and the
+transparency log entries. Nothing is verified, see the verify command.
+
+With no arguments, or "-", the document is read from standard input.`,
+		Example: `  pypi-attestations inspect sampleproject-1.0.tar.gz.publish.attestation
+  pypi-attestations inspect --format yaml sampleproject-1.0.tar.gz.sigstore.json`,
+		Args: cobra.MaximumNArgs(1),
+		PreRunE: func(*cobra.Command, []string) error {
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInspect(cmd, opts, args)
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+// inspection describes an attestation.
+type inspection struct {
+	Kind          convert.Kind      `json:"kind"`
+	MediaType     string            `json:"mediaType"`
+	Publisher     map[string]any    `json:"publisher,omitempty"`
+	Certificate   *certinfo.Info    `json:"certificate"`
+	PredicateType string            `json:"predicateType"`
+	Subjects      []inspectSubject  `json:"subjects"`
+	LogEntries    []inspectLogEntry `json:"logEntries"`
+	Timestamps    int               `json:"rfc3161Timestamps,omitempty"`
+}
+
+// inspectOutput is the JSON and YAML output of the inspect command, listing
+// an inspection per attestation of the document.
+type inspectOutput struct {
+	schemaHeader
+
+	Documents []*inspection `json:"documents"`
+}
+
+type inspectSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+type inspectLogEntry struct {
+	LogIndex       int64      `json:"logIndex"`
+	IntegratedTime *time.Time `json:"integratedTime,omitempty"`
+	Kind           string     `json:"kind"`
+}
+
+func runInspect(cmd *cobra.Command, opts *inspectOptions, args []string) error {
+	data, err := readInput(cmd, args)
+	if err != nil {
+		return err
+	}
+	inspections, err := inspectDocument(data)
+	if err != nil {
+		return err
+	}
+
+	v := inspectOutput{schemaHeader: newSchemaHeader("Inspection"), Documents: inspections}
+
+	switch opts.Format {
+	case formatJSON:
+		return writeJSON(cmd.OutOrStdout(), v)
+	case formatYAML:
+		return writeYAML(cmd.OutOrStdout(), v)
+	}
+	for i, ins := range inspections {
+		if i > 0 {
+			fmt.Fprintln(cmd.OutOrStdout())
+		}
+		if err := printInspection(cmd.OutOrStdout(), ins); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readInput reads the file named by the only argument, or standard input
+// when there is none or it is "-".
+func readInput(cmd *cobra.Command, args []string) ([]byte, error) {
+	if len(args) == 0 {
+		return readPath(cmd, stdio)
+	}
+	return readPath(cmd, args[0])
+}
+
+// inspectDocument decodes an attestation, bundle or provenance object.
+func inspectDocument(data []byte) ([]*inspection, error) {
+	parsed, err := convert.Parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	switch parsed.Kind {
+	case convert.KindAttestation:
+		ins, err := inspectAttestation(parsed.Attestation)
+		if err != nil {
+			return nil, err
+		}
+		ins.Kind, ins.MediaType = convert.KindAttestation, mediatype.Attestation
+		return []*inspection{ins}, nil
+	case convert.KindBundle:
+		attestation, err := convert.FromBundle(parsed.Bundle, convert.WithStrict(false))
+		if err != nil {
+			return nil, err
+		}
+		ins, err := inspectAttestation(attestation)
+		if err != nil {
+			return nil, err
+		}
+		ins.Kind, ins.MediaType = convert.KindBundle, parsed.Bundle.GetMediaType()
+		ins.Timestamps = len(parsed.Bundle.GetVerificationMaterial().GetTimestampVerificationData().GetRfc3161Timestamps())
+		return []*inspection{ins}, nil
+	case convert.KindProvenance:
+		var inspections []*inspection
+		for i, ab := range parsed.Provenance.GetAttestationBundles() {
+			for j, attestation := range ab.GetAttestations() {
+				ins, err := inspectAttestation(attestation)
+				if err != nil {
+					return nil, fmt.Errorf("attestation %d of bundle %d: %w", j, i, err)
+				}
+				ins.Kind, ins.MediaType = convert.KindProvenance, mediatype.Provenance
+				ins.Publisher = ab.GetPublisher().AsMap()
+				inspections = append(inspections, ins)
+			}
+		}
+		return inspections, nil
+	}
+	return nil, fmt.Errorf("cannot inspect %s documents", parsed.Kind)
+}
+
+// inspectAttestation decodes the certificate, statement and log entries of
+// the attestation.
+func inspectAttestation(attestation *pb.Attestation) (*inspection, error) {
+	info, err := certinfo.Parse(attestation.GetVerificationMaterial().GetCertificate())
+	if err != nil {
+		return nil, err
+	}
+	s, err := statement.ParseStatement(attestation.GetEnvelope().GetStatement())
+	if err != nil {
+		return nil, err
+	}
+
+	ins := &inspection{
+		Certificate:   info,
+		PredicateType: s.GetPredicateType(),
+		Timestamps:    len(attestation.GetVerificationMaterial().GetRfc3161Timestamps()),
+	}
+	for _, subject := range s.GetSubject() {
+		ins.Subjects = append(ins.Subjects, inspectSubject{Name: subject.GetName(), Digest: subject.GetDigest()})
+	}
+	for i, tle := range attestation.GetVerificationMaterial().GetTransparencyEntries() {
+		entry, err := convert.TransparencyEntryFromStruct(tle)
+		if err != nil {
+			return nil, fmt.Errorf("transparency entry %d: %w", i, err)
+		}
+		le := inspectLogEntry{
+			LogIndex: entry.GetLogIndex(),
+			Kind:     entry.GetKindVersion().GetKind() + "/" + entry.GetKindVersion().GetVersion(),
+		}
+		if entry.GetIntegratedTime() != 0 {
+			t := time.Unix(entry.GetIntegratedTime(), 0).UTC()
+			le.IntegratedTime = &t
+		}
+		ins.LogEntries = append(ins.LogEntries, le)
+	}
+	return ins, nil
+}
+
+// printInspection writes the inspection as an aligned table.
+func printInspection(w io.Writer, ins *inspection) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	row := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", name, value)
+		}
+	}
+
+	row("Kind", string(ins.Kind))
+	row("Media type", ins.MediaType)
+	if kind, ok := ins.Publisher["kind"].(string); ok {
+		row("Publisher", kind)
+	}
+	row("Identity", ins.Certificate.SubjectAlternativeName)
+	row("Issuer", ins.Certificate.Issuer())
+	row("Repository", ins.Certificate.Fulcio.SourceRepositoryURI)
+	row("Ref", ins.Certificate.SourceRef())
+	row("Commit", ins.Certificate.SHA())
+	row("Build config", ins.Certificate.Fulcio.BuildConfigURI)
+	row("Trigger", ins.Certificate.BuildTrigger())
+	row("Run", ins.Certificate.Fulcio.RunInvocationURI)
+	row("Valid", ins.Certificate.NotBefore.UTC().Format(time.RFC3339)+" to "+ins.Certificate.NotAfter.UTC().Format(time.RFC3339))
+	row("Predicate type", ins.PredicateType)
+	for _, subject := range ins.Subjects {
+		algs := make([]string, 0, len(subject.Digest))
+		for alg := range subject.Digest {
+			algs = append(algs, alg)
+		}
+		sort.Strings(algs)
+		for _, alg := range algs {
+			row("Subject", subject.Name+" "+alg+":"+subject.Digest[alg])
+		}
+	}
+	for _, entry := range ins.LogEntries {
+		logged := "not recorded"
+		if entry.IntegratedTime != nil {
+			logged = entry.IntegratedTime.Format(time.RFC3339)
+		}
+		row("Log entry", fmt.Sprintf("%d (%s) at %s", entry.LogIndex, entry.Kind, logged))
+	}
+	if ins.Timestamps > 0 {
+		row("RFC 3161 timestamps", fmt.Sprint(ins.Timestamps))
+	}
+	return tw.Flush()
+}
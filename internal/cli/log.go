@@ -0,0 +1,40 @@
+package cli
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// setupLogging installs the default logger used by the packages of the
+// module, writing to the standard error of cmd at the level and in the
+// format set with --log-level and --log-format.
+func setupLogging(cmd *cobra.Command) error {
+	levelName, err := cmd.Flags().GetString("log-level")
+	if err != nil {
+		return err
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(levelName)); err != nil {
+		return fmt.Errorf("invalid --log-level %q: must be debug, info, warn or error", levelName)
+	}
+
+	format, err := cmd.Flags().GetString("log-format")
+	if err != nil {
+		return err
+	}
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch strings.ToLower(format) {
+	case "text":
+		h = slog.NewTextHandler(cmd.ErrOrStderr(), opts)
+	case "json":
+		h = slog.NewJSONHandler(cmd.ErrOrStderr(), opts)
+	default:
+		return fmt.Errorf("invalid --log-format %q: must be text or json", format)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
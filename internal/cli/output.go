@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+// Output formats of the commands.
+const (
+	formatText  = "text"
+	formatTable = "table"
+	formatJSON  = "json"
+	formatYAML  = "yaml"
+)
+
+// schemaVersion is the version of the JSON and YAML documents written by
+// the commands. Fields may be added within a version, any other change
+// bumps it.
+const schemaVersion = "v1"
+
+// schemaHeader identifies the schema of a machine readable document.
+type schemaHeader struct {
+	SchemaVersion string `json:"schemaVersion"`
+	Kind          string `json:"kind"`
+}
+
+func newSchemaHeader(kind string) schemaHeader {
+	return schemaHeader{SchemaVersion: schemaVersion, Kind: kind}
+}
+
+// addFormatFlag adds the --format flag of the command, also accepted as
+// --output, completing the allowed formats in the shell.
+func addFormatFlag(cmd *cobra.Command, p *string, value, usage string, allowed ...string) {
+	cmd.Flags().StringVar(p, "format", value, usage+": "+strings.Join(allowed, ", "))
+	cmd.Flags().SetNormalizeFunc(func(_ *pflag.FlagSet, name string) pflag.NormalizedName {
+		if name == "output" {
+			name = "format"
+		}
+		return pflag.NormalizedName(name)
+	})
+	_ = cmd.RegisterFlagCompletionFunc("format", cobra.FixedCompletions(allowed, cobra.ShellCompDirectiveNoFileComp))
+}
+
+// validateFormat checks format is one of the allowed formats.
+func validateFormat(format string, allowed ...string) error {
+	if slices.Contains(allowed, format) {
+		return nil
+	}
+	return fmt.Errorf("invalid format %q, must be one of %s", format, strings.Join(allowed, ", "))
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+// writeYAML writes v as YAML. Values are encoded through JSON so the keys
+// match the JSON output.
+func writeYAML(w io.Writer, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var doc any
+	if err := dec.Decode(&doc); err != nil {
+		return err
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(jsonNumbers(doc)); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+// jsonNumbers replaces the json.Number values of a decoded document with
+// integers or floats so they are not quoted in YAML.
+func jsonNumbers(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			v[k] = jsonNumbers(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = jsonNumbers(e)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i
+		}
+		f, _ := v.Float64()
+		return f
+	}
+	return v
+}
@@ -0,0 +1,409 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	"github.com/spf13/cobra"
+)
+
+// Report formats.
+const (
+	formatSARIF    = "sarif"
+	formatMarkdown = "markdown"
+)
+
+// SARIF rules of the report findings.
+const (
+	ruleUnattested = "PYA001"
+	ruleUnverified = "PYA002"
+)
+
+type reportOptions struct {
+	Requirements string
+	IndexURL     string
+	PolicyFile   string
+	Policy       *verify.Policy
+	TrustedRoot  string
+	Offline      bool
+	Format       string
+	Out          string
+	Fail         bool
+}
+
+// AddFlags adds the report flags to the command.
+func (o *reportOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Requirements, "requirements", "r", "", `hashed requirements file whose dependencies are verified, or "-" for standard input`)
+	cmd.Flags().StringVar(&o.IndexURL, "index-url", pypi.DefaultURL, "base URL of the package index")
+	cmd.Flags().StringVar(&o.PolicyFile, "policy", "", "trusted publisher policy file")
+	cmd.Flags().StringVar(&o.TrustedRoot, "trusted-root", "", "Sigstore trusted root JSON (default: public good instance)")
+	cmd.Flags().BoolVar(&o.Offline, "offline", false, "verify the attestations without contacting Sigstore")
+	addFormatFlag(cmd, &o.Format, formatMarkdown, "report format", formatSARIF, formatMarkdown, formatJSON)
+	cmd.Flags().StringVarP(&o.Out, "out", "o", "", "file where the report is written (default: standard output)")
+	cmd.Flags().BoolVar(&o.Fail, "fail", false, "exit with 1 when any target does not verify")
+}
+
+// Validate checks the flag values.
+func (o *reportOptions) Validate() error {
+	return validateFormat(o.Format, formatSARIF, formatMarkdown, formatJSON)
+}
+
+// verifierOptions returns the options of the verifier.
+func (o *reportOptions) verifierOptions() []verify.FnOption {
+	ro := requirementsOptions{PolicyFile: o.PolicyFile, Policy: o.Policy, TrustedRoot: o.TrustedRoot, Offline: o.Offline}
+	return ro.verifierOptions()
+}
+
+func addReport(parent *cobra.Command) {
+	opts := &reportOptions{}
+	cmd := &cobra.Command{
+		Use:   "report [DIST|PROJECT==VERSION...]",
+		Short: "Report the verification status of files and packages",
+		Long: `Report verifies many targets and aggregates the results in a single
+report: local distribution files against their sibling
+DIST.publish.attestation, every file of PROJECT==VERSION releases and the
+dependencies of a hashed --requirements file against the provenance
+served by the index.
+
+The report is written as SARIF, to upload to code scanning, Markdown, to
+post as a pull request comment, or JSON. Unattested and unverified
+targets are reported as findings, the command only fails on them with
+--fail.`,
+		Example: `  pypi-attestations report --format sarif -r requirements.txt --out attestations.sarif
+  pypi-attestations report dist/* sampleproject==4.0.0 >> "$GITHUB_STEP_SUMMARY"`,
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			opts.Policy = configPolicy(cmd)
+			if len(args) == 0 && opts.Requirements == "" {
+				return fmt.Errorf("nothing to report on, pass files, releases or --requirements")
+			}
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runReport(cmd, opts, args)
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+// reportEntry is the verification outcome of a target file.
+type reportEntry struct {
+	// Target is the file path, release or requirement verified.
+	Target   string `json:"target"`
+	Filename string `json:"filename,omitempty"`
+	Verified bool   `json:"verified"`
+
+	// Attested is false when no attestation was found.
+	Attested bool   `json:"attested"`
+	Identity string `json:"identity,omitempty"`
+	Error    string `json:"error,omitempty"`
+
+	// Location is the file and line the target comes from.
+	Location string `json:"location,omitempty"`
+	Line     int    `json:"line,omitempty"`
+}
+
+func runReport(cmd *cobra.Command, opts *reportOptions, args []string) error {
+	v, err := newVerifier(cmd, opts.verifierOptions())
+	if err != nil {
+		return err
+	}
+	client, err := newIndexClient(cmd, pypi.WithURL(opts.IndexURL))
+	if err != nil {
+		return err
+	}
+
+	var files []string
+	var packages []pypi.Package
+	for _, arg := range args {
+		if strings.Contains(arg, "==") {
+			pkg, err := parseRequirement(arg)
+			if err != nil {
+				return err
+			}
+			packages = append(packages, pkg)
+			continue
+		}
+		files = append(files, arg)
+	}
+
+	var entries []reportEntry
+	if len(files) > 0 {
+		dists, err := distributionArgs(files)
+		if err != nil {
+			return err
+		}
+		for _, dist := range dists {
+			entries = append(entries, reportFile(cmd.Context(), v, dist))
+		}
+	}
+	if len(packages) > 0 {
+		releases, err := client.FetchAll(cmd.Context(), packages)
+		if err != nil {
+			return err
+		}
+		for _, release := range releases {
+			entries = append(entries, reportRelease(cmd.Context(), v, release)...)
+		}
+	}
+	if opts.Requirements != "" {
+		reqEntries, err := reportRequirements(cmd, v, client, opts.Requirements)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, reqEntries...)
+	}
+
+	var out bytes.Buffer
+	switch opts.Format {
+	case formatSARIF:
+		err = writeJSON(&out, newSARIF(entries))
+	case formatJSON:
+		err = writeJSON(&out, entries)
+	default:
+		writeMarkdown(&out, entries)
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeOutput(cmd.OutOrStdout(), opts.Out, out.Bytes()); err != nil {
+		return err
+	}
+
+	if failed := countFailed(entries); opts.Fail && failed > 0 {
+		return verificationFailed(fmt.Errorf("%d of %d targets are unattested or do not verify", failed, len(entries)))
+	}
+	return nil
+}
+
+func countFailed(entries []reportEntry) int {
+	failed := 0
+	for _, e := range entries {
+		if !e.Verified {
+			failed++
+		}
+	}
+	return failed
+}
+
+// reportFile verifies a local distribution against its publish
+// attestation.
+func reportFile(ctx context.Context, v *verify.Verifier, dist string) reportEntry {
+	entry := reportEntry{Target: dist, Filename: filepath.Base(dist), Location: dist}
+	attestation := dist + convert.AttestationSuffix
+	if _, err := os.Stat(attestation); err != nil {
+		entry.Error = "no attestation file " + filepath.Base(attestation)
+		return entry
+	}
+	entry.Attested = true
+
+	result, err := v.VerifyFile(ctx, attestation, dist)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	entry.Verified = true
+	entry.Identity = result.Identity
+	return entry
+}
+
+// reportRelease verifies the provenance of every file of a release.
+func reportRelease(ctx context.Context, v *verify.Verifier, release pypi.PackageProvenance) []reportEntry {
+	target := release.Package.Name + "==" + release.Package.Version
+	if release.Error != nil {
+		return []reportEntry{{Target: target, Error: release.Error.Error()}}
+	}
+
+	entries := make([]reportEntry, 0, len(release.Files))
+	for i := range release.Files {
+		rf := verifyRequirementFile(ctx, v, release.Files[i].SHA256, release.Files[i:i+1])
+		entries = append(entries, entryFromFile(target, rf))
+	}
+	return entries
+}
+
+// reportRequirements verifies the dependencies of a requirements file.
+func reportRequirements(cmd *cobra.Command, v *verify.Verifier, client *pypi.Client, path string) ([]reportEntry, error) {
+	reqs, releases, err := fetchRequirements(cmd, client, path)
+	if err != nil {
+		return nil, err
+	}
+
+	location := path
+	if path == stdio {
+		location = ""
+	}
+
+	var entries []reportEntry
+	for i, req := range reqs {
+		target := req.Name + "==" + req.Version
+		res := verifyRequirement(cmd.Context(), v, req, releases[i])
+		if res.Error != "" {
+			entries = append(entries, reportEntry{Target: target, Error: res.Error, Location: location, Line: req.Line})
+			continue
+		}
+		for _, rf := range res.Files {
+			entry := entryFromFile(target, rf)
+			entry.Location, entry.Line = location, req.Line
+			entries = append(entries, entry)
+		}
+	}
+	return entries, nil
+}
+
+// entryFromFile converts the verification of a release file to an entry.
+func entryFromFile(target string, rf requirementFile) reportEntry {
+	entry := reportEntry{
+		Target:   target,
+		Filename: rf.Filename,
+		Verified: rf.Verified,
+		Attested: rf.Report != nil && len(rf.Report.Attestations) > 0,
+		Error:    rf.Error,
+	}
+	if entry.Filename == "" {
+		entry.Filename = "sha256:" + rf.SHA256
+	}
+	if rf.Report != nil {
+		for _, a := range rf.Report.Attestations {
+			if a.Verification != nil {
+				entry.Identity = a.Verification.Identity
+				break
+			}
+		}
+	}
+	return entry
+}
+
+// writeMarkdown writes the entries as a Markdown summary and table.
+func writeMarkdown(w io.Writer, entries []reportEntry) {
+	fmt.Fprintf(w, "## PyPI attestations\n\n")
+	fmt.Fprintf(w, "%d of %d files verified.\n\n", len(entries)-countFailed(entries), len(entries))
+	fmt.Fprintln(w, "| Status | Target | File | Details |")
+	fmt.Fprintln(w, "| --- | --- | --- | --- |")
+	for _, e := range entries {
+		status, details := "✅ verified", e.Identity
+		switch {
+		case !e.Attested:
+			status, details = "⚠️ unattested", e.Error
+		case !e.Verified:
+			status, details = "❌ failed", e.Error
+		}
+		fmt.Fprintf(w, "| %s | %s | %s | %s |\n", status, markdownCell(e.Target), markdownCell(e.Filename), markdownCell(details))
+	}
+}
+
+// markdownCell escapes a value for a Markdown table cell.
+func markdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.ReplaceAll(s, "\n", " ")
+}
+
+// sarifLog is the subset of a SARIF 2.1.0 log written by the report.
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	Name             string       `json:"name"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           *sarifRegion          `json:"region,omitempty"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// newSARIF builds a SARIF log with a result for every target that is
+// unattested or does not verify.
+func newSARIF(entries []reportEntry) *sarifLog {
+	run := sarifRun{
+		Tool: sarifTool{Driver: sarifDriver{
+			Name:           appName,
+			InformationURI: "https://github.com/carabiner-dev/pypi-attestations",
+			Rules: []sarifRule{
+				{ID: ruleUnattested, Name: "UnattestedDistribution", ShortDescription: sarifMessage{Text: "Distribution has no PEP 740 attestations"}},
+				{ID: ruleUnverified, Name: "UnverifiedAttestation", ShortDescription: sarifMessage{Text: "Distribution attestations do not verify"}},
+			},
+		}},
+		Results: []sarifResult{},
+	}
+
+	for _, e := range entries {
+		if e.Verified {
+			continue
+		}
+		res := sarifResult{
+			RuleID:  ruleUnverified,
+			Level:   "error",
+			Message: sarifMessage{Text: fmt.Sprintf("%s (%s): %s", e.Target, e.Filename, e.Error)},
+		}
+		if !e.Attested {
+			res.RuleID, res.Level = ruleUnattested, "warning"
+		}
+		if e.Location != "" {
+			loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: filepath.ToSlash(e.Location)},
+			}}
+			if e.Line > 0 {
+				loc.PhysicalLocation.Region = &sarifRegion{StartLine: e.Line}
+			}
+			res.Locations = append(res.Locations, loc)
+		}
+		run.Results = append(run.Results, res)
+	}
+
+	return &sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs:    []sarifRun{run},
+	}
+}
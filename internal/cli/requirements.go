@@ -0,0 +1,260 @@
+package cli
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	"github.com/spf13/cobra"
+)
+
+type requirementsOptions struct {
+	IndexURL    string
+	PolicyFile  string
+	Policy      *verify.Policy
+	TrustedRoot string
+	Offline     bool
+	Format      string
+}
+
+// AddFlags adds the verify-requirements flags to the command.
+func (o *requirementsOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.IndexURL, "index-url", pypi.DefaultURL, "base URL of the package index")
+	cmd.Flags().StringVar(&o.PolicyFile, "policy", "", "trusted publisher policy file all dependencies must satisfy")
+	cmd.Flags().StringVar(&o.TrustedRoot, "trusted-root", "", "Sigstore trusted root JSON (default: public good instance)")
+	cmd.Flags().BoolVar(&o.Offline, "offline", false, "verify the attestations without contacting Sigstore")
+	addFormatFlag(cmd, &o.Format, formatText, "output format", formatText, formatJSON)
+}
+
+// Validate checks the flag values.
+func (o *requirementsOptions) Validate() error {
+	return validateFormat(o.Format, formatText, formatJSON)
+}
+
+// verifierOptions returns the options of the verifier.
+func (o *requirementsOptions) verifierOptions() []verify.FnOption {
+	funcs := []verify.FnOption{verify.WithOffline(o.Offline)}
+	if o.TrustedRoot != "" {
+		funcs = append(funcs, verify.WithTrustedRootPath(o.TrustedRoot))
+	}
+	if o.PolicyFile != "" {
+		funcs = append(funcs, verify.WithPolicyFile(o.PolicyFile))
+	} else if o.Policy != nil {
+		funcs = append(funcs, verify.WithPolicy(o.Policy))
+	}
+	return funcs
+}
+
+func addVerifyRequirements(parent *cobra.Command) {
+	opts := &requirementsOptions{}
+	cmd := &cobra.Command{
+		Use:   "verify-requirements FILE|-",
+		Short: "Verify the provenance of the dependencies of a requirements file",
+		Long: `Verify-requirements reads a pip requirements file pinning every
+dependency with == and --hash options, as produced by pip-compile
+--generate-hashes or uv export, or from standard input when FILE is "-".
+For each file allowed by a hash it fetches the provenance from the index
+and verifies its attestations against the hash and the trusted publisher
+of the project.
+
+The command fails listing every dependency that has no hashes, whose
+files have no attestations or whose attestations do not verify. It exits
+with 0 when all dependencies verify, 1 when any fails and 2 on usage or
+input errors.`,
+		Example: `  pypi-attestations verify-requirements requirements.txt
+  pypi-attestations verify-requirements --policy publishers.yaml --format json requirements.txt`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, _ []string) error {
+			opts.Policy = configPolicy(cmd)
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runVerifyRequirements(cmd, opts, args[0])
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+// requirementResult is the outcome of verifying a requirement.
+type requirementResult struct {
+	pypi.Requirement
+
+	Verified bool              `json:"verified"`
+	Error    string            `json:"error,omitempty"`
+	Files    []requirementFile `json:"files,omitempty"`
+}
+
+// requirementFile is the outcome of verifying a file of a requirement.
+type requirementFile struct {
+	Filename string       `json:"filename,omitempty"`
+	SHA256   string       `json:"sha256"`
+	Verified bool         `json:"verified"`
+	Error    string       `json:"error,omitempty"`
+	Report   *pypi.Report `json:"report,omitempty"`
+}
+
+func runVerifyRequirements(cmd *cobra.Command, opts *requirementsOptions, path string) error {
+	client, err := newIndexClient(cmd, pypi.WithURL(opts.IndexURL))
+	if err != nil {
+		return err
+	}
+	v, err := newVerifier(cmd, opts.verifierOptions())
+	if err != nil {
+		return err
+	}
+	reqs, releases, err := fetchRequirements(cmd, client, path)
+	if err != nil {
+		return err
+	}
+
+	results := make([]requirementResult, len(reqs))
+	failed := 0
+	for i, req := range reqs {
+		results[i] = verifyRequirement(cmd.Context(), v, req, releases[i])
+		if !results[i].Verified {
+			failed++
+		}
+	}
+
+	if opts.Format == formatJSON {
+		if err := writeJSON(cmd.OutOrStdout(), results); err != nil {
+			return err
+		}
+	} else {
+		printRequirementResults(cmd.OutOrStdout(), results)
+	}
+
+	if failed > 0 {
+		return verificationFailed(fmt.Errorf("%d of %d requirements are unattested or do not verify", failed, len(results)))
+	}
+	return nil
+}
+
+// fetchRequirements parses a requirements file and fetches the provenance
+// of the pinned releases.
+func fetchRequirements(cmd *cobra.Command, client *pypi.Client, path string) ([]pypi.Requirement, []pypi.PackageProvenance, error) {
+	f, err := openPath(cmd, path)
+	if err != nil {
+		return nil, nil, err
+	}
+	reqs, err := pypi.ParseRequirements(f)
+	f.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if len(reqs) == 0 {
+		return nil, nil, fmt.Errorf("%s has no requirements", path)
+	}
+
+	packages := make([]pypi.Package, len(reqs))
+	for i, req := range reqs {
+		packages[i] = pypi.Package{Name: req.Name, Version: req.Version}
+	}
+	releases, err := client.FetchAll(cmd.Context(), packages)
+	if err != nil {
+		return nil, nil, err
+	}
+	return reqs, releases, nil
+}
+
+// verifyRequirement verifies the provenance of every file of the release
+// allowed by the hashes of the requirement.
+func verifyRequirement(ctx context.Context, v *verify.Verifier, req pypi.Requirement, release pypi.PackageProvenance) requirementResult {
+	result := requirementResult{Requirement: req}
+	switch {
+	case len(req.Hashes) == 0:
+		result.Error = "requirement has no sha256 hashes"
+		return result
+	case release.Error != nil:
+		result.Error = release.Error.Error()
+		return result
+	}
+
+	result.Verified = true
+	for _, hash := range req.Hashes {
+		rf := verifyRequirementFile(ctx, v, hash, release.Files)
+		result.Verified = result.Verified && rf.Verified
+		result.Files = append(result.Files, rf)
+	}
+	return result
+}
+
+func verifyRequirementFile(ctx context.Context, v *verify.Verifier, hash string, files []pypi.FileProvenance) requirementFile {
+	rf := requirementFile{SHA256: hash}
+
+	var file *pypi.FileProvenance
+	for i := range files {
+		if strings.EqualFold(files[i].SHA256, hash) {
+			file = &files[i]
+			break
+		}
+	}
+	if file == nil {
+		rf.Error = "no file of the release has this hash"
+		return rf
+	}
+	rf.Filename = file.Filename
+
+	switch {
+	case errors.Is(file.Error, pypi.ErrNotFound):
+		rf.Error = "file has no attestations"
+		return rf
+	case file.Error != nil:
+		rf.Error = file.Error.Error()
+		return rf
+	}
+
+	digest, err := hex.DecodeString(hash)
+	if err != nil {
+		rf.Error = fmt.Sprintf("invalid hash: %v", err)
+		return rf
+	}
+	report, err := pypi.VerifyProvenance(ctx, v, file.Provenance, file.Filename, digest)
+	if err != nil {
+		rf.Error = err.Error()
+		return rf
+	}
+	rf.Report = report
+	rf.Verified = report.Passed()
+	if !rf.Verified {
+		rf.Error = "attestations do not verify"
+		for _, a := range report.Attestations {
+			if a.Error != "" {
+				rf.Error = a.Error
+				break
+			}
+		}
+	}
+	return rf
+}
+
+// printRequirementResults writes a line per requirement and the reason of
+// every failure.
+func printRequirementResults(w io.Writer, results []requirementResult) {
+	for _, res := range results {
+		status := "OK  "
+		if !res.Verified {
+			status = "FAIL"
+		}
+		fmt.Fprintf(w, "%s %s==%s\n", status, res.Name, res.Version)
+		if res.Error != "" {
+			fmt.Fprintf(w, "       %s\n", res.Error)
+		}
+		for _, f := range res.Files {
+			if f.Error == "" {
+				continue
+			}
+			name := f.Filename
+			if name == "" {
+				name = "sha256:" + f.SHA256
+			}
+			fmt.Fprintf(w, "       %s: %s\n", name, f.Error)
+		}
+	}
+}
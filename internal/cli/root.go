@@ -0,0 +1,118 @@
+// Package cli implements the pypi-attestations command line tool.
+package cli
+
+import (
+	"errors"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+const appName = "pypi-attestations"
+
+// Exit codes of the tool. Verification failures are told apart from usage
+// and input errors so CI jobs can gate on them.
+const (
+	exitFailed = 1
+	exitError  = 2
+)
+
+// exitCodeError is an error that sets the exit code of the tool.
+type exitCodeError struct {
+	code int
+	err  error
+}
+
+func (e *exitCodeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *exitCodeError) Unwrap() error {
+	return e.err
+}
+
+// verificationFailed marks err as a verification failure.
+func verificationFailed(err error) error {
+	return &exitCodeError{code: exitFailed, err: err}
+}
+
+// New returns the root command of the tool with all its subcommands.
+func New() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   appName,
+		Short: "Work with PyPI attestations (PEP 740)",
+		Long: `pypi-attestations converts, inspects and verifies the attestations
+of Python distributions published on PyPI (PEP 740).
+
+Defaults for the index-url, trusted-root, policy, proxy, log-level and
+log-format flags are read from the PYPI_ATTESTATIONS_<FLAG> environment
+variables, for example PYPI_ATTESTATIONS_INDEX_URL, and then from the
+configuration file. The file may also list the trusted publishers used
+when no policy is set:
+
+  index-url: https://pypi.org
+  trusted-root: trusted_root.json
+  publishers:
+    - repository: pypa/sampleproject
+      workflow: release.yml
+
+Flags set on the command line always take precedence.
+
+The JSON and YAML documents written with --format (or --output) json
+carry a schemaVersion and a kind field. Fields are only added within a
+schema version. With --quiet nothing is printed and the outcome is only
+reported by the exit code: 0 on success, 1 when a verification fails or
+documents differ and 2 on usage or input errors.
+
+Network requests, conversions and verification steps are logged to
+stderr. Use --log-level debug to trace them when a command fails.`,
+		SilenceUsage: true,
+		PersistentPreRunE: func(cmd *cobra.Command, _ []string) error {
+			if quiet, _ := cmd.Flags().GetBool("quiet"); quiet {
+				cmd.SetOut(io.Discard)
+				cmd.SetErr(io.Discard)
+			}
+			if err := applyConfig(cmd); err != nil {
+				return err
+			}
+			return setupLogging(cmd)
+		},
+	}
+	cmd.PersistentFlags().BoolP("quiet", "q", false, "print nothing, report the outcome only through the exit code")
+	cmd.PersistentFlags().String("config", "", "configuration file (default: "+configPathHelp()+")")
+	cmd.PersistentFlags().String("log-level", "warn", "level of the messages logged to stderr: debug, info, warn or error")
+	cmd.PersistentFlags().String("log-format", "text", "format of the messages logged to stderr: text or json")
+	cmd.PersistentFlags().String("proxy", "", "URL of the HTTP proxy to reach the index and Sigstore")
+	addConvert(cmd)
+	addVerify(cmd)
+	addInspect(cmd)
+	addFetch(cmd)
+	addSign(cmd)
+	addUpload(cmd)
+	addVerifyRequirements(cmd)
+	addReport(cmd)
+	addDiff(cmd)
+	addExport(cmd)
+	addImport(cmd)
+	addWatch(cmd)
+	addServe(cmd)
+	return cmd
+}
+
+// Execute runs the tool. It exits with exitFailed when a verification
+// fails and with exitError on any other error.
+func Execute() {
+	if err := New().Execute(); err != nil {
+		os.Exit(exitCode(err))
+	}
+}
+
+// exitCode returns the exit code for the error returned by a command.
+func exitCode(err error) int {
+	var ece *exitCodeError
+	if errors.As(err, &ece) {
+		return ece.code
+	}
+	return exitError
+}
@@ -0,0 +1,223 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/internal/transport"
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	"github.com/carabiner-dev/pypi-attestations/pkg/server"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	"github.com/spf13/cobra"
+)
+
+type serveOptions struct {
+	GRPC           string
+	HTTP           string
+	Metrics        string
+	IndexURL       string
+	CacheTTL       time.Duration
+	MaxRequestSize int64
+	PolicyFile     string
+	Policy         *verify.Policy
+	TrustedRoot    string
+	Offline        bool
+}
+
+// AddFlags adds the serve flags to the command.
+func (o *serveOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.GRPC, "grpc", "", "address the gRPC service listens on, eg :50051")
+	cmd.Flags().StringVar(&o.HTTP, "http", "", "address the HTTP API listens on, eg :8080")
+	cmd.Flags().StringVar(&o.Metrics, "metrics", "", "address the metrics and health endpoints listen on, eg :9090 (default: on the HTTP API)")
+	cmd.Flags().StringVar(&o.IndexURL, "index-url", pypi.DefaultURL, "base URL of the package index the HTTP API fetches provenance from")
+	cmd.Flags().Int64Var(&o.MaxRequestSize, "max-request-size", server.DefaultMaxRequestSize, "maximum size in bytes of the HTTP request bodies")
+	cmd.Flags().DurationVar(&o.CacheTTL, "cache-ttl", 10*time.Minute, "time verification results are cached for, 0 to disable")
+	cmd.Flags().StringVar(&o.PolicyFile, "policy", "", "trusted publisher policy file used when a request lists no publishers")
+	cmd.Flags().StringVar(&o.TrustedRoot, "trusted-root", "", "Sigstore trusted root JSON (default: public good instance)")
+	cmd.Flags().BoolVar(&o.Offline, "offline", false, "verify without network access")
+}
+
+// Validate checks the flag values.
+func (o *serveOptions) Validate() error {
+	if o.GRPC == "" && o.HTTP == "" {
+		return fmt.Errorf("a listen address must be set with --grpc or --http")
+	}
+	if o.CacheTTL < 0 {
+		return fmt.Errorf("--cache-ttl cannot be negative")
+	}
+	if o.MaxRequestSize < 1 {
+		return fmt.Errorf("--max-request-size must be positive")
+	}
+	return nil
+}
+
+// serverOptions returns the options of the service.
+func (o *serveOptions) serverOptions(cmd *cobra.Command) ([]server.FnOption, error) {
+	// The external calls and cache lookups are recorded in the server metrics
+	metrics := server.NewMetrics()
+	base := http.DefaultTransport
+	if proxy := proxyURL(cmd); proxy != "" {
+		client, err := (&transport.Config{Proxy: proxy}).Client()
+		if err != nil {
+			return nil, err
+		}
+		base = client.Transport
+	}
+
+	funcs := []verify.FnOption{
+		verify.WithOffline(o.Offline),
+		verify.WithHTTPClient(&http.Client{Transport: metrics.RoundTripper("tuf", base)}),
+	}
+	if o.CacheTTL > 0 {
+		funcs = append(funcs, verify.WithCache(metrics.VerificationCache(verify.NewMemoryCache(o.CacheTTL))))
+	}
+	if o.TrustedRoot != "" {
+		funcs = append(funcs, verify.WithTrustedRootPath(o.TrustedRoot))
+	}
+	policy := o.Policy
+	if o.PolicyFile != "" {
+		var err error
+		if policy, err = verify.LoadPolicy(o.PolicyFile); err != nil {
+			return nil, err
+		}
+	}
+	client, err := pypi.NewClient(
+		pypi.WithURL(o.IndexURL),
+		pypi.WithRoundTripper(metrics.RoundTripper("pypi", base)),
+		pypi.WithResponseCache(metrics.ResponseCache(pypi.NewMemoryResponseCache())),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return []server.FnOption{
+		server.WithMetrics(metrics),
+		server.WithVerifyOptions(funcs...),
+		server.WithPolicy(policy),
+		server.WithIndexClient(client),
+		server.WithMaxRequestSize(o.MaxRequestSize),
+	}, nil
+}
+
+func addServe(parent *cobra.Command) {
+	opts := &serveOptions{}
+	cmd := &cobra.Command{
+		Use:   "serve [--grpc ADDR] [--http ADDR]",
+		Short: "Serve conversion and verification over the network",
+		Long: `Serve runs the AttestationService gRPC service defined in
+proto/service.proto, with Convert, Verify and Inspect RPCs, and an HTTP
+API so services not written in Go can use this tool without
+reimplementing it. The HTTP API is described by the OpenAPI document
+served at /openapi.yaml:
+
+  POST /convert?to=bundle|attestation
+  POST /verify
+  GET  /packages/{name}/{version}/provenance[?filename=FILE]
+
+HTTP errors are written as {"error": {"code": ..., "message": ...}}.
+
+Prometheus metrics are served at /metrics, along with the /healthz and
+/readyz probes, on the HTTP API or on the --metrics address. They count
+the requests and verification outcomes, the latency of the calls to PyPI
+and the TUF repository, and the hits of the caches. The gRPC service also
+registers the standard grpc.health.v1 service.
+
+Verify requests are checked against the publishers they list or, when
+they list none, the --policy file or the publishers of the configuration
+file. The server stops gracefully on SIGINT or SIGTERM.`,
+		Example: `  pypi-attestations serve --grpc :50051 --policy publishers.yaml
+  pypi-attestations serve --http :8080 --index-url https://pypi.internal.example.com
+  pypi-attestations serve --grpc :50051 --metrics :9090`,
+		Args: cobra.NoArgs,
+		PreRunE: func(cmd *cobra.Command, _ []string) error {
+			opts.Policy = configPolicy(cmd)
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			return runServe(cmd, opts)
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+func runServe(cmd *cobra.Command, opts *serveOptions) error {
+	funcs, err := opts.serverOptions(cmd)
+	if err != nil {
+		return err
+	}
+	srv, err := server.New(funcs...)
+	if err != nil {
+		return err
+	}
+
+	var grpcListener, httpListener, metricsListener net.Listener
+	if opts.GRPC != "" {
+		if grpcListener, err = net.Listen("tcp", opts.GRPC); err != nil {
+			return err
+		}
+		defer grpcListener.Close()
+	}
+	if opts.HTTP != "" {
+		if httpListener, err = net.Listen("tcp", opts.HTTP); err != nil {
+			return err
+		}
+		defer httpListener.Close()
+	}
+	if opts.Metrics != "" {
+		if metricsListener, err = net.Listen("tcp", opts.Metrics); err != nil {
+			return err
+		}
+		defer metricsListener.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errs := make(chan error, 3)
+	var shutdown []func()
+	if grpcListener != nil {
+		gs := srv.NewGRPCServer()
+		shutdown = append(shutdown, gs.GracefulStop)
+		fmt.Fprintf(cmd.ErrOrStderr(), "gRPC service listening on %s\n", grpcListener.Addr())
+		go func() { errs <- gs.Serve(grpcListener) }()
+	}
+	serveHTTP := func(name string, lis net.Listener, handler http.Handler) {
+		hs := &http.Server{Handler: handler, ReadHeaderTimeout: 10 * time.Second}
+		shutdown = append(shutdown, func() {
+			sctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			hs.Shutdown(sctx)
+		})
+		fmt.Fprintf(cmd.ErrOrStderr(), "%s listening on %s\n", name, lis.Addr())
+		go func() {
+			if err := hs.Serve(lis); !errors.Is(err, http.ErrServerClosed) {
+				errs <- err
+				return
+			}
+			errs <- nil
+		}()
+	}
+	if httpListener != nil {
+		serveHTTP("HTTP API", httpListener, srv.Handler())
+	}
+	if metricsListener != nil {
+		serveHTTP("Metrics", metricsListener, srv.OpsHandler())
+	}
+
+	// The first server to stop, or a signal, stops them all
+	select {
+	case <-ctx.Done():
+	case err = <-errs:
+	}
+	for _, fn := range shutdown {
+		fn()
+	}
+	return err
+}
@@ -0,0 +1,209 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/distfile"
+	"github.com/carabiner-dev/pypi-attestations/pkg/sign"
+	"github.com/spf13/cobra"
+)
+
+type signOptions struct {
+	IdentityToken string
+	OIDCIssuer    string
+	OIDCClientID  string
+	OAuthFlow     string
+	Staging       bool
+	KeyPath       string
+	CertPath      string
+	Out           string
+	Overwrite     bool
+}
+
+// AddFlags adds the sign flags to the command.
+func (o *signOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.IdentityToken, "identity-token", "", "OIDC identity token (default: ambient CI credentials or interactive login)")
+	cmd.Flags().StringVar(&o.OIDCIssuer, "oidc-issuer", "", "OIDC provider of the interactive login (default: Sigstore)")
+	cmd.Flags().StringVar(&o.OIDCClientID, "oidc-client-id", sign.DefaultOIDCClientID, "OAuth client ID of the interactive login")
+	cmd.Flags().StringVar(&o.OAuthFlow, "oauth-flow", string(sign.FlowAuto), "interactive login flow: auto, browser or device")
+	cmd.Flags().BoolVar(&o.Staging, "staging", false, "sign with the Sigstore staging instance")
+	cmd.Flags().StringVar(&o.KeyPath, "key", "", "PEM private key to sign with instead of a Fulcio certificate")
+	cmd.Flags().StringVar(&o.CertPath, "certificate", "", "PEM certificate chain of --key")
+	cmd.Flags().StringVarP(&o.Out, "out", "o", "", `directory where the attestations are written, or "-" for standard output (default: next to each file)`)
+	cmd.Flags().BoolVar(&o.Overwrite, "overwrite", false, "replace existing attestation files")
+}
+
+// Validate checks the flag values.
+func (o *signOptions) Validate() error {
+	if (o.KeyPath == "") != (o.CertPath == "") {
+		return fmt.Errorf("--key and --certificate must be set together")
+	}
+	if o.KeyPath != "" && o.IdentityToken != "" {
+		return fmt.Errorf("--identity-token cannot be combined with --key")
+	}
+	switch sign.Flow(o.OAuthFlow) {
+	case sign.FlowAuto, sign.FlowBrowser, sign.FlowDevice:
+	default:
+		return fmt.Errorf("invalid --oauth-flow %q, must be auto, browser or device", o.OAuthFlow)
+	}
+	return nil
+}
+
+// signerOptions returns the options of the signer. The identity token is
+// resolved once so interactive logins happen a single time.
+func (o *signOptions) signerOptions(ctx context.Context) ([]sign.FnOption, error) {
+	var funcs []sign.FnOption
+	if o.Staging {
+		funcs = append(funcs, sign.WithStaging())
+	}
+	if o.KeyPath != "" {
+		return append(funcs, sign.WithKeyFile(o.KeyPath, o.CertPath)), nil
+	}
+
+	token := o.IdentityToken
+	if token == "" {
+		ts, ok := sign.AmbientTokenSource(os.Getenv, nil)
+		if !ok {
+			issuer := o.OIDCIssuer
+			if issuer == "" && o.Staging {
+				issuer = sign.StagingOIDCIssuer
+			}
+			ts = sign.InteractiveTokenSource(sign.OIDCOptions{
+				Issuer:   issuer,
+				ClientID: o.OIDCClientID,
+				Flow:     sign.Flow(o.OAuthFlow),
+			})
+		}
+		var err error
+		if token, err = ts.IDToken(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return append(funcs, sign.WithIDToken(token)), nil
+}
+
+// attestationPath returns the path where the attestation of dist is
+// written.
+func (o *signOptions) attestationPath(dist string) string {
+	if o.Out == "" {
+		return dist + convert.AttestationSuffix
+	}
+	return filepath.Join(o.Out, filepath.Base(dist)+convert.AttestationSuffix)
+}
+
+func addSign(parent *cobra.Command) {
+	opts := &signOptions{}
+	cmd := &cobra.Command{
+		Use:   "sign DIST...",
+		Short: "Create the publish attestations of distribution files",
+		Long: `Sign creates the PEP 740 publish attestation of each wheel and sdist
+and writes it next to the file as DIST.publish.attestation, the name the
+PyPA upload tooling looks for. Files that are not distributions are
+skipped, so dist/* can be passed.
+
+The signing certificate is issued by Fulcio for the identity token set
+with --identity-token, the ambient credentials of the CI job (GitHub
+Actions or SIGSTORE_ID_TOKEN) or, when there are none, an interactive
+login. Signatures are recorded in the public Rekor transparency log.
+
+With --out -, the attestations are written to standard output, one
+compact JSON document per line.`,
+		Example: `  pypi-attestations sign dist/*
+  pypi-attestations sign --oauth-flow device dist/sampleproject-1.0.tar.gz
+  pypi-attestations sign --out - dist/sampleproject-1.0.tar.gz | pypi-attestations convert --to bundle -`,
+		Args: cobra.MinimumNArgs(1),
+		PreRunE: func(*cobra.Command, []string) error {
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSign(cmd, opts, args)
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+func runSign(cmd *cobra.Command, opts *signOptions, args []string) error {
+	dists, err := distributionArgs(args)
+	if err != nil {
+		return err
+	}
+	if opts.Out != "" && opts.Out != stdio {
+		if err := os.MkdirAll(opts.Out, 0o755); err != nil {
+			return fmt.Errorf("creating output directory: %w", err)
+		}
+	}
+	if !opts.Overwrite && opts.Out != stdio {
+		for _, dist := range dists {
+			if _, err := os.Stat(opts.attestationPath(dist)); err == nil {
+				return fmt.Errorf("%s already exists, use --overwrite to replace it", opts.attestationPath(dist))
+			}
+		}
+	}
+
+	signer, err := newSigner(cmd, opts)
+	if err != nil {
+		return err
+	}
+	for _, dist := range dists {
+		if err := signDistribution(cmd, signer, opts, dist); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newSigner returns a signer honoring --proxy.
+func newSigner(cmd *cobra.Command, opts *signOptions) (*sign.Signer, error) {
+	funcs, err := opts.signerOptions(cmd.Context())
+	if err != nil {
+		return nil, err
+	}
+	if proxy := proxyURL(cmd); proxy != "" {
+		funcs = append(funcs, sign.WithProxy(proxy))
+	}
+	return sign.New(funcs...)
+}
+
+// signDistribution signs dist and writes its attestation.
+func signDistribution(cmd *cobra.Command, signer *sign.Signer, opts *signOptions, dist string) error {
+	attestation, err := signer.Sign(cmd.Context(), dist)
+	if err != nil {
+		return fmt.Errorf("signing %s: %w", dist, err)
+	}
+	data, err := convert.MarshalAttestation(attestation)
+	if err != nil {
+		return err
+	}
+	if opts.Out == stdio {
+		return writeCompact(cmd.OutOrStdout(), data)
+	}
+	if err := os.WriteFile(opts.attestationPath(dist), data, 0o644); err != nil {
+		return err
+	}
+	fmt.Fprintln(cmd.OutOrStdout(), opts.attestationPath(dist))
+	return nil
+}
+
+// distributionArgs expands the arguments and keeps the paths of wheels and
+// sdists.
+func distributionArgs(args []string) ([]string, error) {
+	paths, _, err := expandArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	var dists []string
+	for _, path := range paths {
+		if _, err := distfile.Parse(filepath.Base(path)); err == nil {
+			dists = append(dists, path)
+		}
+	}
+	if len(dists) == 0 {
+		return nil, fmt.Errorf("no distribution files in the arguments")
+	}
+	return dists, nil
+}
@@ -0,0 +1,58 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// stdio is the path naming standard input or output.
+const stdio = "-"
+
+// readPath reads the file at path, or standard input when path is "-".
+func readPath(cmd *cobra.Command, path string) ([]byte, error) {
+	if path != stdio {
+		return os.ReadFile(path)
+	}
+	data, err := io.ReadAll(cmd.InOrStdin())
+	if err != nil {
+		return nil, fmt.Errorf("reading standard input: %w", err)
+	}
+	return data, nil
+}
+
+// openPath opens the file at path, or standard input when path is "-".
+func openPath(cmd *cobra.Command, path string) (io.ReadCloser, error) {
+	if path == stdio {
+		return io.NopCloser(cmd.InOrStdin()), nil
+	}
+	return os.Open(path)
+}
+
+// writeOutput writes data to the file at path, or to w when path is empty
+// or "-".
+func writeOutput(w io.Writer, path string, data []byte) error {
+	if path != "" && path != stdio {
+		return os.WriteFile(path, data, 0o644)
+	}
+	if !bytes.HasSuffix(data, []byte("\n")) {
+		data = append(data, '\n')
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// writeCompact writes the JSON document data to w on a single line.
+func writeCompact(w io.Writer, data []byte) error {
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return err
+	}
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
@@ -0,0 +1,187 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/distfile"
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/spf13/cobra"
+)
+
+// Environment variables with the upload credentials, shared with twine.
+const (
+	usernameEnv = "TWINE_USERNAME"
+	passwordEnv = "TWINE_PASSWORD"
+)
+
+type uploadOptions struct {
+	RepositoryURL    string
+	Username         string
+	Password         string
+	AttestationsOnly bool
+}
+
+// AddFlags adds the upload flags to the command.
+func (o *uploadOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.RepositoryURL, "repository-url", pypi.DefaultUploadURL, "upload endpoint of the index")
+	cmd.Flags().StringVarP(&o.Username, "username", "u", "", "username to authenticate with (default: $"+usernameEnv+" or __token__)")
+	cmd.Flags().StringVarP(&o.Password, "password", "p", "", "password or API token (default: $"+passwordEnv+")")
+	cmd.Flags().BoolVar(&o.AttestationsOnly, "attestations-only", false, "only upload files with attestations, to add them to already published files")
+}
+
+// Validate checks the flag values, reading the credentials from the
+// environment when not set.
+func (o *uploadOptions) Validate() error {
+	if o.Username == "" {
+		o.Username = os.Getenv(usernameEnv)
+	}
+	if o.Password == "" {
+		o.Password = os.Getenv(passwordEnv)
+	}
+	if o.Password == "" {
+		return fmt.Errorf("a password or API token is required, set --password or $%s", passwordEnv)
+	}
+	return nil
+}
+
+func addUpload(parent *cobra.Command) {
+	opts := &uploadOptions{}
+	cmd := &cobra.Command{
+		Use:   "upload DIST...",
+		Short: "Upload distribution files with their attestations",
+		Long: `Upload publishes wheels and sdists through the legacy upload API of
+the index, the way twine does, along with their sibling attestation files:
+DIST.publish.attestation and any other DIST.*.attestation. Files that are
+not distributions are skipped, so dist/* can be passed.
+
+With --attestations-only, files without attestations are skipped and the
+others are submitted again to attach their attestations. Indexes that do
+not permit adding attestations to published files reject the upload,
+PyPI among them at the time of writing.`,
+		Example: `  pypi-attestations upload dist/*
+  pypi-attestations upload --repository-url https://test.pypi.org/legacy/ dist/*`,
+		Args: cobra.MinimumNArgs(1),
+		PreRunE: func(*cobra.Command, []string) error {
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runUpload(cmd, opts, args)
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+func runUpload(cmd *cobra.Command, opts *uploadOptions, args []string) error {
+	dists, err := distributionArgs(args)
+	if err != nil {
+		return err
+	}
+
+	// Read all the attestations first so a broken file does not leave a
+	// partial release
+	attestations := make([][]*pb.Attestation, len(dists))
+	for i, dist := range dists {
+		if attestations[i], err = siblingAttestations(dist); err != nil {
+			return err
+		}
+	}
+
+	client, err := newIndexClient(cmd, pypi.WithUploadURL(opts.RepositoryURL))
+	if err != nil {
+		return err
+	}
+
+	for i, dist := range dists {
+		if opts.AttestationsOnly && len(attestations[i]) == 0 {
+			fmt.Fprintf(cmd.ErrOrStderr(), "skipping %s, it has no attestations\n", dist)
+			continue
+		}
+		if err := uploadFile(cmd, client, opts, dist, attestations[i]); err != nil {
+			return err
+		}
+		fmt.Fprintf(cmd.OutOrStdout(), "%s (%d attestations)\n", dist, len(attestations[i]))
+	}
+	return nil
+}
+
+func uploadFile(cmd *cobra.Command, client *pypi.Client, opts *uploadOptions, dist string, attestations []*pb.Attestation) error {
+	f, err := distfile.Parse(filepath.Base(dist))
+	if err != nil {
+		return err
+	}
+	content, err := os.Open(dist)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	return client.Upload(cmd.Context(), &pypi.UploadRequest{
+		Name:         f.Name,
+		Version:      f.Version,
+		Filename:     f.Filename,
+		Content:      content,
+		Attestations: attestations,
+		Username:     opts.Username,
+		Password:     opts.Password,
+	})
+}
+
+// siblingAttestations reads the DIST.*.attestation files next to the
+// distribution, the publish attestation first.
+func siblingAttestations(dist string) ([]*pb.Attestation, error) {
+	paths, err := siblingAttestationPaths(dist)
+	if err != nil {
+		return nil, err
+	}
+
+	var attestations []*pb.Attestation
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		attestation, err := convert.UnmarshalAttestation(data)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		attestations = append(attestations, attestation)
+	}
+	return attestations, nil
+}
+
+// siblingAttestationPaths lists the DIST.*.attestation files next to the
+// distribution, the publish attestation first.
+func siblingAttestationPaths(dist string) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Dir(dist))
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := filepath.Base(dist) + "."
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasPrefix(e.Name(), prefix) && strings.HasSuffix(e.Name(), ".attestation") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Slice(names, func(i, j int) bool {
+		pi, pj := strings.HasSuffix(names[i], convert.AttestationSuffix), strings.HasSuffix(names[j], convert.AttestationSuffix)
+		if pi != pj {
+			return pi
+		}
+		return names[i] < names[j]
+	})
+
+	paths := make([]string, len(names))
+	for i, name := range names {
+		paths[i] = filepath.Join(filepath.Dir(dist), name)
+	}
+	return paths, nil
+}
@@ -0,0 +1,244 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	"github.com/spf13/cobra"
+)
+
+type verifyOptions struct {
+	Attestation string
+	Filename    string
+	Repository  string
+	Workflow    string
+	Issuer      string
+	PolicyFile  string
+	Policy      *verify.Policy
+	TrustedRoot string
+	Offline     bool
+	Format      string
+	FromArchive string
+}
+
+// AddFlags adds the verify flags to the command.
+func (o *verifyOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&o.Attestation, "attestation", "a", "", `attestation file, or "-" for standard input (default: DIST.publish.attestation)`)
+	cmd.Flags().StringVar(&o.Filename, "filename", "", "name of the distribution read from standard input")
+	cmd.Flags().StringVar(&o.Repository, "repository", "", "repository (owner/name) of the trusted publisher")
+	cmd.Flags().StringVar(&o.Workflow, "workflow", "", "workflow filename of the trusted publisher, eg release.yml (default: any)")
+	cmd.Flags().StringVar(&o.Issuer, "issuer", verify.GitHubIssuer, "OIDC issuer of the trusted publisher")
+	cmd.Flags().StringVar(&o.PolicyFile, "policy", "", "trusted publisher policy file, instead of --repository")
+	cmd.Flags().StringVar(&o.TrustedRoot, "trusted-root", "", "Sigstore trusted root JSON (default: public good instance)")
+	cmd.Flags().BoolVar(&o.Offline, "offline", false, "verify without network access")
+	cmd.Flags().StringVar(&o.FromArchive, "from-archive", "", `verify the contents of an archive written by export, or "-" for standard input`)
+	addFormatFlag(cmd, &o.Format, formatText, "output format", formatText, formatJSON)
+}
+
+// Validate checks the flag values.
+func (o *verifyOptions) Validate() error {
+	if o.Repository == "" && o.PolicyFile == "" && o.Policy == nil {
+		return fmt.Errorf("the trusted publisher must be set with --repository, --policy or the publishers of the configuration file")
+	}
+	if o.Repository != "" && o.PolicyFile != "" {
+		return fmt.Errorf("--repository and --policy cannot be combined")
+	}
+	if o.FromArchive != "" && o.Attestation != "" {
+		return fmt.Errorf("--from-archive cannot be combined with --attestation")
+	}
+	return validateFormat(o.Format, formatText, formatJSON)
+}
+
+// verifierOptions returns the options of the verifier.
+func (o *verifyOptions) verifierOptions() []verify.FnOption {
+	funcs := []verify.FnOption{verify.WithOffline(o.Offline)}
+	if o.TrustedRoot != "" {
+		funcs = append(funcs, verify.WithTrustedRootPath(o.TrustedRoot))
+	}
+	return append(funcs, o.policyOptions()...)
+}
+
+// policyOptions returns the verifier options setting the publisher policy.
+func (o *verifyOptions) policyOptions() []verify.FnOption {
+	var funcs []verify.FnOption
+	if o.PolicyFile != "" {
+		return append(funcs, verify.WithPolicyFile(o.PolicyFile))
+	}
+	if o.Repository == "" {
+		return append(funcs, verify.WithPolicy(o.Policy))
+	}
+	return append(funcs, verify.WithPolicy(&verify.Policy{
+		Publishers: []verify.PublisherPolicy{{
+			Issuer:     o.Issuer,
+			Repository: o.Repository,
+			Workflow:   o.Workflow,
+		}},
+	}))
+}
+
+func addVerify(parent *cobra.Command) {
+	opts := &verifyOptions{}
+	cmd := &cobra.Command{
+		Use:   "verify DIST|-|--from-archive ARCHIVE",
+		Short: "Verify the attestation of a distribution file",
+		Long: `Verify checks the PEP 740 attestation of a distribution file: the
+Sigstore signature and transparency log inclusion, that the statement
+attests the file, and that it was signed by the trusted publisher set
+with --repository and --workflow, or by one listed in a --policy file.
+
+The distribution is read from standard input when DIST is "-", its name
+is then set with --filename and the attestation with --attestation.
+Alternatively, the attestation is read from standard input with
+--attestation -.
+
+With --from-archive, the contents of an archive written by export are
+verified offline instead of DIST, with the embedded public good instance
+root or --trusted-root. The root pinned in the archive must be the same.
+
+The command exits with 0 when the attestation verifies, 1 when the
+verification fails and 2 on usage or input errors.`,
+		Example: `  pypi-attestations verify dist/sampleproject-1.0.tar.gz --repository pypa/sampleproject --workflow release.yml
+  pypi-attestations verify sampleproject-1.0.tar.gz --attestation att.json --repository pypa/sampleproject --format json
+  curl -sL $URL | pypi-attestations verify - --filename sampleproject-1.0.tar.gz --attestation att.json --repository pypa/sampleproject
+  pypi-attestations verify --from-archive transfer.tar.gz --policy publishers.yaml`,
+		Args: cobra.RangeArgs(0, 1),
+		PreRunE: func(cmd *cobra.Command, args []string) error {
+			opts.Policy = configPolicy(cmd)
+			if (opts.FromArchive == "") == (len(args) == 0) {
+				return fmt.Errorf("either DIST or --from-archive must be set")
+			}
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.FromArchive != "" {
+				return runVerifyArchive(cmd, opts)
+			}
+			return runVerify(cmd, opts, args[0])
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+// verifyReport is the JSON output of the verify command.
+type verifyReport struct {
+	schemaHeader
+
+	Distribution string                     `json:"distribution"`
+	Attestation  string                     `json:"attestation"`
+	Verified     bool                       `json:"verified"`
+	Error        string                     `json:"error,omitempty"`
+	Result       *verify.VerificationResult `json:"result,omitempty"`
+}
+
+func runVerify(cmd *cobra.Command, opts *verifyOptions, dist string) error {
+	name := filepath.Base(dist)
+	attestationPath := opts.Attestation
+	if dist == stdio {
+		switch {
+		case opts.Filename == "":
+			return fmt.Errorf("--filename must be set when the distribution is read from standard input")
+		case attestationPath == "", attestationPath == stdio:
+			return fmt.Errorf("--attestation must be a file when the distribution is read from standard input")
+		}
+		name = opts.Filename
+	} else if attestationPath == "" {
+		attestationPath = dist + convert.AttestationSuffix
+	}
+
+	data, err := readPath(cmd, attestationPath)
+	if err != nil {
+		return err
+	}
+	// An unreadable attestation is an input error, not a failed verification
+	attestation, err := convert.UnmarshalAttestation(data)
+	if err != nil {
+		return fmt.Errorf("parsing attestation %s: %w", attestationPath, err)
+	}
+	f, err := openPath(cmd, dist)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	v, err := newVerifier(cmd, opts.verifierOptions())
+	if err != nil {
+		return err
+	}
+
+	report := verifyReport{
+		schemaHeader: newSchemaHeader("Verification"),
+		Distribution: dist,
+		Attestation:  attestationPath,
+	}
+	result, verr := v.VerifyNamed(cmd.Context(), attestation, name, f)
+	if verr == nil {
+		report.Verified = true
+		report.Result = result
+	} else {
+		report.Error = verr.Error()
+	}
+
+	if opts.Format == formatJSON {
+		if err := writeJSON(cmd.OutOrStdout(), report); err != nil {
+			return err
+		}
+	} else if verr == nil {
+		printVerificationResult(cmd.OutOrStdout(), name, result)
+	}
+
+	if verr != nil {
+		return verificationFailed(fmt.Errorf("verifying %s: %w", name, verr))
+	}
+	return nil
+}
+
+func runVerifyArchive(cmd *cobra.Command, opts *verifyOptions) error {
+	tmp, err := os.MkdirTemp("", appName+"-archive-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmp)
+
+	_, entries, err := checkArchive(cmd, opts.FromArchive, tmp, opts.TrustedRoot, opts.policyOptions())
+	if err != nil {
+		return err
+	}
+	if err := writeArchiveResults(cmd, opts.Format, entries); err != nil {
+		return err
+	}
+	if failed := countFailed(entries); failed > 0 {
+		return verificationFailed(fmt.Errorf("%d of %d files of the archive are unattested or do not verify", failed, len(entries)))
+	}
+	return nil
+}
+
+// verifyDistribution parses the attestation and verifies it against the
+// distribution named name read from dist.
+func verifyDistribution(ctx context.Context, v *verify.Verifier, data []byte, name string, dist io.Reader) (*verify.VerificationResult, error) {
+	attestation, err := convert.UnmarshalAttestation(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing attestation: %w", err)
+	}
+	return v.VerifyNamed(ctx, attestation, name, dist)
+}
+
+// printVerificationResult writes a human readable summary of the result.
+func printVerificationResult(w io.Writer, name string, result *verify.VerificationResult) {
+	claims := verify.ClaimsFromResult(result)
+	fmt.Fprintf(w, "Verified %s\n", name)
+	fmt.Fprintf(w, "  Identity:   %s\n", result.Identity)
+	fmt.Fprintf(w, "  Issuer:     %s\n", result.Issuer)
+	fmt.Fprintf(w, "  Repository: %s\n", claims.Repository)
+	fmt.Fprintf(w, "  Workflow:   %s\n", claims.Workflow)
+	fmt.Fprintf(w, "  Predicate:  %s\n", result.PredicateType)
+	for _, entry := range result.LogEntries {
+		fmt.Fprintf(w, "  Log entry:  %d at %s\n", entry.LogIndex, entry.IntegratedTime.UTC().Format(time.RFC3339))
+	}
+}
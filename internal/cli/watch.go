@@ -0,0 +1,281 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/distfile"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	"github.com/spf13/cobra"
+)
+
+type watchOptions struct {
+	Sign     bool
+	Verify   bool
+	Interval time.Duration
+	Existing bool
+
+	signOptions   signOptions
+	verifyOptions verifyOptions
+}
+
+// verifyFlags are the flags of the verify command shared by watch.
+var verifyFlags = []string{"repository", "workflow", "issuer", "policy", "trusted-root", "offline"}
+
+// AddFlags adds the watch flags to the command, along with those of the
+// sign command and the publisher flags of the verify command.
+func (o *watchOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&o.Sign, "sign", false, "sign the new distributions")
+	cmd.Flags().BoolVar(&o.Verify, "verify", false, "verify the new distributions against their attestation")
+	cmd.Flags().DurationVar(&o.Interval, "interval", 2*time.Second, "time between two scans of the directory")
+	cmd.Flags().BoolVar(&o.Existing, "existing", false, "also process the distributions present when the watch starts")
+	o.signOptions.AddFlags(cmd)
+
+	shared := &cobra.Command{}
+	o.verifyOptions.AddFlags(shared)
+	for _, name := range verifyFlags {
+		cmd.Flags().AddFlag(shared.Flags().Lookup(name))
+	}
+	o.verifyOptions.Format = formatText
+}
+
+// Validate checks the flag values.
+func (o *watchOptions) Validate() error {
+	if o.Sign == o.Verify {
+		return fmt.Errorf("exactly one of --sign or --verify must be set")
+	}
+	if o.Interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	if o.Sign {
+		return o.signOptions.Validate()
+	}
+	return o.verifyOptions.Validate()
+}
+
+func addWatch(parent *cobra.Command) {
+	opts := &watchOptions{}
+	cmd := &cobra.Command{
+		Use:   "watch DIR (--sign|--verify)",
+		Short: "Sign or verify the distributions dropped in a directory",
+		Long: `Watch scans a directory every --interval and signs, or verifies, each
+wheel and sdist written to it. A file is processed once its size and
+modification time are unchanged between two scans, so build systems can
+keep writing it in place. Files replaced later are processed again.
+
+With --sign, the attestations are written as with the sign command and
+distributions that already have one are skipped unless --overwrite is
+set. The identity token is resolved when the watch starts, set --key for
+jobs that outlive it.
+
+With --verify, a distribution is verified once its DIST.publish.attestation
+sibling is written, against the publisher set with --repository or
+--policy.
+
+Failures are reported and the watch goes on until it is interrupted.`,
+		Example: `  pypi-attestations watch dist/ --sign
+  pypi-attestations watch dist/ --verify --repository pypa/sampleproject --existing`,
+		Args: cobra.ExactArgs(1),
+		PreRunE: func(cmd *cobra.Command, _ []string) error {
+			opts.verifyOptions.Policy = configPolicy(cmd)
+			return opts.Validate()
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runWatch(cmd, opts, args[0])
+		},
+	}
+	opts.AddFlags(cmd)
+	parent.AddCommand(cmd)
+}
+
+func runWatch(cmd *cobra.Command, opts *watchOptions, dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+
+	ctx, stop := signal.NotifyContext(cmd.Context(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	cmd.SetContext(ctx)
+
+	var process func(dist string) error
+	var ready func(w *watcher, dist string) bool
+	if opts.Sign {
+		so := &opts.signOptions
+		if so.Out != "" && so.Out != stdio {
+			if err := os.MkdirAll(so.Out, 0o755); err != nil {
+				return fmt.Errorf("creating output directory: %w", err)
+			}
+		}
+		signer, err := newSigner(cmd, so)
+		if err != nil {
+			return err
+		}
+		process = func(dist string) error {
+			return signDistribution(cmd, signer, so, dist)
+		}
+		ready = func(_ *watcher, dist string) bool {
+			if so.Overwrite || so.Out == stdio {
+				return true
+			}
+			_, err := os.Stat(so.attestationPath(dist))
+			return err != nil
+		}
+	} else {
+		v, err := newVerifier(cmd, opts.verifyOptions.verifierOptions())
+		if err != nil {
+			return err
+		}
+		process = func(dist string) error {
+			return watchVerify(cmd, v, dist)
+		}
+		ready = func(w *watcher, dist string) bool {
+			return w.stable(filepath.Base(dist) + convert.AttestationSuffix)
+		}
+	}
+
+	w := newWatcher(dir)
+	if err := w.scan(); err != nil {
+		return err
+	}
+	if !opts.Existing {
+		w.skipAll()
+	}
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if err := w.scan(); err != nil {
+			return err
+		}
+		for _, name := range w.pending() {
+			dist := filepath.Join(dir, name)
+			if !ready(w, dist) {
+				continue
+			}
+			w.markDone(name)
+			if err := process(dist); err != nil {
+				if ctx.Err() != nil {
+					return nil
+				}
+				fmt.Fprintf(cmd.ErrOrStderr(), "FAIL %s: %v\n", name, err)
+			}
+		}
+	}
+}
+
+// watchVerify verifies dist against its publish attestation.
+func watchVerify(cmd *cobra.Command, v *verify.Verifier, dist string) error {
+	data, err := os.ReadFile(dist + convert.AttestationSuffix)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(dist)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	result, err := verifyDistribution(cmd.Context(), v, data, filepath.Base(dist), f)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(cmd.OutOrStdout(), "OK   %s (%s)\n", filepath.Base(dist), result.Identity)
+	return nil
+}
+
+// fileState is what a scan records of a file to tell when it is no longer
+// written.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// watcher tracks the files of a directory across scans.
+type watcher struct {
+	dir      string
+	previous map[string]fileState
+	current  map[string]fileState
+	done     map[string]fileState
+}
+
+func newWatcher(dir string) *watcher {
+	return &watcher{dir: dir, done: map[string]fileState{}}
+}
+
+// scan records the state of the regular files of the directory.
+func (w *watcher) scan() error {
+	entries, err := os.ReadDir(w.dir)
+	if err != nil {
+		return err
+	}
+	w.previous, w.current = w.current, map[string]fileState{}
+	for _, e := range entries {
+		if !e.Type().IsRegular() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			// Removed since the directory was read
+			continue
+		}
+		w.current[e.Name()] = fileState{size: info.Size(), modTime: info.ModTime()}
+	}
+	return nil
+}
+
+// stable returns whether the file is unchanged since the previous scan.
+func (w *watcher) stable(name string) bool {
+	cur, ok := w.current[name]
+	if !ok {
+		return false
+	}
+	prev, ok := w.previous[name]
+	return ok && prev == cur
+}
+
+// pending returns the stable distributions not processed in their current
+// state, sorted by name.
+func (w *watcher) pending() []string {
+	var names []string
+	for name, state := range w.current {
+		if strings.HasSuffix(name, ".attestation") || !w.stable(name) {
+			continue
+		}
+		if done, ok := w.done[name]; ok && done == state {
+			continue
+		}
+		if _, err := distfile.Parse(name); err != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// markDone records that a file was processed in its current state.
+func (w *watcher) markDone(name string) {
+	w.done[name] = w.current[name]
+}
+
+// skipAll marks the files of the last scan as processed.
+func (w *watcher) skipAll() {
+	for name := range w.current {
+		w.markDone(name)
+	}
+}
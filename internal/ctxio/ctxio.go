@@ -0,0 +1,52 @@
+// Package ctxio makes blocking reads and calls to APIs without a context
+// honor the cancellation of a context.
+package ctxio
+
+import (
+	"context"
+	"io"
+)
+
+// Reader is an io.Reader failing with the context error once the context
+// is done. Reads already in progress are not interrupted, so long copies
+// stop at the next read.
+type Reader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// NewReader returns a reader of r checking ctx before every read.
+func NewReader(ctx context.Context, r io.Reader) *Reader {
+	return &Reader{ctx: ctx, r: r}
+}
+
+// Read reads from the wrapped reader unless the context is done.
+func (r *Reader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
+
+// Run calls fn and returns its result, or the context error if ctx is done
+// first. fn is left running until it returns and its result is discarded,
+// so it must not hold resources the caller releases.
+func Run[T any](ctx context.Context, fn func() (T, error)) (T, error) {
+	type result struct {
+		v   T
+		err error
+	}
+	done := make(chan result, 1)
+	go func() {
+		v, err := fn()
+		done <- result{v, err}
+	}()
+
+	select {
+	case <-ctx.Done():
+		var zero T
+		return zero, ctx.Err()
+	case res := <-done:
+		return res.v, res.err
+	}
+}
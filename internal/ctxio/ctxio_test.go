@@ -0,0 +1,37 @@
+package ctxio
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReader(t *testing.T) {
+	data, err := io.ReadAll(NewReader(context.Background(), strings.NewReader("data")))
+	if err != nil || string(data) != "data" {
+		t.Errorf("Unexpected read: %q %v", data, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := io.ReadAll(NewReader(ctx, strings.NewReader("data"))); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the read to be canceled, got %v", err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	v, err := Run(context.Background(), func() (int, error) { return 1, nil })
+	if v != 1 || err != nil {
+		t.Errorf("Unexpected result: %d %v", v, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	block := make(chan struct{})
+	defer close(block)
+	cancel()
+	if _, err := Run(ctx, func() (int, error) { <-block; return 1, nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the call to be canceled, got %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+// Command genroots refreshes the trusted roots embedded in pkg/verify. It
+// fetches trusted_root.json from the TUF repository of each Sigstore
+// instance, verified with the TUF root shipped in sigstore-go, and writes
+// it to <out>/<instance>.json.
+//
+// Run it with go generate ./pkg/verify and bump EmbeddedRootsVersion.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tuf"
+)
+
+// instances maps the embedded root names to their TUF repositories.
+var instances = map[string]*tuf.Options{
+	"production": tuf.DefaultOptions(),
+	"staging":    tuf.DefaultOptions().WithRoot(tuf.StagingRoot()).WithRepositoryBaseURL(tuf.StagingMirror),
+}
+
+func main() {
+	out := flag.String("out", "trustedroots", "directory to write the trusted roots to")
+	flag.Parse()
+	for name, opts := range instances {
+		if err := refresh(name, opts.WithDisableLocalCache(), *out); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+			os.Exit(1)
+		}
+	}
+}
+
+func refresh(name string, opts *tuf.Options, out string) error {
+	client, err := tuf.New(opts)
+	if err != nil {
+		return fmt.Errorf("creating TUF client: %w", err)
+	}
+	data, err := client.GetTarget("trusted_root.json")
+	if err != nil {
+		return fmt.Errorf("fetching trusted root: %w", err)
+	}
+	if _, err := root.NewTrustedRootFromJSON(data); err != nil {
+		return fmt.Errorf("parsing trusted root: %w", err)
+	}
+	return os.WriteFile(filepath.Join(out, name+".json"), data, 0o644)
+}
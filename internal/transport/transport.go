@@ -0,0 +1,85 @@
+// Package transport builds the HTTP clients used by the network-facing
+// packages of the module.
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// Config describes how to reach remote services.
+type Config struct {
+	// Proxy is the URL of the proxy to use. When empty, the proxy is read
+	// from the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string
+
+	// RootCAs is the pool of CAs trusted for TLS connections. When nil,
+	// the system pool is used.
+	RootCAs *x509.CertPool
+
+	// RoundTripper replaces the transport entirely. It cannot be combined
+	// with the other settings.
+	RoundTripper http.RoundTripper
+
+	// MaxConnsPerHost caps the number of connections to each host.
+	MaxConnsPerHost int
+}
+
+// IsZero returns true if the configuration does not change the defaults.
+func (c *Config) IsZero() bool {
+	return c.Proxy == "" && c.RootCAs == nil && c.RoundTripper == nil && c.MaxConnsPerHost == 0
+}
+
+// Client returns an HTTP client for the configuration, or nil when it does
+// not change the defaults.
+func (c *Config) Client() (*http.Client, error) {
+	if c.IsZero() {
+		return nil, nil
+	}
+
+	if c.RoundTripper != nil {
+		if c.Proxy != "" || c.RootCAs != nil || c.MaxConnsPerHost != 0 {
+			return nil, fmt.Errorf("a custom round tripper cannot be combined with proxy, CA or connection settings")
+		}
+		return &http.Client{Transport: c.RoundTripper}, nil
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if c.Proxy != "" {
+		u, err := url.Parse(c.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("parsing proxy URL: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+	if c.RootCAs != nil {
+		transport.TLSClientConfig = &tls.Config{
+			RootCAs:    c.RootCAs,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+	transport.MaxConnsPerHost = c.MaxConnsPerHost
+	return &http.Client{Transport: transport}, nil
+}
+
+// LoadCertPool returns the system CA pool with the PEM certificates of the
+// file at path added to it.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading CA file: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
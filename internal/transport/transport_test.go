@@ -0,0 +1,50 @@
+package transport
+
+import (
+	"crypto/x509"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestClient(t *testing.T) {
+	c := Config{}
+	client, err := c.Client()
+	if err != nil || client != nil {
+		t.Errorf("Expected no client for empty config: %v %v", client, err)
+	}
+
+	c = Config{Proxy: "http://proxy.example.com:3128", RootCAs: x509.NewCertPool(), MaxConnsPerHost: 2}
+	client, err = c.Client()
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	tr, ok := client.Transport.(*http.Transport)
+	if !ok {
+		t.Fatalf("Unexpected transport type %T", client.Transport)
+	}
+	proxy, err := tr.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "pypi.org"}})
+	if err != nil || proxy.String() != "http://proxy.example.com:3128" {
+		t.Errorf("Unexpected proxy: %v %v", proxy, err)
+	}
+	if tr.TLSClientConfig.RootCAs != c.RootCAs || tr.MaxConnsPerHost != 2 {
+		t.Error("Transport does not match the configuration")
+	}
+
+	c = Config{RoundTripper: http.DefaultTransport, Proxy: "http://proxy.example.com"}
+	if _, err := c.Client(); err == nil {
+		t.Error("Expected error combining round tripper and proxy")
+	}
+}
+
+func TestLoadCertPool(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(path, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := LoadCertPool(path); err == nil {
+		t.Error("Expected error for file without certificates")
+	}
+}
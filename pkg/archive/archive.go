@@ -0,0 +1,287 @@
+// Package archive packages distributions, their attestations and
+// provenance objects and a pinned Sigstore trusted root into a single
+// gzipped tarball described by a manifest, to move them to networks
+// without access to the index or Sigstore and verify them there.
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/internal/ctxio"
+)
+
+// Names of the archive members with a fixed path.
+const (
+	ManifestName    = "manifest.json"
+	TrustedRootName = "trusted_root.json"
+)
+
+// SchemaVersion is the version of the manifest format.
+const SchemaVersion = "v1"
+
+// Type is the type of an archive member.
+type Type string
+
+const (
+	TypeDistribution Type = "distribution"
+	TypeAttestation  Type = "attestation"
+	TypeProvenance   Type = "provenance"
+	TypeTrustedRoot  Type = "trusted-root"
+)
+
+// ErrIntegrity is returned when the archive members do not match the
+// manifest.
+var ErrIntegrity = errors.New("archive does not match its manifest")
+
+// Manifest lists the members of an archive.
+type Manifest struct {
+	SchemaVersion string    `json:"schemaVersion"`
+	Created       time.Time `json:"created"`
+	Entries       []Entry   `json:"entries"`
+}
+
+// Entry describes an archive member.
+type Entry struct {
+	// Path is the slash separated path of the member in the archive.
+	Path string `json:"path"`
+	Type Type   `json:"type"`
+
+	// SHA256 is the hex encoded digest of the member.
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+
+	// Distribution is the filename of the distribution an attestation or
+	// provenance object refers to.
+	Distribution string `json:"distribution,omitempty"`
+
+	// DistributionSHA256 is the digest of the distribution published by
+	// the index, set on provenance objects exported without their file.
+	DistributionSHA256 string `json:"distributionSha256,omitempty"`
+}
+
+// Writer writes an archive. The manifest is written by Close.
+type Writer struct {
+	gz       *gzip.Writer
+	tw       *tar.Writer
+	manifest Manifest
+	paths    map[string]bool
+}
+
+// NewWriter returns a writer of an archive to w.
+func NewWriter(w io.Writer) *Writer {
+	gz := gzip.NewWriter(w)
+	return &Writer{
+		gz:       gz,
+		tw:       tar.NewWriter(gz),
+		manifest: Manifest{SchemaVersion: SchemaVersion, Created: time.Now().UTC()},
+		paths:    map[string]bool{},
+	}
+}
+
+// Add writes the data of a member described by entry, filling its digest
+// and size.
+func (w *Writer) Add(entry Entry, data []byte) error {
+	return w.add(entry, int64(len(data)), bytes.NewReader(data))
+}
+
+// AddFile writes the file at src as a member described by entry, filling
+// its digest and size.
+func (w *Writer) AddFile(entry Entry, src string) error {
+	f, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	return w.add(entry, info.Size(), f)
+}
+
+func (w *Writer) add(entry Entry, size int64, r io.Reader) error {
+	if err := checkPath(entry.Path); err != nil {
+		return err
+	}
+	if entry.Path == ManifestName || w.paths[entry.Path] {
+		return fmt.Errorf("duplicate archive member %s", entry.Path)
+	}
+
+	hdr := &tar.Header{
+		Name:    entry.Path,
+		Mode:    0o644,
+		Size:    size,
+		ModTime: w.manifest.Created,
+	}
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("writing %s: %w", entry.Path, err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(w.tw, io.TeeReader(r, h)); err != nil {
+		return fmt.Errorf("writing %s: %w", entry.Path, err)
+	}
+
+	entry.SHA256 = hex.EncodeToString(h.Sum(nil))
+	entry.Size = size
+	w.paths[entry.Path] = true
+	w.manifest.Entries = append(w.manifest.Entries, entry)
+	return nil
+}
+
+// Manifest returns the manifest of the members written so far.
+func (w *Writer) Manifest() Manifest {
+	return w.manifest
+}
+
+// Close writes the manifest and flushes the archive. It does not close
+// the underlying writer.
+func (w *Writer) Close() error {
+	data, err := json.MarshalIndent(w.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Name:    ManifestName,
+		Mode:    0o644,
+		Size:    int64(len(data)),
+		ModTime: w.manifest.Created,
+	}
+	if err := w.tw.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if _, err := w.tw.Write(data); err != nil {
+		return err
+	}
+	if err := w.tw.Close(); err != nil {
+		return err
+	}
+	return w.gz.Close()
+}
+
+// Extract writes the members of the archive read from r under dir and
+// checks them against the manifest: every member must be listed with its
+// digest and every entry must be present. The manifest is returned only
+// when the archive is intact.
+func Extract(r io.Reader, dir string) (*Manifest, error) {
+	return ExtractContext(context.Background(), r, dir)
+}
+
+// ExtractContext is Extract stopping when ctx is done. Members already
+// written are left in dir.
+func ExtractContext(ctx context.Context, r io.Reader, dir string) (*Manifest, error) {
+	gz, err := gzip.NewReader(ctxio.NewReader(ctx, r))
+	if err != nil {
+		return nil, fmt.Errorf("reading archive: %w", err)
+	}
+	defer gz.Close()
+
+	var manifest *Manifest
+	digests := map[string]string{}
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading archive: %w", err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil, fmt.Errorf("%w: %s is not a regular file", ErrIntegrity, hdr.Name)
+		}
+		if err := checkPath(hdr.Name); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrIntegrity, err)
+		}
+
+		if hdr.Name == ManifestName {
+			if manifest != nil {
+				return nil, fmt.Errorf("%w: duplicate manifest", ErrIntegrity)
+			}
+			manifest = &Manifest{}
+			if err := json.NewDecoder(tr).Decode(manifest); err != nil {
+				return nil, fmt.Errorf("parsing manifest: %w", err)
+			}
+			continue
+		}
+		if _, ok := digests[hdr.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate member %s", ErrIntegrity, hdr.Name)
+		}
+		if digests[hdr.Name], err = extractFile(tr, filepath.Join(dir, filepath.FromSlash(hdr.Name))); err != nil {
+			return nil, err
+		}
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("%w: %s is missing", ErrIntegrity, ManifestName)
+	}
+	if manifest.SchemaVersion != SchemaVersion {
+		return nil, fmt.Errorf("unsupported manifest version %q", manifest.SchemaVersion)
+	}
+	for _, e := range manifest.Entries {
+		got, ok := digests[e.Path]
+		switch {
+		case !ok:
+			return nil, fmt.Errorf("%w: %s is missing", ErrIntegrity, e.Path)
+		case !strings.EqualFold(got, e.SHA256):
+			return nil, fmt.Errorf("%w: digest of %s is %s, expected %s", ErrIntegrity, e.Path, got, e.SHA256)
+		}
+		delete(digests, e.Path)
+	}
+	for name := range digests {
+		return nil, fmt.Errorf("%w: %s is not listed", ErrIntegrity, name)
+	}
+	return manifest, nil
+}
+
+// extractFile writes the member read from r to dst, returning its digest.
+func extractFile(r io.Reader, dst string) (string, error) {
+	if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+		return "", err
+	}
+	f, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(f, io.TeeReader(r, h)); err != nil {
+		f.Close()
+		return "", fmt.Errorf("extracting %s: %w", dst, err)
+	}
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// checkPath rejects member paths that are absolute or escape the archive.
+func checkPath(p string) error {
+	if p == "" || path.IsAbs(p) || strings.Contains(p, `\`) || path.Clean(p) != p || p == ".." || strings.HasPrefix(p, "../") {
+		return fmt.Errorf("invalid member path %q", p)
+	}
+	return nil
+}
+
+// Find returns the entries of the given type.
+func (m *Manifest) Find(typ Type) []Entry {
+	var entries []Entry
+	for _, e := range m.Entries {
+		if e.Type == typ {
+			entries = append(entries, e)
+		}
+	}
+	return entries
+}
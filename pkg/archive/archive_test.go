@@ -0,0 +1,96 @@
+package archive
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRoundTrip(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "sampleproject-1.0.tar.gz")
+	if err := os.WriteFile(src, []byte("sdist"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.AddFile(Entry{Path: "dist/sampleproject-1.0.tar.gz", Type: TypeDistribution}, src); err != nil {
+		t.Fatalf("Failed to add file: %v", err)
+	}
+	if err := w.Add(Entry{Path: TrustedRootName, Type: TypeTrustedRoot}, []byte("{}")); err != nil {
+		t.Fatalf("Failed to add data: %v", err)
+	}
+	if err := w.Add(Entry{Path: TrustedRootName, Type: TypeTrustedRoot}, []byte("{}")); err == nil {
+		t.Error("Expected error for a duplicate member")
+	}
+	if err := w.Add(Entry{Path: "../escape", Type: TypeAttestation}, nil); err == nil {
+		t.Error("Expected error for a path escaping the archive")
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Failed to close archive: %v", err)
+	}
+
+	dir := t.TempDir()
+	m, err := Extract(bytes.NewReader(buf.Bytes()), dir)
+	if err != nil {
+		t.Fatalf("Failed to extract archive: %v", err)
+	}
+	if len(m.Entries) != 2 || m.Entries[0].Size != 5 || len(m.Find(TypeTrustedRoot)) != 1 {
+		t.Errorf("Unexpected manifest: %+v", m)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "dist", "sampleproject-1.0.tar.gz"))
+	if err != nil || string(data) != "sdist" {
+		t.Errorf("Unexpected extracted file %q: %v", data, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ExtractContext(ctx, bytes.NewReader(buf.Bytes()), t.TempDir()); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the extraction to be canceled, got %v", err)
+	}
+}
+
+// writeRaw writes a tarball with the given members, bypassing the checks
+// of Writer.
+func writeRaw(t *testing.T, members map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range members {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestExtractIntegrity(t *testing.T) {
+	const manifest = `{"schemaVersion": "v1", "entries": [{"path": "a.attestation", "type": "attestation", "sha256": "0000"}]}`
+	for name, members := range map[string]map[string]string{
+		"no manifest":     {"a.attestation": "{}"},
+		"digest mismatch": {ManifestName: manifest, "a.attestation": "{}"},
+		"missing member":  {ManifestName: manifest},
+		"unlisted member": {ManifestName: `{"schemaVersion": "v1"}`, "extra": "data"},
+		"path traversal":  {ManifestName: `{"schemaVersion": "v1"}`, "../extra": "data"},
+	} {
+		_, err := Extract(bytes.NewReader(writeRaw(t, members)), t.TempDir())
+		if !errors.Is(err, ErrIntegrity) {
+			t.Errorf("%s: expected an integrity error, got %v", name, err)
+		}
+	}
+}
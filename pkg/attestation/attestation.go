@@ -0,0 +1,106 @@
+// Package attestation wraps PEP 740 attestations with accessors to the
+// contents of their signed statement.
+package attestation
+
+import (
+	"crypto/x509"
+	"fmt"
+	"sync"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/certinfo"
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/statement"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	intoto "github.com/in-toto/attestation/go/v1"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+)
+
+// Attestation is a PEP 740 attestation. The statement and the signing
+// certificate are parsed on first access and cached, so the attestation
+// must not be modified afterwards.
+type Attestation struct {
+	*pb.Attestation
+
+	statementOnce sync.Once
+	statement     *intoto.Statement
+	statementErr  error
+
+	certOnce sync.Once
+	cert     *x509.Certificate
+	certInfo *certinfo.Info
+	certErr  error
+}
+
+// New wraps a parsed attestation.
+func New(a *pb.Attestation) *Attestation {
+	return &Attestation{Attestation: a}
+}
+
+// Parse unmarshals a PEP 740 JSON attestation, see
+// convert.UnmarshalAttestation.
+func Parse(data []byte, funcs ...convert.ConvertOption) (*Attestation, error) {
+	a, err := convert.UnmarshalAttestation(data, funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return New(a), nil
+}
+
+// Statement returns the in-toto statement of the attestation envelope.
+func (a *Attestation) Statement() (*intoto.Statement, error) {
+	a.statementOnce.Do(func() {
+		a.statement, a.statementErr = statement.ParseStatement(a.GetEnvelope().GetStatement())
+	})
+	return a.statement, a.statementErr
+}
+
+// PredicateType returns the predicate type of the statement.
+func (a *Attestation) PredicateType() (string, error) {
+	s, err := a.Statement()
+	if err != nil {
+		return "", err
+	}
+	return s.GetPredicateType(), nil
+}
+
+// Subjects returns the subjects of the statement, with their digests.
+func (a *Attestation) Subjects() ([]*intoto.ResourceDescriptor, error) {
+	s, err := a.Statement()
+	if err != nil {
+		return nil, err
+	}
+	return s.GetSubject(), nil
+}
+
+// Certificate returns the signing certificate of the attestation.
+func (a *Attestation) Certificate() (*x509.Certificate, error) {
+	a.parseCertificate()
+	return a.cert, a.certErr
+}
+
+// CertificateInfo returns the identity and Fulcio extensions of the signing
+// certificate.
+func (a *Attestation) CertificateInfo() (*certinfo.Info, error) {
+	a.parseCertificate()
+	return a.certInfo, a.certErr
+}
+
+func (a *Attestation) parseCertificate() {
+	a.certOnce.Do(func() {
+		a.cert, a.certErr = x509.ParseCertificate(a.GetVerificationMaterial().GetCertificate())
+		if a.certErr != nil {
+			a.certErr = fmt.Errorf("parsing certificate: %w", a.certErr)
+			return
+		}
+		a.certInfo, a.certErr = certinfo.FromCertificate(a.cert)
+	})
+}
+
+// ToBundle converts the attestation to a Sigstore bundle reusing the
+// parsed certificate, see convert.ToBundle.
+func (a *Attestation) ToBundle(funcs ...convert.ConvertOption) (*bundle.Bundle, error) {
+	if cert, err := a.Certificate(); err == nil {
+		funcs = append([]convert.ConvertOption{convert.WithCertificate(cert)}, funcs...)
+	}
+	return convert.ToBundle(a.Attestation, funcs...)
+}
@@ -0,0 +1,140 @@
+package attestation
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/statement"
+)
+
+func TestAccessors(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+
+	att, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse attestation: %v", err)
+	}
+
+	predicateType, err := att.PredicateType()
+	if err != nil {
+		t.Fatalf("Failed to get predicate type: %v", err)
+	}
+	if predicateType != "https://docs.pypi.org/attestations/publish/v1" {
+		t.Errorf("Unexpected predicate type: %s", predicateType)
+	}
+
+	subjects, err := att.Subjects()
+	if err != nil {
+		t.Fatalf("Failed to get subjects: %v", err)
+	}
+	if len(subjects) != 1 {
+		t.Fatalf("Expected 1 subject, got %d", len(subjects))
+	}
+	if subjects[0].GetName() != "pypi_attestations-0.0.28.tar.gz" {
+		t.Errorf("Unexpected subject name: %s", subjects[0].GetName())
+	}
+	if subjects[0].GetDigest()["sha256"] != "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f" {
+		t.Errorf("Unexpected subject digest: %v", subjects[0].GetDigest())
+	}
+
+	att.Envelope.Statement = []byte("{}")
+	if _, err := New(att.Attestation).PredicateType(); err == nil {
+		t.Error("Expected error for an invalid statement")
+	}
+}
+
+func TestCertificate(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+
+	att, err := Parse(data)
+	if err != nil {
+		t.Fatalf("Failed to parse attestation: %v", err)
+	}
+
+	cert, err := att.Certificate()
+	if err != nil {
+		t.Fatalf("Failed to get certificate: %v", err)
+	}
+	if again, _ := att.Certificate(); again != cert {
+		t.Error("Expected the certificate to be cached")
+	}
+	if !bytes.Equal(cert.Raw, att.GetVerificationMaterial().GetCertificate()) {
+		t.Error("Certificate does not match the attestation")
+	}
+
+	info, err := att.CertificateInfo()
+	if err != nil {
+		t.Fatalf("Failed to get certificate info: %v", err)
+	}
+	if info.Issuer() != "https://token.actions.githubusercontent.com" {
+		t.Errorf("Unexpected issuer: %s", info.Issuer())
+	}
+
+	b, err := att.ToBundle()
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	if !bytes.Equal(b.GetVerificationMaterial().GetCertificate().GetRawBytes(), cert.Raw) {
+		t.Error("Bundle certificate does not match the attestation")
+	}
+
+	att.VerificationMaterial.Certificate = []byte("invalid")
+	invalid := New(att.Attestation)
+	if _, err := invalid.Certificate(); err == nil {
+		t.Error("Expected error for an invalid certificate")
+	}
+	if _, err := invalid.ToBundle(); err == nil {
+		t.Error("Expected error converting an invalid certificate")
+	}
+}
+
+func TestGroup(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.provenance.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	provenance, err := convert.UnmarshalProvenance(data)
+	if err != nil {
+		t.Fatalf("Failed to parse provenance: %v", err)
+	}
+
+	attestations := FromProvenance(provenance)
+	if len(attestations) == 0 {
+		t.Fatal("Expected attestations in the provenance")
+	}
+
+	groups, err := Group(attestations)
+	if err != nil {
+		t.Fatalf("Failed to group attestations: %v", err)
+	}
+	if len(groups[statement.PublishPredicateType]) != len(attestations) {
+		t.Errorf("Expected all attestations to be publish attestations, got %v", groups)
+	}
+
+	slsa, err := Filter(attestations, statement.SLSAProvenancePredicateType)
+	if err != nil {
+		t.Fatalf("Failed to filter attestations: %v", err)
+	}
+	if len(slsa) != 0 {
+		t.Errorf("Expected no SLSA provenance, got %d", len(slsa))
+	}
+
+	if err := Require(attestations, Exactly(statement.PublishPredicateType, 1), Optional(statement.SLSAProvenancePredicateType)); err != nil {
+		t.Errorf("Unexpected requirement error: %v", err)
+	}
+	if err := Require(attestations, Exactly(statement.SLSAProvenancePredicateType, 1)); err == nil {
+		t.Error("Expected error for a missing SLSA provenance")
+	}
+	if err := Require(append(attestations, attestations...), Exactly(statement.PublishPredicateType, 1)); err == nil {
+		t.Error("Expected error for duplicated publish attestations")
+	}
+}
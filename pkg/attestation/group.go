@@ -0,0 +1,95 @@
+package attestation
+
+import (
+	"errors"
+	"fmt"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// Wrap wraps a list of parsed attestations.
+func Wrap(attestations []*pb.Attestation) []*Attestation {
+	ret := make([]*Attestation, len(attestations))
+	for i, a := range attestations {
+		ret[i] = New(a)
+	}
+	return ret
+}
+
+// FromProvenance returns the attestations of all the bundles of a PEP 740
+// provenance object.
+func FromProvenance(provenance *pb.Provenance) []*Attestation {
+	var ret []*Attestation
+	for _, b := range provenance.GetAttestationBundles() {
+		ret = append(ret, Wrap(b.GetAttestations())...)
+	}
+	return ret
+}
+
+// Group groups attestations by predicate type, keeping their order. It
+// fails if a statement cannot be parsed.
+func Group(attestations []*Attestation) (map[string][]*Attestation, error) {
+	groups := map[string][]*Attestation{}
+	for i, a := range attestations {
+		t, err := a.PredicateType()
+		if err != nil {
+			return nil, fmt.Errorf("attestation %d: %w", i, err)
+		}
+		groups[t] = append(groups[t], a)
+	}
+	return groups, nil
+}
+
+// Filter returns the attestations with the given predicate type. It fails
+// if a statement cannot be parsed.
+func Filter(attestations []*Attestation, predicateType string) ([]*Attestation, error) {
+	groups, err := Group(attestations)
+	if err != nil {
+		return nil, err
+	}
+	return groups[predicateType], nil
+}
+
+// Requirement is the number of attestations of a predicate type expected
+// for a file.
+type Requirement struct {
+	PredicateType string
+
+	// Min and Max bound the number of attestations, a Max of zero means
+	// no upper bound.
+	Min int
+	Max int
+}
+
+// Exactly returns a requirement of exactly n attestations of a type.
+func Exactly(predicateType string, n int) Requirement {
+	return Requirement{PredicateType: predicateType, Min: n, Max: n}
+}
+
+// Optional returns a requirement of at most one attestation of a type.
+func Optional(predicateType string) Requirement {
+	return Requirement{PredicateType: predicateType, Min: 0, Max: 1}
+}
+
+// Require checks the number of attestations of each required predicate
+// type, eg exactly one publish attestation and optionally one SLSA
+// provenance. Predicate types without a requirement are ignored. All the
+// unmet requirements are returned joined.
+func Require(attestations []*Attestation, requirements ...Requirement) error {
+	groups, err := Group(attestations)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, r := range requirements {
+		n := len(groups[r.PredicateType])
+		switch {
+		case n < r.Min:
+			errs = append(errs, fmt.Errorf("%s: expected at least %d attestations, found %d", r.PredicateType, r.Min, n))
+		case r.Max > 0 && n > r.Max:
+			errs = append(errs, fmt.Errorf("%s: expected at most %d attestations, found %d", r.PredicateType, r.Max, n))
+		}
+	}
+	return errors.Join(errs...)
+}
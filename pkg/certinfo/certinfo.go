@@ -0,0 +1,206 @@
+// Package certinfo decodes the Fulcio extensions of Sigstore signing
+// certificates into named fields for reports and inspection output.
+package certinfo
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sigstore/sigstore-go/pkg/fulcio/certificate"
+)
+
+// OIDFulcioPrefix is the arc of the Fulcio certificate extensions.
+var OIDFulcioPrefix = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1}
+
+// names maps the last arc of the Fulcio OIDs to their documented names.
+var names = map[int]string{
+	1:  "Issuer (deprecated)",
+	2:  "GitHub Workflow Trigger (deprecated)",
+	3:  "GitHub Workflow SHA (deprecated)",
+	4:  "GitHub Workflow Name (deprecated)",
+	5:  "GitHub Workflow Repository (deprecated)",
+	6:  "GitHub Workflow Ref (deprecated)",
+	7:  "OtherName SAN",
+	8:  "Issuer",
+	9:  "Build Signer URI",
+	10: "Build Signer Digest",
+	11: "Runner Environment",
+	12: "Source Repository URI",
+	13: "Source Repository Digest",
+	14: "Source Repository Ref",
+	15: "Source Repository Identifier",
+	16: "Source Repository Owner URI",
+	17: "Source Repository Owner Identifier",
+	18: "Build Config URI",
+	19: "Build Config Digest",
+	20: "Build Trigger",
+	21: "Run Invocation URI",
+	22: "Source Repository Visibility At Signing",
+}
+
+// Extension is a Fulcio extension of a certificate.
+type Extension struct {
+	// OID is the dotted object identifier of the extension.
+	OID string `json:"oid"`
+
+	// Name is the human readable name of the extension, empty when the OID
+	// is not known.
+	Name string `json:"name,omitempty"`
+
+	// Value is the decoded value of the extension. Values that are not
+	// strings are hex encoded.
+	Value string `json:"value"`
+}
+
+// Info describes a Fulcio issued signing certificate.
+type Info struct {
+	// SubjectAlternativeName is the identity the certificate was issued to.
+	SubjectAlternativeName string `json:"subjectAlternativeName"`
+
+	// SerialNumber is the hex encoded serial number of the certificate.
+	SerialNumber string `json:"serialNumber"`
+
+	NotBefore time.Time `json:"notBefore"`
+	NotAfter  time.Time `json:"notAfter"`
+
+	// Fulcio holds the known Fulcio extensions as typed fields.
+	Fulcio certificate.Extensions `json:"extensions"`
+
+	// RunID is the CI run identifier parsed from the run invocation URI.
+	RunID string `json:"runId,omitempty"`
+
+	// Fields lists every Fulcio extension of the certificate, including
+	// unknown ones, in certificate order.
+	Fields []Extension `json:"fields"`
+}
+
+// Issuer returns the OIDC issuer of the certificate.
+func (i *Info) Issuer() string { return i.Fulcio.Issuer }
+
+// BuildTrigger returns the event that triggered the signing workflow.
+func (i *Info) BuildTrigger() string {
+	if i.Fulcio.BuildTrigger != "" {
+		return i.Fulcio.BuildTrigger
+	}
+	return i.Fulcio.GithubWorkflowTrigger
+}
+
+// SHA returns the source repository commit that was built.
+func (i *Info) SHA() string {
+	if i.Fulcio.SourceRepositoryDigest != "" {
+		return i.Fulcio.SourceRepositoryDigest
+	}
+	return i.Fulcio.GithubWorkflowSHA
+}
+
+// SourceRef returns the source repository ref that was built.
+func (i *Info) SourceRef() string {
+	if i.Fulcio.SourceRepositoryRef != "" {
+		return i.Fulcio.SourceRepositoryRef
+	}
+	return i.Fulcio.GithubWorkflowRef
+}
+
+// Parse decodes a PEM or DER encoded leaf certificate.
+func Parse(data []byte) (*Info, error) {
+	if block, _ := pem.Decode(data); block != nil {
+		data = block.Bytes
+	}
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate: %w", err)
+	}
+	return FromCertificate(cert)
+}
+
+// FromCertificate decodes the Fulcio extensions of cert.
+func FromCertificate(cert *x509.Certificate) (*Info, error) {
+	if cert == nil {
+		return nil, fmt.Errorf("certificate cannot be nil")
+	}
+
+	ext, err := certificate.ParseExtensions(cert.Extensions)
+	if err != nil {
+		return nil, fmt.Errorf("parsing Fulcio extensions: %w", err)
+	}
+
+	info := &Info{
+		SubjectAlternativeName: subjectAlternativeName(cert),
+		SerialNumber:           hex.EncodeToString(cert.SerialNumber.Bytes()),
+		NotBefore:              cert.NotBefore,
+		NotAfter:               cert.NotAfter,
+		Fulcio:                 ext,
+		RunID:                  runID(ext.RunInvocationURI),
+		Fields:                 []Extension{},
+	}
+
+	for _, e := range cert.Extensions {
+		if !isFulcio(e.Id) {
+			continue
+		}
+		info.Fields = append(info.Fields, Extension{
+			OID:   e.Id.String(),
+			Name:  Name(e.Id),
+			Value: decodeValue(e.Id, e.Value),
+		})
+	}
+	return info, nil
+}
+
+// Name returns the human readable name of a Fulcio OID, or an empty string
+// if it is not known.
+func Name(oid asn1.ObjectIdentifier) string {
+	if !isFulcio(oid) {
+		return ""
+	}
+	return names[oid[len(OIDFulcioPrefix)]]
+}
+
+// isFulcio returns true if oid is a direct child of the Fulcio arc.
+func isFulcio(oid asn1.ObjectIdentifier) bool {
+	return len(oid) == len(OIDFulcioPrefix)+1 && oid[:len(OIDFulcioPrefix)].Equal(OIDFulcioPrefix)
+}
+
+// decodeValue returns the string value of an extension. The deprecated
+// extensions (1 to 6) hold raw strings, later ones are DER encoded.
+func decodeValue(oid asn1.ObjectIdentifier, value []byte) string {
+	if oid[len(OIDFulcioPrefix)] > 6 {
+		var s string
+		if rest, err := asn1.Unmarshal(value, &s); err == nil && len(rest) == 0 {
+			return s
+		}
+	} else if utf8.Valid(value) {
+		return string(value)
+	}
+	return hex.EncodeToString(value)
+}
+
+// subjectAlternativeName returns the first SAN of the certificate.
+func subjectAlternativeName(cert *x509.Certificate) string {
+	switch {
+	case len(cert.URIs) > 0:
+		return cert.URIs[0].String()
+	case len(cert.EmailAddresses) > 0:
+		return cert.EmailAddresses[0]
+	case len(cert.DNSNames) > 0:
+		return cert.DNSNames[0]
+	}
+	return ""
+}
+
+// runID extracts the run identifier from a GitHub Actions run invocation
+// URI (https://github.com/{repo}/actions/runs/{id}/attempts/{n}).
+func runID(uri string) string {
+	_, rest, ok := strings.Cut(uri, "/actions/runs/")
+	if !ok {
+		return ""
+	}
+	id, _, _ := strings.Cut(rest, "/")
+	return id
+}
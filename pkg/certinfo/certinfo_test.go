@@ -0,0 +1,78 @@
+package certinfo
+
+import (
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/sigstore/sigstore-go/pkg/fulcio/certificate"
+)
+
+func loadTestCertificate(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := convert.UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	return attestation.VerificationMaterial.Certificate
+}
+
+func TestParse(t *testing.T) {
+	der := loadTestCertificate(t)
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"der", der},
+		{"pem", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := Parse(tc.data)
+			if err != nil {
+				t.Fatalf("Failed to parse certificate: %v", err)
+			}
+			if info.Issuer() != "https://token.actions.githubusercontent.com" {
+				t.Errorf("Unexpected issuer: %s", info.Issuer())
+			}
+			if info.SourceRef() != "refs/tags/v0.0.28" {
+				t.Errorf("Unexpected source ref: %s", info.SourceRef())
+			}
+			if info.BuildTrigger() == "" || info.SHA() == "" || info.RunID == "" {
+				t.Errorf("Expected trigger, SHA and run ID: %+v", info)
+			}
+			if len(info.Fields) == 0 {
+				t.Fatal("Expected Fulcio extension fields")
+			}
+			for _, f := range info.Fields {
+				if f.OID == certificate.OIDSourceRepositoryURI.String() {
+					if f.Name != "Source Repository URI" || f.Value != "https://github.com/pypi/pypi-attestations" {
+						t.Errorf("Unexpected source repository field: %+v", f)
+					}
+				}
+			}
+		})
+	}
+
+	if _, err := Parse([]byte("not a certificate")); err == nil {
+		t.Error("Expected error parsing invalid data")
+	}
+}
+
+func TestName(t *testing.T) {
+	if got := Name(certificate.OIDBuildTrigger); got != "Build Trigger" {
+		t.Errorf("Unexpected name: %s", got)
+	}
+	if got := Name(append(OIDFulcioPrefix[:len(OIDFulcioPrefix):len(OIDFulcioPrefix)], 99)); got != "" {
+		t.Errorf("Expected no name for unknown OID, got %s", got)
+	}
+	if got := Name(certificate.OIDBuildTrigger[:5]); got != "" {
+		t.Errorf("Expected no name for non Fulcio OID, got %s", got)
+	}
+}
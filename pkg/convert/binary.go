@@ -0,0 +1,34 @@
+package convert
+
+import (
+	"fmt"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// MarshalAttestationBinary marshals an Attestation to the protobuf wire
+// format. The encoding is deterministic so the output of the same
+// attestation can be content addressed. Unlike the PEP 740 JSON form it
+// preserves every field of the attestation.
+func MarshalAttestationBinary(attestation *pb.Attestation) ([]byte, error) {
+	if attestation == nil {
+		return nil, fmt.Errorf("attestation cannot be nil")
+	}
+
+	data, err := proto.MarshalOptions{Deterministic: true}.Marshal(attestation)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal attestation: %w", err)
+	}
+	return data, nil
+}
+
+// UnmarshalAttestationBinary unmarshals an Attestation from the protobuf
+// wire format.
+func UnmarshalAttestationBinary(data []byte) (*pb.Attestation, error) {
+	attestation := &pb.Attestation{}
+	if err := proto.Unmarshal(data, attestation); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal attestation: %w", err)
+	}
+	return attestation, nil
+}
@@ -1,11 +1,12 @@
 package convert
 
 import (
-	"crypto/x509"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"strings"
 
+	"github.com/carabiner-dev/pypi-attestations/pkg/mediatype"
 	pb "github.com/carabiner-dev/pypi-attestations/proto"
 	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
 	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
@@ -16,204 +17,340 @@ import (
 	"google.golang.org/protobuf/types/known/structpb"
 )
 
-// ToBundle converts a PyPI attestation (PEP 740) to a Sigstore Bundle.
-func ToBundle(attestation *pb.Attestation) (*bundle.Bundle, error) {
+// ToBundle converts a PyPI attestation (PEP 740) to a Sigstore Bundle. The
+// bundle uses the v0.3 media type unless WithBundleVersion is passed.
+//
+// Bundles before v0.3 carry the intermediate certificates of the
+// attestation in their certificate chain. The v0.3 media type only allows
+// the leaf certificate, so the intermediates are dropped with a warning, or
+// fail the conversion in strict mode, see WithStrict.
+func ToBundle(attestation *pb.Attestation, funcs ...ConvertOption) (*bundle.Bundle, error) {
+	opts, err := newConvertOptions(funcs)
+	if err != nil {
+		return nil, err
+	}
+
 	if attestation == nil {
 		return nil, fmt.Errorf("attestation cannot be nil")
 	}
 
-	if attestation.Version != 1 {
-		return nil, fmt.Errorf("unsupported attestation version: %d", attestation.Version)
+	mediaType, err := mediatype.Bundle(opts.BundleVersion)
+	if err != nil {
+		return nil, err
+	}
+	version := "v" + strings.TrimPrefix(opts.BundleVersion, "v")
+	opts.logger().Debug("converting attestation to bundle", "bundleVersion", version)
+
+	caps, err := VersionCapabilities(attestation.Version)
+	if err != nil {
+		return nil, err
+	}
+	if !caps.Bundle {
+		return nil, fmt.Errorf("%w: version %d cannot be converted to a bundle", ErrUnsupportedVersion, attestation.Version)
 	}
 
 	// Parse the certificate
-	cert, err := x509.ParseCertificate(attestation.VerificationMaterial.Certificate)
+	cert, err := opts.certificate(attestation.VerificationMaterial.Certificate)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCertificate, err)
 	}
 
 	// Create DSSE envelope
 	envelope := &protodsse.Envelope{
 		Payload:     attestation.Envelope.Statement,
-		PayloadType: "application/vnd.in-toto+json",
+		PayloadType: inTotoPayloadType,
 		Signatures: []*protodsse.Signature{
 			{
 				Sig: attestation.Envelope.Signature,
 			},
 		},
 	}
+	for _, sig := range attestation.Envelope.AdditionalSignatures {
+		envelope.Signatures = append(envelope.Signatures, &protodsse.Signature{
+			Sig:   sig.GetSig(),
+			Keyid: sig.GetKeyid(),
+		})
+	}
 
-	// Parse the transparency log entry
+	// Parse the transparency log entries. Attestations logged to more than
+	// one log (or log shard) carry an entry for each.
 	if len(attestation.VerificationMaterial.TransparencyEntries) == 0 {
-		return nil, fmt.Errorf("no transparency entries found")
+		return nil, ErrNoTransparencyEntries
 	}
 
-	tlogEntry, err := transparencyEntryFromStruct(attestation.VerificationMaterial.TransparencyEntries[0])
-	if err != nil {
-		return nil, fmt.Errorf("failed to convert transparency entry: %w", err)
+	tlogEntries := make([]*protorekor.TransparencyLogEntry, len(attestation.VerificationMaterial.TransparencyEntries))
+	for i, s := range attestation.VerificationMaterial.TransparencyEntries {
+		tlogEntries[i], err = TransparencyEntryFromStruct(s)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert transparency entry %d: %w", i, err)
+		}
 	}
 
 	// Create the Sigstore bundle protobuf
 	pbBundle := &protobundle.Bundle{
-		MediaType: "application/vnd.dev.sigstore.bundle.v0.3+json",
+		MediaType: mediaType,
 		VerificationMaterial: &protobundle.VerificationMaterial{
 			Content: &protobundle.VerificationMaterial_Certificate{
 				Certificate: &protocommon.X509Certificate{
 					RawBytes: cert.Raw,
 				},
 			},
-			TlogEntries: []*protorekor.TransparencyLogEntry{tlogEntry},
+			TlogEntries: tlogEntries,
 		},
 		Content: &protobundle.Bundle_DsseEnvelope{
 			DsseEnvelope: envelope,
 		},
 	}
 
+	// Bundles before v0.3 only support certificate chains
+	intermediates := attestation.VerificationMaterial.IntermediateCertificates
+	if version != "v0.3" {
+		chain := &protocommon.X509CertificateChain{
+			Certificates: []*protocommon.X509Certificate{{RawBytes: cert.Raw}},
+		}
+		for _, c := range intermediates {
+			chain.Certificates = append(chain.Certificates, &protocommon.X509Certificate{RawBytes: c})
+		}
+		pbBundle.VerificationMaterial.Content = &protobundle.VerificationMaterial_X509CertificateChain{
+			X509CertificateChain: chain,
+		}
+	} else if len(intermediates) > 0 {
+		if err := opts.lossy(
+			nil, "intermediate_certificates", "%d certificates cannot be represented in %s bundles", len(intermediates), version,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	// Carry over any RFC 3161 timestamps
+	if len(attestation.VerificationMaterial.Rfc3161Timestamps) > 0 {
+		tsData := &protobundle.TimestampVerificationData{}
+		for _, ts := range attestation.VerificationMaterial.Rfc3161Timestamps {
+			tsData.Rfc3161Timestamps = append(tsData.Rfc3161Timestamps, &protocommon.RFC3161SignedTimestamp{
+				SignedTimestamp: ts,
+			})
+		}
+		pbBundle.VerificationMaterial.TimestampVerificationData = tsData
+	}
+
 	// Wrap in bundle.Bundle
 	return bundle.NewBundle(pbBundle)
 }
 
 // FromBundle converts a Sigstore Bundle to a PyPI attestation (PEP 740).
-func FromBundle(b *bundle.Bundle) (*pb.Attestation, error) {
+//
+// PEP 740 attestations hold a single signature with no key ID. The
+// signature selected with WithSignatureIndex (the first by default) becomes
+// the attestation signature, the rest are kept as additional signatures.
+// A key ID on the selected signature is dropped with a warning, or fails
+// the conversion in strict mode.
+func FromBundle(b *bundle.Bundle, funcs ...ConvertOption) (*pb.Attestation, error) {
+	opts, err := newConvertOptions(funcs)
+	if err != nil {
+		return nil, err
+	}
+
 	if b == nil || b.Bundle == nil {
 		return nil, fmt.Errorf("bundle cannot be nil")
 	}
+	opts.logger().Debug("converting bundle to attestation", "mediaType", b.Bundle.GetMediaType())
 
-	// Extract certificate
+	// Extract certificate, keeping the rest of the chain if present
 	var certBytes []byte
+	var intermediates [][]byte
 	switch content := b.Bundle.VerificationMaterial.Content.(type) {
 	case *protobundle.VerificationMaterial_Certificate:
 		certBytes = content.Certificate.RawBytes
 	case *protobundle.VerificationMaterial_X509CertificateChain:
 		if len(content.X509CertificateChain.Certificates) == 0 {
-			return nil, fmt.Errorf("no certificates in chain")
+			return nil, fmt.Errorf("%w: no certificates in chain", ErrInvalidCertificate)
 		}
 		certBytes = content.X509CertificateChain.Certificates[0].RawBytes
+		for _, c := range content.X509CertificateChain.Certificates[1:] {
+			intermediates = append(intermediates, c.RawBytes)
+		}
 	default:
-		return nil, fmt.Errorf("unsupported certificate type")
+		return nil, fmt.Errorf("%w: unsupported certificate type", ErrInvalidCertificate)
 	}
 
-	// Extract DSSE envelope
-	dsseEnvelope, ok := b.Bundle.Content.(*protobundle.Bundle_DsseEnvelope)
-	if !ok {
-		return nil, fmt.Errorf("bundle does not contain a DSSE envelope")
+	// Extract DSSE envelope. Message signatures sign an artifact digest
+	// with no statement, so they have no PEP 740 representation.
+	var dsseEnvelope *protobundle.Bundle_DsseEnvelope
+	switch content := b.Bundle.Content.(type) {
+	case *protobundle.Bundle_DsseEnvelope:
+		dsseEnvelope = content
+	case *protobundle.Bundle_MessageSignature:
+		return nil, fmt.Errorf("%w: bundle contains a message signature", ErrNotDSSE)
+	default:
+		return nil, ErrNotDSSE
 	}
 
-	if len(dsseEnvelope.DsseEnvelope.Signatures) != 1 {
-		return nil, fmt.Errorf("expected exactly one signature, got %d", len(dsseEnvelope.DsseEnvelope.Signatures))
+	signature, additional, err := selectSignature(dsseEnvelope.DsseEnvelope.Signatures, &opts)
+	if err != nil {
+		return nil, err
 	}
 
 	// Convert transparency log entries
 	tlogEntries := make([]*structpb.Struct, len(b.Bundle.VerificationMaterial.TlogEntries))
 	for i, entry := range b.Bundle.VerificationMaterial.TlogEntries {
-		s, err := transparencyEntryToStruct(entry)
+		s, err := TransparencyEntryToStruct(entry)
 		if err != nil {
 			return nil, fmt.Errorf("failed to convert transparency entry %d: %w", i, err)
 		}
 		tlogEntries[i] = s
 	}
 
+	// Preserve RFC 3161 timestamps
+	var timestamps [][]byte
+	for _, ts := range b.Bundle.VerificationMaterial.GetTimestampVerificationData().GetRfc3161Timestamps() {
+		timestamps = append(timestamps, ts.GetSignedTimestamp())
+	}
+
 	attestation := &pb.Attestation{
-		Version: 1,
+		Version: LatestAttestationVersion,
 		VerificationMaterial: &pb.VerificationMaterial{
-			Certificate:         certBytes,
-			TransparencyEntries: tlogEntries,
+			Certificate:              certBytes,
+			TransparencyEntries:      tlogEntries,
+			Rfc3161Timestamps:        timestamps,
+			IntermediateCertificates: intermediates,
 		},
 		Envelope: &pb.Envelope{
-			Statement: dsseEnvelope.DsseEnvelope.Payload,
-			Signature: dsseEnvelope.DsseEnvelope.Signatures[0].Sig,
+			Statement:            dsseEnvelope.DsseEnvelope.Payload,
+			Signature:            signature,
+			AdditionalSignatures: additional,
 		},
 	}
 
 	return attestation, nil
 }
 
-// transparencyEntryToStruct converts a Rekor TransparencyLogEntry to a structpb.Struct.
-func transparencyEntryToStruct(entry *protorekor.TransparencyLogEntry) (*structpb.Struct, error) {
-	// Marshal to JSON
-	jsonBytes, err := protojson.Marshal(entry)
-	if err != nil {
-		return nil, fmt.Errorf("failed to marshal transparency entry to JSON: %w", err)
+// selectSignature returns the DSSE signature picked by the SignatureIndex
+// option and the rest as additional signatures.
+func selectSignature(signatures []*protodsse.Signature, opts *ConvertOptions) ([]byte, []*pb.Signature, error) {
+	if len(signatures) == 0 {
+		return nil, nil, ErrNoSignatures
 	}
-
-	// Unmarshal to map
-	var m map[string]interface{}
-	if err := json.Unmarshal(jsonBytes, &m); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON to map: %w", err)
+	if opts.SignatureIndex < 0 || opts.SignatureIndex >= len(signatures) {
+		return nil, nil, fmt.Errorf("signature index %d out of range, envelope has %d signatures", opts.SignatureIndex, len(signatures))
 	}
-
-	// Convert to structpb.Struct
-	s, err := structpb.NewStruct(m)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create structpb.Struct: %w", err)
+	signature := signatures[opts.SignatureIndex]
+	if signature.Keyid != "" {
+		if err := opts.lossy(nil, "keyid", "signature key ID %q is dropped", signature.Keyid); err != nil {
+			return nil, nil, err
+		}
 	}
 
-	return s, nil
+	var additional []*pb.Signature
+	for i, sig := range signatures {
+		if i == opts.SignatureIndex {
+			continue
+		}
+		additional = append(additional, &pb.Signature{Sig: sig.Sig, Keyid: sig.Keyid})
+	}
+	return signature.Sig, additional, nil
 }
 
-// transparencyEntryFromStruct converts a structpb.Struct to a Rekor TransparencyLogEntry.
-func transparencyEntryFromStruct(s *structpb.Struct) (*protorekor.TransparencyLogEntry, error) {
-	// Marshal to JSON
-	jsonBytes, err := protojson.Marshal(s)
+// TransparencyEntryToStruct converts a Rekor TransparencyLogEntry to a
+// structpb.Struct holding its protojson representation.
+func TransparencyEntryToStruct(entry *protorekor.TransparencyLogEntry) (*structpb.Struct, error) {
+	s, err := messageToStruct(entry.ProtoReflect())
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal struct to JSON: %w", err)
+		return nil, fmt.Errorf("converting transparency entry: %w", err)
 	}
+	return s, nil
+}
 
-	// Unmarshal to TransparencyLogEntry
+// TransparencyEntryFromStruct converts a structpb.Struct to a Rekor
+// TransparencyLogEntry. Unknown fields are discarded so that entries from
+// newer log versions (eg Rekor v2) still convert.
+func TransparencyEntryFromStruct(s *structpb.Struct) (*protorekor.TransparencyLogEntry, error) {
 	var entry protorekor.TransparencyLogEntry
-	if err := protojson.Unmarshal(jsonBytes, &entry); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON to TransparencyLogEntry: %w", err)
+	if err := structToMessage(s, entry.ProtoReflect()); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidTransparencyEntry, err)
 	}
-
 	return &entry, nil
 }
 
-// MarshalBundle marshals a Sigstore Bundle to JSON.
-func MarshalBundle(b *bundle.Bundle) ([]byte, error) {
-	if b == nil || b.Bundle == nil {
-		return nil, fmt.Errorf("bundle cannot be nil")
+// MarshalBundle marshals a Sigstore Bundle to JSON. The output is indented
+// unless WithCanonical is passed. See MarshalBundleTo to reuse buffers.
+func MarshalBundle(b *bundle.Bundle, funcs ...ConvertOption) ([]byte, error) {
+	return MarshalBundleTo(nil, b, funcs...)
+}
+
+// UnmarshalBundle unmarshals JSON to a Sigstore Bundle. The input is
+// subject to the size, depth and transparency entry limits of the options.
+func UnmarshalBundle(data []byte, funcs ...ConvertOption) (*bundle.Bundle, error) {
+	opts, err := newConvertOptions(funcs)
+	if err != nil {
+		return nil, err
 	}
 
-	return protojson.MarshalOptions{
-		Multiline: true,
-		Indent:    "  ",
-	}.Marshal(b.Bundle)
-}
+	if err := opts.checkLimits(data); err != nil {
+		return nil, err
+	}
 
-// UnmarshalBundle unmarshals JSON to a Sigstore Bundle.
-func UnmarshalBundle(data []byte) (*bundle.Bundle, error) {
 	pbBundle := &protobundle.Bundle{}
 	if err := protojson.Unmarshal(data, pbBundle); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal bundle JSON: %w", err)
 	}
+	if err := opts.checkEntries(len(pbBundle.GetVerificationMaterial().GetTlogEntries())); err != nil {
+		return nil, err
+	}
 
 	return bundle.NewBundle(pbBundle)
 }
 
 // MarshalAttestation marshals an Attestation to JSON in PEP 740 format.
-func MarshalAttestation(attestation *pb.Attestation) ([]byte, error) {
-	// Create a map for custom JSON marshaling to handle base64 encoding
-	result := map[string]interface{}{
-		"version": attestation.Version,
-		"verification_material": map[string]interface{}{
-			"certificate":          base64.StdEncoding.EncodeToString(attestation.VerificationMaterial.Certificate),
-			"transparency_entries": attestation.VerificationMaterial.TransparencyEntries,
-		},
-		"envelope": map[string]interface{}{
-			"statement": base64.StdEncoding.EncodeToString(attestation.Envelope.Statement),
-			"signature": base64.StdEncoding.EncodeToString(attestation.Envelope.Signature),
-		},
-	}
-
-	return json.MarshalIndent(result, "", "  ")
+//
+// PEP 740 cannot represent RFC 3161 timestamps, intermediate certificates
+// or additional signatures. If the attestation carries any, they are
+// dropped from the output with a warning, or marshaling fails in strict
+// mode. See MarshalAttestationTo to reuse buffers.
+func MarshalAttestation(attestation *pb.Attestation, funcs ...ConvertOption) ([]byte, error) {
+	return MarshalAttestationTo(nil, attestation, funcs...)
 }
 
 // UnmarshalAttestation unmarshals JSON in PEP 740 format to an Attestation.
-func UnmarshalAttestation(data []byte) (*pb.Attestation, error) {
+// The document is decoded according to its version field, see
+// SupportedVersions.
+//
+// Binary fields are expected in standard padded base64, but base64url and
+// unpadded values are accepted unless WithStrictBase64 is passed. Unknown
+// fields are ignored and missing ones left empty, see
+// UnmarshalAttestationStrict to reject them. The input is subject to the
+// size, depth and transparency entry limits of the options.
+func UnmarshalAttestation(data []byte, funcs ...ConvertOption) (*pb.Attestation, error) {
+	opts, err := newConvertOptions(funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := opts.checkLimits(data); err != nil {
+		return nil, err
+	}
+
+	// Strict decoding checks the fields on the generic maps
+	if !opts.StrictFields {
+		attestation, err := decodeAttestationTyped(data, &opts)
+		if !errors.Is(err, errUntyped) {
+			return attestation, err
+		}
+	}
+
 	var raw map[string]interface{}
 	if err := json.Unmarshal(data, &raw); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
 	}
+	return decodeAttestation(raw, &opts)
+}
+
+// decodeAttestationV1 decodes the JSON of a version 1 attestation.
+func decodeAttestationV1(raw map[string]interface{}, opts *ConvertOptions) (*pb.Attestation, error) {
+	if opts.StrictFields {
+		if err := checkFields("", raw, attestationV1Fields); err != nil {
+			return nil, fmt.Errorf("invalid attestation: %w", err)
+		}
+	}
 
 	attestation := &pb.Attestation{
 		VerificationMaterial: &pb.VerificationMaterial{},
@@ -228,7 +365,7 @@ func UnmarshalAttestation(data []byte) (*pb.Attestation, error) {
 	// Parse verification material
 	if vm, ok := raw["verification_material"].(map[string]interface{}); ok {
 		if certStr, ok := vm["certificate"].(string); ok {
-			cert, err := base64.StdEncoding.DecodeString(certStr)
+			cert, err := opts.decodeBase64(certStr)
 			if err != nil {
 				return nil, fmt.Errorf("failed to decode certificate: %w", err)
 			}
@@ -236,6 +373,9 @@ func UnmarshalAttestation(data []byte) (*pb.Attestation, error) {
 		}
 
 		if entries, ok := vm["transparency_entries"].([]interface{}); ok {
+			if err := opts.checkEntries(len(entries)); err != nil {
+				return nil, err
+			}
 			for _, entry := range entries {
 				if entryMap, ok := entry.(map[string]interface{}); ok {
 					s, err := structpb.NewStruct(entryMap)
@@ -254,7 +394,7 @@ func UnmarshalAttestation(data []byte) (*pb.Attestation, error) {
 	// Parse envelope
 	if env, ok := raw["envelope"].(map[string]interface{}); ok {
 		if stmtStr, ok := env["statement"].(string); ok {
-			stmt, err := base64.StdEncoding.DecodeString(stmtStr)
+			stmt, err := opts.decodeBase64(stmtStr)
 			if err != nil {
 				return nil, fmt.Errorf("failed to decode statement: %w", err)
 			}
@@ -262,7 +402,7 @@ func UnmarshalAttestation(data []byte) (*pb.Attestation, error) {
 		}
 
 		if sigStr, ok := env["signature"].(string); ok {
-			sig, err := base64.StdEncoding.DecodeString(sigStr)
+			sig, err := opts.decodeBase64(sigStr)
 			if err != nil {
 				return nil, fmt.Errorf("failed to decode signature: %w", err)
 			}
@@ -2,14 +2,37 @@ package convert
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/klauspost/compress/zstd"
 	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protodsse "github.com/sigstore/protobuf-specs/gen/pb-go/dsse"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
 	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
 )
 
 func TestUnmarshalAttestation(t *testing.T) {
@@ -365,3 +388,2032 @@ func TestInvalidInputs(t *testing.T) {
 		}
 	})
 }
+
+func TestTransparencyEntryFromStructUnknownFields(t *testing.T) {
+	s, err := structpb.NewStruct(map[string]interface{}{
+		"logIndex": "42",
+		"kindVersion": map[string]interface{}{
+			"kind":    "dsse",
+			"version": "0.0.2",
+		},
+		"someFutureField": "value",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create struct: %v", err)
+	}
+
+	entry, err := TransparencyEntryFromStruct(s)
+	if err != nil {
+		t.Fatalf("Failed to convert entry with unknown fields: %v", err)
+	}
+
+	if entry.GetKindVersion().GetVersion() != "0.0.2" {
+		t.Errorf("Expected kind version 0.0.2, got %s", entry.GetKindVersion().GetVersion())
+	}
+}
+
+func TestTransparencyEntryStruct(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	for _, s := range attestation.VerificationMaterial.TransparencyEntries {
+		// The conversion must match going through protojson
+		jsonBytes, err := protojson.Marshal(s)
+		if err != nil {
+			t.Fatalf("Failed to marshal struct: %v", err)
+		}
+		want := &protorekor.TransparencyLogEntry{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(jsonBytes, want); err != nil {
+			t.Fatalf("Failed to unmarshal entry: %v", err)
+		}
+
+		entry, err := TransparencyEntryFromStruct(s)
+		if err != nil {
+			t.Fatalf("Failed to convert struct: %v", err)
+		}
+		if !proto.Equal(entry, want) {
+			t.Errorf("Entry mismatch:\ngot  %v\nwant %v", entry, want)
+		}
+
+		got, err := TransparencyEntryToStruct(entry)
+		if err != nil {
+			t.Fatalf("Failed to convert entry: %v", err)
+		}
+		jsonBytes, err = protojson.Marshal(want)
+		if err != nil {
+			t.Fatalf("Failed to marshal entry: %v", err)
+		}
+		wantStruct := &structpb.Struct{}
+		if err := protojson.Unmarshal(jsonBytes, wantStruct); err != nil {
+			t.Fatalf("Failed to unmarshal struct: %v", err)
+		}
+		if !proto.Equal(got, wantStruct) {
+			t.Errorf("Struct mismatch:\ngot  %v\nwant %v", got, wantStruct)
+		}
+	}
+
+	for name, fields := range map[string]map[string]interface{}{
+		"bad base64":    {"canonicalizedBody": "not base64!"},
+		"bad integer":   {"logIndex": "forty-two"},
+		"bad type":      {"kindVersion": "dsse"},
+		"bad list item": {"inclusionProof": map[string]interface{}{"hashes": []interface{}{true}}},
+	} {
+		s, err := structpb.NewStruct(fields)
+		if err != nil {
+			t.Fatalf("%s: failed to create struct: %v", name, err)
+		}
+		if _, err := TransparencyEntryFromStruct(s); !errors.Is(err, ErrInvalidTransparencyEntry) {
+			t.Errorf("%s: expected ErrInvalidTransparencyEntry, got %v", name, err)
+		}
+	}
+}
+
+func TestRFC3161TimestampsRoundTrip(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	timestamp := []byte{0x30, 0x03, 0x02, 0x01, 0x01}
+	attestation.VerificationMaterial.Rfc3161Timestamps = [][]byte{timestamp}
+
+	bundle, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+
+	tsData := bundle.Bundle.VerificationMaterial.GetTimestampVerificationData().GetRfc3161Timestamps()
+	if len(tsData) != 1 || !bytes.Equal(tsData[0].GetSignedTimestamp(), timestamp) {
+		t.Fatalf("Timestamp not carried to bundle: %v", tsData)
+	}
+
+	roundTripped, err := FromBundle(bundle)
+	if err != nil {
+		t.Fatalf("Failed to convert from bundle: %v", err)
+	}
+
+	if len(roundTripped.VerificationMaterial.Rfc3161Timestamps) != 1 ||
+		!bytes.Equal(roundTripped.VerificationMaterial.Rfc3161Timestamps[0], timestamp) {
+		t.Error("Timestamp lost after round-trip")
+	}
+
+	if _, err := MarshalAttestation(roundTripped, WithStrict(true)); err == nil {
+		t.Error("Expected error marshaling timestamps to PEP 740 JSON")
+	}
+
+	if _, err := MarshalAttestation(roundTripped); err != nil {
+		t.Errorf("Expected lossy marshal to succeed by default: %v", err)
+	}
+}
+
+func TestValidateSubjectName(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		subject  string
+		filename string
+		mustErr  bool
+	}{
+		{"exact sdist", "pypi_attestations-0.0.28.tar.gz", "pypi_attestations-0.0.28.tar.gz", false},
+		{"normalized sdist", "pypi-attestations-0.0.28.tar.gz", "PyPI.Attestations-0.0.28.tar.gz", false},
+		{"normalized wheel", "pypi_attestations-0.0.28-py3-none-any.whl", "PyPI_Attestations-0.0.28-py3-none-any.whl", false},
+		{"version mismatch", "pypi_attestations-0.0.28.tar.gz", "pypi_attestations-0.0.29.tar.gz", true},
+		{"project mismatch", "other-0.0.28.tar.gz", "pypi_attestations-0.0.28.tar.gz", true},
+		{"wheel tag mismatch", "foo-1.0-py3-none-any.whl", "foo-1.0-cp312-cp312-linux_x86_64.whl", true},
+		{"path in subject", "dist/foo-1.0.tar.gz", "foo-1.0.tar.gz", true},
+		{"not a distribution", "foo-1.0.txt", "foo-1.0.txt", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ValidateSubjectName(tc.subject, tc.filename)
+			if tc.mustErr && err == nil {
+				t.Error("Expected validation to fail")
+			} else if !tc.mustErr && err != nil {
+				t.Errorf("Expected validation to pass: %v", err)
+			}
+		})
+	}
+}
+
+func TestNormalizeProjectName(t *testing.T) {
+	if got := NormalizeProjectName("Friendly-Bard__x.y"); got != "friendly-bard-x-y" {
+		t.Errorf("Unexpected normalized name: %s", got)
+	}
+}
+
+func TestProvenanceRoundTrip(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.provenance.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+
+	provenance, err := UnmarshalProvenance(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal provenance: %v", err)
+	}
+
+	if provenance.Version != 1 || len(provenance.AttestationBundles) != 1 {
+		t.Fatalf("Unexpected provenance: %v", provenance)
+	}
+
+	bundle := provenance.AttestationBundles[0]
+	if kind := bundle.Publisher.Fields["kind"].GetStringValue(); kind != "GitHub" {
+		t.Errorf("Unexpected publisher kind: %s", kind)
+	}
+	if len(bundle.Attestations) != 1 || bundle.Attestations[0].Version != 1 {
+		t.Fatalf("Unexpected attestations: %v", bundle.Attestations)
+	}
+
+	marshaled, err := MarshalProvenance(provenance)
+	if err != nil {
+		t.Fatalf("Failed to marshal provenance: %v", err)
+	}
+
+	roundTrip, err := UnmarshalProvenance(marshaled)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal marshaled provenance: %v", err)
+	}
+	if !proto.Equal(provenance, roundTrip) {
+		t.Error("Provenance does not match after round trip")
+	}
+
+	if _, err := UnmarshalProvenance([]byte("{")); err == nil {
+		t.Error("Expected error for invalid JSON")
+	}
+}
+
+func TestProvenanceToBundles(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.provenance.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+
+	provenance, err := UnmarshalProvenance(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal provenance: %v", err)
+	}
+
+	// Add a second bundle from another publisher
+	second := proto.Clone(provenance.AttestationBundles[0]).(*pb.AttestationBundle)
+	second.Publisher.Fields["repository"] = structpb.NewStringValue("example/other")
+	provenance.AttestationBundles = append(provenance.AttestationBundles, second)
+
+	bundles, publishers, err := ProvenanceToBundles(provenance)
+	if err != nil {
+		t.Fatalf("Failed to convert provenance: %v", err)
+	}
+
+	if len(bundles) != 2 || len(publishers) != 2 {
+		t.Fatalf("Expected 2 bundles and publishers, got %d and %d", len(bundles), len(publishers))
+	}
+
+	if repo := publishers[1].Fields["repository"].GetStringValue(); repo != "example/other" {
+		t.Errorf("Unexpected publisher of second bundle: %s", repo)
+	}
+
+	for i, b := range bundles {
+		if _, err := b.TlogEntries(); err != nil {
+			t.Errorf("Bundle %d has invalid transparency entries: %v", i, err)
+		}
+	}
+
+	if _, _, err := ProvenanceToBundles(nil); err == nil {
+		t.Error("Expected error for nil provenance")
+	}
+}
+
+func TestNewProvenance(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	publisher := map[string]interface{}{
+		"kind":        "GitHub",
+		"repository":  "pypi/pypi-attestations",
+		"workflow":    "release.yml",
+		"environment": nil,
+	}
+
+	provenance, err := NewProvenance(publisher, attestation)
+	if err != nil {
+		t.Fatalf("Failed to build provenance: %v", err)
+	}
+
+	marshaled, err := MarshalProvenance(provenance)
+	if err != nil {
+		t.Fatalf("Failed to marshal provenance: %v", err)
+	}
+
+	// The result must match the provenance served by the index
+	expected, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.provenance.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	var got, want interface{}
+	if err := json.Unmarshal(marshaled, &got); err != nil {
+		t.Fatalf("Failed to parse marshaled provenance: %v", err)
+	}
+	if err := json.Unmarshal(expected, &want); err != nil {
+		t.Fatalf("Failed to parse expected provenance: %v", err)
+	}
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Errorf("Provenance does not match:\n got: %s\nwant: %s", gotJSON, wantJSON)
+	}
+
+	if _, err := NewProvenance(map[string]interface{}{"repository": "x"}, attestation); err == nil {
+		t.Error("Expected error for publisher without kind")
+	}
+	if _, err := NewProvenance(publisher); err == nil {
+		t.Error("Expected error for bundle without attestations")
+	}
+}
+
+func TestParse(t *testing.T) {
+	attestationData, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	provenanceData, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.provenance.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(attestationData)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	bundleData, err := MarshalBundle(b)
+	if err != nil {
+		t.Fatalf("Failed to marshal bundle: %v", err)
+	}
+	envelopeData, err := protojson.Marshal(b.GetDsseEnvelope())
+	if err != nil {
+		t.Fatalf("Failed to marshal envelope: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+		kind Kind
+	}{
+		{"attestation", attestationData, KindAttestation},
+		{"bundle", bundleData, KindBundle},
+		{"provenance", provenanceData, KindProvenance},
+		{"envelope", envelopeData, KindEnvelope},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := Parse(tc.data)
+			if err != nil {
+				t.Fatalf("Failed to parse: %v", err)
+			}
+			if p.Kind != tc.kind {
+				t.Fatalf("Expected %s, got %s", tc.kind, p.Kind)
+			}
+			switch tc.kind {
+			case KindAttestation:
+				if !proto.Equal(p.Attestation, attestation) {
+					t.Error("Parsed attestation does not match")
+				}
+			case KindBundle:
+				if !proto.Equal(p.Bundle.Bundle, b.Bundle) {
+					t.Error("Parsed bundle does not match")
+				}
+			case KindProvenance:
+				if len(p.Provenance.AttestationBundles) != 1 {
+					t.Errorf("Unexpected provenance: %v", p.Provenance)
+				}
+			case KindEnvelope:
+				if !bytes.Equal(p.Envelope.Payload, attestation.Envelope.Statement) {
+					t.Error("Parsed envelope payload does not match")
+				}
+			}
+		})
+	}
+
+	for _, data := range []string{`not json`, `{}`, `{"mediaType": "text/plain"}`} {
+		if _, err := Parse([]byte(data)); err == nil {
+			t.Errorf("Expected error parsing %s", data)
+		}
+	}
+}
+
+func TestBundleVersions(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	for _, tc := range []struct {
+		version   string
+		mediaType string
+		chain     bool
+	}{
+		{"v0.1", "application/vnd.dev.sigstore.bundle+json;version=0.1", true},
+		{"0.2", "application/vnd.dev.sigstore.bundle+json;version=0.2", true},
+		{"v0.3", "application/vnd.dev.sigstore.bundle.v0.3+json", false},
+	} {
+		t.Run(tc.version, func(t *testing.T) {
+			b, err := ToBundle(attestation, WithBundleVersion(tc.version))
+			if err != nil {
+				t.Fatalf("Failed to convert to bundle: %v", err)
+			}
+			if b.MediaType != tc.mediaType {
+				t.Errorf("Unexpected media type: %s", b.MediaType)
+			}
+			if _, ok := b.VerificationMaterial.Content.(*protobundle.VerificationMaterial_X509CertificateChain); ok != tc.chain {
+				t.Errorf("Unexpected verification material: %T", b.VerificationMaterial.Content)
+			}
+
+			// Ensure the bundle survives serialization and converts back
+			bundleData, err := MarshalBundle(b)
+			if err != nil {
+				t.Fatalf("Failed to marshal bundle: %v", err)
+			}
+			b, err = UnmarshalBundle(bundleData)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal bundle: %v", err)
+			}
+			converted, err := FromBundle(b)
+			if err != nil {
+				t.Fatalf("Failed to convert from bundle: %v", err)
+			}
+			if !proto.Equal(converted, attestation) {
+				t.Error("Attestation does not match after conversion")
+			}
+		})
+	}
+
+	if _, err := ToBundle(attestation, WithBundleVersion("v0.4")); err == nil {
+		t.Error("Expected error for unsupported bundle version")
+	}
+}
+
+func TestFromBundleMessageSignature(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+
+	b.Content = &protobundle.Bundle_MessageSignature{
+		MessageSignature: &protocommon.MessageSignature{Signature: attestation.Envelope.Signature},
+	}
+	if _, err := FromBundle(b); err == nil {
+		t.Error("Expected error converting message signature bundle")
+	}
+}
+
+func TestCertificateChain(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	// Any DER certificate works as a stand in intermediate
+	intermediate := attestation.VerificationMaterial.Certificate
+	b, err := ToBundle(attestation, WithBundleVersion("v0.2"))
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	chain := b.VerificationMaterial.GetX509CertificateChain()
+	chain.Certificates = append(chain.Certificates, &protocommon.X509Certificate{RawBytes: intermediate})
+
+	converted, err := FromBundle(b)
+	if err != nil {
+		t.Fatalf("Failed to convert from bundle: %v", err)
+	}
+	if len(converted.VerificationMaterial.IntermediateCertificates) != 1 {
+		t.Fatalf("Expected intermediate certificate to be preserved, got %d", len(converted.VerificationMaterial.IntermediateCertificates))
+	}
+
+	b, err = ToBundle(converted, WithBundleVersion("v0.2"))
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	if n := len(b.VerificationMaterial.GetX509CertificateChain().GetCertificates()); n != 2 {
+		t.Errorf("Expected 2 certificates in chain, got %d", n)
+	}
+
+	if _, err := ToBundle(converted, WithStrict(true)); err == nil {
+		t.Error("Expected error dropping intermediates in v0.3 bundle")
+	}
+	if _, err := ToBundle(converted, WithAllowLossy(true)); err != nil {
+		t.Errorf("Failed to convert with lossy conversion allowed: %v", err)
+	}
+
+	if _, err := MarshalAttestation(converted, WithAllowLossy(false)); err == nil {
+		t.Error("Expected error marshaling intermediates to PEP 740 JSON")
+	}
+	if _, err := MarshalAttestation(converted, WithAllowLossy(true)); err != nil {
+		t.Errorf("Failed to marshal with lossy conversion allowed: %v", err)
+	}
+}
+
+func TestConversionWarnings(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+
+	env := b.GetDsseEnvelope()
+	env.Signatures[0].Keyid = "key"
+	env.Signatures = append(env.Signatures, &protodsse.Signature{Sig: []byte("second")})
+
+	if _, err := FromBundle(b, WithStrict(true)); err == nil {
+		t.Fatal("Expected strict conversion to fail")
+	}
+	if _, err := FromBundle(b, func(*ConvertOptions) error { return errors.New("bad option") }); err == nil {
+		t.Fatal("Expected the option error to be returned")
+	}
+
+	var warnings Warnings
+	converted, err := FromBundle(b, WithWarnings(&warnings))
+	if err != nil {
+		t.Fatalf("Failed to convert from bundle: %v", err)
+	}
+	if !bytes.Equal(converted.Envelope.Signature, attestation.Envelope.Signature) {
+		t.Error("Expected first signature to be kept")
+	}
+	if len(warnings) != 1 || warnings[0].Field != "keyid" {
+		t.Errorf("Unexpected warnings: %v", warnings)
+	}
+
+	converted.VerificationMaterial.Rfc3161Timestamps = [][]byte{[]byte("ts")}
+	warnings = nil
+	if _, err := MarshalAttestation(converted, WithAllowLossy(true), WithWarnings(&warnings)); err != nil {
+		t.Fatalf("Failed to marshal attestation: %v", err)
+	}
+	if len(warnings) != 2 || warnings[0].Field != "rfc3161_timestamps" || warnings[1].Field != "additional_signatures" {
+		t.Errorf("Unexpected warnings: %v", warnings)
+	}
+}
+
+func TestMultipleSignatures(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	attestation.Envelope.AdditionalSignatures = []*pb.Signature{{Sig: []byte("second"), Keyid: "key"}}
+
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	if n := len(b.GetDsseEnvelope().Signatures); n != 2 {
+		t.Fatalf("Expected 2 signatures, got %d", n)
+	}
+
+	converted, err := FromBundle(b)
+	if err != nil {
+		t.Fatalf("Failed to convert from bundle: %v", err)
+	}
+	if !proto.Equal(converted, attestation) {
+		t.Error("Attestation does not match after round trip")
+	}
+
+	converted, err = FromBundle(b, WithSignatureIndex(1), WithStrict(false))
+	if err != nil {
+		t.Fatalf("Failed to convert from bundle: %v", err)
+	}
+	if string(converted.Envelope.Signature) != "second" {
+		t.Error("Expected selected signature to be the attestation signature")
+	}
+	if len(converted.Envelope.AdditionalSignatures) != 1 ||
+		!bytes.Equal(converted.Envelope.AdditionalSignatures[0].Sig, attestation.Envelope.Signature) {
+		t.Errorf("Unexpected additional signatures: %v", converted.Envelope.AdditionalSignatures)
+	}
+
+	if _, err := FromBundle(b, WithSignatureIndex(2)); err == nil {
+		t.Error("Expected error for out of range signature index")
+	}
+	if _, err := MarshalAttestation(attestation, WithStrict(true)); err == nil {
+		t.Error("Expected error marshaling additional signatures to PEP 740 JSON")
+	}
+}
+
+func TestMultipleTransparencyEntries(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	second := proto.Clone(attestation.VerificationMaterial.TransparencyEntries[0]).(*structpb.Struct)
+	second.Fields["logIndex"] = structpb.NewStringValue("1")
+	attestation.VerificationMaterial.TransparencyEntries = append(attestation.VerificationMaterial.TransparencyEntries, second)
+
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	if n := len(b.VerificationMaterial.TlogEntries); n != 2 {
+		t.Fatalf("Expected 2 tlog entries, got %d", n)
+	}
+	if b.VerificationMaterial.TlogEntries[1].LogIndex != 1 {
+		t.Errorf("Unexpected log index of second entry: %d", b.VerificationMaterial.TlogEntries[1].LogIndex)
+	}
+
+	converted, err := FromBundle(b)
+	if err != nil {
+		t.Fatalf("Failed to convert from bundle: %v", err)
+	}
+	if !proto.Equal(converted, attestation) {
+		t.Error("Attestation does not match after round trip")
+	}
+}
+
+// testDigest is the sha256 of the distribution attested in the test data.
+const testDigest = "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"
+
+// cosignBundleFromAttestation builds the cosign attest-blob bundle that
+// signed the test attestation.
+func cosignBundleFromAttestation(t *testing.T, attestation *pb.Attestation) *CosignBundle {
+	t.Helper()
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	envelope, err := protojson.Marshal(b.GetDsseEnvelope())
+	if err != nil {
+		t.Fatalf("Failed to marshal envelope: %v", err)
+	}
+	entry := b.VerificationMaterial.TlogEntries[0]
+	return &CosignBundle{
+		Base64Signature: base64.StdEncoding.EncodeToString(envelope),
+		Cert: base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{
+			Type: "CERTIFICATE", Bytes: attestation.VerificationMaterial.Certificate,
+		})),
+		RekorBundle: &CosignRekorBundle{
+			SignedEntryTimestamp: entry.InclusionPromise.SignedEntryTimestamp,
+			Payload: CosignRekorPayload{
+				Body:           base64.StdEncoding.EncodeToString(entry.CanonicalizedBody),
+				IntegratedTime: entry.IntegratedTime,
+				LogIndex:       entry.LogIndex,
+				LogID:          hex.EncodeToString(entry.LogId.KeyId),
+			},
+		},
+	}
+}
+
+func TestFromCosignBundle(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	t.Run("attest-blob", func(t *testing.T) {
+		cosignData, err := json.Marshal(cosignBundleFromAttestation(t, attestation))
+		if err != nil {
+			t.Fatalf("Failed to marshal cosign bundle: %v", err)
+		}
+		converted, err := AttestationFromCosignBundle(cosignData)
+		if err != nil {
+			t.Fatalf("Failed to convert cosign bundle: %v", err)
+		}
+		if !bytes.Equal(converted.Envelope.Statement, attestation.Envelope.Statement) ||
+			!bytes.Equal(converted.Envelope.Signature, attestation.Envelope.Signature) ||
+			!bytes.Equal(converted.VerificationMaterial.Certificate, attestation.VerificationMaterial.Certificate) {
+			t.Error("Converted attestation does not match")
+		}
+		if _, ok := converted.VerificationMaterial.TransparencyEntries[0].Fields["inclusionProof"]; ok {
+			t.Error("Cosign bundles carry no inclusion proof")
+		}
+	})
+
+	t.Run("sign-blob", func(t *testing.T) {
+		// The hashedrekord body must carry a valid signature of the digest
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		template := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+		der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+		if err != nil {
+			t.Fatalf("Failed to create certificate: %v", err)
+		}
+		digest, err := hex.DecodeString(testDigest)
+		if err != nil {
+			t.Fatalf("Failed to decode digest: %v", err)
+		}
+		sig, err := ecdsa.SignASN1(rand.Reader, key, digest)
+		if err != nil {
+			t.Fatalf("Failed to sign digest: %v", err)
+		}
+
+		cb := cosignBundleFromAttestation(t, attestation)
+		cb.Base64Signature = base64.StdEncoding.EncodeToString(sig)
+		cb.Cert = base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+		cb.RekorBundle.Payload.Body = base64.StdEncoding.EncodeToString([]byte(
+			`{"apiVersion":"0.0.1","kind":"hashedrekord","spec":{"data":{"hash":{"algorithm":"sha256","value":"` +
+				testDigest + `"}},"signature":{"content":"` + cb.Base64Signature +
+				`","publicKey":{"content":"` + cb.Cert + `"}}}}`,
+		))
+		cosignData, err := json.Marshal(cb)
+		if err != nil {
+			t.Fatalf("Failed to marshal cosign bundle: %v", err)
+		}
+		b, err := FromCosignBundle(cosignData)
+		if err != nil {
+			t.Fatalf("Failed to convert cosign bundle: %v", err)
+		}
+		ms := b.GetMessageSignature()
+		if ms == nil || hex.EncodeToString(ms.MessageDigest.Digest) != testDigest {
+			t.Fatalf("Unexpected bundle content: %v", b.Content)
+		}
+		if _, err := FromBundle(b); err == nil {
+			t.Error("Expected error converting message signature to PEP 740")
+		}
+	})
+
+	if _, err := FromCosignBundle([]byte(`{"base64Signature":"c2ln"}`)); err == nil {
+		t.Error("Expected error for bundle without Rekor bundle")
+	}
+}
+
+func TestFromGitHubAttestations(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	bundleData, err := protojson.Marshal(b.Bundle)
+	if err != nil {
+		t.Fatalf("Failed to marshal bundle: %v", err)
+	}
+
+	resp, err := json.Marshal(GitHubAttestations{Attestations: []GitHubAttestation{
+		{Bundle: bundleData, RepositoryID: 1},
+		{Bundle: bundleData, RepositoryID: 1},
+	}})
+	if err != nil {
+		t.Fatalf("Failed to marshal response: %v", err)
+	}
+
+	bundles, err := FromGitHubAttestations(resp)
+	if err != nil {
+		t.Fatalf("Failed to convert GitHub attestations: %v", err)
+	}
+	if len(bundles) != 1 || len(bundles[0].Attestations) != 2 {
+		t.Fatalf("Expected one bundle with two attestations, got %v", bundles)
+	}
+	pub := bundles[0].Publisher.AsMap()
+	if pub["kind"] != "GitHub" || pub["repository"] != "pypi/pypi-attestations" || pub["workflow"] != "release.yml" {
+		t.Errorf("Unexpected publisher: %v", pub)
+	}
+	if !proto.Equal(bundles[0].Attestations[0], attestation) {
+		t.Error("Converted attestation does not match")
+	}
+
+	for _, data := range []string{
+		`{"attestations": []}`,
+		`{"attestations": [{"bundle": null, "bundle_url": "https://example.com"}]}`,
+	} {
+		if _, err := FromGitHubAttestations([]byte(data)); err == nil {
+			t.Errorf("Expected error converting %s", data)
+		}
+	}
+}
+
+func TestBundlesJSONL(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+
+	jsonl, err := MarshalBundles([]*bundle.Bundle{b, b, b})
+	if err != nil {
+		t.Fatalf("Failed to marshal bundles: %v", err)
+	}
+	if n := bytes.Count(jsonl, []byte("\n")); n != 3 {
+		t.Fatalf("Expected 3 lines, got %d", n)
+	}
+
+	bundles, err := UnmarshalBundles(jsonl)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal bundles: %v", err)
+	}
+	if len(bundles) != 3 {
+		t.Fatalf("Expected 3 bundles, got %d", len(bundles))
+	}
+	for _, got := range bundles {
+		if !proto.Equal(got.Bundle, b.Bundle) {
+			t.Error("Bundle does not match after round trip")
+		}
+	}
+
+	var buf bytes.Buffer
+	w := NewBundleWriter(&buf)
+	if err := w.Write(b); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Failed to flush writer: %v", err)
+	}
+	buf.WriteString("\n{\"mediaType\": \"invalid\"}\n")
+
+	r := NewBundleReader(&buf)
+	if _, err := r.Next(); err != nil {
+		t.Fatalf("Failed to read bundle: %v", err)
+	}
+	if _, err := r.Next(); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("Expected error reading invalid bundle, got %v", err)
+	}
+	if _, err := r.Next(); !errors.Is(err, io.EOF) {
+		t.Errorf("Expected io.EOF at end of stream, got %v", err)
+	}
+}
+
+func TestAttestationFromEnvelope(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+
+	envelope, err := protojson.Marshal(b.GetDsseEnvelope())
+	if err != nil {
+		t.Fatalf("Failed to marshal envelope: %v", err)
+	}
+	entry, err := protojson.Marshal(b.VerificationMaterial.TlogEntries[0])
+	if err != nil {
+		t.Fatalf("Failed to marshal entry: %v", err)
+	}
+	cert := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: attestation.VerificationMaterial.Certificate})
+
+	assembled, err := AttestationFromEnvelope(envelope, cert, [][]byte{entry})
+	if err != nil {
+		t.Fatalf("Failed to assemble attestation: %v", err)
+	}
+	if !proto.Equal(assembled, attestation) {
+		t.Error("Assembled attestation does not match")
+	}
+
+	if _, err := AttestationFromEnvelope(envelope, cert, nil); err == nil {
+		t.Error("Expected error without transparency entries")
+	}
+	if _, err := AttestationFromEnvelope(envelope, []byte("not a certificate"), [][]byte{entry}); err == nil {
+		t.Error("Expected error for invalid certificate")
+	}
+	if _, err := AttestationFromEnvelope([]byte(`{"payloadType":"text/plain","payload":"","signatures":[]}`), cert, [][]byte{entry}); err == nil {
+		t.Error("Expected error for non in-toto payload")
+	}
+}
+
+func TestAttestationBinary(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	attestation.VerificationMaterial.Rfc3161Timestamps = [][]byte{[]byte("ts")}
+
+	bin, err := MarshalAttestationBinary(attestation)
+	if err != nil {
+		t.Fatalf("Failed to marshal attestation: %v", err)
+	}
+	if len(bin) >= len(data) {
+		t.Errorf("Expected binary form to be smaller than JSON: %d >= %d", len(bin), len(data))
+	}
+
+	again, err := MarshalAttestationBinary(attestation)
+	if err != nil {
+		t.Fatalf("Failed to marshal attestation: %v", err)
+	}
+	if !bytes.Equal(bin, again) {
+		t.Error("Expected deterministic binary encoding")
+	}
+
+	decoded, err := UnmarshalAttestationBinary(bin)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	if !proto.Equal(decoded, attestation) {
+		t.Error("Attestation does not match after binary round trip")
+	}
+
+	if _, err := UnmarshalAttestationBinary([]byte{0xff}); err == nil {
+		t.Error("Expected error for invalid binary data")
+	}
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name      string
+		marshal   func() ([]byte, error)
+		unmarshal func([]byte) error
+	}{
+		{
+			"attestation",
+			func() ([]byte, error) { return MarshalAttestation(attestation, WithCanonical(true)) },
+			func(data []byte) error {
+				got, err := UnmarshalAttestation(data)
+				if err == nil && !proto.Equal(got, attestation) {
+					err = fmt.Errorf("attestation does not match")
+				}
+				return err
+			},
+		},
+		{
+			"bundle",
+			func() ([]byte, error) { return MarshalBundle(b, WithCanonical(true)) },
+			func(data []byte) error {
+				got, err := UnmarshalBundle(data)
+				if err == nil && !proto.Equal(got.Bundle, b.Bundle) {
+					err = fmt.Errorf("bundle does not match")
+				}
+				return err
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			first, err := tc.marshal()
+			if err != nil {
+				t.Fatalf("Failed to marshal: %v", err)
+			}
+			second, err := tc.marshal()
+			if err != nil {
+				t.Fatalf("Failed to marshal: %v", err)
+			}
+			if !bytes.Equal(first, second) {
+				t.Error("Expected reproducible output")
+			}
+			if bytes.ContainsAny(first, "\n\t") {
+				t.Error("Expected no insignificant whitespace")
+			}
+			if err := tc.unmarshal(first); err != nil {
+				t.Errorf("Failed to round trip canonical JSON: %v", err)
+			}
+		})
+	}
+}
+
+func TestYAML(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	y, err := MarshalAttestationYAML(attestation)
+	if err != nil {
+		t.Fatalf("Failed to marshal attestation YAML: %v", err)
+	}
+	if !bytes.Contains(y, []byte("version: 1\n")) {
+		t.Errorf("Expected integer version in YAML:\n%s", y)
+	}
+	got, err := UnmarshalAttestationYAML(y)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation YAML: %v", err)
+	}
+	if !proto.Equal(got, attestation) {
+		t.Error("Attestation does not match after YAML round trip")
+	}
+
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	y, err = MarshalBundleYAML(b)
+	if err != nil {
+		t.Fatalf("Failed to marshal bundle YAML: %v", err)
+	}
+	gotBundle, err := UnmarshalBundleYAML(y)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal bundle YAML: %v", err)
+	}
+	if !proto.Equal(gotBundle.Bundle, b.Bundle) {
+		t.Error("Bundle does not match after YAML round trip")
+	}
+
+	if _, err := UnmarshalAttestationYAML([]byte("version: [")); err == nil {
+		t.Error("Expected error for invalid YAML")
+	}
+}
+
+func TestReadWrite(t *testing.T) {
+	f, err := os.Open(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to open test data: %v", err)
+	}
+	defer f.Close()
+
+	attestation, err := ReadAttestation(f)
+	if err != nil {
+		t.Fatalf("Failed to read attestation: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := WriteAttestation(&buf, attestation); err != nil {
+		t.Fatalf("Failed to write attestation: %v", err)
+	}
+	size := int64(buf.Len())
+
+	if _, err := ReadAttestation(bytes.NewReader(buf.Bytes()), WithMaxSize(size-1)); err == nil {
+		t.Error("Expected error reading past the maximum size")
+	}
+	got, err := ReadAttestation(&buf, WithMaxSize(size))
+	if err != nil {
+		t.Fatalf("Failed to read attestation: %v", err)
+	}
+	if !proto.Equal(got, attestation) {
+		t.Error("Attestation does not match after round trip")
+	}
+
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	if err := WriteBundle(&buf, b); err != nil {
+		t.Fatalf("Failed to write bundle: %v", err)
+	}
+	gotBundle, err := ReadBundle(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read bundle: %v", err)
+	}
+	if !proto.Equal(gotBundle.Bundle, b.Bundle) {
+		t.Error("Bundle does not match after round trip")
+	}
+
+	provenance, err := NewProvenance(map[string]interface{}{"kind": "GitHub"}, attestation)
+	if err != nil {
+		t.Fatalf("Failed to create provenance: %v", err)
+	}
+	if err := WriteProvenance(&buf, provenance); err != nil {
+		t.Fatalf("Failed to write provenance: %v", err)
+	}
+	gotProvenance, err := ReadProvenance(&buf)
+	if err != nil {
+		t.Fatalf("Failed to read provenance: %v", err)
+	}
+	if !proto.Equal(gotProvenance, provenance) {
+		t.Error("Provenance does not match after round trip")
+	}
+}
+
+func TestReadCompressed(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	want, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	var gz bytes.Buffer
+	gw := gzip.NewWriter(&gz)
+	if _, err := gw.Write(data); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("Failed to compress: %v", err)
+	}
+
+	zw, err := zstd.NewWriter(nil)
+	if err != nil {
+		t.Fatalf("Failed to create zstd writer: %v", err)
+	}
+	zst := zw.EncodeAll(data, nil)
+
+	for name, compressed := range map[string][]byte{"gzip": gz.Bytes(), "zstd": zst} {
+		t.Run(name, func(t *testing.T) {
+			got, err := ReadAttestation(bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatalf("Failed to read attestation: %v", err)
+			}
+			if !proto.Equal(got, want) {
+				t.Error("Attestation does not match the uncompressed one")
+			}
+
+			// The limit applies to the decompressed size
+			if _, err := ReadAttestation(bytes.NewReader(compressed), WithMaxSize(int64(len(data)-1))); err == nil {
+				t.Error("Expected error reading past the maximum decompressed size")
+			}
+		})
+	}
+}
+
+func TestConvertTree(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	bundleData, err := MarshalBundle(b)
+	if err != nil {
+		t.Fatalf("Failed to marshal bundle: %v", err)
+	}
+
+	fsys := fstest.MapFS{
+		"dist/pkg-1.0.tar.gz.publish.attestation":      {Data: data},
+		"other/pkg-1.0-py3-none-any.whl.sigstore.json": {Data: bundleData},
+		"broken.json": {Data: []byte("{}")},
+		"README.md":   {Data: []byte("# readme")},
+	}
+
+	out := t.TempDir()
+	results, err := ConvertTree(fsys, "*", WithOutputDir(out))
+	if err != nil {
+		t.Fatalf("ConvertTree failed: %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("Expected 4 results, got %d", len(results))
+	}
+
+	byPath := map[string]TreeResult{}
+	for _, r := range results {
+		byPath[r.Source] = r
+	}
+	for _, name := range []string{"README.md", "broken.json"} {
+		if byPath[name].Error == nil {
+			t.Errorf("Expected %s to fail", name)
+		}
+	}
+
+	r := byPath["dist/pkg-1.0.tar.gz.publish.attestation"]
+	if r.Error != nil || r.Target != "dist/pkg-1.0.tar.gz.sigstore.json" || r.To != KindBundle {
+		t.Errorf("Unexpected attestation result: %+v", r)
+	}
+	written, err := os.ReadFile(filepath.Join(out, "dist", "pkg-1.0.tar.gz.sigstore.json"))
+	if err != nil {
+		t.Fatalf("Failed to read converted bundle: %v", err)
+	}
+	if _, err := UnmarshalBundle(written); err != nil {
+		t.Errorf("Converted bundle does not parse: %v", err)
+	}
+
+	r = byPath["other/pkg-1.0-py3-none-any.whl.sigstore.json"]
+	if r.Error != nil || r.Target != "other/pkg-1.0-py3-none-any.whl.publish.attestation" || r.To != KindAttestation {
+		t.Errorf("Unexpected bundle result: %+v", r)
+	}
+	written, err = os.ReadFile(filepath.Join(out, "other", "pkg-1.0-py3-none-any.whl.publish.attestation"))
+	if err != nil {
+		t.Fatalf("Failed to read converted attestation: %v", err)
+	}
+	got, err := UnmarshalAttestation(written)
+	if err != nil {
+		t.Fatalf("Converted attestation does not parse: %v", err)
+	}
+	if !proto.Equal(got, attestation) {
+		t.Error("Converted attestation does not match the original")
+	}
+
+	// Only matching files are converted
+	results, err = ConvertTree(fsys, "*.publish.attestation", WithOutputDir(t.TempDir()))
+	if err != nil {
+		t.Fatalf("ConvertTree failed: %v", err)
+	}
+	if len(results) != 1 {
+		t.Errorf("Expected 1 result, got %d", len(results))
+	}
+
+	if _, err := ConvertTree(fsys, "*"); err == nil {
+		t.Error("Expected error without output directory")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	results, err = ConvertTreeContext(ctx, fsys, "*", WithOutputDir(t.TempDir()))
+	if !errors.Is(err, context.Canceled) || len(results) != 0 {
+		t.Errorf("Expected the walk to be canceled, got %d results: %v", len(results), err)
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	bundleData, err := MarshalBundle(b)
+	if err != nil {
+		t.Fatalf("Failed to marshal bundle: %v", err)
+	}
+
+	fsys := fstest.MapFS{
+		"dist/pkg-1.0.tar.gz.publish.attestation":      {Data: data},
+		"other/pkg-1.0-py3-none-any.whl.sigstore.json": {Data: bundleData},
+		"broken.json": {Data: []byte("{}")},
+		"README.md":   {Data: []byte("# readme")},
+	}
+	p, err := NewPipeline(WithWorkers(3), WithQueueSize(1))
+	if err != nil {
+		t.Fatalf("Failed to create pipeline: %v", err)
+	}
+
+	out := t.TempDir()
+	stats, err := p.RunFS(context.Background(), fsys, "*", WriteTreeSink(out, nil))
+	if err != nil {
+		t.Fatalf("RunFS failed: %v", err)
+	}
+	if stats.Processed != 4 || stats.Converted != 2 || stats.Failed != 2 ||
+		stats.Errors[ErrUnrecognizedFormat.Error()] != 1 || stats.Errors["other"] != 1 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	if stats.BytesIn != int64(len(data)+len(bundleData)+len("{}")+len("# readme")) || stats.BytesOut == 0 || stats.Throughput() <= 0 {
+		t.Errorf("Unexpected stats: %+v", stats)
+	}
+	for _, name := range []string{"dist/pkg-1.0.tar.gz.sigstore.json", "other/pkg-1.0-py3-none-any.whl.publish.attestation"} {
+		if _, err := os.Stat(filepath.Join(out, filepath.FromSlash(name))); err != nil {
+			t.Errorf("Expected %s to be written: %v", name, err)
+		}
+	}
+
+	// A channel fed with many documents, stopped by the sink
+	in := make(chan PipelineInput)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		defer close(in)
+		for i := range 100 {
+			select {
+			case <-ctx.Done():
+				return
+			case in <- PipelineInput{Name: fmt.Sprintf("doc-%d", i), Data: data}:
+			}
+		}
+	}()
+	converted := 0
+	errStop := errors.New("stop")
+	stats, err = p.Run(ctx, in, func(res PipelineOutput) error {
+		if res.Error != nil || res.To != KindBundle {
+			t.Errorf("Unexpected output: %+v", res)
+		}
+		if converted++; converted == 10 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) || stats.Processed != 10 {
+		t.Errorf("Expected the sink to stop the run after 10 documents, got %d: %v", stats.Processed, err)
+	}
+
+	if _, err := NewPipeline(WithWorkers(0)); err == nil {
+		t.Error("Expected error for zero workers")
+	}
+	if _, err := p.RunFS(context.Background(), fsys, "[", WriteTreeSink(out, nil)); err == nil {
+		t.Error("Expected error for an invalid glob")
+	}
+}
+
+func TestDiff(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	bundleData, err := MarshalBundle(b)
+	if err != nil {
+		t.Fatalf("Failed to marshal bundle: %v", err)
+	}
+	canonical, err := MarshalAttestation(attestation, WithCanonical(true))
+	if err != nil {
+		t.Fatalf("Failed to marshal attestation: %v", err)
+	}
+
+	// The same attestation in all its forms
+	for _, other := range []any{attestation, b, data, bundleData, canonical} {
+		equal, err := Equal(attestation, other)
+		if err != nil {
+			t.Fatalf("Equal failed for %T: %v", other, err)
+		}
+		if !equal {
+			diffs, _ := Diff(attestation, other)
+			t.Errorf("Expected %T to be equal, got differences: %v", other, diffs)
+		}
+	}
+
+	changed := proto.Clone(attestation).(*pb.Attestation)
+	changed.Envelope.Signature = []byte("other")
+	changed.VerificationMaterial.TransparencyEntries[0].Fields["logIndex"] = structpb.NewStringValue("1")
+
+	diffs, err := Diff(bundleData, changed)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	paths := map[string]bool{}
+	for _, d := range diffs {
+		paths[d.Path] = true
+	}
+	for _, p := range []string{"envelope.signature", "verification_material.transparency_entries[0].log_index"} {
+		if !paths[p] {
+			t.Errorf("Expected a difference at %s, got %v", p, diffs)
+		}
+	}
+	if len(diffs) != 2 {
+		t.Errorf("Expected 2 differences, got %v", diffs)
+	}
+
+	if _, err := Diff(attestation, "text"); err == nil {
+		t.Error("Expected error comparing an unsupported type")
+	}
+}
+
+func TestAttestationVersions(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("Failed to unmarshal test data: %v", err)
+	}
+
+	caps, err := VersionCapabilities(1)
+	if err != nil {
+		t.Fatalf("Failed to get version 1 capabilities: %v", err)
+	}
+	if !caps.Bundle || !caps.Provenance {
+		t.Errorf("Unexpected version 1 capabilities: %+v", caps)
+	}
+	if _, err := VersionCapabilities(2); err == nil {
+		t.Error("Expected error for unsupported version")
+	}
+
+	raw["version"] = 2
+	v2, err := json.Marshal(raw)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+	if _, err := UnmarshalAttestation(v2); err == nil {
+		t.Error("Expected error unmarshaling an unsupported version")
+	}
+
+	// Registering a decoder makes the version supported
+	attestationCodecs[2] = attestationCodec{
+		capabilities: Capabilities{Version: 2},
+		decode: func(raw map[string]interface{}, opts *ConvertOptions) (*pb.Attestation, error) {
+			a, err := decodeAttestationV1(raw, opts)
+			if err != nil {
+				return nil, err
+			}
+			a.Version = 2
+			return a, nil
+		},
+	}
+	defer delete(attestationCodecs, 2)
+
+	if versions := SupportedVersions(); len(versions) != 2 || versions[1] != 2 {
+		t.Errorf("Unexpected supported versions: %v", versions)
+	}
+	attestation, err := UnmarshalAttestation(v2)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal version 2 attestation: %v", err)
+	}
+	if attestation.Version != 2 {
+		t.Errorf("Expected version 2, got %d", attestation.Version)
+	}
+	if _, err := ToBundle(attestation); err == nil {
+		t.Error("Expected error converting a version without bundle support")
+	}
+}
+
+func TestLenientBase64(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	want, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	for name, enc := range map[string]*base64.Encoding{
+		"url":          base64.URLEncoding,
+		"unpadded":     base64.RawStdEncoding,
+		"url unpadded": base64.RawURLEncoding,
+	} {
+		t.Run(name, func(t *testing.T) {
+			doc := map[string]interface{}{
+				"version": 1,
+				"verification_material": map[string]interface{}{
+					"certificate":          enc.EncodeToString(want.VerificationMaterial.Certificate),
+					"transparency_entries": want.VerificationMaterial.TransparencyEntries,
+				},
+				"envelope": map[string]interface{}{
+					"statement": enc.EncodeToString(want.Envelope.Statement),
+					"signature": enc.EncodeToString(want.Envelope.Signature),
+				},
+			}
+			encoded, err := json.Marshal(doc)
+			if err != nil {
+				t.Fatalf("Failed to marshal: %v", err)
+			}
+
+			got, err := UnmarshalAttestation(encoded)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal attestation: %v", err)
+			}
+			if !proto.Equal(got, want) {
+				t.Error("Attestation does not match")
+			}
+
+			if _, err := UnmarshalAttestation(encoded, WithStrictBase64(true)); err == nil {
+				t.Error("Expected error in strict mode")
+			}
+		})
+	}
+}
+
+func TestUnmarshalAttestationStrict(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	want, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	got, err := UnmarshalAttestationStrict(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation strictly: %v", err)
+	}
+	if !proto.Equal(got, want) {
+		t.Error("Strict unmarshal result does not match")
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(raw map[string]interface{})
+		paths  []string
+	}{
+		{
+			name:   "missing version",
+			modify: func(raw map[string]interface{}) { delete(raw, "version") },
+			paths:  []string{"version: missing"},
+		},
+		{
+			name: "unknown fields",
+			modify: func(raw map[string]interface{}) {
+				raw["extra"] = true
+				raw["envelope"].(map[string]interface{})["keyid"] = "abc"
+			},
+			paths: []string{"extra: unknown", "envelope.keyid: unknown"},
+		},
+		{
+			name: "missing and mistyped fields",
+			modify: func(raw map[string]interface{}) {
+				vm := raw["verification_material"].(map[string]interface{})
+				delete(vm, "certificate")
+				vm["transparency_entries"] = []interface{}{"entry"}
+				raw["envelope"].(map[string]interface{})["signature"] = 1
+			},
+			paths: []string{
+				"verification_material.certificate: missing",
+				"verification_material.transparency_entries[0]: expected an object",
+				"envelope.signature: expected string",
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			var raw map[string]interface{}
+			if err := json.Unmarshal(data, &raw); err != nil {
+				t.Fatalf("Failed to unmarshal test data: %v", err)
+			}
+			tc.modify(raw)
+			modified, err := json.Marshal(raw)
+			if err != nil {
+				t.Fatalf("Failed to marshal: %v", err)
+			}
+
+			_, err = UnmarshalAttestationStrict(modified)
+			if err == nil {
+				t.Fatal("Expected error")
+			}
+			for _, p := range tc.paths {
+				if !strings.Contains(err.Error(), p) {
+					t.Errorf("Expected error to mention %q, got: %v", p, err)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateAttestation(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	if err := ValidateAttestation(attestation); err != nil {
+		t.Fatalf("Expected attestation to be valid: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name   string
+		modify func(a *pb.Attestation)
+		want   string
+	}{
+		{"version", func(a *pb.Attestation) { a.Version = 3 }, "version:"},
+		{"certificate", func(a *pb.Attestation) { a.VerificationMaterial.Certificate = []byte("junk") }, "verification_material: certificate:"},
+		{"no entries", func(a *pb.Attestation) { a.VerificationMaterial.TransparencyEntries = nil }, "transparency_entries: no transparency entries found"},
+		{"bad entry", func(a *pb.Attestation) {
+			a.VerificationMaterial.TransparencyEntries[0].Fields["logIndex"] = structpb.NewBoolValue(true)
+		}, "transparency_entries[0]:"},
+		{"statement", func(a *pb.Attestation) { a.Envelope.Statement = []byte(`{"_type": "x"}`) }, "envelope: statement:"},
+		{"signature", func(a *pb.Attestation) { a.Envelope.Signature = []byte("short") }, "envelope: signature: implausible size"},
+		{"missing signature", func(a *pb.Attestation) { a.Envelope.Signature = nil }, "envelope: signature: missing"},
+		{"missing envelope", func(a *pb.Attestation) { a.Envelope = nil }, "envelope: envelope is missing"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			a := proto.Clone(attestation).(*pb.Attestation)
+			tc.modify(a)
+			err := ValidateAttestation(a)
+			if err == nil {
+				t.Fatal("Expected validation error")
+			}
+			if !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("Expected error to contain %q, got: %v", tc.want, err)
+			}
+		})
+	}
+}
+
+func TestValidateSchema(t *testing.T) {
+	for _, name := range []string{"pypi.attestation.json", "pypi.provenance.json"} {
+		data, err := os.ReadFile(filepath.Join("..", "..", "testdata", name))
+		if err != nil {
+			t.Fatalf("Failed to read test data: %v", err)
+		}
+		if err := ValidateSchema(data); err != nil {
+			t.Errorf("Expected %s to match its schema: %v", name, err)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	var raw map[string]interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		t.Fatalf("Failed to unmarshal test data: %v", err)
+	}
+	raw["version"] = 2
+	envelope := raw["envelope"].(map[string]interface{})
+	delete(envelope, "signature")
+	envelope["statement"] = "not base64!"
+	modified, err := json.Marshal(raw)
+	if err != nil {
+		t.Fatalf("Failed to marshal: %v", err)
+	}
+
+	err = ValidateSchema(modified)
+	var serr *SchemaError
+	if !errors.As(err, &serr) {
+		t.Fatalf("Expected a SchemaError, got %v", err)
+	}
+	if serr.Kind != KindAttestation {
+		t.Errorf("Expected attestation kind, got %s", serr.Kind)
+	}
+	pointers := map[string]bool{}
+	for _, v := range serr.Violations {
+		pointers[v.Pointer] = true
+	}
+	for _, p := range []string{"/version", "/envelope", "/envelope/statement"} {
+		if !pointers[p] {
+			t.Errorf("Expected a violation at %s, got %v", p, serr.Violations)
+		}
+	}
+}
+
+func TestSentinelErrors(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+
+	unsupported := proto.Clone(attestation).(*pb.Attestation)
+	unsupported.Version = 7
+	noEntries := proto.Clone(attestation).(*pb.Attestation)
+	noEntries.VerificationMaterial.TransparencyEntries = nil
+	badCert := proto.Clone(attestation).(*pb.Attestation)
+	badCert.VerificationMaterial.Certificate = []byte("junk")
+	multiSig := proto.Clone(attestation).(*pb.Attestation)
+	multiSig.Envelope.AdditionalSignatures = []*pb.Signature{{Sig: []byte("second")}}
+	messageSig := &bundle.Bundle{Bundle: proto.Clone(b.Bundle).(*protobundle.Bundle)}
+	messageSig.Content = &protobundle.Bundle_MessageSignature{
+		MessageSignature: &protocommon.MessageSignature{Signature: attestation.Envelope.Signature},
+	}
+
+	for _, tc := range []struct {
+		name string
+		fn   func() error
+		want []error
+	}{
+		{"version", func() error { _, err := ToBundle(unsupported); return err }, []error{ErrUnsupportedVersion}},
+		{"entries", func() error { _, err := ToBundle(noEntries); return err }, []error{ErrNoTransparencyEntries}},
+		{"certificate", func() error { _, err := ToBundle(badCert); return err }, []error{ErrInvalidCertificate}},
+		{"dsse", func() error { _, err := FromBundle(messageSig); return err }, []error{ErrNotDSSE}},
+		{"signatures", func() error { _, err := MarshalAttestation(multiSig, WithStrict(true)); return err }, []error{ErrLossyConversion, ErrMultipleSignatures}},
+		{"format", func() error { _, err := Detect([]byte(`{"foo": 1}`)); return err }, []error{ErrUnrecognizedFormat}},
+		{"size", func() error { _, err := ReadAttestation(bytes.NewReader(data), WithMaxSize(10)); return err }, []error{ErrTooLarge}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.fn()
+			for _, want := range tc.want {
+				if !errors.Is(err, want) {
+					t.Errorf("Expected error %v, got %v", want, err)
+				}
+			}
+		})
+	}
+
+	_, err = MarshalAttestation(multiSig, WithStrict(true))
+	var lossErr *LossError
+	if !errors.As(err, &lossErr) || lossErr.Field != "additional_signatures" {
+		t.Errorf("Expected a LossError for additional_signatures, got %v", err)
+	}
+}
+
+func TestUnmarshalLimits(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	bundleData, err := MarshalBundle(b)
+	if err != nil {
+		t.Fatalf("Failed to marshal bundle: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		opt  ConvertOption
+	}{
+		{"size", WithMaxSize(100)},
+		{"entries", WithMaxTransparencyEntries(0)},
+		{"depth", WithMaxDepth(3)},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, errAttestation := UnmarshalAttestation(data, tc.opt)
+			_, errBundle := UnmarshalBundle(bundleData, tc.opt)
+			if tc.name == "entries" {
+				// Zero disables the limit
+				if errAttestation != nil || errBundle != nil {
+					t.Fatalf("Unexpected errors: %v, %v", errAttestation, errBundle)
+				}
+				return
+			}
+			if !errors.Is(errAttestation, ErrTooLarge) {
+				t.Errorf("Expected attestation error to be ErrTooLarge, got %v", errAttestation)
+			}
+			if !errors.Is(errBundle, ErrTooLarge) {
+				t.Errorf("Expected bundle error to be ErrTooLarge, got %v", errBundle)
+			}
+		})
+	}
+
+	attestation.VerificationMaterial.TransparencyEntries = append(
+		attestation.VerificationMaterial.TransparencyEntries, attestation.VerificationMaterial.TransparencyEntries[0],
+	)
+	b, err = ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert to bundle: %v", err)
+	}
+	bundleData, err = MarshalBundle(b)
+	if err != nil {
+		t.Fatalf("Failed to marshal bundle: %v", err)
+	}
+	if _, err := UnmarshalBundle(bundleData, WithMaxTransparencyEntries(1)); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge for too many entries, got %v", err)
+	}
+
+	deep := []byte(strings.Repeat("[", 100) + strings.Repeat("]", 100))
+	if _, err := UnmarshalAttestation(deep); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("Expected ErrTooLarge for deep nesting, got %v", err)
+	}
+	// Brackets inside strings do not count
+	if err := checkDepth([]byte(`{"a": "[[[[[[\"[[[["}`), 2); err != nil {
+		t.Errorf("Unexpected depth error: %v", err)
+	}
+}
+
+func TestDecodeAttestationTyped(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+
+	docs := map[string]string{
+		"testdata":         string(data),
+		"no version":       `{"envelope": {"statement": "YQ==", "signature": "Yg=="}}`,
+		"nulls":            `{"version": 1, "verification_material": null, "envelope": {"statement": null}}`,
+		"non-object entry": `{"version": 1, "verification_material": {"transparency_entries": [1, {"logIndex": "7"}]}}`,
+		"wrong type":       `{"version": 1, "verification_material": {"certificate": 5}, "envelope": {"signature": "Yg=="}}`,
+		"duplicate entry":  `{"version": 1, "verification_material": {"transparency_entries": [{"a": 1, "a": 2}]}}`,
+		"invalid version":  `{"version": "1"}`,
+		"unknown version":  `{"version": 7}`,
+		"bad base64":       `{"version": 1, "envelope": {"statement": "!!"}}`,
+		"invalid JSON":     `{"version": 1,`,
+		"too many entries": `{"verification_material": {"transparency_entries": [` + strings.Repeat(`{},`, 40) + `{}]}}`,
+		"duplicate keys":   `{"version": 1, "envelope": {"statement": "YQ=="}, "envelope": {"statement": "Yg=="}}`,
+		"unknown keys":     `{"version": 1, "Extra": {"Envelope": 1}, "envelope": {"statement": "YQ==", "note": [1, "]"]}}`,
+	}
+	for name, doc := range docs {
+		t.Run(name, func(t *testing.T) {
+			got, err := UnmarshalAttestation([]byte(doc))
+
+			// Reference result of the generic decoder
+			var want *pb.Attestation
+			var raw map[string]interface{}
+			wantErr := json.Unmarshal([]byte(doc), &raw)
+			if wantErr == nil {
+				opts := defaultConvertOptions
+				want, wantErr = decodeAttestation(raw, &opts)
+			}
+
+			if (err == nil) != (wantErr == nil) {
+				t.Fatalf("Error mismatch: got %v, want %v", err, wantErr)
+			}
+			if !proto.Equal(got, want) {
+				t.Errorf("Attestation mismatch:\ngot  %v\nwant %v", got, want)
+			}
+		})
+	}
+
+	// Keys match regardless of case and repeated objects are merged, like
+	// everywhere in encoding/json
+	got, err := UnmarshalAttestation([]byte(`{"Version": 1, "Envelope": {"statement": "YQ=="}, "envelope": {"\u017fignature": "Yg=="}, "verification_material": {"Certificate": "Yg=="}}`))
+	if err != nil {
+		t.Fatalf("Failed to unmarshal mixed case keys: %v", err)
+	}
+	if got.Version != 1 || string(got.Envelope.Statement) != "a" || string(got.Envelope.Signature) != "b" || string(got.VerificationMaterial.Certificate) != "b" {
+		t.Errorf("Unexpected attestation for mixed case keys: %v", got)
+	}
+}
+
+func TestMarshalAttestationTo(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	// Entry values exercising the escaping and number formatting
+	entry, err := structpb.NewStruct(map[string]interface{}{
+		"s":    "<a href=\"x\">&\\\n\t\b\f\r\x01\x1f  é</a>",
+		"n":    []interface{}{0, -1.5, 1e21, 1e-7, 123456789, 0.000001},
+		"o":    map[string]interface{}{},
+		"l":    []interface{}{},
+		"z":    nil,
+		"b":    true,
+		"k<\"": "v",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create struct: %v", err)
+	}
+	odd := proto.Clone(attestation).(*pb.Attestation)
+	odd.VerificationMaterial.TransparencyEntries = append(odd.VerificationMaterial.TransparencyEntries, entry, &structpb.Struct{})
+
+	for name, a := range map[string]*pb.Attestation{
+		"testdata":   attestation,
+		"odd":        odd,
+		"no entries": {VerificationMaterial: &pb.VerificationMaterial{}, Envelope: &pb.Envelope{}},
+		"empty":      {VerificationMaterial: &pb.VerificationMaterial{TransparencyEntries: []*structpb.Struct{}}, Envelope: &pb.Envelope{}},
+	} {
+		for _, canonical := range []bool{false, true} {
+			t.Run(fmt.Sprintf("%s/canonical=%v", name, canonical), func(t *testing.T) {
+				// Reference output of encoding/json
+				ref := map[string]interface{}{
+					"version": a.Version,
+					"verification_material": map[string]interface{}{
+						"certificate":          base64.StdEncoding.EncodeToString(a.VerificationMaterial.Certificate),
+						"transparency_entries": a.VerificationMaterial.TransparencyEntries,
+					},
+					"envelope": map[string]interface{}{
+						"statement": base64.StdEncoding.EncodeToString(a.Envelope.Statement),
+						"signature": base64.StdEncoding.EncodeToString(a.Envelope.Signature),
+					},
+				}
+				opts := defaultConvertOptions
+				opts.Canonical = canonical
+				want, err := opts.marshalJSON(ref)
+				if err != nil {
+					t.Fatalf("Failed to marshal reference: %v", err)
+				}
+
+				got, err := MarshalAttestationTo([]byte("prefix"), a, WithCanonical(canonical))
+				if err != nil {
+					t.Fatalf("Failed to marshal attestation: %v", err)
+				}
+				if !bytes.Equal(got, append([]byte("prefix"), want...)) {
+					t.Errorf("Output mismatch:\ngot  %s\nwant prefix%s", got, want)
+				}
+			})
+		}
+	}
+
+	// Appending to a buffer large enough does not allocate
+	buf := make([]byte, 0, 64<<10)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, err := MarshalAttestationTo(buf[:0], attestation); err != nil {
+			t.Fatal(err)
+		}
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+
+	// Invalid values fail
+	bad := proto.Clone(attestation).(*pb.Attestation)
+	bad.VerificationMaterial.TransparencyEntries = []*structpb.Struct{{Fields: map[string]*structpb.Value{"s": structpb.NewStringValue("\xff")}}}
+	if _, err := MarshalAttestationTo(nil, bad); err == nil {
+		t.Error("Expected error on invalid UTF-8")
+	}
+}
+
+func TestMarshalBundleTo(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	b, err := ToBundle(attestation)
+	if err != nil {
+		t.Fatalf("Failed to convert attestation: %v", err)
+	}
+
+	for _, canonical := range []bool{false, true} {
+		want, err := MarshalBundle(b, WithCanonical(canonical))
+		if err != nil {
+			t.Fatalf("Failed to marshal bundle: %v", err)
+		}
+		got, err := MarshalBundleTo([]byte("prefix"), b, WithCanonical(canonical))
+		if err != nil {
+			t.Fatalf("Failed to marshal bundle: %v", err)
+		}
+		// protojson varies its whitespace between builds, compare values
+		if !bytes.HasPrefix(got, []byte("prefix")) {
+			t.Fatalf("Expected output appended to dst, got %q", got[:10])
+		}
+		var gotBundle, wantBundle interface{}
+		if err := json.Unmarshal(got[len("prefix"):], &gotBundle); err != nil {
+			t.Fatalf("Invalid output: %v", err)
+		}
+		if err := json.Unmarshal(want, &wantBundle); err != nil {
+			t.Fatalf("Invalid output: %v", err)
+		}
+		if fmt.Sprint(gotBundle) != fmt.Sprint(wantBundle) {
+			t.Errorf("Bundle mismatch (canonical=%v)", canonical)
+		}
+	}
+}
+
+func BenchmarkMarshalAttestation(b *testing.B) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		b.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		b.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	b.Run("new", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := MarshalAttestation(attestation); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("append", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := make([]byte, 0, 64<<10)
+		for b.Loop() {
+			if _, err := MarshalAttestationTo(buf[:0], attestation); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("canonical", func(b *testing.B) {
+		b.ReportAllocs()
+		buf := make([]byte, 0, 64<<10)
+		for b.Loop() {
+			if _, err := MarshalAttestationTo(buf[:0], attestation, WithCanonical(true)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestWithCertificate(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	cert, err := x509.ParseCertificate(attestation.VerificationMaterial.Certificate)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), NotAfter: time.Now().Add(time.Hour)}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	other, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+
+	// A certificate other than the attestation one is ignored
+	for _, c := range []*x509.Certificate{cert, other} {
+		b, err := ToBundle(attestation, WithCertificate(c))
+		if err != nil {
+			t.Fatalf("Failed to convert to bundle: %v", err)
+		}
+		if !bytes.Equal(b.VerificationMaterial.GetCertificate().GetRawBytes(), attestation.VerificationMaterial.Certificate) {
+			t.Error("Bundle certificate does not match the attestation")
+		}
+	}
+}
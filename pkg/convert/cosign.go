@@ -0,0 +1,186 @@
+package convert
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/mediatype"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protodsse "github.com/sigstore/protobuf-specs/gen/pb-go/dsse"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// CosignBundle is the legacy bundle written by cosign sign-blob and
+// attest-blob with the --bundle flag.
+type CosignBundle struct {
+	// Base64Signature is the signature over the blob. For attest-blob it
+	// holds the whole DSSE envelope JSON.
+	Base64Signature string `json:"base64Signature"`
+
+	// Cert is the base64 encoded PEM signing certificate. It is empty for
+	// key based signatures.
+	Cert string `json:"cert,omitempty"`
+
+	// RekorBundle is the offline proof of the Rekor entry.
+	RekorBundle *CosignRekorBundle `json:"rekorBundle"`
+}
+
+// CosignRekorBundle is the Rekor entry and its signed entry timestamp.
+type CosignRekorBundle struct {
+	SignedEntryTimestamp []byte             `json:"SignedEntryTimestamp"`
+	Payload              CosignRekorPayload `json:"Payload"`
+}
+
+// CosignRekorPayload is the Rekor entry of a cosign bundle.
+type CosignRekorPayload struct {
+	// Body is the base64 encoded canonicalized Rekor entry.
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogIndex       int64  `json:"logIndex"`
+
+	// LogID is the hex encoded ID of the log.
+	LogID string `json:"logID"`
+}
+
+// rekorBody is the subset of a canonicalized Rekor entry needed to rebuild
+// the bundle content.
+type rekorBody struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Spec       struct {
+		Data struct {
+			Hash struct {
+				Algorithm string `json:"algorithm"`
+				Value     string `json:"value"`
+			} `json:"hash"`
+		} `json:"data"`
+	} `json:"spec"`
+}
+
+// UnmarshalCosignBundle parses a legacy cosign bundle.
+func UnmarshalCosignBundle(data []byte) (*CosignBundle, error) {
+	cb := &CosignBundle{}
+	if err := json.Unmarshal(data, cb); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal cosign bundle: %w", err)
+	}
+	if cb.Base64Signature == "" || cb.RekorBundle == nil {
+		return nil, fmt.Errorf("cosign bundle requires a signature and a Rekor bundle")
+	}
+	return cb, nil
+}
+
+// FromCosignBundle converts a legacy cosign bundle to a v0.1 Sigstore
+// bundle. Bundles written by attest-blob contain a DSSE envelope and can be
+// further converted to PEP 740 attestations, sign-blob bundles become
+// message signatures.
+//
+// Cosign bundles only hold the signed entry timestamp of the Rekor entry,
+// so the result carries no inclusion proof. Use the rekor package to
+// backfill it before converting to newer bundle versions.
+func FromCosignBundle(data []byte) (*bundle.Bundle, error) {
+	cb, err := UnmarshalCosignBundle(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if cb.Cert == "" {
+		return nil, fmt.Errorf("%w: cosign bundle has no certificate, key based signatures are not supported", ErrInvalidCertificate)
+	}
+	certPEM, err := base64.StdEncoding.DecodeString(cb.Cert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode certificate: %w", err)
+	}
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return nil, fmt.Errorf("%w: certificate is not PEM encoded", ErrInvalidCertificate)
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(cb.Base64Signature)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	rawBody, err := base64.StdEncoding.DecodeString(cb.RekorBundle.Payload.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode Rekor entry body: %w", err)
+	}
+	body := rekorBody{}
+	if err := json.Unmarshal(rawBody, &body); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal Rekor entry body: %w", err)
+	}
+
+	logID, err := hex.DecodeString(cb.RekorBundle.Payload.LogID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode log ID: %w", err)
+	}
+
+	pbBundle := &protobundle.Bundle{
+		MediaType: mediatype.BundleV01,
+		VerificationMaterial: &protobundle.VerificationMaterial{
+			Content: &protobundle.VerificationMaterial_X509CertificateChain{
+				X509CertificateChain: &protocommon.X509CertificateChain{
+					Certificates: []*protocommon.X509Certificate{{RawBytes: block.Bytes}},
+				},
+			},
+			TlogEntries: []*protorekor.TransparencyLogEntry{
+				{
+					LogIndex:       cb.RekorBundle.Payload.LogIndex,
+					LogId:          &protocommon.LogId{KeyId: logID},
+					KindVersion:    &protorekor.KindVersion{Kind: body.Kind, Version: body.APIVersion},
+					IntegratedTime: cb.RekorBundle.Payload.IntegratedTime,
+					InclusionPromise: &protorekor.InclusionPromise{
+						SignedEntryTimestamp: cb.RekorBundle.SignedEntryTimestamp,
+					},
+					CanonicalizedBody: rawBody,
+				},
+			},
+		},
+	}
+
+	switch body.Kind {
+	case "intoto", "dsse":
+		envelope := &protodsse.Envelope{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(sig, envelope); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal DSSE envelope: %w", err)
+		}
+		pbBundle.Content = &protobundle.Bundle_DsseEnvelope{DsseEnvelope: envelope}
+	case "hashedrekord":
+		digest, err := hex.DecodeString(body.Spec.Data.Hash.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode artifact digest: %w", err)
+		}
+		if body.Spec.Data.Hash.Algorithm != "sha256" {
+			return nil, fmt.Errorf("unsupported artifact digest algorithm %q", body.Spec.Data.Hash.Algorithm)
+		}
+		pbBundle.Content = &protobundle.Bundle_MessageSignature{
+			MessageSignature: &protocommon.MessageSignature{
+				MessageDigest: &protocommon.HashOutput{
+					Algorithm: protocommon.HashAlgorithm_SHA2_256,
+					Digest:    digest,
+				},
+				Signature: sig,
+			},
+		}
+	default:
+		return nil, fmt.Errorf("unsupported Rekor entry kind %q", body.Kind)
+	}
+
+	return bundle.NewBundle(pbBundle)
+}
+
+// AttestationFromCosignBundle converts a legacy cosign attest-blob bundle to
+// a PEP 740 attestation. See FromCosignBundle.
+func AttestationFromCosignBundle(data []byte, funcs ...ConvertOption) (*pb.Attestation, error) {
+	b, err := FromCosignBundle(data)
+	if err != nil {
+		return nil, err
+	}
+	return FromBundle(b, funcs...)
+}
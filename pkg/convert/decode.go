@@ -0,0 +1,112 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// attestationV1JSON is the JSON form of a version 1 attestation. The
+// transparency entries are kept raw and converted one by one, the rest of
+// the document is decoded without generic maps. As everywhere in
+// encoding/json, keys match the fields regardless of case and repeated
+// objects are merged.
+type attestationV1JSON struct {
+	Version              interface{} `json:"version"`
+	VerificationMaterial struct {
+		Certificate         *string           `json:"certificate"`
+		TransparencyEntries []json.RawMessage `json:"transparency_entries"`
+	} `json:"verification_material"`
+	Envelope struct {
+		Statement *string `json:"statement"`
+		Signature *string `json:"signature"`
+	} `json:"envelope"`
+}
+
+// errUntyped signals that a document has to be decoded through the generic
+// maps of decodeAttestation.
+var errUntyped = errors.New("document needs generic decoding")
+
+// decodeAttestationTyped decodes a version 1 attestation through
+// attestationV1JSON. It returns errUntyped for other versions and for
+// fields of unexpected types, which decodeAttestationV1 ignores.
+func decodeAttestationTyped(data []byte, opts *ConvertOptions) (*pb.Attestation, error) {
+	var doc attestationV1JSON
+	if err := json.Unmarshal(data, &doc); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &typeErr) {
+			return nil, errUntyped
+		}
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+	if doc.Version != nil {
+		version, err := parseVersion(doc.Version)
+		if err != nil {
+			return nil, err
+		}
+		if version != 1 {
+			return nil, errUntyped
+		}
+	}
+
+	attestation := &pb.Attestation{
+		VerificationMaterial: &pb.VerificationMaterial{},
+		Envelope:             &pb.Envelope{},
+	}
+	if v, ok := doc.Version.(float64); ok {
+		attestation.Version = uint32(v)
+	}
+
+	vm := doc.VerificationMaterial
+	if vm.Certificate != nil {
+		cert, err := opts.decodeBase64(*vm.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode certificate: %w", err)
+		}
+		attestation.VerificationMaterial.Certificate = cert
+	}
+	if vm.TransparencyEntries != nil {
+		if err := opts.checkEntries(len(vm.TransparencyEntries)); err != nil {
+			return nil, err
+		}
+		for _, entry := range vm.TransparencyEntries {
+			// Entries that are not objects are skipped
+			if !bytes.HasPrefix(entry, []byte("{")) {
+				continue
+			}
+			var entryMap map[string]interface{}
+			if err := json.Unmarshal(entry, &entryMap); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+			}
+			s, err := structpb.NewStruct(entryMap)
+			if err != nil {
+				return nil, fmt.Errorf("failed to create transparency entry struct: %w", err)
+			}
+			attestation.VerificationMaterial.TransparencyEntries = append(
+				attestation.VerificationMaterial.TransparencyEntries, s,
+			)
+		}
+	}
+
+	env := doc.Envelope
+	if env.Statement != nil {
+		stmt, err := opts.decodeBase64(*env.Statement)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode statement: %w", err)
+		}
+		attestation.Envelope.Statement = stmt
+	}
+	if env.Signature != nil {
+		sig, err := opts.decodeBase64(*env.Signature)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode signature: %w", err)
+		}
+		attestation.Envelope.Signature = sig
+	}
+
+	return attestation, nil
+}
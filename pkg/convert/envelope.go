@@ -0,0 +1,84 @@
+package convert
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/mediatype"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	protodsse "github.com/sigstore/protobuf-specs/gen/pb-go/dsse"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// inTotoPayloadType is the DSSE payload type of PEP 740 statements.
+const inTotoPayloadType = mediatype.InToto
+
+// AttestationFromEnvelope assembles a PEP 740 attestation from its parts
+// when they were produced separately, for example when signing with
+// sigstore-go directly:
+//
+//   - envelope is the DSSE envelope JSON of the in-toto statement.
+//   - cert is the PEM or DER encoded signing certificate.
+//   - entries are the transparency log entries in their protobuf JSON form,
+//     as found in Sigstore bundles. Entries in the Rekor API format can be
+//     converted with rekor.ParseEntry.
+//
+// The signatures of the envelope are selected as in FromBundle.
+func AttestationFromEnvelope(envelope, cert []byte, entries [][]byte, funcs ...ConvertOption) (*pb.Attestation, error) {
+	opts, err := newConvertOptions(funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	env := &protodsse.Envelope{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(envelope, env); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal DSSE envelope: %w", err)
+	}
+	if env.PayloadType != inTotoPayloadType {
+		return nil, fmt.Errorf("%w %q", ErrUnsupportedPayloadType, env.PayloadType)
+	}
+
+	if block, _ := pem.Decode(cert); block != nil {
+		cert = block.Bytes
+	}
+	if _, err := x509.ParseCertificate(cert); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCertificate, err)
+	}
+
+	if len(entries) == 0 {
+		return nil, ErrNoTransparencyEntries
+	}
+	tlogEntries := make([]*structpb.Struct, len(entries))
+	for i, data := range entries {
+		entry := &protorekor.TransparencyLogEntry{}
+		if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, entry); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal transparency entry %d: %w", i, err)
+		}
+		s, err := TransparencyEntryToStruct(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to convert transparency entry %d: %w", i, err)
+		}
+		tlogEntries[i] = s
+	}
+
+	signature, additional, err := selectSignature(env.Signatures, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.Attestation{
+		Version: LatestAttestationVersion,
+		VerificationMaterial: &pb.VerificationMaterial{
+			Certificate:         cert,
+			TransparencyEntries: tlogEntries,
+		},
+		Envelope: &pb.Envelope{
+			Statement:            env.Payload,
+			Signature:            signature,
+			AdditionalSignatures: additional,
+		},
+	}, nil
+}
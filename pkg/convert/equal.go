@@ -0,0 +1,230 @@
+package convert
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+	"strings"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// maxDiffValue is the length at which values are truncated in differences.
+const maxDiffValue = 48
+
+// Difference is a field that differs between two compared documents.
+type Difference struct {
+	// Path is the path of the field using the proto field names, eg
+	// envelope.signature.
+	Path string
+
+	// A and B are printable forms of the values in each document.
+	A string
+	B string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s: %s != %s", d.Path, d.A, d.B)
+}
+
+// Equal reports whether a and b hold the same attestation. See Diff for the
+// accepted values and how they are compared.
+func Equal(a, b any) (bool, error) {
+	diffs, err := Diff(a, b)
+	if err != nil {
+		return false, err
+	}
+	return len(diffs) == 0, nil
+}
+
+// Diff compares two attestations semantically and returns their
+// differences. Each value can be a *pb.Attestation, a *bundle.Bundle or the
+// JSON of either, so an attestation can be compared to its bundle form.
+//
+// The comparison is made on the decoded data, ignoring JSON key order,
+// base64 padding and the casing of transparency entry field names.
+// Transparency entries are compared in their protobuf form, bundles by the
+// attestation they convert to.
+func Diff(a, b any) ([]Difference, error) {
+	attA, entriesA, err := normalizeAttestation(a)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing first value: %w", err)
+	}
+	attB, entriesB, err := normalizeAttestation(b)
+	if err != nil {
+		return nil, fmt.Errorf("normalizing second value: %w", err)
+	}
+
+	var diffs []Difference
+	diffMessage("", attA.ProtoReflect(), attB.ProtoReflect(), &diffs)
+
+	const entriesPath = "verification_material.transparency_entries"
+	if len(entriesA) != len(entriesB) {
+		diffs = append(diffs, Difference{
+			Path: entriesPath,
+			A:    fmt.Sprintf("%d entries", len(entriesA)),
+			B:    fmt.Sprintf("%d entries", len(entriesB)),
+		})
+	}
+	for i := 0; i < len(entriesA) && i < len(entriesB); i++ {
+		diffMessage(fmt.Sprintf("%s[%d]", entriesPath, i), entriesA[i].ProtoReflect(), entriesB[i].ProtoReflect(), &diffs)
+	}
+	return diffs, nil
+}
+
+// normalizeAttestation decodes v to an attestation without transparency
+// entries and returns the entries separately in their protobuf form.
+func normalizeAttestation(v any) (*pb.Attestation, []*protorekor.TransparencyLogEntry, error) {
+	var attestation *pb.Attestation
+	var err error
+	switch t := v.(type) {
+	case *pb.Attestation:
+		attestation = t
+	case *bundle.Bundle:
+		attestation, err = FromBundle(t, WithStrict(false))
+	case []byte:
+		var p *Parsed
+		p, err = Parse(t)
+		if err != nil {
+			break
+		}
+		switch p.Kind {
+		case KindAttestation:
+			attestation = p.Attestation
+		case KindBundle:
+			attestation, err = FromBundle(p.Bundle, WithStrict(false))
+		default:
+			err = fmt.Errorf("cannot compare %s documents", p.Kind)
+		}
+	default:
+		err = fmt.Errorf("unsupported type %T", v)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	if attestation == nil {
+		return nil, nil, fmt.Errorf("attestation cannot be nil")
+	}
+
+	entries := make([]*protorekor.TransparencyLogEntry, len(attestation.GetVerificationMaterial().GetTransparencyEntries()))
+	for i, s := range attestation.GetVerificationMaterial().GetTransparencyEntries() {
+		entries[i], err = TransparencyEntryFromStruct(s)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to convert transparency entry %d: %w", i, err)
+		}
+	}
+
+	attestation = proto.Clone(attestation).(*pb.Attestation)
+	if attestation.VerificationMaterial != nil {
+		attestation.VerificationMaterial.TransparencyEntries = nil
+	}
+	return attestation, entries, nil
+}
+
+// diffMessage appends the differences between the populated fields of a
+// and b to diffs.
+func diffMessage(prefix string, a, b protoreflect.Message, diffs *[]Difference) {
+	fields := a.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		path := string(fd.Name())
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		switch {
+		case fd.IsList():
+			la, lb := a.Get(fd).List(), b.Get(fd).List()
+			if la.Len() != lb.Len() {
+				*diffs = append(*diffs, Difference{
+					Path: path,
+					A:    fmt.Sprintf("%d items", la.Len()),
+					B:    fmt.Sprintf("%d items", lb.Len()),
+				})
+			}
+			for j := 0; j < la.Len() && j < lb.Len(); j++ {
+				diffValue(fmt.Sprintf("%s[%d]", path, j), fd, la.Get(j), lb.Get(j), diffs)
+			}
+		case fd.IsMap():
+			ma, mb := a.Get(fd).Map(), b.Get(fd).Map()
+			keys := map[string]protoreflect.MapKey{}
+			for _, m := range []protoreflect.Map{ma, mb} {
+				m.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+					keys[k.String()] = k
+					return true
+				})
+			}
+			names := make([]string, 0, len(keys))
+			for k := range keys {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				k := keys[name]
+				p := fmt.Sprintf("%s[%s]", path, name)
+				switch {
+				case !ma.Has(k):
+					*diffs = append(*diffs, Difference{Path: p, A: "<unset>", B: formatValue(fd.MapValue(), mb.Get(k))})
+				case !mb.Has(k):
+					*diffs = append(*diffs, Difference{Path: p, A: formatValue(fd.MapValue(), ma.Get(k)), B: "<unset>"})
+				default:
+					diffValue(p, fd.MapValue(), ma.Get(k), mb.Get(k), diffs)
+				}
+			}
+		case fd.Message() != nil:
+			hasA, hasB := a.Has(fd), b.Has(fd)
+			switch {
+			case !hasA && !hasB:
+			case !hasA:
+				*diffs = append(*diffs, Difference{Path: path, A: "<unset>", B: "<set>"})
+			case !hasB:
+				*diffs = append(*diffs, Difference{Path: path, A: "<set>", B: "<unset>"})
+			default:
+				diffMessage(path, a.Get(fd).Message(), b.Get(fd).Message(), diffs)
+			}
+		default:
+			diffValue(path, fd, a.Get(fd), b.Get(fd), diffs)
+		}
+	}
+}
+
+// diffValue appends a difference if the values of the field fd differ.
+func diffValue(path string, fd protoreflect.FieldDescriptor, a, b protoreflect.Value, diffs *[]Difference) {
+	if fd.Message() != nil {
+		diffMessage(path, a.Message(), b.Message(), diffs)
+		return
+	}
+	if a.Equal(b) {
+		return
+	}
+	*diffs = append(*diffs, Difference{Path: path, A: formatValue(fd, a), B: formatValue(fd, b)})
+}
+
+// formatValue returns a printable, truncated form of a scalar value.
+func formatValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) string {
+	var s string
+	switch fd.Kind() {
+	case protoreflect.BytesKind:
+		s = base64.StdEncoding.EncodeToString(v.Bytes())
+	case protoreflect.StringKind:
+		s = fmt.Sprintf("%q", v.String())
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			s = string(ev.Name())
+		} else {
+			s = fmt.Sprint(v.Enum())
+		}
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		s = "<set>"
+	default:
+		s = v.String()
+	}
+	if len(s) > maxDiffValue {
+		s = strings.TrimSpace(s[:maxDiffValue]) + "..."
+	}
+	return s
+}
@@ -0,0 +1,79 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Errors returned by the conversion functions. They are wrapped with
+// details, test for them with errors.Is.
+var (
+	// ErrUnsupportedVersion is returned for attestations of a format
+	// version this package cannot handle.
+	ErrUnsupportedVersion = errors.New("unsupported attestation version")
+
+	// ErrNoTransparencyEntries is returned when an attestation has no
+	// transparency log entries.
+	ErrNoTransparencyEntries = errors.New("no transparency entries found")
+
+	// ErrInvalidTransparencyEntry is returned when a transparency entry
+	// cannot be decoded.
+	ErrInvalidTransparencyEntry = errors.New("invalid transparency entry")
+
+	// ErrInvalidCertificate is returned when the signing certificate is
+	// missing or cannot be parsed.
+	ErrInvalidCertificate = errors.New("invalid certificate")
+
+	// ErrNotDSSE is returned when a bundle holds something other than a
+	// DSSE envelope, such as a message signature.
+	ErrNotDSSE = errors.New("bundle does not contain a DSSE envelope")
+
+	// ErrUnsupportedPayloadType is returned for DSSE envelopes whose
+	// payload is not an in-toto statement.
+	ErrUnsupportedPayloadType = errors.New("unsupported envelope payload type")
+
+	// ErrNoSignatures is returned for envelopes without signatures.
+	ErrNoSignatures = errors.New("envelope has no signatures")
+
+	// ErrMultipleSignatures is returned when the additional signatures of
+	// a multi-signature envelope cannot be represented in the target
+	// format. It is always wrapped by a *LossError.
+	ErrMultipleSignatures = errors.New("multiple signatures")
+
+	// ErrLossyConversion is returned by strict conversions that would drop
+	// data. It is always wrapped by a *LossError.
+	ErrLossyConversion = errors.New("lossy conversion")
+
+	// ErrUnrecognizedFormat is returned when a document is none of the
+	// formats handled by this package.
+	ErrUnrecognizedFormat = errors.New("unrecognized attestation format")
+
+	// ErrTooLarge is returned when the input exceeds the size limits.
+	ErrTooLarge = errors.New("data too large")
+)
+
+// LossError is returned by strict conversions when the target format cannot
+// represent a field. It matches ErrLossyConversion and, when set, Err.
+type LossError struct {
+	// Field is the name of the field that would be dropped.
+	Field string
+
+	// Message describes what would be dropped.
+	Message string
+
+	// Err is a more specific sentinel for the loss, eg
+	// ErrMultipleSignatures. It may be nil.
+	Err error
+}
+
+func (e *LossError) Error() string {
+	return fmt.Sprintf("%s cannot be converted: %s", e.Field, e.Message)
+}
+
+// Unwrap returns ErrLossyConversion and the specific sentinel, if any.
+func (e *LossError) Unwrap() []error {
+	if e.Err == nil {
+		return []error{ErrLossyConversion}
+	}
+	return []error{ErrLossyConversion, e.Err}
+}
@@ -0,0 +1,92 @@
+package convert
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/publisher"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"google.golang.org/protobuf/proto"
+)
+
+// GitHubAttestations is the response of the GitHub artifact attestations
+// API (/repos/{owner}/{repo}/attestations/{digest}).
+type GitHubAttestations struct {
+	Attestations []GitHubAttestation `json:"attestations"`
+}
+
+// GitHubAttestation is an attestation returned by the GitHub API.
+type GitHubAttestation struct {
+	// Bundle is the Sigstore bundle of the attestation.
+	Bundle json.RawMessage `json:"bundle"`
+
+	// RepositoryID is the ID of the repository the attestation belongs to.
+	RepositoryID int64 `json:"repository_id"`
+
+	// BundleURL is the URL to download the bundle from when the API does
+	// not inline it.
+	BundleURL string `json:"bundle_url,omitempty"`
+}
+
+// FromGitHubAttestations converts a GitHub artifact attestations API
+// response to PEP 740 attestation bundles. The publisher of each
+// attestation is derived from its signing certificate, attestations with
+// the same publisher are grouped in the same bundle.
+//
+// Only attestations signed with Fulcio certificates of a supported Trusted
+// Publisher can be converted. Responses that do not inline the bundle
+// (only listing its URL) are rejected.
+func FromGitHubAttestations(data []byte, funcs ...ConvertOption) ([]*pb.AttestationBundle, error) {
+	resp := GitHubAttestations{}
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal GitHub attestations: %w", err)
+	}
+	if len(resp.Attestations) == 0 {
+		return nil, fmt.Errorf("response contains no attestations")
+	}
+
+	var bundles []*pb.AttestationBundle
+	for i, ga := range resp.Attestations {
+		if len(ga.Bundle) == 0 || string(ga.Bundle) == "null" {
+			return nil, fmt.Errorf("attestation %d has no inline bundle, fetch it from %q", i, ga.BundleURL)
+		}
+
+		b, err := UnmarshalBundle(ga.Bundle)
+		if err != nil {
+			return nil, fmt.Errorf("attestation %d: %w", i, err)
+		}
+		attestation, err := FromBundle(b, funcs...)
+		if err != nil {
+			return nil, fmt.Errorf("attestation %d: %w", i, err)
+		}
+
+		cert, err := x509.ParseCertificate(attestation.VerificationMaterial.Certificate)
+		if err != nil {
+			return nil, fmt.Errorf("attestation %d: failed to parse certificate: %w", i, err)
+		}
+		pub, err := publisher.FromCertificate(cert)
+		if err != nil {
+			return nil, fmt.Errorf("attestation %d: %w", i, err)
+		}
+		claims, err := publisher.ToStruct(pub)
+		if err != nil {
+			return nil, fmt.Errorf("attestation %d: %w", i, err)
+		}
+
+		var ab *pb.AttestationBundle
+		for _, existing := range bundles {
+			if proto.Equal(existing.Publisher, claims) {
+				ab = existing
+				break
+			}
+		}
+		if ab == nil {
+			ab = &pb.AttestationBundle{Publisher: claims}
+			bundles = append(bundles, ab)
+		}
+		ab.Attestations = append(ab.Attestations, attestation)
+	}
+
+	return bundles, nil
+}
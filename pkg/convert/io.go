@@ -0,0 +1,114 @@
+package convert
+
+import (
+	"fmt"
+	"io"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+)
+
+// DefaultMaxSize is the default limit of the data read by the Read
+// functions.
+//
+// The Read functions transparently decompress gzip and zstd input. The
+// size limit applies to the decompressed data.
+const DefaultMaxSize = 16 << 20
+
+// ReadAttestation reads a PEP 740 JSON attestation from r. Reading fails
+// if r holds more than the configured maximum size, see WithMaxSize.
+func ReadAttestation(r io.Reader, funcs ...ConvertOption) (*pb.Attestation, error) {
+	data, err := readLimited(r, funcs)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalAttestation(data, funcs...)
+}
+
+// WriteAttestation writes the attestation to w in PEP 740 JSON format.
+func WriteAttestation(w io.Writer, attestation *pb.Attestation, funcs ...ConvertOption) error {
+	data, err := MarshalAttestation(attestation, funcs...)
+	if err != nil {
+		return err
+	}
+	return writeAll(w, data)
+}
+
+// ReadBundle reads a JSON Sigstore bundle from r. Reading fails if r holds
+// more than the configured maximum size, see WithMaxSize.
+func ReadBundle(r io.Reader, funcs ...ConvertOption) (*bundle.Bundle, error) {
+	data, err := readLimited(r, funcs)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalBundle(data, funcs...)
+}
+
+// WriteBundle writes the bundle to w as JSON.
+func WriteBundle(w io.Writer, b *bundle.Bundle, funcs ...ConvertOption) error {
+	data, err := MarshalBundle(b, funcs...)
+	if err != nil {
+		return err
+	}
+	return writeAll(w, data)
+}
+
+// ReadProvenance reads a PEP 740 JSON provenance object from r. Reading
+// fails if r holds more than the configured maximum size, see WithMaxSize.
+func ReadProvenance(r io.Reader, funcs ...ConvertOption) (*pb.Provenance, error) {
+	data, err := readLimited(r, funcs)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalProvenance(data, funcs...)
+}
+
+// WriteProvenance writes the provenance object to w in PEP 740 JSON format.
+func WriteProvenance(w io.Writer, provenance *pb.Provenance, funcs ...ConvertOption) error {
+	data, err := MarshalProvenance(provenance, funcs...)
+	if err != nil {
+		return err
+	}
+	return writeAll(w, data)
+}
+
+// readLimited reads all of r, decompressing gzip and zstd data, failing
+// when it exceeds the maximum size.
+func readLimited(r io.Reader, funcs []ConvertOption) ([]byte, error) {
+	opts, err := newConvertOptions(funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	if r == nil {
+		return nil, fmt.Errorf("reader cannot be nil")
+	}
+
+	// The limit applies to the decompressed data to stop decompression
+	// bombs.
+	dr, closeFn, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	defer closeFn()
+
+	data, err := io.ReadAll(io.LimitReader(dr, opts.MaxSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading data: %w", err)
+	}
+	if int64(len(data)) > opts.MaxSize {
+		return nil, fmt.Errorf("%w: exceeds the maximum size of %d bytes", ErrTooLarge, opts.MaxSize)
+	}
+	return data, nil
+}
+
+// writeAll writes data to w.
+func writeAll(w io.Writer, data []byte) error {
+	if w == nil {
+		return fmt.Errorf("writer cannot be nil")
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("writing data: %w", err)
+	}
+	return nil
+}
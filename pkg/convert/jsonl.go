@@ -0,0 +1,126 @@
+package convert
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// BundleReader reads a stream of newline delimited Sigstore bundles
+// (JSONL) one bundle at a time.
+type BundleReader struct {
+	dec *json.Decoder
+	n   int
+}
+
+// NewBundleReader returns a BundleReader reading from r.
+func NewBundleReader(r io.Reader) *BundleReader {
+	return &BundleReader{dec: json.NewDecoder(r)}
+}
+
+// Next returns the next bundle of the stream. It returns io.EOF when the
+// stream is exhausted.
+func (br *BundleReader) Next() (*bundle.Bundle, error) {
+	var raw json.RawMessage
+	if err := br.dec.Decode(&raw); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, fmt.Errorf("reading bundle %d: %w", br.n, err)
+	}
+	br.n++
+
+	b, err := UnmarshalBundle(raw)
+	if err != nil {
+		return nil, fmt.Errorf("bundle %d: %w", br.n-1, err)
+	}
+	return b, nil
+}
+
+// BundleWriter writes Sigstore bundles as newline delimited JSON.
+type BundleWriter struct {
+	w *bufio.Writer
+}
+
+// NewBundleWriter returns a BundleWriter writing to w. Flush must be called
+// after the last bundle is written.
+func NewBundleWriter(w io.Writer) *BundleWriter {
+	return &BundleWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes b as a single line.
+func (bw *BundleWriter) Write(b *bundle.Bundle) error {
+	data, err := marshalBundleLine(b)
+	if err != nil {
+		return err
+	}
+	if _, err := bw.w.Write(data); err != nil {
+		return err
+	}
+	return bw.w.WriteByte('\n')
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (bw *BundleWriter) Flush() error {
+	return bw.w.Flush()
+}
+
+// MarshalBundles marshals bundles as newline delimited JSON.
+func MarshalBundles(bundles []*bundle.Bundle) ([]byte, error) {
+	var out []byte
+	for i, b := range bundles {
+		data, err := marshalBundleLine(b)
+		if err != nil {
+			return nil, fmt.Errorf("bundle %d: %w", i, err)
+		}
+		out = append(out, data...)
+		out = append(out, '\n')
+	}
+	return out, nil
+}
+
+// UnmarshalBundles unmarshals newline delimited JSON bundles.
+func UnmarshalBundles(data []byte) ([]*bundle.Bundle, error) {
+	return ReadBundles(bytes.NewReader(data))
+}
+
+// ReadBundles reads all the newline delimited JSON bundles from r.
+func ReadBundles(r io.Reader) ([]*bundle.Bundle, error) {
+	br := NewBundleReader(r)
+	var bundles []*bundle.Bundle
+	for {
+		b, err := br.Next()
+		if errors.Is(err, io.EOF) {
+			return bundles, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		bundles = append(bundles, b)
+	}
+}
+
+// marshalBundleLine marshals a bundle to single line JSON.
+func marshalBundleLine(b *bundle.Bundle) ([]byte, error) {
+	if b == nil || b.Bundle == nil {
+		return nil, fmt.Errorf("bundle cannot be nil")
+	}
+	data, err := protojson.Marshal(b.Bundle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+
+	// protojson output whitespace is not stable, compact it to ensure
+	// a single line
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, data); err != nil {
+		return nil, fmt.Errorf("failed to compact bundle JSON: %w", err)
+	}
+	return buf.Bytes(), nil
+}
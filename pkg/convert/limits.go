@@ -0,0 +1,80 @@
+package convert
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// Default parsing limits, see WithMaxTransparencyEntries and WithMaxDepth.
+const (
+	DefaultMaxTransparencyEntries = 32
+	DefaultMaxDepth               = 64
+)
+
+// checkLimits enforces the size and nesting depth limits on JSON data
+// before it is decoded.
+func (o *ConvertOptions) checkLimits(data []byte) error {
+	if o.MaxSize > 0 && int64(len(data)) > o.MaxSize {
+		return fmt.Errorf("%w: exceeds the maximum size of %d bytes", ErrTooLarge, o.MaxSize)
+	}
+	if o.MaxDepth > 0 {
+		if err := checkDepth(data, o.MaxDepth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkEntries enforces the limit on the number of transparency entries.
+func (o *ConvertOptions) checkEntries(n int) error {
+	if o.MaxTransparencyEntries > 0 && n > o.MaxTransparencyEntries {
+		return fmt.Errorf("%w: %d transparency entries exceed the maximum of %d", ErrTooLarge, n, o.MaxTransparencyEntries)
+	}
+	return nil
+}
+
+// checkDepth fails if the objects and arrays of the JSON data nest deeper
+// than max. It only tracks brackets outside strings, syntax errors are
+// left to the decoder.
+func checkDepth(data []byte, max int) error {
+	depth := 0
+	for i := 0; i < len(data); i++ {
+		switch data[i] {
+		case '"':
+			end := stringEnd(data, i)
+			if end < 0 {
+				return nil
+			}
+			i = end - 1
+		case '{', '[':
+			depth++
+			if depth > max {
+				return fmt.Errorf("%w: JSON nesting exceeds the maximum depth of %d", ErrTooLarge, max)
+			}
+		case '}', ']':
+			depth--
+		}
+	}
+	return nil
+}
+
+// stringEnd returns the offset following the JSON string starting with
+// the quote at data[start], or -1 if the string is not terminated.
+func stringEnd(data []byte, start int) int {
+	for i := start + 1; ; {
+		q := bytes.IndexByte(data[i:], '"')
+		if q < 0 {
+			return -1
+		}
+		i += q
+		// The quote is escaped if preceded by an odd number of backslashes
+		n := 0
+		for j := i - 1; j > start && data[j] == '\\'; j-- {
+			n++
+		}
+		i++
+		if n%2 == 0 {
+			return i
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package convert
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"sync"
+	"unicode/utf8"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// maxPooledBuffer is the capacity above which buffers are not returned to
+// the pool, so that a few huge documents do not pin memory.
+const maxPooledBuffer = 1 << 20
+
+// bufferPool holds the scratch buffers of the marshaling functions.
+var bufferPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 16<<10)
+		return &b
+	},
+}
+
+// keysPool holds the slices used to sort the keys of structs.
+var keysPool = sync.Pool{
+	New: func() any { return new([]string) },
+}
+
+func getBuffer() *[]byte {
+	return bufferPool.Get().(*[]byte)
+}
+
+func putBuffer(b *[]byte) {
+	if cap(*b) > maxPooledBuffer {
+		return
+	}
+	*b = (*b)[:0]
+	bufferPool.Put(b)
+}
+
+// MarshalAttestationTo appends the PEP 740 JSON of an attestation to dst
+// and returns the extended buffer, see MarshalAttestation. Called without
+// options, appending to a buffer with enough capacity does not allocate,
+// which suits hot paths reusing their buffers.
+func MarshalAttestationTo(dst []byte, attestation *pb.Attestation, funcs ...ConvertOption) ([]byte, error) {
+	// Applying options moves them to the heap, skip it without any
+	opts := defaultConvertOptions
+	if len(funcs) > 0 {
+		var err error
+		if opts, err = newConvertOptions(funcs); err != nil {
+			return dst, err
+		}
+	}
+
+	if n := len(attestation.GetVerificationMaterial().GetRfc3161Timestamps()); n > 0 {
+		if err := opts.lossy(nil, "rfc3161_timestamps", "%d timestamps cannot be represented in PEP 740 JSON", n); err != nil {
+			return dst, err
+		}
+	}
+	if n := len(attestation.GetVerificationMaterial().GetIntermediateCertificates()); n > 0 {
+		if err := opts.lossy(nil, "intermediate_certificates", "%d certificates cannot be represented in PEP 740 JSON", n); err != nil {
+			return dst, err
+		}
+	}
+	if n := len(attestation.GetEnvelope().GetAdditionalSignatures()); n > 0 {
+		if err := opts.lossy(ErrMultipleSignatures, "additional_signatures", "%d signatures cannot be represented in PEP 740 JSON", n); err != nil {
+			return dst, err
+		}
+	}
+
+	if !opts.Canonical {
+		w := jsonWriter{buf: dst, indent: true}
+		if err := w.attestation(attestation); err != nil {
+			return dst, err
+		}
+		return w.buf, nil
+	}
+
+	// The canonical form is produced from the compact one
+	scratch := getBuffer()
+	defer putBuffer(scratch)
+	w := jsonWriter{buf: *scratch}
+	err := w.attestation(attestation)
+	*scratch = w.buf
+	if err != nil {
+		return dst, err
+	}
+	out, err := canonicalize(w.buf)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, out...), nil
+}
+
+// MarshalBundleTo appends the JSON of a Sigstore Bundle to dst and returns
+// the extended buffer, see MarshalBundle.
+func MarshalBundleTo(dst []byte, b *bundle.Bundle, funcs ...ConvertOption) ([]byte, error) {
+	// Applying options moves them to the heap, skip it without any
+	opts := defaultConvertOptions
+	if len(funcs) > 0 {
+		var err error
+		if opts, err = newConvertOptions(funcs); err != nil {
+			return dst, err
+		}
+	}
+
+	if b == nil || b.Bundle == nil {
+		return dst, fmt.Errorf("bundle cannot be nil")
+	}
+
+	if !opts.Canonical {
+		return protojson.MarshalOptions{Multiline: true, Indent: "  "}.MarshalAppend(dst, b.Bundle)
+	}
+
+	scratch := getBuffer()
+	defer putBuffer(scratch)
+	data, err := protojson.MarshalOptions{}.MarshalAppend(*scratch, b.Bundle)
+	*scratch = data
+	if err != nil {
+		return dst, fmt.Errorf("failed to marshal bundle: %w", err)
+	}
+	out, err := canonicalize(data)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, out...), nil
+}
+
+// jsonWriter appends JSON to a buffer. The output is byte for byte that of
+// encoding/json, indented with two spaces when indent is set, and of
+// protojson for the structpb values it nests.
+type jsonWriter struct {
+	buf    []byte
+	indent bool
+	depth  int
+}
+
+// attestation writes the PEP 740 JSON of an attestation.
+func (w *jsonWriter) attestation(a *pb.Attestation) error {
+	vm, env := a.GetVerificationMaterial(), a.GetEnvelope()
+
+	w.open('{')
+	w.key("envelope", true)
+	w.open('{')
+	w.key("signature", true)
+	w.base64(env.GetSignature())
+	w.key("statement", false)
+	w.base64(env.GetStatement())
+	w.close('}')
+
+	w.key("verification_material", false)
+	w.open('{')
+	w.key("certificate", true)
+	w.base64(vm.GetCertificate())
+	w.key("transparency_entries", false)
+	if entries := vm.GetTransparencyEntries(); entries == nil {
+		w.buf = append(w.buf, "null"...)
+	} else if len(entries) == 0 {
+		w.buf = append(w.buf, "[]"...)
+	} else {
+		w.open('[')
+		for i, entry := range entries {
+			w.item(i == 0)
+			if entry == nil {
+				w.buf = append(w.buf, "null"...)
+				continue
+			}
+			if err := w.structValue(entry); err != nil {
+				return fmt.Errorf("failed to marshal transparency entry: %w", err)
+			}
+		}
+		w.close(']')
+	}
+	w.close('}')
+
+	w.key("version", false)
+	w.buf = strconv.AppendUint(w.buf, uint64(a.GetVersion()), 10)
+	w.close('}')
+	return nil
+}
+
+// value writes a structpb value.
+func (w *jsonWriter) value(v *structpb.Value) error {
+	switch k := v.GetKind().(type) {
+	case *structpb.Value_NullValue:
+		w.buf = append(w.buf, "null"...)
+	case *structpb.Value_BoolValue:
+		w.buf = strconv.AppendBool(w.buf, k.BoolValue)
+	case *structpb.Value_NumberValue:
+		if math.IsNaN(k.NumberValue) || math.IsInf(k.NumberValue, 0) {
+			return fmt.Errorf("invalid number %v", k.NumberValue)
+		}
+		w.buf = appendFloat(w.buf, k.NumberValue)
+	case *structpb.Value_StringValue:
+		return w.string(k.StringValue)
+	case *structpb.Value_StructValue:
+		return w.structValue(k.StructValue)
+	case *structpb.Value_ListValue:
+		values := k.ListValue.GetValues()
+		if len(values) == 0 {
+			w.buf = append(w.buf, "[]"...)
+			return nil
+		}
+		w.open('[')
+		for i, item := range values {
+			w.item(i == 0)
+			if err := w.value(item); err != nil {
+				return err
+			}
+		}
+		w.close(']')
+	default:
+		return fmt.Errorf("value has no kind set")
+	}
+	return nil
+}
+
+// structValue writes a struct with its keys sorted, as protojson does.
+func (w *jsonWriter) structValue(s *structpb.Struct) error {
+	fields := s.GetFields()
+	if len(fields) == 0 {
+		w.buf = append(w.buf, "{}"...)
+		return nil
+	}
+
+	keys := keysPool.Get().(*[]string)
+	defer func() {
+		clear(*keys)
+		*keys = (*keys)[:0]
+		keysPool.Put(keys)
+	}()
+	for k := range fields {
+		*keys = append(*keys, k)
+	}
+	slices.Sort(*keys)
+
+	w.open('{')
+	for i, k := range *keys {
+		w.item(i == 0)
+		if err := w.string(k); err != nil {
+			return err
+		}
+		w.colon()
+		if err := w.value(fields[k]); err != nil {
+			return fmt.Errorf("%s: %w", k, err)
+		}
+	}
+	w.close('}')
+	return nil
+}
+
+// open starts an object or array.
+func (w *jsonWriter) open(c byte) {
+	w.buf = append(w.buf, c)
+	w.depth++
+}
+
+// close ends an object or array.
+func (w *jsonWriter) close(c byte) {
+	w.depth--
+	w.newline()
+	w.buf = append(w.buf, c)
+}
+
+// key writes the key of an object member, preceded by a comma unless it is
+// the first one. Keys written with key need no escaping.
+func (w *jsonWriter) key(k string, first bool) {
+	w.item(first)
+	w.buf = append(w.buf, '"')
+	w.buf = append(w.buf, k...)
+	w.buf = append(w.buf, '"')
+	w.colon()
+}
+
+// item starts an array item or object member.
+func (w *jsonWriter) item(first bool) {
+	if !first {
+		w.buf = append(w.buf, ',')
+	}
+	w.newline()
+}
+
+func (w *jsonWriter) colon() {
+	w.buf = append(w.buf, ':')
+	if w.indent {
+		w.buf = append(w.buf, ' ')
+	}
+}
+
+func (w *jsonWriter) newline() {
+	if !w.indent {
+		return
+	}
+	w.buf = append(w.buf, '\n')
+	for range w.depth {
+		w.buf = append(w.buf, "  "...)
+	}
+}
+
+// base64 writes data as a standard base64 string.
+func (w *jsonWriter) base64(data []byte) {
+	w.buf = append(w.buf, '"')
+	w.buf = base64.StdEncoding.AppendEncode(w.buf, data)
+	w.buf = append(w.buf, '"')
+}
+
+// string writes a JSON string escaped like protojson output embedded by
+// encoding/json: control characters, quotes and backslashes as protojson
+// escapes them, plus the HTML and JavaScript sensitive characters.
+func (w *jsonWriter) string(s string) error {
+	const hex = "0123456789abcdef"
+	w.buf = append(w.buf, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c < utf8.RuneSelf {
+			if c >= ' ' && c != '"' && c != '\\' && c != '<' && c != '>' && c != '&' {
+				i++
+				continue
+			}
+			w.buf = append(w.buf, s[start:i]...)
+			switch c {
+			case '"', '\\':
+				w.buf = append(w.buf, '\\', c)
+			case '\b':
+				w.buf = append(w.buf, '\\', 'b')
+			case '\f':
+				w.buf = append(w.buf, '\\', 'f')
+			case '\n':
+				w.buf = append(w.buf, '\\', 'n')
+			case '\r':
+				w.buf = append(w.buf, '\\', 'r')
+			case '\t':
+				w.buf = append(w.buf, '\\', 't')
+			default:
+				w.buf = append(w.buf, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xf])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			return fmt.Errorf("invalid UTF-8 in string %q", s)
+		}
+		if r == '\u2028' || r == '\u2029' {
+			w.buf = append(w.buf, s[start:i]...)
+			w.buf = append(w.buf, '\\', 'u', '2', '0', '2', hex[r&0xf])
+			start = i + size
+		}
+		i += size
+	}
+	w.buf = append(w.buf, s[start:]...)
+	w.buf = append(w.buf, '"')
+	return nil
+}
+
+// appendFloat formats a number like encoding/json and protojson do.
+func appendFloat(b []byte, f float64) []byte {
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// Clean up e-09 to e-9
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
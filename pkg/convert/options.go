@@ -0,0 +1,288 @@
+package convert
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
+)
+
+// ConvertOptions controls how attestations are converted between formats.
+type ConvertOptions struct {
+	// Strict makes conversions that would drop data the target format
+	// cannot represent fail. When false, the default, the data is dropped
+	// and a warning is recorded.
+	Strict bool
+
+	// Warnings collects the data dropped by non strict conversions. It is
+	// left untouched when nil.
+	Warnings *Warnings
+
+	// BundleVersion is the Sigstore bundle media type version produced
+	// when converting to bundles: v0.1, v0.2 or v0.3.
+	BundleVersion string
+
+	// Canonical makes marshaling emit RFC 8785 (JCS) canonical JSON: sorted
+	// keys and no insignificant whitespace.
+	Canonical bool
+
+	// MaxSize is the maximum number of bytes read by the Read functions
+	// and accepted by the Unmarshal functions. For compressed input it
+	// limits the decompressed size.
+	MaxSize int64
+
+	// MaxTransparencyEntries is the maximum number of transparency entries
+	// accepted when unmarshaling an attestation or bundle. Zero disables
+	// the limit.
+	MaxTransparencyEntries int
+
+	// MaxDepth is the maximum nesting depth of the JSON documents accepted
+	// by the Unmarshal functions. Zero disables the limit.
+	MaxDepth int
+
+	// StrictBase64 makes decoding PEP 740 JSON only accept standard padded
+	// base64. When false, base64url and unpadded values are accepted too.
+	StrictBase64 bool
+
+	// StrictFields makes decoding PEP 740 JSON fail on unknown, missing or
+	// mistyped fields instead of ignoring them.
+	StrictFields bool
+
+	// SignatureIndex selects the signature of a multi-signature envelope
+	// that becomes the PEP 740 signature when converting from bundles.
+	SignatureIndex int
+
+	// Logger receives the conversions and the data they drop. When nil,
+	// slog.Default() is used.
+	Logger *slog.Logger
+
+	// Certificate is the already parsed signing certificate of the
+	// attestation being converted, see WithCertificate.
+	Certificate *x509.Certificate
+}
+
+var defaultConvertOptions = ConvertOptions{
+	BundleVersion:          "v0.3",
+	MaxSize:                DefaultMaxSize,
+	MaxTransparencyEntries: DefaultMaxTransparencyEntries,
+	MaxDepth:               DefaultMaxDepth,
+}
+
+// ConvertOption is a functional option to configure a conversion.
+type ConvertOption func(*ConvertOptions) error
+
+// newConvertOptions returns the default options modified by funcs.
+func newConvertOptions(funcs []ConvertOption) (ConvertOptions, error) {
+	opts := defaultConvertOptions
+	for _, fn := range funcs {
+		if err := fn(&opts); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// WithStrict enables or disables failing on conversions that drop data.
+// Conversions are lenient by default, see WithWarnings.
+func WithStrict(strict bool) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.Strict = strict
+		return nil
+	}
+}
+
+// WithAllowLossy enables or disables conversions that drop data. It is the
+// inverse of WithStrict.
+func WithAllowLossy(allow bool) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.Strict = !allow
+		return nil
+	}
+}
+
+// WithWarnings collects the data dropped by non strict conversions in w.
+func WithWarnings(w *Warnings) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.Warnings = w
+		return nil
+	}
+}
+
+// WithBundleVersion sets the media type version of the bundles produced by
+// ToBundle. Versions before v0.3 carry the signing certificate in a chain.
+func WithBundleVersion(version string) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.BundleVersion = version
+		return nil
+	}
+}
+
+// WithCanonical enables or disables RFC 8785 canonical JSON output, making
+// the marshaled bytes reproducible across runs and library versions.
+func WithCanonical(canonical bool) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.Canonical = canonical
+		return nil
+	}
+}
+
+// WithMaxSize sets the maximum number of bytes read by the Read functions
+// and accepted by the Unmarshal functions. Compressed input is limited by
+// its decompressed size.
+func WithMaxSize(n int64) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.MaxSize = n
+		return nil
+	}
+}
+
+// WithMaxTransparencyEntries sets the maximum number of transparency
+// entries of unmarshaled attestations and bundles. Zero disables the limit.
+func WithMaxTransparencyEntries(n int) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.MaxTransparencyEntries = n
+		return nil
+	}
+}
+
+// WithMaxDepth sets the maximum nesting depth of unmarshaled JSON
+// documents. Zero disables the limit.
+func WithMaxDepth(n int) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.MaxDepth = n
+		return nil
+	}
+}
+
+// WithStrictBase64 enables or disables rejecting base64url and unpadded
+// base64 when decoding PEP 740 JSON.
+func WithStrictBase64(strict bool) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.StrictBase64 = strict
+		return nil
+	}
+}
+
+// WithStrictFields enables or disables failing on unknown, missing or
+// mistyped fields when decoding PEP 740 JSON.
+func WithStrictFields(strict bool) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.StrictFields = strict
+		return nil
+	}
+}
+
+// WithSignatureIndex selects which signature of a multi-signature DSSE
+// envelope is used as the PEP 740 signature. The rest are carried in the
+// additional signatures of the attestation envelope.
+func WithSignatureIndex(i int) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.SignatureIndex = i
+		return nil
+	}
+}
+
+// WithLogger sets the logger of the conversion.
+func WithLogger(l *slog.Logger) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.Logger = l
+		return nil
+	}
+}
+
+// WithCertificate passes the parsed signing certificate of the attestation
+// so that ToBundle does not parse it again. It is ignored when it is not
+// the certificate of the attestation.
+func WithCertificate(cert *x509.Certificate) ConvertOption {
+	return func(o *ConvertOptions) error {
+		o.Certificate = cert
+		return nil
+	}
+}
+
+// certificate returns the parsed certificate if it matches der, or parses
+// der.
+func (o *ConvertOptions) certificate(der []byte) (*x509.Certificate, error) {
+	if o.Certificate != nil && bytes.Equal(o.Certificate.Raw, der) {
+		return o.Certificate, nil
+	}
+	return x509.ParseCertificate(der)
+}
+
+// logger returns the configured logger or the default one.
+func (o *ConvertOptions) logger() *slog.Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+	return slog.Default()
+}
+
+// Warning describes data dropped by a conversion.
+type Warning struct {
+	// Field is the name of the dropped field.
+	Field string `json:"field"`
+
+	// Message describes what was dropped.
+	Message string `json:"message"`
+}
+
+func (w Warning) String() string {
+	return w.Field + ": " + w.Message
+}
+
+// Warnings is the list of data dropped by a conversion.
+type Warnings []Warning
+
+// lossy handles a conversion that drops field. Strict conversions return a
+// *LossError wrapping sentinel, otherwise the loss is recorded as a warning.
+func (o *ConvertOptions) lossy(sentinel error, field, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if o.Strict {
+		return &LossError{Field: field, Message: msg, Err: sentinel}
+	}
+	o.logger().Info("conversion dropped data", "field", field, "message", msg)
+	if o.Warnings != nil {
+		*o.Warnings = append(*o.Warnings, Warning{Field: field, Message: msg})
+	}
+	return nil
+}
+
+// decodeBase64 decodes a base64 field of PEP 740 JSON. Unless StrictBase64
+// is set, the URL alphabet and missing padding are tolerated.
+func (o *ConvertOptions) decodeBase64(s string) ([]byte, error) {
+	if o.StrictBase64 {
+		return base64.StdEncoding.DecodeString(s)
+	}
+	enc := base64.RawStdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	return enc.DecodeString(strings.TrimRight(s, "="))
+}
+
+// marshalJSON marshals v indented, or canonicalized when the Canonical
+// option is set.
+func (o *ConvertOptions) marshalJSON(v any) ([]byte, error) {
+	if !o.Canonical {
+		return json.MarshalIndent(v, "", "  ")
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return canonicalize(data)
+}
+
+// canonicalize transforms JSON data to its RFC 8785 canonical form.
+func canonicalize(data []byte) ([]byte, error) {
+	out, err := jsoncanonicalizer.Transform(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize JSON: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,101 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/mediatype"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	protodsse "github.com/sigstore/protobuf-specs/gen/pb-go/dsse"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// Kind identifies the format of a parsed document.
+type Kind string
+
+// Formats recognized by Parse.
+const (
+	KindAttestation Kind = "attestation"
+	KindBundle      Kind = "bundle"
+	KindProvenance  Kind = "provenance"
+	KindEnvelope    Kind = "envelope"
+)
+
+// BundleMediaTypePrefix is the prefix of all Sigstore bundle media types.
+const BundleMediaTypePrefix = mediatype.BundlePrefix
+
+// Parsed is a document decoded by Parse. Only the field matching Kind is
+// set.
+type Parsed struct {
+	Kind Kind
+
+	Attestation *pb.Attestation
+	Bundle      *bundle.Bundle
+	Provenance  *pb.Provenance
+	Envelope    *protodsse.Envelope
+}
+
+// Parse detects whether data is a PEP 740 attestation, a Sigstore bundle, a
+// PEP 740 provenance object or a bare DSSE envelope and decodes it.
+func Parse(data []byte) (*Parsed, error) {
+	kind, err := Detect(data)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Parsed{Kind: kind}
+	switch kind {
+	case KindAttestation:
+		p.Attestation, err = UnmarshalAttestation(data)
+	case KindBundle:
+		p.Bundle, err = UnmarshalBundle(data)
+	case KindProvenance:
+		p.Provenance, err = UnmarshalProvenance(data)
+	case KindEnvelope:
+		p.Envelope = &protodsse.Envelope{}
+		if err = (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, p.Envelope); err != nil {
+			err = fmt.Errorf("failed to unmarshal DSSE envelope: %w", err)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Detect returns the format of data by looking at its top level keys.
+func Detect(data []byte) (Kind, error) {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return "", fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	if mt, ok := raw["mediaType"]; ok {
+		var mediaType string
+		if err := json.Unmarshal(mt, &mediaType); err == nil && mediatype.IsBundle(mediaType) {
+			return KindBundle, nil
+		}
+	}
+
+	has := func(keys ...string) bool {
+		for _, k := range keys {
+			if _, ok := raw[k]; !ok {
+				return false
+			}
+		}
+		return true
+	}
+
+	switch {
+	case has("attestation_bundles"):
+		return KindProvenance, nil
+	case has("verification_material", "envelope"):
+		return KindAttestation, nil
+	case has("verificationMaterial"):
+		return KindBundle, nil
+	case has("payload", "payloadType", "signatures"):
+		return KindEnvelope, nil
+	}
+	return "", ErrUnrecognizedFormat
+}
@@ -0,0 +1,351 @@
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PipelineOptions controls how a Pipeline converts documents.
+type PipelineOptions struct {
+	// Workers is the number of documents converted at once.
+	Workers int
+
+	// QueueSize is the number of documents waiting for a worker or for the
+	// sink. Together with Workers and the MaxSize of the conversion it
+	// bounds the memory used by the pipeline.
+	QueueSize int
+
+	// Convert are the options passed to the conversion functions.
+	Convert []ConvertOption
+}
+
+// PipelineOption is a functional option to configure a Pipeline.
+type PipelineOption func(*PipelineOptions) error
+
+// WithWorkers sets the number of documents converted at once. It defaults
+// to the number of CPUs.
+func WithWorkers(n int) PipelineOption {
+	return func(o *PipelineOptions) error {
+		if n < 1 {
+			return fmt.Errorf("workers must be at least 1")
+		}
+		o.Workers = n
+		return nil
+	}
+}
+
+// WithQueueSize sets the number of documents waiting for a worker or for
+// the sink. It defaults to twice the number of workers.
+func WithQueueSize(n int) PipelineOption {
+	return func(o *PipelineOptions) error {
+		if n < 0 {
+			return fmt.Errorf("queue size cannot be negative")
+		}
+		o.QueueSize = n
+		return nil
+	}
+}
+
+// WithPipelineConvertOptions sets the options used to convert each
+// document.
+func WithPipelineConvertOptions(funcs ...ConvertOption) PipelineOption {
+	return func(o *PipelineOptions) error {
+		o.Convert = funcs
+		return nil
+	}
+}
+
+// PipelineInput is a document fed to a Pipeline.
+type PipelineInput struct {
+	// Name identifies the document in the output, eg its path.
+	Name string
+
+	// Data is the document. It is ignored when Open is set.
+	Data []byte
+
+	// Open returns the document contents. It is called by the worker
+	// converting the document so only the documents being converted are
+	// held in memory. Compressed contents are decompressed.
+	Open func() (io.ReadCloser, error)
+}
+
+// PipelineOutput is the outcome of converting a PipelineInput.
+type PipelineOutput struct {
+	// Name is the name of the input.
+	Name string
+
+	// From and To are the formats of the input and converted documents.
+	From Kind
+	To   Kind
+
+	// Data is the converted document, nil if the conversion failed.
+	Data []byte
+
+	// Error is the reason the conversion failed, nil if it succeeded.
+	Error error
+}
+
+// PipelineSink receives the outputs of a Pipeline. It is never called
+// concurrently. Returning an error stops the pipeline.
+type PipelineSink func(PipelineOutput) error
+
+// PipelineStats aggregates the outcome of a pipeline run.
+type PipelineStats struct {
+	// Processed is the number of documents read from the input.
+	Processed int64
+
+	// Converted and Failed count the documents that converted and those
+	// that failed.
+	Converted int64
+	Failed    int64
+
+	// BytesIn and BytesOut are the sizes of the documents read and of the
+	// converted documents.
+	BytesIn  int64
+	BytesOut int64
+
+	// Errors counts the failures by cause, the message of the sentinel
+	// error they match (eg "lossy conversion") or "other".
+	Errors map[string]int64
+
+	// Duration is the time the run took.
+	Duration time.Duration
+}
+
+// Throughput returns the number of documents processed per second.
+func (s *PipelineStats) Throughput() float64 {
+	if s.Duration <= 0 {
+		return 0
+	}
+	return float64(s.Processed) / s.Duration.Seconds()
+}
+
+// Pipeline converts attestations to bundles and bundles to attestations
+// with a pool of workers, for corpora too large to convert one at a time.
+type Pipeline struct {
+	Options PipelineOptions
+}
+
+// NewPipeline returns a Pipeline configured with the passed options.
+func NewPipeline(funcs ...PipelineOption) (*Pipeline, error) {
+	// A negative queue size is replaced by the default once the number of
+	// workers is known
+	opts := PipelineOptions{Workers: runtime.GOMAXPROCS(0), QueueSize: -1}
+	for _, fn := range funcs {
+		if err := fn(&opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.QueueSize < 0 {
+		opts.QueueSize = 2 * opts.Workers
+	}
+	return &Pipeline{Options: opts}, nil
+}
+
+// Run converts the documents received from in until it is closed, passing
+// the outputs to sink in no particular order. A document failing to
+// convert does not stop the run, it is reported to the sink and counted in
+// the stats. Run returns early with the context error when ctx is done, or
+// with the error of the sink, so producers must stop sending to in when ctx
+// is done or Run returns.
+func (p *Pipeline) Run(ctx context.Context, in <-chan PipelineInput, sink PipelineSink) (*PipelineStats, error) {
+	start := time.Now()
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var bytesIn atomic.Int64
+	out := make(chan PipelineOutput, p.Options.QueueSize)
+	var wg sync.WaitGroup
+	for range p.Options.Workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				var item PipelineInput
+				var ok bool
+				select {
+				case <-runCtx.Done():
+					return
+				case item, ok = <-in:
+					if !ok {
+						return
+					}
+				}
+				res, n := p.convert(item)
+				bytesIn.Add(n)
+				select {
+				case <-runCtx.Done():
+					return
+				case out <- res:
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	stats := &PipelineStats{Errors: map[string]int64{}}
+	var sinkErr error
+	for res := range out {
+		if sinkErr != nil {
+			continue
+		}
+		stats.Processed++
+		if res.Error != nil {
+			stats.Failed++
+			stats.Errors[errorCause(res.Error)]++
+		} else {
+			stats.Converted++
+			stats.BytesOut += int64(len(res.Data))
+		}
+		if err := sink(res); err != nil {
+			sinkErr = fmt.Errorf("%s: %w", res.Name, err)
+			cancel()
+		}
+	}
+	stats.BytesIn = bytesIn.Load()
+	stats.Duration = time.Since(start)
+
+	if sinkErr != nil {
+		return stats, sinkErr
+	}
+	return stats, ctx.Err()
+}
+
+// RunFS converts the files of fsys whose base name matches glob (see
+// path.Match), walking the tree as the workers consume it. Input names are
+// the slash separated paths of the files.
+func (p *Pipeline) RunFS(ctx context.Context, fsys fs.FS, glob string, sink PipelineSink) (*PipelineStats, error) {
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	in := make(chan PipelineInput, p.Options.QueueSize)
+	walkErr := make(chan error, 1)
+	go func() {
+		defer close(in)
+		walkErr <- fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				return nil
+			}
+			// The pattern was validated above, Match cannot fail
+			if ok, _ := path.Match(glob, d.Name()); !ok {
+				return nil
+			}
+			item := PipelineInput{Name: name, Open: func() (io.ReadCloser, error) { return fsys.Open(name) }}
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case in <- item:
+				return nil
+			}
+		})
+	}()
+
+	stats, err := p.Run(ctx, in, sink)
+	cancel()
+	if werr := <-walkErr; err == nil && werr != nil && !errors.Is(werr, context.Canceled) {
+		err = fmt.Errorf("walking tree: %w", werr)
+	}
+	return stats, err
+}
+
+// convert converts a document, returning the output and the size of the
+// input.
+func (p *Pipeline) convert(item PipelineInput) (PipelineOutput, int64) {
+	res := PipelineOutput{Name: item.Name}
+	data := item.Data
+	if item.Open != nil {
+		rc, err := item.Open()
+		if err != nil {
+			res.Error = fmt.Errorf("opening document: %w", err)
+			return res, 0
+		}
+		data, err = readLimited(rc, p.Options.Convert)
+		rc.Close()
+		if err != nil {
+			res.Error = err
+			return res, int64(len(data))
+		}
+	}
+
+	var err error
+	res.From, err = Detect(data)
+	if err != nil {
+		res.Error = err
+		return res, int64(len(data))
+	}
+	switch res.From {
+	case KindAttestation:
+		res.To = KindBundle
+		res.Data, err = attestationToBundleJSON(data, p.Options.Convert)
+	case KindBundle:
+		res.To = KindAttestation
+		res.Data, err = bundleToAttestationJSON(data, p.Options.Convert)
+	default:
+		err = fmt.Errorf("cannot convert %s documents", res.From)
+	}
+	if err != nil {
+		res.Data, res.Error = nil, err
+	}
+	return res, int64(len(data))
+}
+
+// WriteTreeSink returns a sink writing the converted documents under dir,
+// at the path of their input name renamed with rename, or DefaultRename
+// when nil. Failed conversions are skipped. It pairs with RunFS to mirror
+// a tree of documents.
+func WriteTreeSink(dir string, rename func(name string, to Kind) string) PipelineSink {
+	if rename == nil {
+		rename = DefaultRename
+	}
+	return func(res PipelineOutput) error {
+		if res.Error != nil {
+			return nil
+		}
+		target := path.Join(path.Dir(res.Name), rename(path.Base(res.Name), res.To))
+		dest := filepath.Join(dir, filepath.FromSlash(target))
+		if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+			return fmt.Errorf("creating output directory: %w", err)
+		}
+		if err := os.WriteFile(dest, res.Data, 0o644); err != nil {
+			return fmt.Errorf("writing file: %w", err)
+		}
+		return nil
+	}
+}
+
+// pipelineCauses are the errors failures are counted by in the stats.
+var pipelineCauses = []error{
+	ErrLossyConversion, ErrTooLarge, ErrUnrecognizedFormat, ErrUnsupportedVersion,
+	ErrInvalidCertificate, ErrInvalidTransparencyEntry, ErrNoTransparencyEntries,
+	ErrNotDSSE, ErrUnsupportedPayloadType, ErrNoSignatures,
+}
+
+// errorCause returns the stats key of a conversion failure.
+func errorCause(err error) string {
+	for _, cause := range pipelineCauses {
+		if errors.Is(err, cause) {
+			return cause.Error()
+		}
+	}
+	return "other"
+}
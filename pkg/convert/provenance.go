@@ -0,0 +1,164 @@
+package convert
+
+import (
+	"encoding/json"
+	"fmt"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// MarshalProvenance marshals a Provenance object to JSON in PEP 740 format.
+// The options are applied to every contained attestation.
+func MarshalProvenance(provenance *pb.Provenance, funcs ...ConvertOption) ([]byte, error) {
+	opts, err := newConvertOptions(funcs)
+	if err != nil {
+		return nil, err
+	}
+
+	if provenance == nil {
+		return nil, fmt.Errorf("provenance cannot be nil")
+	}
+
+	bundles := make([]map[string]interface{}, 0, len(provenance.AttestationBundles))
+	for i, b := range provenance.AttestationBundles {
+		attestations := make([]json.RawMessage, 0, len(b.Attestations))
+		for j, a := range b.Attestations {
+			data, err := MarshalAttestation(a, funcs...)
+			if err != nil {
+				return nil, fmt.Errorf("marshaling attestation %d of bundle %d: %w", j, i, err)
+			}
+			attestations = append(attestations, data)
+		}
+
+		publisher := b.GetPublisher().AsMap()
+		bundles = append(bundles, map[string]interface{}{
+			"publisher":    publisher,
+			"attestations": attestations,
+		})
+	}
+
+	result := map[string]interface{}{
+		"version":             provenance.Version,
+		"attestation_bundles": bundles,
+	}
+
+	return opts.marshalJSON(result)
+}
+
+// UnmarshalProvenance unmarshals JSON in PEP 740 format to a Provenance.
+// The size and depth limits of the options apply to the whole document, the
+// options are also applied to every contained attestation.
+func UnmarshalProvenance(data []byte, funcs ...ConvertOption) (*pb.Provenance, error) {
+	opts, err := newConvertOptions(funcs)
+	if err != nil {
+		return nil, err
+	}
+	if err := opts.checkLimits(data); err != nil {
+		return nil, err
+	}
+
+	var raw struct {
+		Version            uint32 `json:"version"`
+		AttestationBundles []struct {
+			Publisher    map[string]interface{} `json:"publisher"`
+			Attestations []json.RawMessage      `json:"attestations"`
+		} `json:"attestation_bundles"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	provenance := &pb.Provenance{Version: raw.Version}
+	for i, b := range raw.AttestationBundles {
+		publisher, err := structpb.NewStruct(b.Publisher)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create publisher struct of bundle %d: %w", i, err)
+		}
+
+		bundle := &pb.AttestationBundle{Publisher: publisher}
+		for j, a := range b.Attestations {
+			attestation, err := UnmarshalAttestation(a, funcs...)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal attestation %d of bundle %d: %w", j, i, err)
+			}
+			bundle.Attestations = append(bundle.Attestations, attestation)
+		}
+		provenance.AttestationBundles = append(provenance.AttestationBundles, bundle)
+	}
+
+	return provenance, nil
+}
+
+// ProvenanceToBundles flattens the attestation bundles of a provenance
+// object into Sigstore bundles, one per attestation. The second slice holds
+// the publisher claims of each bundle, at the same index.
+func ProvenanceToBundles(provenance *pb.Provenance) ([]*bundle.Bundle, []*structpb.Struct, error) {
+	if provenance == nil {
+		return nil, nil, fmt.Errorf("provenance cannot be nil")
+	}
+
+	var bundles []*bundle.Bundle
+	var publishers []*structpb.Struct
+	for i, ab := range provenance.AttestationBundles {
+		for j, a := range ab.Attestations {
+			b, err := ToBundle(a)
+			if err != nil {
+				return nil, nil, fmt.Errorf("converting attestation %d of bundle %d: %w", j, i, err)
+			}
+			bundles = append(bundles, b)
+			publishers = append(publishers, ab.Publisher)
+		}
+	}
+
+	return bundles, publishers, nil
+}
+
+// NewProvenance builds a PEP 740 provenance object grouping attestations
+// produced by a publisher. The publisher claims must have a "kind" key
+// identifying the Trusted Publisher type (GitHub, GitLab, etc).
+func NewProvenance(publisher map[string]interface{}, attestations ...*pb.Attestation) (*pb.Provenance, error) {
+	provenance := &pb.Provenance{Version: 1}
+	if err := AddAttestationBundle(provenance, publisher, attestations...); err != nil {
+		return nil, err
+	}
+	return provenance, nil
+}
+
+// AddAttestationBundle appends a bundle with the attestations produced by a
+// publisher to the provenance object.
+func AddAttestationBundle(provenance *pb.Provenance, publisher map[string]interface{}, attestations ...*pb.Attestation) error {
+	if provenance == nil {
+		return fmt.Errorf("provenance cannot be nil")
+	}
+
+	if kind, ok := publisher["kind"].(string); !ok || kind == "" {
+		return fmt.Errorf("publisher must specify its kind")
+	}
+
+	if len(attestations) == 0 {
+		return fmt.Errorf("attestation bundle needs at least one attestation")
+	}
+
+	for i, a := range attestations {
+		if a == nil || a.GetVerificationMaterial() == nil || a.GetEnvelope() == nil {
+			return fmt.Errorf("attestation %d is incomplete", i)
+		}
+		caps, err := VersionCapabilities(a.Version)
+		if err != nil || !caps.Provenance {
+			return fmt.Errorf("attestation %d: %w: %d", i, ErrUnsupportedVersion, a.Version)
+		}
+	}
+
+	s, err := structpb.NewStruct(publisher)
+	if err != nil {
+		return fmt.Errorf("failed to create publisher struct: %w", err)
+	}
+
+	provenance.AttestationBundles = append(provenance.AttestationBundles, &pb.AttestationBundle{
+		Publisher:    s,
+		Attestations: attestations,
+	})
+	return nil
+}
@@ -0,0 +1,162 @@
+package convert
+
+import (
+	"bytes"
+	"embed"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// schemaFS holds the JSON schemas of the PEP 740 documents.
+//
+//go:embed schemas/*.schema.json
+var schemaFS embed.FS
+
+// schemaBaseURL is the base URL the embedded schemas are registered at.
+const schemaBaseURL = "mem:///"
+
+var (
+	compileSchemas sync.Once
+	schemas        map[Kind]*jsonschema.Schema
+	schemasErr     error
+)
+
+// SchemaViolation is a part of a document not matching its schema.
+type SchemaViolation struct {
+	// Pointer is the JSON pointer (RFC 6901) of the offending value, empty
+	// for the document root.
+	Pointer string
+
+	// Message describes the violation.
+	Message string
+}
+
+func (v SchemaViolation) String() string {
+	if v.Pointer == "" {
+		return "/: " + v.Message
+	}
+	return v.Pointer + ": " + v.Message
+}
+
+// SchemaError is returned by ValidateSchema when a document does not match
+// the schema of its kind.
+type SchemaError struct {
+	// Kind is the format the document was validated as.
+	Kind Kind
+
+	// Violations lists the mismatches found.
+	Violations []SchemaViolation
+}
+
+func (e *SchemaError) Error() string {
+	msgs := make([]string, len(e.Violations))
+	for i, v := range e.Violations {
+		msgs[i] = v.String()
+	}
+	return fmt.Sprintf("%s does not match its schema: %s", e.Kind, strings.Join(msgs, "; "))
+}
+
+// ValidateSchema validates a PEP 740 attestation or provenance object
+// against its JSON schema. The schemas are embedded in the package and
+// follow the models of PEP 740, transparency entries are checked against
+// the protobuf JSON form of Rekor TransparencyLogEntry messages.
+//
+// Mismatches are returned as a *SchemaError.
+func ValidateSchema(data []byte) error {
+	kind, err := Detect(data)
+	if err != nil {
+		return err
+	}
+	return ValidateSchemaKind(kind, data)
+}
+
+// ValidateSchemaKind validates data against the JSON schema of kind, which
+// must be KindAttestation or KindProvenance.
+func ValidateSchemaKind(kind Kind, data []byte) error {
+	compileSchemas.Do(loadSchemas)
+	if schemasErr != nil {
+		return schemasErr
+	}
+
+	schema, ok := schemas[kind]
+	if !ok {
+		return fmt.Errorf("no schema for %s documents", kind)
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	err = schema.Validate(doc)
+	if err == nil {
+		return nil
+	}
+	var verr *jsonschema.ValidationError
+	if !errors.As(err, &verr) {
+		return fmt.Errorf("validating %s: %w", kind, err)
+	}
+
+	serr := &SchemaError{Kind: kind}
+	collectViolations(verr, message.NewPrinter(language.English), &serr.Violations)
+	return serr
+}
+
+// loadSchemas compiles the embedded schemas.
+func loadSchemas() {
+	c := jsonschema.NewCompiler()
+	files := map[Kind]string{
+		KindAttestation: "attestation.schema.json",
+		KindProvenance:  "provenance.schema.json",
+	}
+	for _, name := range files {
+		data, err := schemaFS.ReadFile("schemas/" + name)
+		if err != nil {
+			schemasErr = fmt.Errorf("reading schema %s: %w", name, err)
+			return
+		}
+		doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+		if err != nil {
+			schemasErr = fmt.Errorf("parsing schema %s: %w", name, err)
+			return
+		}
+		if err := c.AddResource(schemaBaseURL+name, doc); err != nil {
+			schemasErr = fmt.Errorf("adding schema %s: %w", name, err)
+			return
+		}
+	}
+
+	schemas = map[Kind]*jsonschema.Schema{}
+	for kind, name := range files {
+		s, err := c.Compile(schemaBaseURL + name)
+		if err != nil {
+			schemasErr = fmt.Errorf("compiling schema %s: %w", name, err)
+			return
+		}
+		schemas[kind] = s
+	}
+}
+
+// collectViolations appends the leaf errors of a validation error tree.
+func collectViolations(err *jsonschema.ValidationError, p *message.Printer, out *[]SchemaViolation) {
+	if len(err.Causes) == 0 {
+		var pointer strings.Builder
+		for _, t := range err.InstanceLocation {
+			pointer.WriteString("/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(t))
+		}
+		*out = append(*out, SchemaViolation{
+			Pointer: pointer.String(),
+			Message: err.ErrorKind.LocalizedString(p),
+		})
+		return
+	}
+	for _, cause := range err.Causes {
+		collectViolations(cause, p, out)
+	}
+}
@@ -0,0 +1,99 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// jsonField describes a required field of a JSON document for strict
+// unmarshaling.
+type jsonField struct {
+	name   string
+	kind   string
+	fields []jsonField
+}
+
+// attestationV1Fields are the fields of a version 1 PEP 740 attestation.
+var attestationV1Fields = []jsonField{
+	{name: "version", kind: "number"},
+	{name: "verification_material", kind: "object", fields: []jsonField{
+		{name: "certificate", kind: "string"},
+		{name: "transparency_entries", kind: "array of objects"},
+	}},
+	{name: "envelope", kind: "object", fields: []jsonField{
+		{name: "statement", kind: "string"},
+		{name: "signature", kind: "string"},
+	}},
+}
+
+// UnmarshalAttestationStrict unmarshals JSON in PEP 740 format to an
+// Attestation, failing on unknown or missing fields and on fields of the
+// wrong type. The errors name the path of the offending fields. See
+// WithStrictFields.
+func UnmarshalAttestationStrict(data []byte, funcs ...ConvertOption) (*pb.Attestation, error) {
+	return UnmarshalAttestation(data, append(funcs, WithStrictFields(true))...)
+}
+
+// checkFields checks that obj has exactly the fields described by spec,
+// with the expected JSON types. All the problems found are returned.
+func checkFields(prefix string, obj map[string]interface{}, spec []jsonField) error {
+	var errs []error
+	known := map[string]bool{}
+	for _, f := range spec {
+		known[f.name] = true
+		path := f.name
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+
+		v, ok := obj[f.name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: missing required field", path))
+			continue
+		}
+
+		switch f.kind {
+		case "number":
+			_, ok = v.(float64)
+		case "string":
+			_, ok = v.(string)
+		case "object":
+			var m map[string]interface{}
+			if m, ok = v.(map[string]interface{}); ok {
+				errs = append(errs, checkFields(path, m, f.fields))
+			}
+		case "array of objects":
+			var items []interface{}
+			if items, ok = v.([]interface{}); ok {
+				for i, item := range items {
+					if _, isObj := item.(map[string]interface{}); !isObj {
+						errs = append(errs, fmt.Errorf("%s[%d]: expected an object", path, i))
+					}
+				}
+			}
+		}
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: expected %s", path, f.kind))
+		}
+	}
+
+	var unknown []string
+	for k := range obj {
+		if !known[k] {
+			unknown = append(unknown, k)
+		}
+	}
+	sort.Strings(unknown)
+	for _, k := range unknown {
+		path := k
+		if prefix != "" {
+			path = prefix + "." + path
+		}
+		errs = append(errs, fmt.Errorf("%s: unknown field", path))
+	}
+
+	return errors.Join(errs...)
+}
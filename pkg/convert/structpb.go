@@ -0,0 +1,356 @@
+package convert
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// The functions in this file convert protobuf messages to and from
+// structpb values walking them with protoreflect. They produce the same
+// values as marshaling the message with protojson and decoding the JSON
+// into a structpb.Struct, and the reverse, without the intermediate JSON
+// which dominated the cost of converting transparency entries.
+
+// messageToStruct converts m to a Struct following the protojson mapping:
+// fields are keyed by their JSON name, 64 bit integers are strings, bytes
+// are base64 encoded and enums are named.
+func messageToStruct(m protoreflect.Message) (*structpb.Struct, error) {
+	s := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+	var err error
+	m.Range(func(fd protoreflect.FieldDescriptor, v protoreflect.Value) bool {
+		var value *structpb.Value
+		value, err = fieldToValue(fd, v)
+		if err != nil {
+			err = fmt.Errorf("%s: %w", fd.JSONName(), err)
+			return false
+		}
+		s.Fields[fd.JSONName()] = value
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// fieldToValue converts the value of a field, a list or a map.
+func fieldToValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) (*structpb.Value, error) {
+	switch {
+	case fd.IsList():
+		list := v.List()
+		values := make([]*structpb.Value, list.Len())
+		for i := range list.Len() {
+			var err error
+			if values[i], err = singularToValue(fd, list.Get(i)); err != nil {
+				return nil, err
+			}
+		}
+		return structpb.NewListValue(&structpb.ListValue{Values: values}), nil
+	case fd.IsMap():
+		s := &structpb.Struct{Fields: map[string]*structpb.Value{}}
+		var err error
+		v.Map().Range(func(k protoreflect.MapKey, mv protoreflect.Value) bool {
+			var value *structpb.Value
+			if value, err = singularToValue(fd.MapValue(), mv); err != nil {
+				return false
+			}
+			s.Fields[k.String()] = value
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewStructValue(s), nil
+	}
+	return singularToValue(fd, v)
+}
+
+// singularToValue converts a single value of the kind of fd.
+func singularToValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) (*structpb.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return structpb.NewBoolValue(v.Bool()), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return structpb.NewNumberValue(float64(v.Int())), nil
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return structpb.NewNumberValue(float64(v.Uint())), nil
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return structpb.NewStringValue(strconv.FormatInt(v.Int(), 10)), nil
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return structpb.NewStringValue(strconv.FormatUint(v.Uint(), 10)), nil
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		f := v.Float()
+		switch {
+		case math.IsNaN(f):
+			return structpb.NewStringValue("NaN"), nil
+		case math.IsInf(f, 1):
+			return structpb.NewStringValue("Infinity"), nil
+		case math.IsInf(f, -1):
+			return structpb.NewStringValue("-Infinity"), nil
+		}
+		return structpb.NewNumberValue(f), nil
+	case protoreflect.StringKind:
+		return structpb.NewStringValue(v.String()), nil
+	case protoreflect.BytesKind:
+		return structpb.NewStringValue(base64.StdEncoding.EncodeToString(v.Bytes())), nil
+	case protoreflect.EnumKind:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return structpb.NewStringValue(string(ev.Name())), nil
+		}
+		return structpb.NewNumberValue(float64(v.Enum())), nil
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if isWellKnown(fd.Message()) {
+			return wellKnownToValue(v.Message())
+		}
+		s, err := messageToStruct(v.Message())
+		if err != nil {
+			return nil, err
+		}
+		return structpb.NewStructValue(s), nil
+	}
+	return nil, fmt.Errorf("unsupported field kind %s", fd.Kind())
+}
+
+// structToMessage sets the fields of m from s, the reverse of
+// messageToStruct. Fields are matched by JSON or proto name, unknown
+// fields are ignored and null values leave the field unset.
+func structToMessage(s *structpb.Struct, m protoreflect.Message) error {
+	fields := m.Descriptor().Fields()
+	for name, value := range s.GetFields() {
+		fd := fields.ByJSONName(name)
+		if fd == nil {
+			fd = fields.ByTextName(name)
+		}
+		if fd == nil {
+			continue
+		}
+		if _, ok := value.GetKind().(*structpb.Value_NullValue); ok {
+			continue
+		}
+		if err := setField(m, fd, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// setField sets the field fd of m from value.
+func setField(m protoreflect.Message, fd protoreflect.FieldDescriptor, value *structpb.Value) error {
+	switch {
+	case fd.IsList():
+		lv, ok := value.GetKind().(*structpb.Value_ListValue)
+		if !ok {
+			return fmt.Errorf("expected a list")
+		}
+		list := m.Mutable(fd).List()
+		for i, item := range lv.ListValue.GetValues() {
+			v, err := valueToSingular(fd, item, list.NewElement)
+			if err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+			list.Append(v)
+		}
+		return nil
+	case fd.IsMap():
+		sv, ok := value.GetKind().(*structpb.Value_StructValue)
+		if !ok {
+			return fmt.Errorf("expected an object")
+		}
+		mp := m.Mutable(fd).Map()
+		for k, item := range sv.StructValue.GetFields() {
+			key, err := valueToSingular(fd.MapKey(), structpb.NewStringValue(k), nil)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", k, err)
+			}
+			v, err := valueToSingular(fd.MapValue(), item, mp.NewValue)
+			if err != nil {
+				return fmt.Errorf("key %q: %w", k, err)
+			}
+			mp.Set(key.MapKey(), v)
+		}
+		return nil
+	}
+	v, err := valueToSingular(fd, value, func() protoreflect.Value { return m.NewField(fd) })
+	if err != nil {
+		return err
+	}
+	m.Set(fd, v)
+	return nil
+}
+
+// valueToSingular converts value to a single value of the kind of fd.
+// newMessage returns an empty message value for message fields.
+func valueToSingular(fd protoreflect.FieldDescriptor, value *structpb.Value, newMessage func() protoreflect.Value) (protoreflect.Value, error) {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		b, ok := value.GetKind().(*structpb.Value_BoolValue)
+		if !ok {
+			// Map keys are strings
+			if sv, isString := value.GetKind().(*structpb.Value_StringValue); isString && fd.ContainingMessage().IsMapEntry() {
+				parsed, err := strconv.ParseBool(sv.StringValue)
+				return protoreflect.ValueOfBool(parsed), err
+			}
+			return protoreflect.Value{}, fmt.Errorf("expected a boolean")
+		}
+		return protoreflect.ValueOfBool(b.BoolValue), nil
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := valueToInt(value, 32)
+		return protoreflect.ValueOfInt32(int32(n)), err
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := valueToInt(value, 64)
+		return protoreflect.ValueOfInt64(n), err
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := valueToUint(value, 32)
+		return protoreflect.ValueOfUint32(uint32(n)), err
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := valueToUint(value, 64)
+		return protoreflect.ValueOfUint64(n), err
+	case protoreflect.FloatKind, protoreflect.DoubleKind:
+		f, err := valueToFloat(value)
+		if fd.Kind() == protoreflect.FloatKind {
+			return protoreflect.ValueOfFloat32(float32(f)), err
+		}
+		return protoreflect.ValueOfFloat64(f), err
+	case protoreflect.StringKind:
+		sv, ok := value.GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("expected a string")
+		}
+		return protoreflect.ValueOfString(sv.StringValue), nil
+	case protoreflect.BytesKind:
+		sv, ok := value.GetKind().(*structpb.Value_StringValue)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("expected a base64 string")
+		}
+		b, err := decodeJSONBytes(sv.StringValue)
+		if err != nil {
+			return protoreflect.Value{}, err
+		}
+		return protoreflect.ValueOfBytes(b), nil
+	case protoreflect.EnumKind:
+		switch k := value.GetKind().(type) {
+		case *structpb.Value_StringValue:
+			ev := fd.Enum().Values().ByName(protoreflect.Name(k.StringValue))
+			if ev == nil {
+				return protoreflect.Value{}, fmt.Errorf("invalid enum value %q", k.StringValue)
+			}
+			return protoreflect.ValueOfEnum(ev.Number()), nil
+		case *structpb.Value_NumberValue:
+			n, err := valueToInt(value, 32)
+			return protoreflect.ValueOfEnum(protoreflect.EnumNumber(n)), err
+		}
+		return protoreflect.Value{}, fmt.Errorf("expected an enum name or number")
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		msg := newMessage()
+		if isWellKnown(fd.Message()) {
+			return msg, valueToWellKnown(value, msg.Message())
+		}
+		sv, ok := value.GetKind().(*structpb.Value_StructValue)
+		if !ok {
+			return protoreflect.Value{}, fmt.Errorf("expected an object")
+		}
+		return msg, structToMessage(sv.StructValue, msg.Message())
+	}
+	return protoreflect.Value{}, fmt.Errorf("unsupported field kind %s", fd.Kind())
+}
+
+// valueToInt converts a number or a decimal string to a signed integer of
+// the given size.
+func valueToInt(value *structpb.Value, bits int) (int64, error) {
+	switch k := value.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return strconv.ParseInt(k.StringValue, 10, bits)
+	case *structpb.Value_NumberValue:
+		f := k.NumberValue
+		if f != math.Trunc(f) || f < -math.Ldexp(1, bits-1) || f >= math.Ldexp(1, bits-1) {
+			return 0, fmt.Errorf("invalid integer %v", f)
+		}
+		return int64(f), nil
+	}
+	return 0, fmt.Errorf("expected an integer")
+}
+
+// valueToUint converts a number or a decimal string to an unsigned
+// integer of the given size.
+func valueToUint(value *structpb.Value, bits int) (uint64, error) {
+	switch k := value.GetKind().(type) {
+	case *structpb.Value_StringValue:
+		return strconv.ParseUint(k.StringValue, 10, bits)
+	case *structpb.Value_NumberValue:
+		f := k.NumberValue
+		if f != math.Trunc(f) || f < 0 || f >= math.Ldexp(1, bits) {
+			return 0, fmt.Errorf("invalid unsigned integer %v", f)
+		}
+		return uint64(f), nil
+	}
+	return 0, fmt.Errorf("expected an unsigned integer")
+}
+
+// valueToFloat converts a number, or the strings protojson uses for the
+// special values, to a float.
+func valueToFloat(value *structpb.Value) (float64, error) {
+	switch k := value.GetKind().(type) {
+	case *structpb.Value_NumberValue:
+		return k.NumberValue, nil
+	case *structpb.Value_StringValue:
+		switch k.StringValue {
+		case "NaN":
+			return math.NaN(), nil
+		case "Infinity":
+			return math.Inf(1), nil
+		case "-Infinity":
+			return math.Inf(-1), nil
+		}
+		return strconv.ParseFloat(k.StringValue, 64)
+	}
+	return 0, fmt.Errorf("expected a number")
+}
+
+// decodeJSONBytes decodes a bytes field the way protojson does, accepting
+// the standard and URL alphabets with or without padding.
+func decodeJSONBytes(s string) ([]byte, error) {
+	enc := base64.RawStdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.RawURLEncoding
+	}
+	b, err := enc.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64: %w", err)
+	}
+	return b, nil
+}
+
+// isWellKnown reports whether md is a well-known type with a special JSON
+// mapping, eg Timestamp. They are converted through protojson.
+func isWellKnown(md protoreflect.MessageDescriptor) bool {
+	return md.ParentFile().Package() == "google.protobuf"
+}
+
+// wellKnownToValue converts a well-known type through its JSON form.
+func wellKnownToValue(m protoreflect.Message) (*structpb.Value, error) {
+	data, err := protojson.Marshal(m.Interface())
+	if err != nil {
+		return nil, err
+	}
+	value := &structpb.Value{}
+	if err := protojson.Unmarshal(data, value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// valueToWellKnown sets a well-known type from its JSON form.
+func valueToWellKnown(value *structpb.Value, m protoreflect.Message) error {
+	data, err := protojson.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return protojson.UnmarshalOptions{DiscardUnknown: true}.Unmarshal(data, m.Interface())
+}
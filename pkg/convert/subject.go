@@ -0,0 +1,34 @@
+package convert
+
+import (
+	"fmt"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/distfile"
+)
+
+// NormalizeProjectName normalizes a project name as described in PEP 503.
+func NormalizeProjectName(name string) string {
+	return distfile.NormalizeName(name)
+}
+
+// ValidateSubjectName checks that the in-toto subject name of a PEP 740
+// attestation refers to the distribution filename. Project names are
+// compared after PEP 503 normalization to tolerate the PEP 427 escaping of
+// wheel filenames, the rest of the filename must match exactly.
+func ValidateSubjectName(subject, filename string) error {
+	s, err := distfile.Parse(subject)
+	if err != nil {
+		return fmt.Errorf("parsing subject name: %w", err)
+	}
+
+	f, err := distfile.Parse(filename)
+	if err != nil {
+		return fmt.Errorf("parsing distribution filename: %w", err)
+	}
+
+	if s.ProjectName() != f.ProjectName() || subject[len(s.Name):] != filename[len(f.Name):] {
+		return fmt.Errorf("subject name %q does not match distribution filename %q", subject, filename)
+	}
+
+	return nil
+}
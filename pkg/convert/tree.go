@@ -0,0 +1,215 @@
+package convert
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// File name suffixes of PEP 740 attestations and Sigstore bundles.
+const (
+	AttestationSuffix = ".publish.attestation"
+	BundleSuffix      = ".sigstore.json"
+)
+
+// TreeOptions controls how ConvertTree converts the files of a tree.
+type TreeOptions struct {
+	// OutputDir is the directory where converted files are written. The
+	// directory structure of the source tree is mirrored under it.
+	OutputDir string
+
+	// Rename returns the name of the converted file from the name of the
+	// source file and the format it is converted to.
+	Rename func(name string, to Kind) string
+
+	// Convert are the options passed to the conversion functions.
+	Convert []ConvertOption
+}
+
+var defaultTreeOptions = TreeOptions{
+	Rename: DefaultRename,
+}
+
+// TreeOption is a functional option to configure ConvertTree.
+type TreeOption func(*TreeOptions)
+
+// WithOutputDir sets the directory where converted files are written.
+func WithOutputDir(dir string) TreeOption {
+	return func(o *TreeOptions) {
+		o.OutputDir = dir
+	}
+}
+
+// WithRename sets the function naming the converted files.
+func WithRename(fn func(name string, to Kind) string) TreeOption {
+	return func(o *TreeOptions) {
+		o.Rename = fn
+	}
+}
+
+// WithConvertOptions sets the options used to convert each file.
+func WithConvertOptions(funcs ...ConvertOption) TreeOption {
+	return func(o *TreeOptions) {
+		o.Convert = funcs
+	}
+}
+
+// TreeResult is the outcome of converting a file of a tree.
+type TreeResult struct {
+	// Source is the path of the file in the source tree.
+	Source string
+
+	// Target is the path of the written file, relative to the output
+	// directory. Empty if the conversion failed.
+	Target string
+
+	// From and To are the formats of the source and converted files.
+	From Kind
+	To   Kind
+
+	// Error is the reason the conversion failed, nil if it succeeded.
+	Error error
+}
+
+// DefaultRename swaps the .publish.attestation and .sigstore.json suffixes
+// of name, appending the suffix of the target format when name has none.
+func DefaultRename(name string, to Kind) string {
+	switch to {
+	case KindBundle:
+		return strings.TrimSuffix(name, AttestationSuffix) + BundleSuffix
+	case KindAttestation:
+		return strings.TrimSuffix(name, BundleSuffix) + AttestationSuffix
+	}
+	return name
+}
+
+// ConvertTree walks fsys converting every file whose base name matches
+// glob (see path.Match): attestations are converted to bundles and bundles
+// to attestations. The converted files are written under the output
+// directory, see WithOutputDir.
+//
+// A file failing to convert does not stop the walk. The returned slice has
+// a result for every matching file recording the written path or the
+// failure. The error is only set when the tree cannot be walked.
+func ConvertTree(fsys fs.FS, glob string, funcs ...TreeOption) ([]TreeResult, error) {
+	return ConvertTreeContext(context.Background(), fsys, glob, funcs...)
+}
+
+// ConvertTreeContext is ConvertTree stopping the walk when ctx is done. The
+// results of the files converted so far are returned with the context
+// error.
+func ConvertTreeContext(ctx context.Context, fsys fs.FS, glob string, funcs ...TreeOption) ([]TreeResult, error) {
+	opts := defaultTreeOptions
+	for _, fn := range funcs {
+		fn(&opts)
+	}
+
+	if opts.Rename == nil {
+		opts.Rename = DefaultRename
+	}
+	if opts.OutputDir == "" {
+		return nil, fmt.Errorf("output directory not set")
+	}
+	if _, err := path.Match(glob, ""); err != nil {
+		return nil, fmt.Errorf("invalid glob %q: %w", glob, err)
+	}
+
+	var results []TreeResult
+	err := fs.WalkDir(fsys, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		// The pattern was validated above, Match cannot fail
+		if ok, _ := path.Match(glob, d.Name()); !ok {
+			return nil
+		}
+		results = append(results, convertTreeFile(fsys, p, &opts))
+		return nil
+	})
+	if err != nil {
+		return results, fmt.Errorf("walking tree: %w", err)
+	}
+	return results, nil
+}
+
+// convertTreeFile converts the file at p and writes the result.
+func convertTreeFile(fsys fs.FS, p string, opts *TreeOptions) TreeResult {
+	res := TreeResult{Source: p}
+
+	data, err := fs.ReadFile(fsys, p)
+	if err != nil {
+		res.Error = fmt.Errorf("reading file: %w", err)
+		return res
+	}
+
+	res.From, err = Detect(data)
+	if err != nil {
+		res.Error = err
+		return res
+	}
+
+	var out []byte
+	switch res.From {
+	case KindAttestation:
+		res.To = KindBundle
+		out, err = attestationToBundleJSON(data, opts.Convert)
+	case KindBundle:
+		res.To = KindAttestation
+		out, err = bundleToAttestationJSON(data, opts.Convert)
+	default:
+		err = fmt.Errorf("cannot convert %s files", res.From)
+	}
+	if err != nil {
+		res.Error = err
+		return res
+	}
+
+	target := path.Join(path.Dir(p), opts.Rename(path.Base(p), res.To))
+	dest := filepath.Join(opts.OutputDir, filepath.FromSlash(target))
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		res.Error = fmt.Errorf("creating output directory: %w", err)
+		return res
+	}
+	if err := os.WriteFile(dest, out, 0o644); err != nil {
+		res.Error = fmt.Errorf("writing file: %w", err)
+		return res
+	}
+	res.Target = target
+	return res
+}
+
+// attestationToBundleJSON converts PEP 740 attestation JSON to bundle JSON.
+func attestationToBundleJSON(data []byte, funcs []ConvertOption) ([]byte, error) {
+	attestation, err := UnmarshalAttestation(data, funcs...)
+	if err != nil {
+		return nil, err
+	}
+	b, err := ToBundle(attestation, funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return MarshalBundle(b, funcs...)
+}
+
+// bundleToAttestationJSON converts bundle JSON to PEP 740 attestation JSON.
+func bundleToAttestationJSON(data []byte, funcs []ConvertOption) ([]byte, error) {
+	b, err := UnmarshalBundle(data, funcs...)
+	if err != nil {
+		return nil, err
+	}
+	attestation, err := FromBundle(b, funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return MarshalAttestation(attestation, funcs...)
+}
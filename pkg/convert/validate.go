@@ -0,0 +1,109 @@
+package convert
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/statement"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// Bounds of a plausible signature size. Signatures in use range from 64
+// bytes (Ed25519, DER ECDSA P-256 is rarely shorter) to 512 bytes (RSA
+// 4096), the bounds leave some margin.
+const (
+	minSignatureSize = 32
+	maxSignatureSize = 1024
+)
+
+// ValidateAttestation checks that an attestation is structurally sound: its
+// version is supported, the certificate parses, the statement is an in-toto
+// statement, the signature has a plausible size and the transparency
+// entries decode. It does not verify any signature, see the verify package.
+//
+// All the problems found are returned joined.
+func ValidateAttestation(attestation *pb.Attestation) error {
+	if attestation == nil {
+		return fmt.Errorf("attestation cannot be nil")
+	}
+
+	var errs []error
+	if _, err := VersionCapabilities(attestation.GetVersion()); err != nil {
+		errs = append(errs, fmt.Errorf("version: %w", err))
+	}
+	if err := ValidateVerificationMaterial(attestation.GetVerificationMaterial()); err != nil {
+		errs = append(errs, fmt.Errorf("verification_material: %w", err))
+	}
+	if err := ValidateEnvelope(attestation.GetEnvelope()); err != nil {
+		errs = append(errs, fmt.Errorf("envelope: %w", err))
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateVerificationMaterial checks that the certificates are DER
+// encoded X.509 certificates and that there is at least one transparency
+// entry, all of them decoding to a Rekor TransparencyLogEntry.
+func ValidateVerificationMaterial(vm *pb.VerificationMaterial) error {
+	if vm == nil {
+		return fmt.Errorf("verification material is missing")
+	}
+
+	var errs []error
+	if len(vm.GetCertificate()) == 0 {
+		errs = append(errs, fmt.Errorf("certificate: %w: missing", ErrInvalidCertificate))
+	} else if _, err := x509.ParseCertificate(vm.GetCertificate()); err != nil {
+		errs = append(errs, fmt.Errorf("certificate: %w: %w", ErrInvalidCertificate, err))
+	}
+	for i, c := range vm.GetIntermediateCertificates() {
+		if _, err := x509.ParseCertificate(c); err != nil {
+			errs = append(errs, fmt.Errorf("intermediate_certificates[%d]: %w: %w", i, ErrInvalidCertificate, err))
+		}
+	}
+
+	if len(vm.GetTransparencyEntries()) == 0 {
+		errs = append(errs, fmt.Errorf("transparency_entries: %w", ErrNoTransparencyEntries))
+	}
+	for i, s := range vm.GetTransparencyEntries() {
+		if _, err := TransparencyEntryFromStruct(s); err != nil {
+			errs = append(errs, fmt.Errorf("transparency_entries[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// ValidateEnvelope checks that the statement is a valid in-toto v1
+// statement and that the signatures have a plausible size.
+func ValidateEnvelope(envelope *pb.Envelope) error {
+	if envelope == nil {
+		return fmt.Errorf("envelope is missing")
+	}
+
+	var errs []error
+	if len(envelope.GetStatement()) == 0 {
+		errs = append(errs, fmt.Errorf("statement: missing"))
+	} else if _, err := statement.ParseStatement(envelope.GetStatement()); err != nil {
+		errs = append(errs, fmt.Errorf("statement: %w", err))
+	}
+
+	if err := checkSignatureSize(envelope.GetSignature()); err != nil {
+		errs = append(errs, fmt.Errorf("signature: %w", err))
+	}
+	for i, sig := range envelope.GetAdditionalSignatures() {
+		if err := checkSignatureSize(sig.GetSig()); err != nil {
+			errs = append(errs, fmt.Errorf("additional_signatures[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// checkSignatureSize fails if sig is empty or has an implausible size.
+func checkSignatureSize(sig []byte) error {
+	switch {
+	case len(sig) == 0:
+		return fmt.Errorf("missing")
+	case len(sig) < minSignatureSize, len(sig) > maxSignatureSize:
+		return fmt.Errorf("implausible size of %d bytes", len(sig))
+	}
+	return nil
+}
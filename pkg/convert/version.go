@@ -0,0 +1,91 @@
+package convert
+
+import (
+	"fmt"
+	"sort"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// LatestAttestationVersion is the attestation format version produced by
+// this package.
+const LatestAttestationVersion uint32 = 1
+
+// Capabilities describes what attestations of a format version support.
+type Capabilities struct {
+	// Version is the attestation format version.
+	Version uint32
+
+	// Bundle is true when the attestations convert to Sigstore bundles.
+	Bundle bool
+
+	// Provenance is true when the attestations can be grouped in PEP 740
+	// provenance objects.
+	Provenance bool
+}
+
+// attestationCodec decodes the JSON form of an attestation format version.
+type attestationCodec struct {
+	capabilities Capabilities
+	decode       func(raw map[string]interface{}, opts *ConvertOptions) (*pb.Attestation, error)
+}
+
+// attestationCodecs are the supported attestation format versions. Adding
+// a version of the spec means registering its decoder and capabilities
+// here.
+var attestationCodecs = map[uint32]attestationCodec{
+	1: {
+		capabilities: Capabilities{Version: 1, Bundle: true, Provenance: true},
+		decode:       decodeAttestationV1,
+	},
+}
+
+// VersionCapabilities returns the capabilities of an attestation format
+// version. It fails if the version is not supported.
+func VersionCapabilities(version uint32) (Capabilities, error) {
+	codec, ok := attestationCodecs[version]
+	if !ok {
+		return Capabilities{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	return codec.capabilities, nil
+}
+
+// SupportedVersions returns the supported attestation format versions in
+// ascending order.
+func SupportedVersions() []uint32 {
+	versions := make([]uint32, 0, len(attestationCodecs))
+	for v := range attestationCodecs {
+		versions = append(versions, v)
+	}
+	sort.Slice(versions, func(i, j int) bool { return versions[i] < versions[j] })
+	return versions
+}
+
+// decodeAttestation dispatches the decoded JSON of an attestation to the
+// decoder of its version. Documents without a version are decoded as
+// version 1, keeping the version unset.
+func decodeAttestation(raw map[string]interface{}, opts *ConvertOptions) (*pb.Attestation, error) {
+	version := uint32(1)
+	if v, ok := raw["version"]; ok {
+		var err error
+		if version, err = parseVersion(v); err != nil {
+			return nil, err
+		}
+	}
+
+	codec, ok := attestationCodecs[version]
+	if !ok {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, version)
+	}
+	return codec.decode(raw, opts)
+}
+
+// parseVersion returns the version of an attestation from the decoded JSON
+// value of its version field.
+func parseVersion(v interface{}) (uint32, error) {
+	f, ok := v.(float64)
+	if !ok || f < 0 || f != float64(uint32(f)) {
+		return 0, fmt.Errorf("%w: invalid version %v", ErrUnsupportedVersion, v)
+	}
+	return uint32(f), nil
+}
@@ -0,0 +1,105 @@
+package convert
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"gopkg.in/yaml.v3"
+)
+
+// MarshalAttestationYAML marshals an Attestation to YAML. The document has
+// the same structure as the PEP 740 JSON form.
+func MarshalAttestationYAML(attestation *pb.Attestation, funcs ...ConvertOption) ([]byte, error) {
+	data, err := MarshalAttestation(attestation, funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToYAML(data)
+}
+
+// UnmarshalAttestationYAML unmarshals a YAML document with the structure of
+// the PEP 740 JSON form to an Attestation.
+func UnmarshalAttestationYAML(data []byte, funcs ...ConvertOption) (*pb.Attestation, error) {
+	j, err := yamlToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalAttestation(j, funcs...)
+}
+
+// MarshalBundleYAML marshals a Sigstore Bundle to YAML. The document has
+// the same structure as the bundle JSON form.
+func MarshalBundleYAML(b *bundle.Bundle, funcs ...ConvertOption) ([]byte, error) {
+	data, err := MarshalBundle(b, funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return jsonToYAML(data)
+}
+
+// UnmarshalBundleYAML unmarshals a YAML document with the structure of the
+// bundle JSON form to a Sigstore Bundle.
+func UnmarshalBundleYAML(data []byte, funcs ...ConvertOption) (*bundle.Bundle, error) {
+	j, err := yamlToJSON(data)
+	if err != nil {
+		return nil, err
+	}
+	return UnmarshalBundle(j, funcs...)
+}
+
+// jsonToYAML converts a JSON document to YAML, preserving integers.
+func jsonToYAML(data []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
+	}
+
+	out, err := yaml.Marshal(yamlNumbers(v))
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal YAML: %w", err)
+	}
+	return out, nil
+}
+
+// yamlNumbers replaces the json.Number values of v with integers or floats
+// so they are not written as YAML strings.
+func yamlNumbers(v any) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, e := range t {
+			t[k] = yamlNumbers(e)
+		}
+	case []any:
+		for i, e := range t {
+			t[i] = yamlNumbers(e)
+		}
+	case json.Number:
+		if i, err := t.Int64(); err == nil {
+			return i
+		}
+		if f, err := t.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
+
+// yamlToJSON converts a YAML document to JSON.
+func yamlToJSON(data []byte) ([]byte, error) {
+	var v any
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal YAML: %w", err)
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to convert YAML to JSON: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,151 @@
+// Package distfile parses the filenames of Python distributions, wheels
+// (PEP 427) and source distributions (PEP 625), and normalizes project
+// names (PEP 503).
+package distfile
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Kind is the kind of a distribution file.
+type Kind string
+
+const (
+	Wheel Kind = "wheel"
+	Sdist Kind = "sdist"
+)
+
+// nameSeparators matches the runs of characters collapsed by PEP 503
+// project name normalization.
+var nameSeparators = regexp.MustCompile(`[-_.]+`)
+
+// NormalizeName normalizes a project name as described in PEP 503.
+func NormalizeName(name string) string {
+	return strings.ToLower(nameSeparators.ReplaceAllString(name, "-"))
+}
+
+// EscapeName returns the form of a project name used in wheel and sdist
+// filenames: normalized, with underscores instead of dashes.
+func EscapeName(name string) string {
+	return strings.ReplaceAll(NormalizeName(name), "-", "_")
+}
+
+// SimplePath returns the path of the Simple API page of a project,
+// relative to the index root, eg /simple/friendly-bard/.
+func SimplePath(name string) string {
+	return "/simple/" + url.PathEscape(NormalizeName(name)) + "/"
+}
+
+// File is a parsed distribution filename.
+type File struct {
+	// Filename is the parsed filename.
+	Filename string
+
+	// Kind is the kind of distribution.
+	Kind Kind
+
+	// Name is the project name as written in the filename.
+	Name string
+
+	// Version is the project version.
+	Version string
+
+	// BuildTag is the optional build tag of a wheel.
+	BuildTag string
+
+	// PythonTags, ABITags and PlatformTags are the compatibility tags of
+	// a wheel, with compressed tag sets (eg py2.py3) expanded.
+	PythonTags   []string
+	ABITags      []string
+	PlatformTags []string
+
+	// Extension is the file extension, eg .whl or .tar.gz.
+	Extension string
+}
+
+// ProjectName returns the PEP 503 normalized project name.
+func (f *File) ProjectName() string {
+	return NormalizeName(f.Name)
+}
+
+// Parse parses a wheel or sdist filename.
+func Parse(filename string) (*File, error) {
+	if filename == "" || strings.ContainsAny(filename, `/\`) {
+		return nil, fmt.Errorf("%q is not a bare filename", filename)
+	}
+	switch {
+	case strings.HasSuffix(filename, ".whl"):
+		return ParseWheel(filename)
+	case strings.HasSuffix(filename, ".tar.gz"), strings.HasSuffix(filename, ".zip"):
+		return ParseSdist(filename)
+	default:
+		return nil, fmt.Errorf("%q is not a wheel or sdist filename", filename)
+	}
+}
+
+// ParseWheel parses a wheel filename of the form
+// {name}-{version}(-{build})?-{python}-{abi}-{platform}.whl.
+func ParseWheel(filename string) (*File, error) {
+	if !strings.HasSuffix(filename, ".whl") {
+		return nil, fmt.Errorf("%q is not a wheel filename", filename)
+	}
+	parts := strings.Split(strings.TrimSuffix(filename, ".whl"), "-")
+	if len(parts) != 5 && len(parts) != 6 {
+		return nil, fmt.Errorf("invalid wheel filename %q: expected 5 or 6 components, found %d", filename, len(parts))
+	}
+	for _, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("invalid wheel filename %q: empty component", filename)
+		}
+	}
+
+	f := &File{
+		Filename:  filename,
+		Kind:      Wheel,
+		Name:      parts[0],
+		Version:   parts[1],
+		Extension: ".whl",
+	}
+	if len(parts) == 6 {
+		f.BuildTag = parts[2]
+		if f.BuildTag[0] < '0' || f.BuildTag[0] > '9' {
+			return nil, fmt.Errorf("invalid wheel filename %q: build tag must start with a digit", filename)
+		}
+	}
+	tags := parts[len(parts)-3:]
+	f.PythonTags = strings.Split(tags[0], ".")
+	f.ABITags = strings.Split(tags[1], ".")
+	f.PlatformTags = strings.Split(tags[2], ".")
+	return f, nil
+}
+
+// ParseSdist parses a source distribution filename of the form
+// {name}-{version}.tar.gz. Legacy .zip sdists and project names with
+// dashes are accepted, the version never contains a dash.
+func ParseSdist(filename string) (*File, error) {
+	var ext string
+	switch {
+	case strings.HasSuffix(filename, ".tar.gz"):
+		ext = ".tar.gz"
+	case strings.HasSuffix(filename, ".zip"):
+		ext = ".zip"
+	default:
+		return nil, fmt.Errorf("%q is not a sdist filename", filename)
+	}
+
+	stem := strings.TrimSuffix(filename, ext)
+	i := strings.LastIndex(stem, "-")
+	if i <= 0 || i == len(stem)-1 {
+		return nil, fmt.Errorf("%q has no version component", filename)
+	}
+	return &File{
+		Filename:  filename,
+		Kind:      Sdist,
+		Name:      stem[:i],
+		Version:   stem[i+1:],
+		Extension: ext,
+	}, nil
+}
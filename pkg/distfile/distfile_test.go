@@ -0,0 +1,70 @@
+package distfile
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		filename string
+		expected *File
+		mustErr  bool
+	}{
+		{"pypi_attestations-0.0.28.tar.gz", &File{Kind: Sdist, Name: "pypi_attestations", Version: "0.0.28", Extension: ".tar.gz"}, false},
+		{"Legacy-Name-1.0.zip", &File{Kind: Sdist, Name: "Legacy-Name", Version: "1.0", Extension: ".zip"}, false},
+		{"pypi_attestations-0.0.28-py3-none-any.whl", &File{
+			Kind: Wheel, Name: "pypi_attestations", Version: "0.0.28", Extension: ".whl",
+			PythonTags: []string{"py3"}, ABITags: []string{"none"}, PlatformTags: []string{"any"},
+		}, false},
+		{"foo-1.0-1build-py2.py3-none-manylinux_2_17_x86_64.manylinux2014_x86_64.whl", &File{
+			Kind: Wheel, Name: "foo", Version: "1.0", BuildTag: "1build", Extension: ".whl",
+			PythonTags: []string{"py2", "py3"}, ABITags: []string{"none"},
+			PlatformTags: []string{"manylinux_2_17_x86_64", "manylinux2014_x86_64"},
+		}, false},
+		{"foo-1.0-build-py3-none-any.whl", nil, true},
+		{"foo-1.0-py3-none.whl", nil, true},
+		{"foo--py3-none-any.whl", nil, true},
+		{"foo.tar.gz", nil, true},
+		{"foo-.tar.gz", nil, true},
+		{"foo-1.0.exe", nil, true},
+		{"dist/foo-1.0.tar.gz", nil, true},
+	} {
+		t.Run(tc.filename, func(t *testing.T) {
+			f, err := Parse(tc.filename)
+			if tc.mustErr {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", f)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			tc.expected.Filename = tc.filename
+			if !reflect.DeepEqual(f, tc.expected) {
+				t.Errorf("Expected %+v, got %+v", tc.expected, f)
+			}
+		})
+	}
+}
+
+func TestNames(t *testing.T) {
+	if got := NormalizeName("Friendly-Bard__x.y"); got != "friendly-bard-x-y" {
+		t.Errorf("Unexpected normalized name: %s", got)
+	}
+	if got := EscapeName("Friendly-Bard__x.y"); got != "friendly_bard_x_y" {
+		t.Errorf("Unexpected escaped name: %s", got)
+	}
+	if got := SimplePath("Friendly.Bard"); got != "/simple/friendly-bard/" {
+		t.Errorf("Unexpected simple path: %s", got)
+	}
+
+	f, err := Parse("PyPI.Attestations-0.0.28.tar.gz")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if f.ProjectName() != "pypi-attestations" {
+		t.Errorf("Unexpected project name: %s", f.ProjectName())
+	}
+}
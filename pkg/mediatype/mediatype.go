@@ -0,0 +1,140 @@
+// Package mediatype defines the media types of the documents handled by
+// this module and helpers to parse and compare them.
+package mediatype
+
+import (
+	"fmt"
+	"mime"
+	"strings"
+)
+
+const (
+	// BundlePrefix is the prefix of all Sigstore bundle media types.
+	BundlePrefix = "application/vnd.dev.sigstore.bundle"
+
+	// BundleV01 is the media type of v0.1 Sigstore bundles.
+	BundleV01 = "application/vnd.dev.sigstore.bundle+json;version=0.1"
+
+	// BundleV02 is the media type of v0.2 Sigstore bundles.
+	BundleV02 = "application/vnd.dev.sigstore.bundle+json;version=0.2"
+
+	// BundleV03 is the media type of v0.3 Sigstore bundles.
+	BundleV03 = "application/vnd.dev.sigstore.bundle.v0.3+json"
+
+	// Attestation is the media type of PEP 740 attestation files. PEP 740
+	// does not register one, this is the type used by this module when
+	// a document needs to be labeled.
+	Attestation = "application/vnd.pypi.attestation.v1+json"
+
+	// Provenance is the media type of the PEP 740 Integrity API, which
+	// serves provenance objects.
+	Provenance = "application/vnd.pypi.integrity.v1+json"
+
+	// Simple is the media type of the PEP 691 JSON Simple API.
+	Simple = "application/vnd.pypi.simple.v1+json"
+
+	// InToto is the DSSE payload type of in-toto statements.
+	InToto = "application/vnd.in-toto+json"
+
+	// JSON is the plain JSON media type.
+	JSON = "application/json"
+)
+
+// bundleVersions maps the supported bundle versions to their media type.
+var bundleVersions = map[string]string{
+	"v0.1": BundleV01,
+	"v0.2": BundleV02,
+	"v0.3": BundleV03,
+}
+
+// MediaType is a parsed media type.
+type MediaType struct {
+	// Type is the lowercase type and subtype, without parameters.
+	Type string
+
+	// Params are the media type parameters, with lowercase names.
+	Params map[string]string
+}
+
+// String returns the media type formatted as in RFC 2045.
+func (mt MediaType) String() string {
+	return mime.FormatMediaType(mt.Type, mt.Params)
+}
+
+// Parse parses a media type string.
+func Parse(s string) (MediaType, error) {
+	t, params, err := mime.ParseMediaType(s)
+	if err != nil {
+		return MediaType{}, fmt.Errorf("parsing media type %q: %w", s, err)
+	}
+	return MediaType{Type: t, Params: params}, nil
+}
+
+// Equal reports whether two media types are the same, ignoring case,
+// whitespace and the order of parameters. The two notations of bundle
+// versions (eg version=0.3 and .v0.3+json) are considered equal.
+func Equal(a, b string) bool {
+	if va, err := BundleVersion(a); err == nil {
+		vb, err := BundleVersion(b)
+		return err == nil && va == vb
+	}
+
+	ma, err := Parse(a)
+	if err != nil {
+		return false
+	}
+	mb, err := Parse(b)
+	if err != nil {
+		return false
+	}
+	if ma.Type != mb.Type || len(ma.Params) != len(mb.Params) {
+		return false
+	}
+	for k, v := range ma.Params {
+		if mb.Params[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// IsBundle reports whether s is a Sigstore bundle media type.
+func IsBundle(s string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(s)), BundlePrefix)
+}
+
+// BundleVersion returns the version of a Sigstore bundle media type, eg
+// v0.3. Both the version parameter and the versioned subtype notations are
+// understood.
+func BundleVersion(s string) (string, error) {
+	mt, err := Parse(s)
+	if err != nil {
+		return "", err
+	}
+	if !strings.HasPrefix(mt.Type, BundlePrefix) {
+		return "", fmt.Errorf("not a bundle media type: %q", s)
+	}
+
+	var version string
+	switch {
+	case mt.Type == BundlePrefix+"+json":
+		version = mt.Params["version"]
+	case strings.HasSuffix(mt.Type, "+json"):
+		version = strings.TrimSuffix(strings.TrimPrefix(mt.Type, BundlePrefix+"."), "+json")
+	}
+	version = "v" + strings.TrimPrefix(version, "v")
+	if _, ok := bundleVersions[version]; !ok {
+		return "", fmt.Errorf("unsupported bundle version in media type %q", s)
+	}
+	return version, nil
+}
+
+// Bundle returns the media type of the Sigstore bundle version, eg v0.3.
+// The leading v is optional.
+func Bundle(version string) (string, error) {
+	mt, ok := bundleVersions["v"+strings.TrimPrefix(version, "v")]
+	if !ok {
+		return "", fmt.Errorf("unsupported bundle version: %q", version)
+	}
+	return mt, nil
+}
@@ -0,0 +1,80 @@
+package mediatype
+
+import "testing"
+
+func TestBundleVersion(t *testing.T) {
+	for _, tc := range []struct {
+		mediaType string
+		version   string
+		mustErr   bool
+	}{
+		{BundleV01, "v0.1", false},
+		{BundleV02, "v0.2", false},
+		{BundleV03, "v0.3", false},
+		{"application/vnd.dev.sigstore.bundle+json; version=0.3", "v0.3", false},
+		{"Application/VND.dev.sigstore.bundle.v0.3+json", "v0.3", false},
+		{"application/vnd.dev.sigstore.bundle.v0.9+json", "", true},
+		{Provenance, "", true},
+		{"not a media type;", "", true},
+	} {
+		t.Run(tc.mediaType, func(t *testing.T) {
+			version, err := BundleVersion(tc.mediaType)
+			if tc.mustErr {
+				if err == nil {
+					t.Errorf("Expected error, got version %q", version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if version != tc.version {
+				t.Errorf("Expected version %q, got %q", tc.version, version)
+			}
+		})
+	}
+}
+
+func TestBundle(t *testing.T) {
+	for version, want := range map[string]string{"v0.1": BundleV01, "0.2": BundleV02, "v0.3": BundleV03} {
+		got, err := Bundle(version)
+		if err != nil {
+			t.Fatalf("Unexpected error for %s: %v", version, err)
+		}
+		if got != want {
+			t.Errorf("Expected %q for %s, got %q", want, version, got)
+		}
+	}
+	if _, err := Bundle("v1.0"); err == nil {
+		t.Error("Expected error for unsupported version")
+	}
+}
+
+func TestEqual(t *testing.T) {
+	for _, tc := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{Provenance, "APPLICATION/vnd.pypi.integrity.v1+json", true},
+		{"text/plain; charset=utf-8; format=flowed", "text/plain;format=flowed;charset=utf-8", true},
+		{"text/plain; charset=utf-8", "text/plain", false},
+		{BundleV03, "application/vnd.dev.sigstore.bundle+json;version=0.3", true},
+		{BundleV03, BundleV02, false},
+		{BundleV01, JSON, false},
+		{Simple, Provenance, false},
+		{"", "", false},
+	} {
+		if got := Equal(tc.a, tc.b); got != tc.equal {
+			t.Errorf("Equal(%q, %q) = %v, expected %v", tc.a, tc.b, got, tc.equal)
+		}
+	}
+}
+
+func TestIsBundle(t *testing.T) {
+	if !IsBundle(BundleV02) || !IsBundle(BundleV03) {
+		t.Error("Expected bundle media types to be detected")
+	}
+	if IsBundle(Attestation) {
+		t.Error("Attestation media type detected as a bundle")
+	}
+}
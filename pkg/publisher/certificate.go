@@ -0,0 +1,155 @@
+package publisher
+
+import (
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/sigstore/sigstore-go/pkg/fulcio/certificate"
+)
+
+// OIDC issuers of the supported Trusted Publishers.
+const (
+	IssuerGitHub = "https://token.actions.githubusercontent.com"
+	IssuerGitLab = "https://gitlab.com"
+	IssuerGoogle = "https://accounts.google.com"
+)
+
+// FromCertificate derives the publisher claims from the Fulcio extensions
+// of a signing certificate. Deployment environments are not recorded in
+// Fulcio certificates so they are always left empty.
+func FromCertificate(cert *x509.Certificate) (Publisher, error) {
+	if cert == nil {
+		return nil, fmt.Errorf("certificate cannot be nil")
+	}
+
+	ext, err := certificate.ParseExtensions(cert.Extensions)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate extensions: %w", err)
+	}
+
+	switch ext.Issuer {
+	case IssuerGitHub:
+		repo, err := repositoryPath(ext.SourceRepositoryURI)
+		if err != nil {
+			return nil, err
+		}
+		// https://github.com/{repo}/.github/workflows/{workflow}@{ref}
+		workflow, _, _ := strings.Cut(ext.BuildConfigURI, "@")
+		if workflow == "" {
+			return nil, fmt.Errorf("certificate has no build config URI")
+		}
+		return &GitHubPublisher{
+			Repository: repo,
+			Workflow:   path.Base(workflow),
+		}, nil
+
+	case IssuerGitLab:
+		repo, err := repositoryPath(ext.SourceRepositoryURI)
+		if err != nil {
+			return nil, err
+		}
+		// https://gitlab.com/{repo}//{workflow_filepath}@{ref}
+		config, _, _ := strings.Cut(ext.BuildConfigURI, "@")
+		pipeline, ok := strings.CutPrefix(config, ext.SourceRepositoryURI+"//")
+		if !ok || pipeline == "" {
+			return nil, fmt.Errorf("unexpected GitLab build config URI %q", ext.BuildConfigURI)
+		}
+		return &GitLabPublisher{
+			Repository:       repo,
+			WorkflowFilepath: pipeline,
+		}, nil
+
+	case IssuerGoogle:
+		if len(cert.EmailAddresses) != 1 {
+			return nil, fmt.Errorf("expected one email address in Google certificate, got %d", len(cert.EmailAddresses))
+		}
+		return &GooglePublisher{Email: cert.EmailAddresses[0]}, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported publisher issuer %q", ext.Issuer)
+	}
+}
+
+// repositoryPath returns the project path of a source repository URL.
+func repositoryPath(uri string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return "", fmt.Errorf("invalid source repository URI %q", uri)
+	}
+	repo := strings.Trim(u.Path, "/")
+	if repo == "" {
+		return "", fmt.Errorf("invalid source repository URI %q", uri)
+	}
+	return repo, nil
+}
+
+// MismatchError is returned when a publisher claim does not match the
+// identity of the signing certificate.
+type MismatchError struct {
+	// Field is the name of the mismatched publisher claim.
+	Field string
+
+	// Publisher is the value claimed by the publisher.
+	Publisher string
+
+	// Certificate is the value derived from the certificate.
+	Certificate string
+}
+
+func (e *MismatchError) Error() string {
+	return fmt.Sprintf(
+		"publisher %s %q does not match certificate %s %q",
+		e.Field, e.Publisher, e.Field, e.Certificate,
+	)
+}
+
+// CheckCertificate ensures the publisher is consistent with the identity of
+// the signing certificate, detecting publisher blocks that were spoofed or
+// attached to attestations signed by someone else. Repository paths and
+// emails are compared case insensitively. The deployment environment is not
+// recorded in Fulcio certificates so it cannot be checked.
+func CheckCertificate(p Publisher, cert *x509.Certificate) error {
+	if p == nil {
+		return fmt.Errorf("publisher cannot be nil")
+	}
+
+	got, err := FromCertificate(cert)
+	if err != nil {
+		return err
+	}
+	if got.Kind() != p.Kind() {
+		return &MismatchError{Field: "kind", Publisher: p.Kind(), Certificate: got.Kind()}
+	}
+
+	switch want := p.(type) {
+	case *GitHubPublisher:
+		c := got.(*GitHubPublisher)
+		if !strings.EqualFold(want.Repository, c.Repository) {
+			return &MismatchError{Field: "repository", Publisher: want.Repository, Certificate: c.Repository}
+		}
+		if want.Workflow != c.Workflow {
+			return &MismatchError{Field: "workflow", Publisher: want.Workflow, Certificate: c.Workflow}
+		}
+	case *GitLabPublisher:
+		c := got.(*GitLabPublisher)
+		if !strings.EqualFold(want.Repository, c.Repository) {
+			return &MismatchError{Field: "repository", Publisher: want.Repository, Certificate: c.Repository}
+		}
+		if want.WorkflowFilepath != c.WorkflowFilepath {
+			return &MismatchError{
+				Field: "workflow_filepath", Publisher: want.WorkflowFilepath, Certificate: c.WorkflowFilepath,
+			}
+		}
+	case *GooglePublisher:
+		c := got.(*GooglePublisher)
+		if !strings.EqualFold(want.Email, c.Email) {
+			return &MismatchError{Field: "email", Publisher: want.Email, Certificate: c.Email}
+		}
+	default:
+		return fmt.Errorf("unsupported publisher kind %q", p.Kind())
+	}
+	return nil
+}
@@ -0,0 +1,221 @@
+// Package publisher models the Trusted Publisher claims that PyPI attaches
+// to the attestation bundles of a PEP 740 provenance object.
+package publisher
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Publisher kinds defined by PyPI.
+const (
+	KindGitHub = "GitHub"
+	KindGitLab = "GitLab"
+	KindGoogle = "Google"
+)
+
+// Publisher is the identity that produced a bundle of attestations.
+type Publisher interface {
+	// Kind returns the publisher type.
+	Kind() string
+
+	// Claims returns the publisher in its PEP 740 JSON object form,
+	// including the kind key.
+	Claims() map[string]interface{}
+}
+
+// GitHubPublisher is a GitHub Actions Trusted Publisher.
+type GitHubPublisher struct {
+	// Repository is the slug of the repository (owner/name).
+	Repository string `json:"repository"`
+
+	// Workflow is the filename of the workflow that published the files.
+	Workflow string `json:"workflow"`
+
+	// Environment is the optional GitHub deployment environment.
+	Environment string `json:"environment"`
+
+	// ExtraClaims holds additional claims published by the index.
+	ExtraClaims map[string]interface{} `json:"claims,omitempty"`
+}
+
+// Kind returns KindGitHub.
+func (p *GitHubPublisher) Kind() string { return KindGitHub }
+
+// Claims returns the PEP 740 JSON object form of the publisher.
+func (p *GitHubPublisher) Claims() map[string]interface{} {
+	return claims(KindGitHub, p.ExtraClaims, map[string]interface{}{
+		"repository":  p.Repository,
+		"workflow":    p.Workflow,
+		"environment": nullable(p.Environment),
+	})
+}
+
+// MarshalJSON encodes the publisher with its kind.
+func (p *GitHubPublisher) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Claims())
+}
+
+// GitLabPublisher is a GitLab CI/CD Trusted Publisher.
+type GitLabPublisher struct {
+	// Repository is the full project path (namespace/project).
+	Repository string `json:"repository"`
+
+	// WorkflowFilepath is the path of the top-level pipeline file.
+	WorkflowFilepath string `json:"workflow_filepath"`
+
+	// Environment is the optional GitLab deployment environment.
+	Environment string `json:"environment"`
+
+	// ExtraClaims holds additional claims published by the index.
+	ExtraClaims map[string]interface{} `json:"claims,omitempty"`
+}
+
+// Kind returns KindGitLab.
+func (p *GitLabPublisher) Kind() string { return KindGitLab }
+
+// Claims returns the PEP 740 JSON object form of the publisher.
+func (p *GitLabPublisher) Claims() map[string]interface{} {
+	return claims(KindGitLab, p.ExtraClaims, map[string]interface{}{
+		"repository":        p.Repository,
+		"workflow_filepath": p.WorkflowFilepath,
+		"environment":       nullable(p.Environment),
+	})
+}
+
+// MarshalJSON encodes the publisher with its kind.
+func (p *GitLabPublisher) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Claims())
+}
+
+// GooglePublisher is a Google Cloud Trusted Publisher.
+type GooglePublisher struct {
+	// Email is the service account email of the publisher.
+	Email string `json:"email"`
+
+	// ExtraClaims holds additional claims published by the index.
+	ExtraClaims map[string]interface{} `json:"claims,omitempty"`
+}
+
+// Kind returns KindGoogle.
+func (p *GooglePublisher) Kind() string { return KindGoogle }
+
+// Claims returns the PEP 740 JSON object form of the publisher.
+func (p *GooglePublisher) Claims() map[string]interface{} {
+	return claims(KindGoogle, p.ExtraClaims, map[string]interface{}{
+		"email": p.Email,
+	})
+}
+
+// MarshalJSON encodes the publisher with its kind.
+func (p *GooglePublisher) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Claims())
+}
+
+// UnknownPublisher preserves the claims of publisher kinds not modeled by
+// this package.
+type UnknownPublisher struct {
+	Fields map[string]interface{}
+}
+
+// Kind returns the kind key of the claims.
+func (p *UnknownPublisher) Kind() string {
+	kind, _ := p.Fields["kind"].(string)
+	return kind
+}
+
+// Claims returns the raw claims.
+func (p *UnknownPublisher) Claims() map[string]interface{} {
+	return p.Fields
+}
+
+// MarshalJSON encodes the raw claims.
+func (p *UnknownPublisher) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.Fields)
+}
+
+// Environment returns the deployment environment of the publisher, empty
+// when it has none or its kind does not define one.
+func Environment(p Publisher) string {
+	switch p := p.(type) {
+	case *GitHubPublisher:
+		return p.Environment
+	case *GitLabPublisher:
+		return p.Environment
+	case *UnknownPublisher:
+		env, _ := p.Fields["environment"].(string)
+		return env
+	}
+	return ""
+}
+
+// Parse decodes a publisher from its JSON object form.
+func Parse(data []byte) (Publisher, error) {
+	var kind struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &kind); err != nil {
+		return nil, fmt.Errorf("parsing publisher: %w", err)
+	}
+
+	var p Publisher
+	switch kind.Kind {
+	case "":
+		return nil, fmt.Errorf("publisher has no kind")
+	case KindGitHub:
+		p = &GitHubPublisher{}
+	case KindGitLab:
+		p = &GitLabPublisher{}
+	case KindGoogle:
+		p = &GooglePublisher{}
+	default:
+		p = &UnknownPublisher{}
+		if err := json.Unmarshal(data, &p.(*UnknownPublisher).Fields); err != nil {
+			return nil, fmt.Errorf("parsing publisher: %w", err)
+		}
+		return p, nil
+	}
+
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("parsing %s publisher: %w", kind.Kind, err)
+	}
+	return p, nil
+}
+
+// FromStruct decodes a publisher from the protobuf form used in the
+// attestation bundles of provenance objects.
+func FromStruct(s *structpb.Struct) (Publisher, error) {
+	if s == nil {
+		return nil, fmt.Errorf("publisher cannot be nil")
+	}
+	data, err := json.Marshal(s.AsMap())
+	if err != nil {
+		return nil, fmt.Errorf("encoding publisher: %w", err)
+	}
+	return Parse(data)
+}
+
+// ToStruct encodes a publisher in the protobuf form used in the attestation
+// bundles of provenance objects.
+func ToStruct(p Publisher) (*structpb.Struct, error) {
+	return structpb.NewStruct(p.Claims())
+}
+
+// claims builds the JSON object of a publisher.
+func claims(kind string, extra, fields map[string]interface{}) map[string]interface{} {
+	fields["kind"] = kind
+	if len(extra) > 0 {
+		fields["claims"] = extra
+	}
+	return fields
+}
+
+// nullable returns nil for empty strings, which PyPI encodes as null.
+func nullable(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
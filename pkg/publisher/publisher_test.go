@@ -0,0 +1,207 @@
+package publisher
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		data    string
+		check   func(Publisher) bool
+		mustErr bool
+	}{
+		{
+			name: "github",
+			data: `{"kind": "GitHub", "repository": "pypi/pypi-attestations", "workflow": "release.yml", "environment": null}`,
+			check: func(p Publisher) bool {
+				gh, ok := p.(*GitHubPublisher)
+				return ok && gh.Repository == "pypi/pypi-attestations" && gh.Workflow == "release.yml" && gh.Environment == ""
+			},
+		},
+		{
+			name: "gitlab",
+			data: `{"kind": "GitLab", "repository": "group/project", "workflow_filepath": ".gitlab-ci.yml", "environment": "release"}`,
+			check: func(p Publisher) bool {
+				gl, ok := p.(*GitLabPublisher)
+				return ok && gl.Repository == "group/project" && gl.WorkflowFilepath == ".gitlab-ci.yml" && gl.Environment == "release"
+			},
+		},
+		{
+			name: "google",
+			data: `{"kind": "Google", "email": "publisher@project.iam.gserviceaccount.com"}`,
+			check: func(p Publisher) bool {
+				g, ok := p.(*GooglePublisher)
+				return ok && g.Email == "publisher@project.iam.gserviceaccount.com"
+			},
+		},
+		{
+			name: "unknown",
+			data: `{"kind": "ActiveState", "organization": "example"}`,
+			check: func(p Publisher) bool {
+				u, ok := p.(*UnknownPublisher)
+				return ok && u.Kind() == "ActiveState" && u.Fields["organization"] == "example"
+			},
+		},
+		{
+			name:    "no kind",
+			data:    `{"repository": "pypi/pypi-attestations"}`,
+			mustErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := Parse([]byte(tc.data))
+			if tc.mustErr {
+				if err == nil {
+					t.Error("Expected parsing to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to parse publisher: %v", err)
+			}
+			if !tc.check(p) {
+				t.Errorf("Unexpected publisher: %+v", p)
+			}
+
+			// Encoding must produce the same JSON object
+			data, err := json.Marshal(p)
+			if err != nil {
+				t.Fatalf("Failed to marshal publisher: %v", err)
+			}
+			var got, want map[string]interface{}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Failed to parse marshaled publisher: %v", err)
+			}
+			if err := json.Unmarshal([]byte(tc.data), &want); err != nil {
+				t.Fatalf("Failed to parse test data: %v", err)
+			}
+			if len(got) != len(want) {
+				t.Errorf("Marshaled publisher differs:\n got: %v\nwant: %v", got, want)
+			}
+			for k, v := range want {
+				if got[k] != v {
+					t.Errorf("Marshaled publisher differs in %s: %v != %v", k, got[k], v)
+				}
+			}
+		})
+	}
+}
+
+func TestStructRoundTrip(t *testing.T) {
+	p := &GitHubPublisher{Repository: "pypi/pypi-attestations", Workflow: "release.yml", Environment: "pypi"}
+	s, err := ToStruct(p)
+	if err != nil {
+		t.Fatalf("Failed to convert publisher: %v", err)
+	}
+	if s.Fields["kind"].GetStringValue() != KindGitHub {
+		t.Errorf("Unexpected kind: %v", s.Fields["kind"])
+	}
+
+	back, err := FromStruct(s)
+	if err != nil {
+		t.Fatalf("Failed to convert struct: %v", err)
+	}
+	gh, ok := back.(*GitHubPublisher)
+	if !ok || gh.Repository != p.Repository || gh.Workflow != p.Workflow || gh.Environment != p.Environment {
+		t.Errorf("Publisher does not match after round trip: %+v", back)
+	}
+}
+
+func TestEnvironment(t *testing.T) {
+	for _, tc := range []struct {
+		publisher Publisher
+		expected  string
+	}{
+		{&GitHubPublisher{Repository: "pypi/pypi-attestations", Environment: "pypi"}, "pypi"},
+		{&GitLabPublisher{Repository: "group/project", Environment: "release"}, "release"},
+		{&GooglePublisher{Email: "publisher@example.iam.gserviceaccount.com"}, ""},
+		{&UnknownPublisher{Fields: map[string]interface{}{"kind": "Forge", "environment": "prod"}}, "prod"},
+		{nil, ""},
+	} {
+		if got := Environment(tc.publisher); got != tc.expected {
+			t.Errorf("Environment(%+v) = %q, expected %q", tc.publisher, got, tc.expected)
+		}
+	}
+}
+
+func loadTestCertificate(t *testing.T) *x509.Certificate {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	// The attestation is decoded by hand as the convert package imports
+	// this one.
+	attestation := struct {
+		VerificationMaterial struct {
+			Certificate []byte `json:"certificate"`
+		} `json:"verification_material"`
+	}{}
+	if err := json.Unmarshal(data, &attestation); err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	cert, err := x509.ParseCertificate(attestation.VerificationMaterial.Certificate)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestFromCertificate(t *testing.T) {
+	p, err := FromCertificate(loadTestCertificate(t))
+	if err != nil {
+		t.Fatalf("Failed to derive publisher: %v", err)
+	}
+
+	gh, ok := p.(*GitHubPublisher)
+	if !ok {
+		t.Fatalf("Expected GitHub publisher, got %T", p)
+	}
+	if gh.Repository != "pypi/pypi-attestations" || gh.Workflow != "release.yml" || gh.Environment != "" {
+		t.Errorf("Unexpected publisher: %+v", gh)
+	}
+
+	if _, err := FromCertificate(&x509.Certificate{}); err == nil {
+		t.Error("Expected error for certificate without Fulcio extensions")
+	}
+}
+
+func TestCheckCertificate(t *testing.T) {
+	cert := loadTestCertificate(t)
+
+	for _, tc := range []struct {
+		name      string
+		publisher Publisher
+		field     string
+	}{
+		{"matching", &GitHubPublisher{Repository: "pypi/pypi-attestations", Workflow: "release.yml"}, ""},
+		{"repository case", &GitHubPublisher{Repository: "PyPI/PyPI-Attestations", Workflow: "release.yml"}, ""},
+		{"environment ignored", &GitHubPublisher{Repository: "pypi/pypi-attestations", Workflow: "release.yml", Environment: "pypi"}, ""},
+		{"other repository", &GitHubPublisher{Repository: "evil/pypi-attestations", Workflow: "release.yml"}, "repository"},
+		{"other workflow", &GitHubPublisher{Repository: "pypi/pypi-attestations", Workflow: "publish.yml"}, "workflow"},
+		{"other kind", &GitLabPublisher{Repository: "pypi/pypi-attestations", WorkflowFilepath: ".gitlab-ci.yml"}, "kind"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			err := CheckCertificate(tc.publisher, cert)
+			if tc.field == "" {
+				if err != nil {
+					t.Fatalf("Failed to check publisher: %v", err)
+				}
+				return
+			}
+			var mismatch *MismatchError
+			if !errors.As(err, &mismatch) {
+				t.Fatalf("Expected mismatch error, got %v", err)
+			}
+			if mismatch.Field != tc.field {
+				t.Errorf("Expected mismatch in %s, got %s", tc.field, mismatch.Field)
+			}
+		})
+	}
+}
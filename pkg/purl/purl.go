@@ -0,0 +1,88 @@
+// Package purl generates and parses pkg:pypi package URLs, used to join
+// attested distributions with SBOMs and vulnerability data.
+package purl
+
+import (
+	"fmt"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/distfile"
+	"github.com/package-url/packageurl-go"
+)
+
+// Qualifiers of pypi package URLs.
+const (
+	FileNameQualifier      = "file_name"
+	RepositoryURLQualifier = "repository_url"
+)
+
+// Package is a PyPI package, optionally narrowed to a distribution file.
+type Package struct {
+	// Name is the PEP 503 normalized project name.
+	Name string
+
+	// Version is the project version, it can be empty.
+	Version string
+
+	// Filename is the name of a distribution file of the release.
+	Filename string
+
+	// RepositoryURL is the index serving the package, empty for PyPI.
+	RepositoryURL string
+}
+
+// FromProject returns the package of a project release.
+func FromProject(project, version string) *Package {
+	return &Package{Name: distfile.NormalizeName(project), Version: version}
+}
+
+// FromFile returns the package of a parsed distribution file.
+func FromFile(f *distfile.File) *Package {
+	return &Package{Name: f.ProjectName(), Version: f.Version, Filename: f.Filename}
+}
+
+// FromFilename parses a distribution filename and returns its package.
+func FromFilename(filename string) (*Package, error) {
+	f, err := distfile.Parse(filename)
+	if err != nil {
+		return nil, err
+	}
+	return FromFile(f), nil
+}
+
+// String returns the package URL, eg
+// pkg:pypi/sampleproject@1.0?file_name=sampleproject-1.0.tar.gz.
+func (p *Package) String() string {
+	qualifiers := map[string]string{}
+	if p.Filename != "" {
+		qualifiers[FileNameQualifier] = p.Filename
+	}
+	if p.RepositoryURL != "" {
+		qualifiers[RepositoryURLQualifier] = p.RepositoryURL
+	}
+	return packageurl.NewPackageURL(
+		packageurl.TypePyPi, "", distfile.NormalizeName(p.Name), p.Version,
+		packageurl.QualifiersFromMap(qualifiers), "",
+	).ToString()
+}
+
+// Parse parses a pkg:pypi package URL.
+func Parse(s string) (*Package, error) {
+	u, err := packageurl.FromString(s)
+	if err != nil {
+		return nil, fmt.Errorf("parsing package URL: %w", err)
+	}
+	if u.Type != packageurl.TypePyPi {
+		return nil, fmt.Errorf("package URL type is %q, not %q", u.Type, packageurl.TypePyPi)
+	}
+	if u.Namespace != "" {
+		return nil, fmt.Errorf("pypi package URLs have no namespace")
+	}
+
+	q := u.Qualifiers.Map()
+	return &Package{
+		Name:          distfile.NormalizeName(u.Name),
+		Version:       u.Version,
+		Filename:      q[FileNameQualifier],
+		RepositoryURL: q[RepositoryURLQualifier],
+	}, nil
+}
@@ -0,0 +1,62 @@
+package purl
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestString(t *testing.T) {
+	for _, tc := range []struct {
+		pkg      *Package
+		expected string
+	}{
+		{FromProject("Django_REST.framework", "3.15.2"), "pkg:pypi/django-rest-framework@3.15.2"},
+		{FromProject("requests", ""), "pkg:pypi/requests"},
+		{&Package{Name: "requests", Version: "2.32.3", RepositoryURL: "https://test.pypi.org/simple/"}, "pkg:pypi/requests@2.32.3?repository_url=https%3A%2F%2Ftest.pypi.org%2Fsimple%2F"},
+	} {
+		if got := tc.pkg.String(); got != tc.expected {
+			t.Errorf("Expected %s, got %s", tc.expected, got)
+		}
+	}
+
+	p, err := FromFilename("pypi_attestations-0.0.28-py3-none-any.whl")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got := p.String(); got != "pkg:pypi/pypi-attestations@0.0.28?file_name=pypi_attestations-0.0.28-py3-none-any.whl" {
+		t.Errorf("Unexpected package URL: %s", got)
+	}
+	if _, err := FromFilename("foo.exe"); err == nil {
+		t.Error("Expected error for an invalid filename")
+	}
+}
+
+func TestParse(t *testing.T) {
+	expected := &Package{
+		Name:          "pypi-attestations",
+		Version:       "0.0.28",
+		Filename:      "pypi_attestations-0.0.28.tar.gz",
+		RepositoryURL: "https://test.pypi.org/simple/",
+	}
+	p, err := Parse(expected.String())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, p)
+	}
+
+	p, err = Parse("pkg:pypi/PyPI_Attestations@0.0.28")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if p.Name != "pypi-attestations" {
+		t.Errorf("Unexpected name: %s", p.Name)
+	}
+
+	for _, s := range []string{"pkg:npm/left-pad@1.0", "pkg:pypi/ns/name@1.0", "not a purl"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Expected error for %q", s)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package pypi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+)
+
+// Package identifies a project release.
+type Package struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+// PackageProvenance holds the provenance of the files of a release.
+type PackageProvenance struct {
+	Package Package
+
+	// Files lists the distribution files of the release.
+	Files []FileProvenance
+
+	// Error is set when the release files could not be listed.
+	Error error
+}
+
+// FileProvenance is the provenance of a distribution file.
+type FileProvenance struct {
+	Filename string
+
+	// SHA256 is the hex encoded digest published by the index.
+	SHA256 string
+
+	// Provenance is the raw provenance object, nil when it could not be
+	// fetched.
+	Provenance []byte
+
+	// Error is the reason the provenance could not be fetched. It wraps
+	// ErrNotFound when the file has no attestations and is a
+	// *DigestMismatchError when the attestation subjects do not match
+	// SHA256.
+	Error error
+}
+
+// releaseJSON is the subset of the JSON API release response listing its
+// files.
+type releaseJSON struct {
+	URLs []struct {
+		Filename string            `json:"filename"`
+		Digests  map[string]string `json:"digests"`
+	} `json:"urls"`
+}
+
+// FetchAll retrieves the provenance of every file of the packages using
+// Options.Concurrency workers. Requests are throttled by the client rate
+// limiter. Like verify.VerifyAll, it does not stop on failures: the result
+// of every package is returned in order, and the error is only set when
+// the context is canceled.
+func (c *Client) FetchAll(ctx context.Context, packages []Package) ([]PackageProvenance, error) {
+	results := make([]PackageProvenance, len(packages))
+
+	workers := c.Options.Concurrency
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = c.fetchPackage(ctx, packages[i])
+			}
+		}()
+	}
+
+	var err error
+	for i := range packages {
+		if err = ctx.Err(); err != nil {
+			for j := i; j < len(packages); j++ {
+				results[j] = PackageProvenance{Package: packages[j], Error: err}
+			}
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results, err
+}
+
+// fetchPackage lists the files of a release and fetches their provenance.
+func (c *Client) fetchPackage(ctx context.Context, pkg Package) PackageProvenance {
+	result := PackageProvenance{Package: pkg}
+
+	data, err := c.get(ctx, fmt.Sprintf("/pypi/%s/%s/json", url.PathEscape(pkg.Name), url.PathEscape(pkg.Version)), JSONMediaType)
+	if err != nil {
+		result.Error = fmt.Errorf("fetching release %s %s: %w", pkg.Name, pkg.Version, err)
+		return result
+	}
+
+	release := releaseJSON{}
+	if err := json.Unmarshal(data, &release); err != nil {
+		result.Error = fmt.Errorf("parsing release %s %s: %w", pkg.Name, pkg.Version, err)
+		return result
+	}
+
+	for _, f := range release.URLs {
+		fp := FileProvenance{Filename: f.Filename, SHA256: f.Digests["sha256"]}
+		fp.Provenance, fp.Error = c.GetProvenance(ctx, pkg.Name, pkg.Version, f.Filename)
+		if fp.Error == nil {
+			fp.Error = CheckProvenanceDigest(fp.Provenance, f.Filename, fp.SHA256)
+		}
+		result.Files = append(result.Files, fp)
+	}
+	return result
+}
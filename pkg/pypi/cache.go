@@ -0,0 +1,105 @@
+package pypi
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CachedResponse is a response body stored with its ETag.
+type CachedResponse struct {
+	ETag string `json:"etag"`
+	Body []byte `json:"body"`
+}
+
+// ResponseCache stores index responses to revalidate them with conditional
+// requests instead of downloading them again.
+type ResponseCache interface {
+	Get(key string) (*CachedResponse, bool)
+	Set(key string, resp *CachedResponse)
+}
+
+// MemoryResponseCache is an in-memory ResponseCache.
+type MemoryResponseCache struct {
+	mu      sync.Mutex
+	entries map[string]*CachedResponse
+}
+
+// NewMemoryResponseCache returns an empty in-memory response cache.
+func NewMemoryResponseCache() *MemoryResponseCache {
+	return &MemoryResponseCache{entries: map[string]*CachedResponse{}}
+}
+
+// Get returns the cached response for key.
+func (c *MemoryResponseCache) Get(key string) (*CachedResponse, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	resp, ok := c.entries[key]
+	return resp, ok
+}
+
+// Set stores the response under key.
+func (c *MemoryResponseCache) Set(key string, resp *CachedResponse) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = resp
+}
+
+// FileResponseCache is a ResponseCache storing responses in a directory so
+// they are reused across runs. Write errors are ignored, the cache is only
+// an optimization.
+type FileResponseCache struct {
+	dir string
+}
+
+// NewFileResponseCache returns a response cache storing files in dir. The
+// directory is created if it does not exist.
+func NewFileResponseCache(dir string) (*FileResponseCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileResponseCache{dir: dir}, nil
+}
+
+// Get returns the cached response for key.
+func (c *FileResponseCache) Get(key string) (*CachedResponse, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+	resp := &CachedResponse{}
+	if err := json.Unmarshal(data, resp); err != nil {
+		return nil, false
+	}
+	return resp, true
+}
+
+// Set stores the response under key.
+func (c *FileResponseCache) Set(key string, resp *CachedResponse) {
+	data, err := json.Marshal(resp)
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, ".response-*")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return
+	}
+	if err := tmp.Close(); err != nil {
+		return
+	}
+	_ = os.Rename(tmp.Name(), c.path(key))
+}
+
+// path returns the file holding the response for key.
+func (c *FileResponseCache) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:])+".json")
+}
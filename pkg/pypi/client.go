@@ -0,0 +1,472 @@
+// Package pypi implements a client for the provenance endpoints of Python
+// package indexes implementing PEP 740, such as pypi.org.
+package pypi
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/internal/transport"
+	"github.com/carabiner-dev/pypi-attestations/pkg/mediatype"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// DefaultURL is the URL of the Python Package Index.
+	DefaultURL = "https://pypi.org"
+
+	// TestPyPIURL is the URL of the TestPyPI index.
+	TestPyPIURL = "https://test.pypi.org"
+
+	// IntegrityMediaType is the media type of the PEP 740 Integrity API.
+	IntegrityMediaType = mediatype.Provenance
+)
+
+// ErrNotFound is returned when the index has no such resource.
+var ErrNotFound = errors.New("not found")
+
+// Options configures the PyPI client.
+type Options struct {
+	// URL is the base URL of the index. Indexes served under a path, like
+	// devpi or Artifactory, are supported.
+	URL string
+
+	// UploadURL is the legacy upload endpoint of the index.
+	UploadURL string
+
+	// HTTPClient is the client used to talk to the index.
+	HTTPClient *http.Client
+
+	// Headers are added to every request sent to the index, typically to
+	// authenticate against private indexes.
+	Headers http.Header
+
+	// MaxRetries is the number of times a request is retried after a
+	// network error, a 429 or a 5xx response.
+	MaxRetries int
+
+	// RetryBackoff is the wait before the first retry. It doubles on every
+	// attempt up to MaxRetryBackoff. Retry-After headers take precedence.
+	RetryBackoff time.Duration
+
+	// MaxRetryBackoff caps the wait between retries.
+	MaxRetryBackoff time.Duration
+
+	// ResponseCache stores responses to send conditional requests using
+	// their ETag. When nil, responses are not cached.
+	ResponseCache ResponseCache
+
+	// RateLimit is the maximum number of requests per second sent by the
+	// client, enforced with a token bucket of RateBurst tokens. Zero
+	// disables rate limiting.
+	RateLimit float64
+
+	// RateBurst is the size of the token bucket of the rate limiter.
+	RateBurst int
+
+	// MaxConnsPerHost caps the number of connections to each host. It only
+	// applies when no HTTPClient is configured. Zero means no limit.
+	MaxConnsPerHost int
+
+	// Proxy is the URL of the HTTP proxy. When empty, the proxy is read
+	// from the environment. Only applies when no HTTPClient is configured.
+	Proxy string
+
+	// RootCAs are the CAs trusted for TLS connections. When nil, the
+	// system pool is used. Only applies when no HTTPClient is configured.
+	RootCAs *x509.CertPool
+
+	// RoundTripper replaces the HTTP transport. Only applies when no
+	// HTTPClient is configured.
+	RoundTripper http.RoundTripper
+
+	// Concurrency is the number of packages FetchAll processes at once.
+	Concurrency int
+
+	// Logger receives the requests sent to the index and their retries.
+	// When nil, slog.Default() is used.
+	Logger *slog.Logger
+}
+
+var defaultOptions = Options{
+	URL:             DefaultURL,
+	UploadURL:       DefaultUploadURL,
+	MaxRetries:      3,
+	RetryBackoff:    500 * time.Millisecond,
+	MaxRetryBackoff: 30 * time.Second,
+	RateBurst:       1,
+	Concurrency:     8,
+}
+
+// FnOption is a functional option to configure the Client.
+type FnOption func(*Options) error
+
+// WithURL sets the base URL of the index.
+func WithURL(u string) FnOption {
+	return func(o *Options) error {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("parsing index URL: %w", err)
+		}
+		if parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("index URL must be absolute: %q", u)
+		}
+		o.URL = strings.TrimSuffix(u, "/")
+		return nil
+	}
+}
+
+// WithUploadURL sets the legacy upload endpoint of the index.
+func WithUploadURL(u string) FnOption {
+	return func(o *Options) error {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return fmt.Errorf("parsing upload URL: %w", err)
+		}
+		if parsed.Scheme == "" || parsed.Host == "" {
+			return fmt.Errorf("upload URL must be absolute: %q", u)
+		}
+		o.UploadURL = u
+		return nil
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to talk to the index.
+func WithHTTPClient(c *http.Client) FnOption {
+	return func(o *Options) error {
+		o.HTTPClient = c
+		return nil
+	}
+}
+
+// WithHeader adds a header sent with every request to the index.
+func WithHeader(key, value string) FnOption {
+	return func(o *Options) error {
+		if o.Headers == nil {
+			o.Headers = http.Header{}
+		} else {
+			o.Headers = o.Headers.Clone()
+		}
+		o.Headers.Add(key, value)
+		return nil
+	}
+}
+
+// WithRetries sets the number of times failed requests are retried.
+func WithRetries(n int) FnOption {
+	return func(o *Options) error {
+		if n < 0 {
+			return fmt.Errorf("retries cannot be negative")
+		}
+		o.MaxRetries = n
+		return nil
+	}
+}
+
+// WithRetryBackoff sets the initial and maximum wait between retries.
+func WithRetryBackoff(initial, maxWait time.Duration) FnOption {
+	return func(o *Options) error {
+		if initial <= 0 || maxWait < initial {
+			return fmt.Errorf("invalid retry backoff %s-%s", initial, maxWait)
+		}
+		o.RetryBackoff = initial
+		o.MaxRetryBackoff = maxWait
+		return nil
+	}
+}
+
+// WithResponseCache enables conditional requests backed by the cache.
+func WithResponseCache(rc ResponseCache) FnOption {
+	return func(o *Options) error {
+		o.ResponseCache = rc
+		return nil
+	}
+}
+
+// WithRateLimit limits the client to rps requests per second, allowing
+// bursts of up to burst requests.
+func WithRateLimit(rps float64, burst int) FnOption {
+	return func(o *Options) error {
+		if rps < 0 || burst < 1 {
+			return fmt.Errorf("invalid rate limit %v/s with burst %d", rps, burst)
+		}
+		o.RateLimit = rps
+		o.RateBurst = burst
+		return nil
+	}
+}
+
+// WithMaxConnsPerHost caps the number of connections to each host.
+func WithMaxConnsPerHost(n int) FnOption {
+	return func(o *Options) error {
+		if n < 0 {
+			return fmt.Errorf("connection limit cannot be negative")
+		}
+		o.MaxConnsPerHost = n
+		return nil
+	}
+}
+
+// WithProxy sets the URL of the HTTP proxy.
+func WithProxy(u string) FnOption {
+	return func(o *Options) error {
+		if _, err := url.Parse(u); err != nil {
+			return fmt.Errorf("parsing proxy URL: %w", err)
+		}
+		o.Proxy = u
+		return nil
+	}
+}
+
+// WithRootCAs sets the pool of CAs trusted for TLS connections.
+func WithRootCAs(pool *x509.CertPool) FnOption {
+	return func(o *Options) error {
+		o.RootCAs = pool
+		return nil
+	}
+}
+
+// WithCAFile trusts the PEM certificates in path in addition to the
+// system CAs.
+func WithCAFile(path string) FnOption {
+	return func(o *Options) error {
+		pool, err := transport.LoadCertPool(path)
+		if err != nil {
+			return err
+		}
+		o.RootCAs = pool
+		return nil
+	}
+}
+
+// WithRoundTripper replaces the HTTP transport of the client.
+func WithRoundTripper(rt http.RoundTripper) FnOption {
+	return func(o *Options) error {
+		o.RoundTripper = rt
+		return nil
+	}
+}
+
+// WithConcurrency sets the number of packages FetchAll processes at once.
+func WithConcurrency(n int) FnOption {
+	return func(o *Options) error {
+		if n < 1 {
+			return fmt.Errorf("concurrency must be at least 1")
+		}
+		o.Concurrency = n
+		return nil
+	}
+}
+
+// WithLogger sets the logger of the client.
+func WithLogger(l *slog.Logger) FnOption {
+	return func(o *Options) error {
+		o.Logger = l
+		return nil
+	}
+}
+
+// WithBasicAuth authenticates requests using HTTP basic authentication.
+func WithBasicAuth(username, password string) FnOption {
+	return func(o *Options) error {
+		req := &http.Request{Header: http.Header{}}
+		req.SetBasicAuth(username, password)
+		return WithHeader("Authorization", req.Header.Get("Authorization"))(o)
+	}
+}
+
+// WithBearerToken authenticates requests using a bearer token.
+func WithBearerToken(token string) FnOption {
+	return WithHeader("Authorization", "Bearer "+token)
+}
+
+// Client talks to a Python package index.
+type Client struct {
+	Options Options
+
+	client  *http.Client
+	limiter *rate.Limiter
+}
+
+// NewClient returns a new PyPI client configured with the passed options.
+func NewClient(funcs ...FnOption) (*Client, error) {
+	opts := defaultOptions
+	for _, fn := range funcs {
+		if err := fn(&opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	c := &Client{Options: opts}
+	if opts.RateLimit > 0 {
+		c.limiter = rate.NewLimiter(rate.Limit(opts.RateLimit), opts.RateBurst)
+	}
+
+	tc := transport.Config{
+		Proxy:           opts.Proxy,
+		RootCAs:         opts.RootCAs,
+		RoundTripper:    opts.RoundTripper,
+		MaxConnsPerHost: opts.MaxConnsPerHost,
+	}
+	client, err := tc.Client()
+	if err != nil {
+		return nil, err
+	}
+	c.client = client
+	return c, nil
+}
+
+// GetProvenanceFromURL fetches the raw provenance object from the URL
+// listed by the Simple API.
+func (c *Client) GetProvenanceFromURL(ctx context.Context, u string) ([]byte, error) {
+	data, err := c.getURL(ctx, u, IntegrityMediaType)
+	if err != nil {
+		return nil, fmt.Errorf("fetching provenance: %w", err)
+	}
+	return data, nil
+}
+
+// GetProvenance fetches the raw PEP 740 provenance object of a distribution
+// file from the Integrity API.
+func (c *Client) GetProvenance(ctx context.Context, project, version, filename string) ([]byte, error) {
+	if project == "" || version == "" || filename == "" {
+		return nil, fmt.Errorf("project, version and filename are required")
+	}
+
+	path := fmt.Sprintf(
+		"/integrity/%s/%s/%s/provenance",
+		url.PathEscape(project), url.PathEscape(version), url.PathEscape(filename),
+	)
+	data, err := c.get(ctx, path, IntegrityMediaType)
+	if err != nil {
+		return nil, fmt.Errorf("fetching provenance of %s: %w", filename, err)
+	}
+	return data, nil
+}
+
+// get requests path from the index and returns the response body.
+func (c *Client) get(ctx context.Context, path, accept string) ([]byte, error) {
+	return c.getURL(ctx, c.Options.URL+path, accept)
+}
+
+// getURL requests the absolute URL u and returns the response body. When a
+// response cache is configured, the request is conditional on the ETag of
+// the cached response, which is returned if the resource did not change.
+func (c *Client) getURL(ctx context.Context, u, accept string) ([]byte, error) {
+	req, err := c.newRequest(ctx, u, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	key := accept + " " + u
+	var cached *CachedResponse
+	if c.Options.ResponseCache != nil {
+		if cr, ok := c.Options.ResponseCache.Get(key); ok {
+			cached = cr
+			req.Header.Set("If-None-Match", cached.ETag)
+		}
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		c.Options.Logger.DebugContext(ctx, "using cached response", "url", u)
+		return cached.Body, nil
+	}
+	if err := checkStatus(resp); err != nil {
+		return nil, err
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	if etag := resp.Header.Get("ETag"); etag != "" && c.Options.ResponseCache != nil {
+		c.Options.ResponseCache.Set(key, &CachedResponse{ETag: etag, Body: data})
+	}
+	return data, nil
+}
+
+// open requests the absolute URL u and returns the response body, which
+// the caller must close.
+func (c *Client) open(ctx context.Context, u, accept string) (io.ReadCloser, error) {
+	req, err := c.newRequest(ctx, u, accept)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkStatus(resp); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// newRequest creates a GET request for u. The configured headers are only
+// sent to the index host.
+func (c *Client) newRequest(ctx context.Context, u, accept string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	if c.isIndexHost(req.URL) {
+		for k, v := range c.Options.Headers {
+			req.Header[k] = v
+		}
+	}
+	req.Header.Set("Accept", accept)
+	return req, nil
+}
+
+// checkStatus returns an error for unsuccessful responses.
+func checkStatus(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+	case http.StatusNotFound:
+		return ErrNotFound
+	default:
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+}
+
+// isIndexHost returns true if u points to the host of the index.
+func (c *Client) isIndexHost(u *url.URL) bool {
+	base, err := url.Parse(c.Options.URL)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(base.Host, u.Host)
+}
+
+// httpClient returns the configured HTTP client or the default one.
+func (c *Client) httpClient() *http.Client {
+	if c.Options.HTTPClient != nil {
+		return c.Options.HTTPClient
+	}
+	if c.client != nil {
+		return c.client
+	}
+	return http.DefaultClient
+}
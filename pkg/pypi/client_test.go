@@ -0,0 +1,738 @@
+package pypi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/publisher"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// testDigest is the sha256 of the distribution attested in the test data.
+const testDigest = "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"
+
+// newTestServer returns an index serving the test provenance under prefix.
+func newTestServer(t *testing.T, prefix string) *httptest.Server {
+	t.Helper()
+	provenance, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.provenance.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(
+		prefix+"/integrity/pypi-attestations/0.0.28/pypi_attestations-0.0.28.tar.gz/provenance",
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Accept") != IntegrityMediaType {
+				http.Error(w, "unexpected accept header", http.StatusNotAcceptable)
+				return
+			}
+			if user, pass, ok := r.BasicAuth(); r.Header.Get("Authorization") != "" && (!ok || user != "user" || pass != "secret") {
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			w.Header().Set("Content-Type", IntegrityMediaType)
+			if _, err := w.Write(provenance); err != nil {
+				t.Errorf("Failed to write response: %v", err)
+			}
+		},
+	)
+	mux.HandleFunc(prefix+"/pypi/pypi-attestations/json", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte(testProjectJSON)); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	})
+	mux.HandleFunc(prefix+"/pypi/pypi-attestations/0.0.28/json", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte(testReleaseJSON)); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	})
+	mux.HandleFunc(prefix+"/files/", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte("not the real sdist")); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	})
+	mux.HandleFunc(prefix+"/simple/pypi-attestations/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Accept") != SimpleMediaType {
+			http.Error(w, "unexpected accept header", http.StatusNotAcceptable)
+			return
+		}
+		w.Header().Set("Content-Type", SimpleMediaType)
+		if _, err := w.Write([]byte(testSimplePage)); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	})
+	return httptest.NewServer(mux)
+}
+
+// testProjectJSON is a JSON API project response matching testSimplePage.
+const testProjectJSON = `{
+  "info": {"name": "pypi-attestations"},
+  "releases": {
+    "0.0.27": [
+      {
+        "filename": "pypi_attestations-0.0.27.tar.gz",
+        "packagetype": "sdist",
+        "digests": {"sha256": "0000000000000000000000000000000000000000000000000000000000000000"},
+        "yanked": true,
+        "upload_time_iso_8601": "2025-09-01T10:00:00.000000Z"
+      }
+    ],
+    "0.0.28": [
+      {
+        "filename": "pypi_attestations-0.0.28.tar.gz",
+        "packagetype": "sdist",
+        "digests": {"sha256": "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"},
+        "yanked": false,
+        "upload_time_iso_8601": "2025-10-16T16:58:10.000000Z"
+      }
+    ]
+  }
+}`
+
+// testReleaseJSON is the JSON API response of release 0.0.28.
+const testReleaseJSON = `{
+  "info": {"name": "pypi-attestations", "version": "0.0.28"},
+  "urls": [
+    {
+      "filename": "pypi_attestations-0.0.28.tar.gz",
+      "digests": {"sha256": "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"}
+    },
+    {
+      "filename": "pypi_attestations-0.0.28-py3-none-any.whl",
+      "digests": {"sha256": "1111111111111111111111111111111111111111111111111111111111111111"}
+    }
+  ]
+}`
+
+// testSimplePage is a PEP 691 project page with one attested file.
+const testSimplePage = `{
+  "meta": {"api-version": "1.3"},
+  "name": "pypi-attestations",
+  "versions": ["0.0.27", "0.0.28"],
+  "files": [
+    {
+      "filename": "pypi_attestations-0.0.27.tar.gz",
+      "url": "https://files.example.com/pypi_attestations-0.0.27.tar.gz",
+      "hashes": {"sha256": "0000000000000000000000000000000000000000000000000000000000000000"},
+      "yanked": "broken release",
+      "provenance": null
+    },
+    {
+      "filename": "pypi_attestations-0.0.28.tar.gz",
+      "url": "../../files/pypi_attestations-0.0.28.tar.gz",
+      "hashes": {"sha256": "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"},
+      "yanked": false,
+      "provenance": "../../integrity/pypi-attestations/0.0.28/pypi_attestations-0.0.28.tar.gz/provenance"
+    }
+  ]
+}`
+
+func TestGetProvenance(t *testing.T) {
+	srv := newTestServer(t, "/root/pypi")
+	defer srv.Close()
+
+	client, err := NewClient(WithURL(srv.URL+"/root/pypi/"), WithBasicAuth("user", "secret"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	data, err := client.GetProvenance(context.Background(), "pypi-attestations", "0.0.28", "pypi_attestations-0.0.28.tar.gz")
+	if err != nil {
+		t.Fatalf("Failed to fetch provenance: %v", err)
+	}
+	if len(data) == 0 {
+		t.Error("Expected provenance data")
+	}
+
+	_, err = client.GetProvenance(context.Background(), "pypi-attestations", "0.0.28", "missing.tar.gz")
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+
+	client, err = NewClient(WithURL(srv.URL+"/root/pypi"), WithBasicAuth("user", "wrong"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := client.GetProvenance(context.Background(), "pypi-attestations", "0.0.28", "pypi_attestations-0.0.28.tar.gz"); err == nil {
+		t.Error("Expected error for bad credentials")
+	}
+}
+
+func TestClientOptions(t *testing.T) {
+	if _, err := NewClient(WithURL("not a url")); err == nil {
+		t.Error("Expected error for relative URL")
+	}
+
+	client, err := NewClient(WithBearerToken("tok"), WithHeader("X-Mirror", "a"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if client.Options.URL != DefaultURL {
+		t.Errorf("Unexpected default URL: %s", client.Options.URL)
+	}
+	if got := client.Options.Headers.Get("Authorization"); got != "Bearer tok" {
+		t.Errorf("Unexpected authorization header: %s", got)
+	}
+	if defaultOptions.Headers != nil {
+		t.Error("Options must not modify the defaults")
+	}
+}
+
+func TestGetSimpleProject(t *testing.T) {
+	srv := newTestServer(t, "/root/pypi")
+	defer srv.Close()
+
+	client, err := NewClient(WithURL(srv.URL+"/root/pypi"), WithBasicAuth("user", "secret"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	page, err := client.GetSimpleProject(context.Background(), "PyPI_Attestations")
+	if err != nil {
+		t.Fatalf("Failed to fetch simple page: %v", err)
+	}
+
+	if len(page.Files) != 2 {
+		t.Fatalf("Expected 2 files, got %d", len(page.Files))
+	}
+
+	if !page.Files[0].Yanked.Yanked || page.Files[0].Yanked.Reason != "broken release" {
+		t.Errorf("Unexpected yanked status: %+v", page.Files[0].Yanked)
+	}
+
+	files := page.FilesWithProvenance()
+	if len(files) != 1 || files[0].Filename != "pypi_attestations-0.0.28.tar.gz" {
+		t.Fatalf("Unexpected files with provenance: %+v", files)
+	}
+
+	if files[0].URL != srv.URL+"/root/pypi/files/pypi_attestations-0.0.28.tar.gz" {
+		t.Errorf("Unexpected file URL: %s", files[0].URL)
+	}
+
+	data, err := client.GetProvenanceFromURL(context.Background(), files[0].Provenance)
+	if err != nil {
+		t.Fatalf("Failed to fetch provenance: %v", err)
+	}
+	if len(data) == 0 {
+		t.Error("Expected provenance data")
+	}
+}
+
+func TestVerifyProvenance(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.provenance.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	v, err := verify.New(verify.WithEmbeddedTrustedRoot(verify.InstanceProduction))
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	report, err := VerifyProvenance(context.Background(), v, data, "pypi_attestations-0.0.28.tar.gz", digest)
+	if err != nil {
+		t.Fatalf("Failed to verify provenance: %v", err)
+	}
+	if !report.Passed() || len(report.Attestations) != 1 {
+		t.Fatalf("Expected report to pass: %+v", report)
+	}
+	if report.Attestations[0].Verification.Extensions.SourceRepositoryURI != "https://github.com/pypi/pypi-attestations" {
+		t.Errorf("Unexpected verification result: %+v", report.Attestations[0].Verification)
+	}
+
+	report, err = VerifyProvenance(context.Background(), v, data, "pypi_attestations-0.0.29.tar.gz", digest)
+	if err != nil {
+		t.Fatalf("Failed to verify provenance: %v", err)
+	}
+	if report.Passed() {
+		t.Error("Expected report to fail for mismatched filename")
+	}
+
+	spoofed := []byte(strings.Replace(string(data), `"workflow": "release.yml"`, `"workflow": "other.yml"`, 1))
+	report, err = VerifyProvenance(context.Background(), v, spoofed, "pypi_attestations-0.0.28.tar.gz", digest)
+	if err != nil {
+		t.Fatalf("Failed to verify provenance: %v", err)
+	}
+	if report.Passed() {
+		t.Error("Expected report to fail for spoofed publisher")
+	}
+}
+
+func TestFetchAndVerify(t *testing.T) {
+	srv := newTestServer(t, "")
+	defer srv.Close()
+
+	client, err := NewClient(WithURL(srv.URL))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// The test server does not serve the real sdist so the attestation
+	// must not verify against the downloaded digest.
+	report, err := client.FetchAndVerify(
+		context.Background(), "pypi-attestations", "0.0.28", "pypi_attestations-0.0.28.tar.gz", nil,
+		verify.WithEmbeddedTrustedRoot(verify.InstanceProduction),
+	)
+	if err != nil {
+		t.Fatalf("Failed to fetch and verify: %v", err)
+	}
+	if report.Passed() || len(report.Attestations) != 1 {
+		t.Errorf("Expected report with one failed attestation: %+v", report)
+	}
+
+	if _, err := client.FetchAndVerify(
+		context.Background(), "pypi-attestations", "0.0.28", "missing.tar.gz", nil,
+		verify.WithEmbeddedTrustedRoot(verify.InstanceProduction),
+	); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	attempts := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		switch {
+		case attempts == 1:
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case attempts == 2:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case r.URL.Path == "/integrity/p/1.0/p-1.0.tar.gz/provenance":
+			if _, err := w.Write([]byte("{}")); err != nil {
+				t.Errorf("Failed to write response: %v", err)
+			}
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+
+	var logs bytes.Buffer
+	client, err := NewClient(
+		WithURL(srv.URL),
+		WithRetryBackoff(time.Millisecond, 10*time.Millisecond),
+		WithLogger(slog.New(slog.NewTextHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug}))),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if _, err := client.GetProvenance(context.Background(), "p", "1.0", "p-1.0.tar.gz"); err != nil {
+		t.Fatalf("Expected request to succeed after retries: %v", err)
+	}
+	if attempts != 3 {
+		t.Errorf("Expected 3 attempts, got %d", attempts)
+	}
+	if n := strings.Count(logs.String(), `msg="retrying request"`); n != 2 {
+		t.Errorf("Expected 2 retries logged, got %d:\n%s", n, logs.String())
+	}
+	if n := strings.Count(logs.String(), `msg="request sent"`); n != 3 {
+		t.Errorf("Expected 3 requests logged, got %d:\n%s", n, logs.String())
+	}
+
+	// Skip the transient failures, every other path fails permanently
+	attempts = 2
+	if _, err := client.GetProvenance(context.Background(), "p", "1.0", "other.tar.gz"); err == nil {
+		t.Error("Expected error after exhausting retries")
+	}
+	if got := attempts - 2; got != 1+client.Options.MaxRetries {
+		t.Errorf("Expected %d attempts, got %d", 1+client.Options.MaxRetries, got)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	if d, ok := retryAfter("5"); !ok || d != 5*time.Second {
+		t.Errorf("Unexpected delay for seconds form: %s", d)
+	}
+	if d, ok := retryAfter(time.Now().Add(time.Hour).UTC().Format(http.TimeFormat)); !ok || d <= 0 {
+		t.Errorf("Unexpected delay for date form: %s", d)
+	}
+	if _, ok := retryAfter("soon"); ok {
+		t.Error("Expected invalid Retry-After to be ignored")
+	}
+}
+
+func TestConditionalRequests(t *testing.T) {
+	full := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		full++
+		w.Header().Set("ETag", `"v1"`)
+		if _, err := w.Write([]byte(`{"version": 1}`)); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	fileCache, err := NewFileResponseCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("Failed to create cache: %v", err)
+	}
+
+	for name, cache := range map[string]ResponseCache{
+		"memory": NewMemoryResponseCache(),
+		"file":   fileCache,
+	} {
+		t.Run(name, func(t *testing.T) {
+			full = 0
+			client, err := NewClient(WithURL(srv.URL), WithResponseCache(cache))
+			if err != nil {
+				t.Fatalf("Failed to create client: %v", err)
+			}
+			for range 3 {
+				data, err := client.GetProvenance(context.Background(), "p", "1.0", "p-1.0.tar.gz")
+				if err != nil {
+					t.Fatalf("Failed to fetch provenance: %v", err)
+				}
+				if string(data) != `{"version": 1}` {
+					t.Errorf("Unexpected response: %s", data)
+				}
+			}
+			if full != 1 {
+				t.Errorf("Expected one full response, got %d", full)
+			}
+		})
+	}
+}
+
+func TestUpload(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := convert.UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+
+	content := "sdist contents"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "__token__" || pass != "pypi-token" {
+			http.Error(w, "unauthorized", http.StatusForbidden)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		for field, expected := range map[string]string{
+			":action":   "file_upload",
+			"name":      "pypi-attestations",
+			"version":   "0.0.28",
+			"filetype":  "sdist",
+			"pyversion": "source",
+			"summary":   "test",
+		} {
+			if got := r.FormValue(field); got != expected {
+				http.Error(w, "unexpected "+field+": "+got, http.StatusBadRequest)
+				return
+			}
+		}
+
+		var attestations []json.RawMessage
+		if err := json.Unmarshal([]byte(r.FormValue("attestations")), &attestations); err != nil || len(attestations) != 1 {
+			http.Error(w, "invalid attestations", http.StatusBadRequest)
+			return
+		}
+		if _, err := convert.UnmarshalAttestation(attestations[0]); err != nil {
+			http.Error(w, "invalid attestation", http.StatusBadRequest)
+			return
+		}
+
+		f, _, err := r.FormFile("content")
+		if err != nil {
+			http.Error(w, "missing content", http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		h := sha256.New()
+		if _, err := io.Copy(h, f); err != nil || hex.EncodeToString(h.Sum(nil)) != r.FormValue("sha256_digest") {
+			http.Error(w, "digest mismatch", http.StatusBadRequest)
+			return
+		}
+	}))
+	defer srv.Close()
+
+	client, err := NewClient(WithUploadURL(srv.URL + "/legacy/"))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	req := &UploadRequest{
+		Name:         "pypi-attestations",
+		Version:      "0.0.28",
+		Filename:     "pypi_attestations-0.0.28.tar.gz",
+		Content:      strings.NewReader(content),
+		Metadata:     map[string][]string{"summary": {"test"}},
+		Attestations: []*pb.Attestation{attestation},
+		Password:     "pypi-token",
+	}
+	if err := client.Upload(context.Background(), req); err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+
+	req.Content = strings.NewReader(content)
+	req.Password = "wrong"
+	if err := client.Upload(context.Background(), req); err == nil {
+		t.Error("Expected error for rejected upload")
+	}
+
+	// The form is streamed, a distribution read error fails the request
+	req.Content = iotest.ErrReader(errors.New("disk failure"))
+	req.Password = "pypi-token"
+	if err := client.Upload(context.Background(), req); err == nil || !strings.Contains(err.Error(), "disk failure") {
+		t.Errorf("Expected the read error, got %v", err)
+	}
+
+	// Uploads wait for the rate limiter
+	limited, err := NewClient(WithUploadURL(srv.URL+"/legacy/"), WithRateLimit(50, 1))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	start := time.Now()
+	for range 3 {
+		req.Content = strings.NewReader(content)
+		if err := limited.Upload(context.Background(), req); err != nil {
+			t.Fatalf("Failed to upload: %v", err)
+		}
+	}
+	// 3 uploads at 50/s with a burst of 1 take at least 40ms
+	if elapsed := time.Since(start); elapsed < 35*time.Millisecond {
+		t.Errorf("Expected uploads to be rate limited, took %s", elapsed)
+	}
+}
+
+func TestDistributionType(t *testing.T) {
+	for filename, expected := range map[string][2]string{
+		"foo-1.0.tar.gz":                      {"sdist", "source"},
+		"foo-1.0-py3-none-any.whl":            {"bdist_wheel", "py3"},
+		"foo-1.0-1-cp312-cp312-linux_x86.whl": {"bdist_wheel", "cp312"},
+	} {
+		filetype, pyversion, err := distributionType(filename)
+		if err != nil {
+			t.Errorf("Failed to parse %s: %v", filename, err)
+			continue
+		}
+		if filetype != expected[0] || pyversion != expected[1] {
+			t.Errorf("Unexpected type of %s: %s %s", filename, filetype, pyversion)
+		}
+	}
+
+	if _, _, err := distributionType("foo-1.0.exe"); err == nil {
+		t.Error("Expected error for unsupported distribution")
+	}
+}
+
+func TestProjectReport(t *testing.T) {
+	srv := newTestServer(t, "")
+	defer srv.Close()
+
+	client, err := NewClient(WithURL(srv.URL))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	inventory, err := client.ProjectReport(context.Background(), "pypi-attestations")
+	if err != nil {
+		t.Fatalf("Failed to build project report: %v", err)
+	}
+
+	if len(inventory.Files) != 2 || inventory.Attested() != 1 {
+		t.Fatalf("Unexpected inventory: %+v", inventory)
+	}
+
+	old, current := inventory.Files[0], inventory.Files[1]
+	if old.Version != "0.0.27" || old.HasAttestation || !old.Yanked {
+		t.Errorf("Unexpected status of old release: %+v", old)
+	}
+	if current.Version != "0.0.28" || !current.HasAttestation || len(current.Publishers) != 1 {
+		t.Fatalf("Unexpected status of current release: %+v", current)
+	}
+
+	gh, ok := current.Publishers[0].(*publisher.GitHubPublisher)
+	if !ok || gh.Repository != "pypi/pypi-attestations" || gh.Workflow != "release.yml" {
+		t.Errorf("Unexpected publisher: %+v", current.Publishers[0])
+	}
+}
+
+func TestFetchAll(t *testing.T) {
+	srv := newTestServer(t, "")
+	defer srv.Close()
+
+	client, err := NewClient(WithURL(srv.URL), WithConcurrency(2), WithMaxConnsPerHost(2), WithRateLimit(1000, 10))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	results, err := client.FetchAll(context.Background(), []Package{
+		{Name: "pypi-attestations", Version: "0.0.28"},
+		{Name: "missing", Version: "1.0"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to fetch packages: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("Expected 2 results, got %d", len(results))
+	}
+
+	if results[0].Error != nil || len(results[0].Files) != 2 {
+		t.Fatalf("Unexpected result: %+v", results[0])
+	}
+	if sdist := results[0].Files[0]; sdist.Error != nil || len(sdist.Provenance) == 0 || sdist.SHA256 != testDigest {
+		t.Errorf("Unexpected sdist provenance: %+v", sdist)
+	}
+	if wheel := results[0].Files[1]; !errors.Is(wheel.Error, ErrNotFound) {
+		t.Errorf("Expected wheel without provenance, got %v", wheel.Error)
+	}
+
+	if !errors.Is(results[1].Error, ErrNotFound) {
+		t.Errorf("Expected missing package error, got %v", results[1].Error)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.FetchAll(ctx, []Package{{Name: "pypi-attestations", Version: "0.0.28"}}); err == nil {
+		t.Error("Expected error for canceled context")
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	srv := newTestServer(t, "")
+	defer srv.Close()
+
+	client, err := NewClient(WithURL(srv.URL), WithRateLimit(50, 1))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	start := time.Now()
+	for range 5 {
+		if _, err := client.GetSimpleProject(context.Background(), "pypi-attestations"); err != nil {
+			t.Fatalf("Failed to fetch simple page: %v", err)
+		}
+	}
+
+	// 5 requests at 50/s with a burst of 1 take at least 80ms
+	if elapsed := time.Since(start); elapsed < 70*time.Millisecond {
+		t.Errorf("Expected requests to be rate limited, took %s", elapsed)
+	}
+}
+
+func TestCustomTransport(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := w.Write([]byte("{}")); err != nil {
+			t.Errorf("Failed to write response: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	// The system pool does not trust the test server
+	client, err := NewClient(WithURL(srv.URL), WithRetries(0))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := client.GetProvenance(context.Background(), "p", "1.0", "p-1.0.tar.gz"); err == nil {
+		t.Error("Expected TLS error without custom CA")
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	client, err = NewClient(WithURL(srv.URL), WithRootCAs(pool))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := client.GetProvenance(context.Background(), "p", "1.0", "p-1.0.tar.gz"); err != nil {
+		t.Errorf("Expected request to succeed with custom CA: %v", err)
+	}
+
+	client, err = NewClient(WithURL(srv.URL), WithRoundTripper(srv.Client().Transport))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := client.GetProvenance(context.Background(), "p", "1.0", "p-1.0.tar.gz"); err != nil {
+		t.Errorf("Expected request to succeed with custom round tripper: %v", err)
+	}
+
+	if _, err := NewClient(WithRoundTripper(http.DefaultTransport), WithRootCAs(pool)); err == nil {
+		t.Error("Expected error combining round tripper and CAs")
+	}
+}
+
+func TestCheckProvenanceDigest(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.provenance.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+
+	if err := CheckProvenanceDigest(data, "pypi_attestations-0.0.28.tar.gz", testDigest); err != nil {
+		t.Errorf("Expected digests to match: %v", err)
+	}
+
+	other := "1111111111111111111111111111111111111111111111111111111111111111"
+	err = CheckProvenanceDigest(data, "pypi_attestations-0.0.28.tar.gz", other)
+	var mismatch *DigestMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected DigestMismatchError, got %v", err)
+	}
+	if mismatch.IndexDigest != other || mismatch.SubjectDigest != testDigest {
+		t.Errorf("Unexpected mismatch details: %+v", mismatch)
+	}
+}
+
+func TestParseRequirements(t *testing.T) {
+	reqs, err := ParseRequirements(strings.NewReader(`# Pinned dependencies
+--index-url https://pypi.org/simple
+requests[socks]==2.32.3 ; python_version >= "3.8" \
+    --hash=sha256:AAAA \
+    --hash=sha256:bbbb
+idna==3.10 --hash sha256:cccc  # via requests
+
+urllib3 == 2.2.3
+`))
+	if err != nil {
+		t.Fatalf("Failed to parse requirements: %v", err)
+	}
+	expected := []Requirement{
+		{Name: "requests", Version: "2.32.3", Hashes: []string{"aaaa", "bbbb"}, Line: 3},
+		{Name: "idna", Version: "3.10", Hashes: []string{"cccc"}, Line: 6},
+		{Name: "urllib3", Version: "2.2.3", Line: 8},
+	}
+	if !reflect.DeepEqual(reqs, expected) {
+		t.Errorf("Unexpected requirements:\n%+v\nexpected:\n%+v", reqs, expected)
+	}
+
+	for _, line := range []string{"requests>=2.0", "requests", "-r other.txt", "-e .", "pkg @ https://example.com/pkg.whl"} {
+		if _, err := ParseRequirements(strings.NewReader(line)); err == nil {
+			t.Errorf("Expected error for %q", line)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package pypi
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+)
+
+// DigestMismatchError is returned when the sha256 digest published by the
+// index for a file does not match the subject digest of its attestations.
+type DigestMismatchError struct {
+	Filename      string
+	IndexDigest   string
+	SubjectDigest string
+}
+
+// Error implements the error interface.
+func (e *DigestMismatchError) Error() string {
+	return fmt.Sprintf(
+		"digest mismatch for %s: index publishes sha256 %s, attestation subject has %s",
+		e.Filename, e.IndexDigest, e.SubjectDigest,
+	)
+}
+
+// CheckProvenanceDigest ensures the subject of every attestation in the
+// provenance object data matches the hex encoded sha256 digest published by
+// the index for the file. It returns a *DigestMismatchError on mismatch.
+func CheckProvenanceDigest(data []byte, filename, indexDigest string) error {
+	if indexDigest == "" {
+		return fmt.Errorf("index does not publish a sha256 digest for %s", filename)
+	}
+
+	p, err := convert.UnmarshalProvenance(data)
+	if err != nil {
+		return fmt.Errorf("parsing provenance: %w", err)
+	}
+
+	for _, bundle := range p.AttestationBundles {
+		for _, attestation := range bundle.Attestations {
+			statement := struct {
+				Subject []struct {
+					Digest map[string]string `json:"digest"`
+				} `json:"subject"`
+			}{}
+			if err := json.Unmarshal(attestation.GetEnvelope().GetStatement(), &statement); err != nil {
+				return fmt.Errorf("parsing statement: %w", err)
+			}
+
+			for _, s := range statement.Subject {
+				if !strings.EqualFold(s.Digest["sha256"], indexDigest) {
+					return &DigestMismatchError{
+						Filename:      filename,
+						IndexDigest:   indexDigest,
+						SubjectDigest: s.Digest["sha256"],
+					}
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package pypi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/publisher"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// Report is the combined outcome of verifying all the attestations in the
+// provenance of a distribution file.
+type Report struct {
+	Project  string `json:"project"`
+	Version  string `json:"version"`
+	Filename string `json:"filename"`
+
+	// Digest is the hex encoded sha256 digest of the downloaded file.
+	Digest string `json:"digest"`
+
+	// Attestations lists the outcome of every attestation in the
+	// provenance object, in order.
+	Attestations []AttestationReport `json:"attestations"`
+}
+
+// AttestationReport is the outcome of verifying one attestation.
+type AttestationReport struct {
+	// Publisher is the publisher of the attestation bundle.
+	Publisher publisher.Publisher `json:"publisher,omitempty"`
+
+	// Verification is the verification result when it passed.
+	Verification *verify.VerificationResult `json:"verification,omitempty"`
+
+	// Error is the reason verification failed, empty if it passed.
+	Error string `json:"error,omitempty"`
+}
+
+// Passed returns true if the file has attestations and all of them verify.
+func (r *Report) Passed() bool {
+	if len(r.Attestations) == 0 {
+		return false
+	}
+	for _, a := range r.Attestations {
+		if a.Error != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// FetchAndVerify downloads a distribution file from pypi.org, fetches its
+// provenance and verifies every attestation using a verifier configured
+// with funcs. See Client.FetchAndVerify for details.
+func FetchAndVerify(ctx context.Context, project, version, filename string, policy *verify.Policy, funcs ...verify.FnOption) (*Report, error) {
+	c, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.FetchAndVerify(ctx, project, version, filename, policy, funcs...)
+}
+
+// FetchAndVerify downloads a distribution file, fetches its provenance and
+// verifies every attestation it contains against the file digest and, when
+// not nil, the publisher policy. Verification failures are recorded in the
+// report, the error is only returned when the file or its provenance
+// cannot be fetched, or a *DigestMismatchError when the attestation
+// subjects do not match the digest published by the index.
+func (c *Client) FetchAndVerify(
+	ctx context.Context, project, version, filename string, policy *verify.Policy, funcs ...verify.FnOption,
+) (*Report, error) {
+	if policy != nil {
+		funcs = append(funcs, verify.WithPolicy(policy))
+	}
+	v, err := verify.New(funcs...)
+	if err != nil {
+		return nil, err
+	}
+
+	page, err := c.GetSimpleProject(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+
+	var file *SimpleFile
+	for i := range page.Files {
+		if page.Files[i].Filename == filename {
+			file = &page.Files[i]
+			break
+		}
+	}
+	if file == nil {
+		return nil, fmt.Errorf("file %s of %s: %w", filename, project, ErrNotFound)
+	}
+
+	digest, err := c.download(ctx, file.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	var data []byte
+	if file.HasProvenance() {
+		data, err = c.GetProvenanceFromURL(ctx, file.Provenance)
+	} else {
+		data, err = c.GetProvenance(ctx, project, version, filename)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := CheckProvenanceDigest(data, filename, file.Hashes["sha256"]); err != nil {
+		return nil, err
+	}
+
+	report, err := VerifyProvenance(ctx, v, data, filename, digest)
+	if err != nil {
+		return nil, err
+	}
+	report.Project = project
+	report.Version = version
+	return report, nil
+}
+
+// download fetches the file at u and returns its sha256 digest.
+func (c *Client) download(ctx context.Context, u string) ([]byte, error) {
+	body, err := c.open(ctx, u, "*/*")
+	if err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", u, err)
+	}
+	defer body.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, body); err != nil {
+		return nil, fmt.Errorf("downloading %s: %w", u, err)
+	}
+	return h.Sum(nil), nil
+}
+
+// verifyProvenance verifies the attestations of the provenance object data
+// against the distribution filename and digest.
+func VerifyProvenance(ctx context.Context, v *verify.Verifier, data []byte, filename string, digest []byte) (*Report, error) {
+	p, err := convert.UnmarshalProvenance(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing provenance: %w", err)
+	}
+	if p.Version != 1 {
+		return nil, fmt.Errorf("unsupported provenance version: %d", p.Version)
+	}
+
+	report := &Report{
+		Filename: filename,
+		Digest:   hex.EncodeToString(digest),
+	}
+	for _, bundle := range p.AttestationBundles {
+		pub, err := publisher.FromStruct(bundle.GetPublisher())
+		if err != nil {
+			return nil, err
+		}
+		for _, attestation := range bundle.Attestations {
+			ar := AttestationReport{Publisher: pub}
+			result, err := verifyAttestation(ctx, v, attestation, pub, filename, digest)
+			if err != nil {
+				ar.Error = err.Error()
+			} else {
+				ar.Verification = result
+			}
+			report.Attestations = append(report.Attestations, ar)
+		}
+	}
+	return report, nil
+}
+
+// verifyAttestation verifies a single attestation and ensures its subject
+// names the distribution file and its signing certificate matches the
+// publisher of the bundle.
+func verifyAttestation(
+	ctx context.Context, v *verify.Verifier, attestation *pb.Attestation, pub publisher.Publisher,
+	filename string, digest []byte,
+) (*verify.VerificationResult, error) {
+	result, err := v.VerifyDigestWithPublisher(ctx, attestation, digest, pub)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, s := range result.Subjects {
+		if err := convert.ValidateSubjectName(s.Name, filename); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := publisher.CheckCertificate(pub, result.Certificate); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
@@ -0,0 +1,159 @@
+package pypi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/mediatype"
+	"github.com/carabiner-dev/pypi-attestations/pkg/publisher"
+)
+
+// JSONMediaType is the media type of the PyPI JSON API.
+const JSONMediaType = mediatype.JSON
+
+// ProjectInventory lists the distribution files of a project and whether
+// they carry attestations.
+type ProjectInventory struct {
+	Project string          `json:"project"`
+	Files   []InventoryFile `json:"files"`
+}
+
+// InventoryFile is the attestation status of a distribution file.
+type InventoryFile struct {
+	Version     string `json:"version"`
+	Filename    string `json:"filename"`
+	PackageType string `json:"packagetype"`
+	SHA256      string `json:"sha256"`
+	Yanked      bool   `json:"yanked"`
+	UploadTime  string `json:"upload_time"`
+
+	// HasAttestation is true when the index serves provenance for the file.
+	HasAttestation bool `json:"has_attestation"`
+
+	// Publishers lists the publisher of every attestation bundle in the
+	// provenance of the file.
+	Publishers []publisher.Publisher `json:"publishers,omitempty"`
+}
+
+// Attested returns the number of files with attestations.
+func (pi *ProjectInventory) Attested() int {
+	n := 0
+	for _, f := range pi.Files {
+		if f.HasAttestation {
+			n++
+		}
+	}
+	return n
+}
+
+// projectJSON is the subset of the JSON API project response used to list
+// the files of all releases.
+type projectJSON struct {
+	Releases map[string][]struct {
+		Filename    string            `json:"filename"`
+		PackageType string            `json:"packagetype"`
+		Digests     map[string]string `json:"digests"`
+		Yanked      bool              `json:"yanked"`
+		UploadTime  string            `json:"upload_time_iso_8601"`
+	} `json:"releases"`
+}
+
+// ProjectReport builds the provenance inventory of a project on pypi.org.
+// See Client.ProjectReport for details.
+func ProjectReport(ctx context.Context, project string) (*ProjectInventory, error) {
+	c, err := NewClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.ProjectReport(ctx, project)
+}
+
+// ProjectReport walks all the releases of a project using the JSON API and
+// reports which files have provenance and who published them. Files are
+// sorted by upload time.
+func (c *Client) ProjectReport(ctx context.Context, project string) (*ProjectInventory, error) {
+	if project == "" {
+		return nil, fmt.Errorf("project name is required")
+	}
+
+	data, err := c.get(ctx, "/pypi/"+url.PathEscape(project)+"/json", JSONMediaType)
+	if err != nil {
+		return nil, fmt.Errorf("fetching project %s: %w", project, err)
+	}
+
+	pj := projectJSON{}
+	if err := json.Unmarshal(data, &pj); err != nil {
+		return nil, fmt.Errorf("parsing project %s: %w", project, err)
+	}
+
+	// The JSON API does not list provenance, it is read from the Simple API
+	page, err := c.GetSimpleProject(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	provenanceURLs := map[string]string{}
+	for _, f := range page.FilesWithProvenance() {
+		provenanceURLs[f.Filename] = f.Provenance
+	}
+
+	inventory := &ProjectInventory{Project: project}
+	for version, files := range pj.Releases {
+		for _, f := range files {
+			file := InventoryFile{
+				Version:     version,
+				Filename:    f.Filename,
+				PackageType: f.PackageType,
+				SHA256:      f.Digests["sha256"],
+				Yanked:      f.Yanked,
+				UploadTime:  f.UploadTime,
+			}
+
+			if u, ok := provenanceURLs[f.Filename]; ok {
+				file.HasAttestation = true
+				file.Publishers, err = c.provenancePublishers(ctx, u)
+				if err != nil {
+					return nil, err
+				}
+			}
+			inventory.Files = append(inventory.Files, file)
+		}
+	}
+
+	sort.Slice(inventory.Files, func(i, j int) bool {
+		a, b := inventory.Files[i], inventory.Files[j]
+		if a.UploadTime != b.UploadTime {
+			return a.UploadTime < b.UploadTime
+		}
+		return a.Filename < b.Filename
+	})
+
+	return inventory, nil
+}
+
+// provenancePublishers fetches a provenance object and returns the
+// publishers of its attestation bundles.
+func (c *Client) provenancePublishers(ctx context.Context, u string) ([]publisher.Publisher, error) {
+	data, err := c.GetProvenanceFromURL(ctx, u)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := convert.UnmarshalProvenance(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing provenance: %w", err)
+	}
+
+	publishers := make([]publisher.Publisher, 0, len(p.AttestationBundles))
+	for _, b := range p.AttestationBundles {
+		pub, err := publisher.FromStruct(b.GetPublisher())
+		if err != nil {
+			return nil, err
+		}
+		publishers = append(publishers, pub)
+	}
+	return publishers, nil
+}
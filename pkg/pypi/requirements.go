@@ -0,0 +1,123 @@
+package pypi
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Requirement is a pinned requirement of a requirements file.
+type Requirement struct {
+	// Name and Version of the pinned release.
+	Name    string `json:"name"`
+	Version string `json:"version"`
+
+	// Hashes are the hex encoded sha256 digests of the files allowed to
+	// install the requirement.
+	Hashes []string `json:"hashes"`
+
+	// Line is the line number of the requirement in the file.
+	Line int `json:"line"`
+}
+
+// ParseRequirements reads a pip requirements file. Every requirement must
+// be pinned with == to an exact version, hashes are read from the --hash
+// options. Extras and environment markers are ignored, as are comments,
+// blank lines and pip options such as --index-url. Nested requirement
+// files and editable or URL requirements are rejected.
+func ParseRequirements(r io.Reader) ([]Requirement, error) {
+	var reqs []Requirement
+	scanner := bufio.NewScanner(r)
+	lineNo, start := 0, 0
+	var logical strings.Builder
+	for scanner.Scan() {
+		lineNo++
+		line := scanner.Text()
+		if logical.Len() == 0 {
+			start = lineNo
+		}
+
+		// Lines ending in a backslash continue on the next line
+		if strings.HasSuffix(line, `\`) {
+			logical.WriteString(strings.TrimSuffix(line, `\`))
+			logical.WriteByte(' ')
+			continue
+		}
+		logical.WriteString(line)
+		text := logical.String()
+		logical.Reset()
+
+		req, ok, err := parseRequirementLine(text)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", start, err)
+		}
+		if ok {
+			req.Line = start
+			reqs = append(reqs, req)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading requirements: %w", err)
+	}
+	return reqs, nil
+}
+
+// parseRequirementLine parses a logical line. ok is false for lines
+// without a requirement.
+func parseRequirementLine(line string) (req Requirement, ok bool, err error) {
+	if i := strings.Index(line, " #"); i >= 0 {
+		line = line[:i]
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+		return req, false, nil
+	}
+
+	if strings.HasPrefix(fields[0], "-") {
+		switch strings.SplitN(fields[0], "=", 2)[0] {
+		case "-r", "--requirement", "-c", "--constraint", "-e", "--editable":
+			return req, false, fmt.Errorf("unsupported option %s", fields[0])
+		}
+		return req, false, nil
+	}
+
+	spec, _, _ := strings.Cut(strings.Join(fieldsUntilOption(fields), " "), ";")
+	name, version, found := strings.Cut(strings.ReplaceAll(spec, " ", ""), "==")
+	if !found || strings.ContainsAny(version, "<>=!~,*") || version == "" {
+		return req, false, fmt.Errorf("requirement %q is not pinned to a version with ==", strings.TrimSpace(spec))
+	}
+	if i := strings.Index(name, "["); i >= 0 {
+		name = name[:i]
+	}
+	if name == "" || strings.Contains(name, "@") {
+		return req, false, fmt.Errorf("unsupported requirement %q", strings.TrimSpace(spec))
+	}
+	req.Name, req.Version = name, version
+
+	for i := 0; i < len(fields); i++ {
+		value, isHash := strings.CutPrefix(fields[i], "--hash=")
+		if !isHash {
+			if fields[i] != "--hash" || i+1 == len(fields) {
+				continue
+			}
+			i++
+			value = fields[i]
+		}
+		alg, digest, _ := strings.Cut(value, ":")
+		if alg == "sha256" {
+			req.Hashes = append(req.Hashes, strings.ToLower(digest))
+		}
+	}
+	return req, true, nil
+}
+
+// fieldsUntilOption returns the fields before the first option.
+func fieldsUntilOption(fields []string) []string {
+	for i, f := range fields {
+		if strings.HasPrefix(f, "--") {
+			return fields[:i]
+		}
+	}
+	return fields
+}
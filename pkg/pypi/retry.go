@@ -0,0 +1,113 @@
+package pypi
+
+import (
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// do sends the request, retrying network errors, 429 and 5xx responses
+// with exponential backoff. Every attempt waits for the rate limiter. Only requests without a body, or with GetBody
+// set, are retried.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	for attempt := 0; ; attempt++ {
+		if c.limiter != nil {
+			if err := c.limiter.Wait(req.Context()); err != nil {
+				return nil, err
+			}
+		}
+
+		start := time.Now()
+		resp, err := c.httpClient().Do(req)
+		c.logResponse(req, resp, err, time.Since(start))
+		if attempt >= c.Options.MaxRetries || !shouldRetry(req, resp, err) {
+			return resp, err
+		}
+
+		wait := c.backoff(attempt, resp)
+		c.Options.Logger.WarnContext(req.Context(), "retrying request",
+			"method", req.Method, "url", req.URL.Redacted(), "attempt", attempt+1, "wait", wait)
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = body
+		}
+	}
+}
+
+// logResponse logs the outcome of a request at debug level.
+func (c *Client) logResponse(req *http.Request, resp *http.Response, err error, elapsed time.Duration) {
+	attrs := []any{"method", req.Method, "url", req.URL.Redacted(), "duration", elapsed}
+	if err != nil {
+		c.Options.Logger.DebugContext(req.Context(), "request failed", append(attrs, "error", err)...)
+		return
+	}
+	c.Options.Logger.DebugContext(req.Context(), "request sent", append(attrs, "status", resp.StatusCode)...)
+}
+
+// shouldRetry returns true if the request failed with a transient error.
+func shouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	if err != nil {
+		return req.Context().Err() == nil
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the wait before retrying. The Retry-After header of the
+// response is honored, otherwise the wait doubles on every attempt with
+// some jitter to spread concurrent clients.
+func (c *Client) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil {
+		if wait, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			return min(wait, c.Options.MaxRetryBackoff)
+		}
+	}
+
+	wait := c.Options.RetryBackoff << attempt
+	if wait <= 0 || wait > c.Options.MaxRetryBackoff {
+		wait = c.Options.MaxRetryBackoff
+	}
+	if wait > 1 {
+		wait = wait/2 + rand.N(wait/2)
+	}
+	return wait
+}
+
+// retryAfter parses a Retry-After header in seconds or HTTP date form.
+func retryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(value); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(time.Until(t), 0), true
+	}
+	return 0, false
+}
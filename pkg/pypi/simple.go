@@ -0,0 +1,134 @@
+package pypi
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/distfile"
+	"github.com/carabiner-dev/pypi-attestations/pkg/mediatype"
+)
+
+// SimpleMediaType is the media type of the PEP 691 JSON Simple API.
+const SimpleMediaType = mediatype.Simple
+
+// SimpleProject is the PEP 691 JSON project page of the Simple API.
+type SimpleProject struct {
+	Meta struct {
+		APIVersion string `json:"api-version"`
+	} `json:"meta"`
+	Name     string       `json:"name"`
+	Versions []string     `json:"versions,omitempty"`
+	Files    []SimpleFile `json:"files"`
+}
+
+// SimpleFile is a distribution file listed in the Simple API.
+type SimpleFile struct {
+	Filename       string            `json:"filename"`
+	URL            string            `json:"url"`
+	Hashes         map[string]string `json:"hashes"`
+	RequiresPython string            `json:"requires-python,omitempty"`
+	Yanked         Yanked            `json:"yanked"`
+	Size           int64             `json:"size,omitempty"`
+	UploadTime     string            `json:"upload-time,omitempty"`
+
+	// Provenance is the URL of the PEP 740 provenance object of the file,
+	// empty when the file has no attestations.
+	Provenance string `json:"provenance,omitempty"`
+}
+
+// HasProvenance returns true if the index serves attestations for the file.
+func (f *SimpleFile) HasProvenance() bool {
+	return f.Provenance != ""
+}
+
+// Yanked is the yank status of a file. In the JSON form it is either a
+// boolean or the reason string of a yanked file.
+type Yanked struct {
+	Yanked bool
+	Reason string
+}
+
+// UnmarshalJSON decodes the boolean or string forms of the yanked key.
+func (y *Yanked) UnmarshalJSON(data []byte) error {
+	var reason string
+	if err := json.Unmarshal(data, &reason); err == nil {
+		*y = Yanked{Yanked: true, Reason: reason}
+		return nil
+	}
+	var yanked bool
+	if err := json.Unmarshal(data, &yanked); err != nil {
+		return fmt.Errorf("decoding yanked status: %w", err)
+	}
+	*y = Yanked{Yanked: yanked}
+	return nil
+}
+
+// MarshalJSON encodes the yank status as the reason string if there is
+// one, or as a boolean.
+func (y Yanked) MarshalJSON() ([]byte, error) {
+	if y.Yanked && y.Reason != "" {
+		return json.Marshal(y.Reason)
+	}
+	return json.Marshal(y.Yanked)
+}
+
+// GetSimpleProject fetches the JSON Simple API page of a project. File and
+// provenance URLs are resolved to absolute URLs.
+func (c *Client) GetSimpleProject(ctx context.Context, project string) (*SimpleProject, error) {
+	if project == "" {
+		return nil, fmt.Errorf("project name is required")
+	}
+
+	path := distfile.SimplePath(project)
+	data, err := c.get(ctx, path, SimpleMediaType)
+	if err != nil {
+		return nil, fmt.Errorf("fetching simple page of %s: %w", project, err)
+	}
+
+	page := &SimpleProject{}
+	if err := json.Unmarshal(data, page); err != nil {
+		return nil, fmt.Errorf("parsing simple page of %s: %w", project, err)
+	}
+
+	base, err := url.Parse(c.Options.URL + path)
+	if err != nil {
+		return nil, fmt.Errorf("parsing page URL: %w", err)
+	}
+	for i := range page.Files {
+		f := &page.Files[i]
+		if f.URL, err = resolveURL(base, f.URL); err != nil {
+			return nil, fmt.Errorf("resolving URL of %s: %w", f.Filename, err)
+		}
+		if f.Provenance, err = resolveURL(base, f.Provenance); err != nil {
+			return nil, fmt.Errorf("resolving provenance URL of %s: %w", f.Filename, err)
+		}
+	}
+
+	return page, nil
+}
+
+// FilesWithProvenance returns the files of the project that have
+// attestations.
+func (p *SimpleProject) FilesWithProvenance() []SimpleFile {
+	var files []SimpleFile
+	for _, f := range p.Files {
+		if f.HasProvenance() {
+			files = append(files, f)
+		}
+	}
+	return files
+}
+
+// resolveURL resolves ref relative to base. Empty references stay empty.
+func resolveURL(base *url.URL, ref string) (string, error) {
+	if ref == "" {
+		return "", nil
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
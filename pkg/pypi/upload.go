@@ -0,0 +1,203 @@
+package pypi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/distfile"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// DefaultUploadURL is the legacy upload endpoint of PyPI.
+const DefaultUploadURL = "https://upload.pypi.org/legacy/"
+
+// UploadRequest describes a distribution file to upload with its
+// attestations.
+type UploadRequest struct {
+	// Name and Version of the project release.
+	Name    string
+	Version string
+
+	// Filename is the name of the distribution file.
+	Filename string
+
+	// Content is the distribution file.
+	Content io.Reader
+
+	// MetadataVersion is the core metadata version of the distribution.
+	// Defaults to "2.1".
+	MetadataVersion string
+
+	// Metadata holds additional core metadata form fields, keyed by their
+	// upload form name (summary, requires_python, etc).
+	Metadata map[string][]string
+
+	// Attestations are published with the file, one per predicate type.
+	Attestations []*pb.Attestation
+
+	// Username and Password authenticate the upload. The username defaults
+	// to "__token__" for PyPI API tokens.
+	Username string
+	Password string
+}
+
+// MarshalAttestations encodes attestations as the JSON array accepted in the
+// "attestations" field of the upload form.
+func MarshalAttestations(attestations []*pb.Attestation) ([]byte, error) {
+	raw := make([]json.RawMessage, 0, len(attestations))
+	for i, a := range attestations {
+		data, err := convert.MarshalAttestation(a)
+		if err != nil {
+			return nil, fmt.Errorf("marshaling attestation %d: %w", i, err)
+		}
+		raw = append(raw, data)
+	}
+	return json.Marshal(raw)
+}
+
+// Upload publishes a distribution file and its attestations using the
+// legacy upload API, the way twine does. Uploads are not retried as
+// indexes reject files that already exist.
+func (c *Client) Upload(ctx context.Context, ur *UploadRequest) error {
+	if ur == nil || ur.Content == nil {
+		return fmt.Errorf("upload request must have content")
+	}
+	if ur.Name == "" || ur.Version == "" || ur.Filename == "" {
+		return fmt.Errorf("name, version and filename are required")
+	}
+
+	if c.limiter != nil {
+		if err := c.limiter.Wait(ctx); err != nil {
+			return err
+		}
+	}
+
+	contentType, body, err := newUploadForm(ur)
+	if err != nil {
+		return err
+	}
+	// Closing the body stops the form writer if the request does not
+	// consume it
+	defer body.Close()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.Options.UploadURL, body)
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", contentType)
+	username := ur.Username
+	if username == "" {
+		username = "__token__"
+	}
+	req.SetBasicAuth(username, ur.Password)
+
+	c.Options.Logger.InfoContext(ctx, "uploading distribution", "filename", ur.Filename, "url", c.Options.UploadURL)
+	start := time.Now()
+	resp, err := c.httpClient().Do(req)
+	c.logResponse(req, resp, err, time.Since(start))
+	if err != nil {
+		return fmt.Errorf("uploading %s: %w", ur.Filename, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("uploading %s: unexpected status %s: %s", ur.Filename, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// newUploadForm returns the multipart form of the upload request. The form
+// is streamed: the distribution is read as the returned body is, and a
+// read error fails the body.
+func newUploadForm(ur *UploadRequest) (string, io.ReadCloser, error) {
+	filetype, pyversion, err := distributionType(ur.Filename)
+	if err != nil {
+		return "", nil, err
+	}
+
+	metadataVersion := ur.MetadataVersion
+	if metadataVersion == "" {
+		metadataVersion = "2.1"
+	}
+
+	fields := [][2]string{
+		{":action", "file_upload"},
+		{"protocol_version", "1"},
+		{"metadata_version", metadataVersion},
+		{"name", ur.Name},
+		{"version", ur.Version},
+		{"filetype", filetype},
+		{"pyversion", pyversion},
+	}
+	for k, values := range ur.Metadata {
+		for _, v := range values {
+			fields = append(fields, [2]string{k, v})
+		}
+	}
+
+	if len(ur.Attestations) > 0 {
+		attestations, err := MarshalAttestations(ur.Attestations)
+		if err != nil {
+			return "", nil, err
+		}
+		fields = append(fields, [2]string{"attestations", string(attestations)})
+	}
+
+	pr, pw := io.Pipe()
+	w := multipart.NewWriter(pw)
+	go func() {
+		pw.CloseWithError(writeUploadForm(w, fields, ur))
+	}()
+	return w.FormDataContentType(), pr, nil
+}
+
+// writeUploadForm writes the form fields, then the distribution and its
+// digest.
+func writeUploadForm(w *multipart.Writer, fields [][2]string, ur *UploadRequest) error {
+	for _, f := range fields {
+		if err := w.WriteField(f[0], f[1]); err != nil {
+			return fmt.Errorf("writing form field %s: %w", f[0], err)
+		}
+	}
+
+	fw, err := w.CreateFormFile("content", ur.Filename)
+	if err != nil {
+		return fmt.Errorf("writing form file: %w", err)
+	}
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(fw, h), ur.Content); err != nil {
+		return fmt.Errorf("reading distribution: %w", err)
+	}
+
+	if err := w.WriteField("sha256_digest", hex.EncodeToString(h.Sum(nil))); err != nil {
+		return fmt.Errorf("writing form field sha256_digest: %w", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("closing form: %w", err)
+	}
+	return nil
+}
+
+// distributionType returns the upload filetype and pyversion fields of a
+// distribution filename.
+func distributionType(filename string) (filetype, pyversion string, err error) {
+	f, err := distfile.Parse(filename)
+	if err != nil {
+		return "", "", fmt.Errorf("unsupported distribution: %w", err)
+	}
+	if f.Kind == distfile.Sdist {
+		return "sdist", "source", nil
+	}
+	return "bdist_wheel", strings.Join(f.PythonTags, "."), nil
+}
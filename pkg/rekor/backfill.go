@@ -0,0 +1,66 @@
+package rekor
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+)
+
+// Backfill replaces the transparency entries of the attestation that only
+// carry an inclusion promise with the full entry fetched from Rekor,
+// including its inclusion proof. The attestation is modified in place.
+func (c *Client) Backfill(ctx context.Context, attestation *pb.Attestation) error {
+	if attestation == nil || attestation.VerificationMaterial == nil {
+		return fmt.Errorf("attestation cannot be nil")
+	}
+
+	for i, s := range attestation.VerificationMaterial.TransparencyEntries {
+		entry, err := convert.TransparencyEntryFromStruct(s)
+		if err != nil {
+			return fmt.Errorf("converting transparency entry %d: %w", i, err)
+		}
+
+		if entry.GetInclusionProof() != nil {
+			continue
+		}
+
+		full, err := c.GetEntryByIndex(ctx, entry.GetLogIndex())
+		if err != nil {
+			return fmt.Errorf("fetching transparency entry %d: %w", i, err)
+		}
+
+		upgraded, err := convert.TransparencyEntryToStruct(full)
+		if err != nil {
+			return fmt.Errorf("converting transparency entry %d: %w", i, err)
+		}
+		attestation.VerificationMaterial.TransparencyEntries[i] = upgraded
+	}
+
+	return nil
+}
+
+// BackfillBundle replaces the transparency log entries of the bundle that
+// only carry an inclusion promise with the full entry fetched from Rekor.
+// The bundle is modified in place.
+func (c *Client) BackfillBundle(ctx context.Context, b *bundle.Bundle) error {
+	if b == nil || b.Bundle == nil || b.Bundle.VerificationMaterial == nil {
+		return fmt.Errorf("bundle cannot be nil")
+	}
+
+	for i, entry := range b.Bundle.VerificationMaterial.TlogEntries {
+		if entry.GetInclusionProof() != nil {
+			continue
+		}
+
+		full, err := c.GetEntryByIndex(ctx, entry.GetLogIndex())
+		if err != nil {
+			return fmt.Errorf("fetching transparency entry %d: %w", i, err)
+		}
+		b.Bundle.VerificationMaterial.TlogEntries[i] = full
+	}
+
+	return nil
+}
@@ -0,0 +1,304 @@
+// Package rekor implements a minimal Rekor client used to fetch the full
+// transparency log entries referenced by PyPI attestations.
+package rekor
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/internal/transport"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+)
+
+// DefaultURL is the URL of the Rekor public good instance.
+const DefaultURL = "https://rekor.sigstore.dev"
+
+// Options configures the Rekor client.
+type Options struct {
+	// URL is the base URL of the Rekor instance.
+	URL string
+
+	// HTTPClient is the client used to talk to Rekor.
+	HTTPClient *http.Client
+
+	// Proxy is the URL of the HTTP proxy. When empty, the proxy is read
+	// from the environment. Only applies when no HTTPClient is configured.
+	Proxy string
+
+	// RootCAs are the CAs trusted for TLS connections. When nil, the
+	// system pool is used. Only applies when no HTTPClient is configured.
+	RootCAs *x509.CertPool
+
+	// RoundTripper replaces the HTTP transport. Only applies when no
+	// HTTPClient is configured.
+	RoundTripper http.RoundTripper
+
+	// Logger receives the requests sent to Rekor. When nil, slog.Default()
+	// is used.
+	Logger *slog.Logger
+}
+
+var defaultOptions = Options{
+	URL: DefaultURL,
+}
+
+// FnOption is a functional option to configure the Client.
+type FnOption func(*Options) error
+
+// WithURL sets the base URL of the Rekor instance.
+func WithURL(u string) FnOption {
+	return func(o *Options) error {
+		if _, err := url.Parse(u); err != nil {
+			return fmt.Errorf("parsing rekor URL: %w", err)
+		}
+		o.URL = strings.TrimSuffix(u, "/")
+		return nil
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to talk to Rekor.
+func WithHTTPClient(c *http.Client) FnOption {
+	return func(o *Options) error {
+		o.HTTPClient = c
+		return nil
+	}
+}
+
+// WithProxy sets the URL of the HTTP proxy.
+func WithProxy(u string) FnOption {
+	return func(o *Options) error {
+		if _, err := url.Parse(u); err != nil {
+			return fmt.Errorf("parsing proxy URL: %w", err)
+		}
+		o.Proxy = u
+		return nil
+	}
+}
+
+// WithRootCAs sets the pool of CAs trusted for TLS connections.
+func WithRootCAs(pool *x509.CertPool) FnOption {
+	return func(o *Options) error {
+		o.RootCAs = pool
+		return nil
+	}
+}
+
+// WithCAFile trusts the PEM certificates in path in addition to the
+// system CAs.
+func WithCAFile(path string) FnOption {
+	return func(o *Options) error {
+		pool, err := transport.LoadCertPool(path)
+		if err != nil {
+			return err
+		}
+		o.RootCAs = pool
+		return nil
+	}
+}
+
+// WithRoundTripper replaces the HTTP transport of the client.
+func WithRoundTripper(rt http.RoundTripper) FnOption {
+	return func(o *Options) error {
+		o.RoundTripper = rt
+		return nil
+	}
+}
+
+// WithLogger sets the logger of the client.
+func WithLogger(l *slog.Logger) FnOption {
+	return func(o *Options) error {
+		o.Logger = l
+		return nil
+	}
+}
+
+// Client fetches entries from a Rekor v1 transparency log.
+type Client struct {
+	Options Options
+
+	client *http.Client
+}
+
+// NewClient returns a new Rekor client configured with the passed options.
+func NewClient(funcs ...FnOption) (*Client, error) {
+	opts := defaultOptions
+	for _, fn := range funcs {
+		if err := fn(&opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	tc := transport.Config{
+		Proxy:        opts.Proxy,
+		RootCAs:      opts.RootCAs,
+		RoundTripper: opts.RoundTripper,
+	}
+	client, err := tc.Client()
+	if err != nil {
+		return nil, err
+	}
+	return &Client{Options: opts, client: client}, nil
+}
+
+// GetEntryByIndex fetches the log entry at the global log index.
+func (c *Client) GetEntryByIndex(ctx context.Context, index int64) (*protorekor.TransparencyLogEntry, error) {
+	return c.getEntry(ctx, "/api/v1/log/entries?logIndex="+strconv.FormatInt(index, 10))
+}
+
+// GetEntryByUUID fetches the log entry with the specified UUID.
+func (c *Client) GetEntryByUUID(ctx context.Context, uuid string) (*protorekor.TransparencyLogEntry, error) {
+	return c.getEntry(ctx, "/api/v1/log/entries/"+url.PathEscape(uuid))
+}
+
+// getEntry requests a log entry and converts it to its protobuf form.
+func (c *Client) getEntry(ctx context.Context, path string) (*protorekor.TransparencyLogEntry, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.Options.URL+path, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	start := time.Now()
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		c.Options.Logger.DebugContext(ctx, "request failed", "url", req.URL.Redacted(), "duration", time.Since(start), "error", err)
+		return nil, fmt.Errorf("fetching log entry: %w", err)
+	}
+	defer resp.Body.Close()
+	c.Options.Logger.DebugContext(ctx, "request sent", "url", req.URL.Redacted(), "duration", time.Since(start), "status", resp.StatusCode)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching log entry: unexpected status %s", resp.Status)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+
+	return ParseEntry(data)
+}
+
+// ParseEntry converts a log entry returned by the Rekor v1 API, a JSON
+// object keyed by the entry UUID, to its protobuf form.
+func ParseEntry(data []byte) (*protorekor.TransparencyLogEntry, error) {
+	entries := map[string]logEntry{}
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("parsing log entry: %w", err)
+	}
+
+	if len(entries) != 1 {
+		return nil, fmt.Errorf("expected one log entry, got %d", len(entries))
+	}
+
+	var entry logEntry
+	for _, e := range entries {
+		entry = e
+	}
+	return entry.toProto()
+}
+
+// httpClient returns the configured HTTP client or the default one.
+func (c *Client) httpClient() *http.Client {
+	if c.Options.HTTPClient != nil {
+		return c.Options.HTTPClient
+	}
+	if c.client != nil {
+		return c.client
+	}
+	return http.DefaultClient
+}
+
+// logEntry is the JSON form of an entry returned by the Rekor v1 API.
+type logEntry struct {
+	Body           string `json:"body"`
+	IntegratedTime int64  `json:"integratedTime"`
+	LogID          string `json:"logID"`
+	LogIndex       int64  `json:"logIndex"`
+	Verification   struct {
+		InclusionProof *struct {
+			Checkpoint string   `json:"checkpoint"`
+			Hashes     []string `json:"hashes"`
+			LogIndex   int64    `json:"logIndex"`
+			RootHash   string   `json:"rootHash"`
+			TreeSize   int64    `json:"treeSize"`
+		} `json:"inclusionProof"`
+		SignedEntryTimestamp string `json:"signedEntryTimestamp"`
+	} `json:"verification"`
+}
+
+// toProto converts the API entry to a TransparencyLogEntry.
+func (e *logEntry) toProto() (*protorekor.TransparencyLogEntry, error) {
+	body, err := base64.StdEncoding.DecodeString(e.Body)
+	if err != nil {
+		return nil, fmt.Errorf("decoding entry body: %w", err)
+	}
+
+	kind := struct {
+		Kind       string `json:"kind"`
+		APIVersion string `json:"apiVersion"`
+	}{}
+	if err := json.Unmarshal(body, &kind); err != nil {
+		return nil, fmt.Errorf("parsing entry body: %w", err)
+	}
+
+	logID, err := hex.DecodeString(e.LogID)
+	if err != nil {
+		return nil, fmt.Errorf("decoding log ID: %w", err)
+	}
+
+	entry := &protorekor.TransparencyLogEntry{
+		LogIndex:          e.LogIndex,
+		LogId:             &protocommon.LogId{KeyId: logID},
+		KindVersion:       &protorekor.KindVersion{Kind: kind.Kind, Version: kind.APIVersion},
+		IntegratedTime:    e.IntegratedTime,
+		CanonicalizedBody: body,
+	}
+
+	if e.Verification.SignedEntryTimestamp != "" {
+		set, err := base64.StdEncoding.DecodeString(e.Verification.SignedEntryTimestamp)
+		if err != nil {
+			return nil, fmt.Errorf("decoding signed entry timestamp: %w", err)
+		}
+		entry.InclusionPromise = &protorekor.InclusionPromise{SignedEntryTimestamp: set}
+	}
+
+	if proof := e.Verification.InclusionProof; proof != nil {
+		rootHash, err := hex.DecodeString(proof.RootHash)
+		if err != nil {
+			return nil, fmt.Errorf("decoding root hash: %w", err)
+		}
+		hashes := make([][]byte, len(proof.Hashes))
+		for i, h := range proof.Hashes {
+			hashes[i], err = hex.DecodeString(h)
+			if err != nil {
+				return nil, fmt.Errorf("decoding proof hash %d: %w", i, err)
+			}
+		}
+		entry.InclusionProof = &protorekor.InclusionProof{
+			LogIndex:   proof.LogIndex,
+			RootHash:   rootHash,
+			TreeSize:   proof.TreeSize,
+			Hashes:     hashes,
+			Checkpoint: &protorekor.Checkpoint{Envelope: proof.Checkpoint},
+		}
+	}
+
+	return entry, nil
+}
@@ -0,0 +1,147 @@
+package rekor
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func loadTestAttestation(t *testing.T) *pb.Attestation {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+
+	attestation, err := convert.UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	return attestation
+}
+
+// testEntryResponse returns entry in the Rekor v1 API format.
+func testEntryResponse(entry *protorekor.TransparencyLogEntry) map[string]any {
+	hashes := []string{}
+	for _, h := range entry.GetInclusionProof().GetHashes() {
+		hashes = append(hashes, hex.EncodeToString(h))
+	}
+
+	return map[string]any{
+		"24296fb24b8ad77a": map[string]any{
+			"body":           base64.StdEncoding.EncodeToString(entry.GetCanonicalizedBody()),
+			"integratedTime": entry.GetIntegratedTime(),
+			"logID":          hex.EncodeToString(entry.GetLogId().GetKeyId()),
+			"logIndex":       entry.GetLogIndex(),
+			"verification": map[string]any{
+				"inclusionProof": map[string]any{
+					"checkpoint": entry.GetInclusionProof().GetCheckpoint().GetEnvelope(),
+					"hashes":     hashes,
+					"logIndex":   entry.GetInclusionProof().GetLogIndex(),
+					"rootHash":   hex.EncodeToString(entry.GetInclusionProof().GetRootHash()),
+					"treeSize":   entry.GetInclusionProof().GetTreeSize(),
+				},
+				"signedEntryTimestamp": base64.StdEncoding.EncodeToString(
+					entry.GetInclusionPromise().GetSignedEntryTimestamp(),
+				),
+			},
+		},
+	}
+}
+
+// newTestServer returns a server that serves entry in the Rekor v1 API format.
+func newTestServer(t *testing.T, entry *protorekor.TransparencyLogEntry) *httptest.Server {
+	t.Helper()
+
+	response := testEntryResponse(entry)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/log/entries" || r.URL.Query().Get("logIndex") != "613501255" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+}
+
+func TestBackfill(t *testing.T) {
+	attestation := loadTestAttestation(t)
+	original, err := convert.TransparencyEntryFromStruct(attestation.VerificationMaterial.TransparencyEntries[0])
+	if err != nil {
+		t.Fatalf("Failed to convert entry: %v", err)
+	}
+
+	srv := newTestServer(t, original)
+	defer srv.Close()
+
+	client, err := NewClient(WithURL(srv.URL))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	// Strip the inclusion proof to leave only the promise
+	delete(attestation.VerificationMaterial.TransparencyEntries[0].Fields, "inclusionProof")
+
+	if err := client.Backfill(context.Background(), attestation); err != nil {
+		t.Fatalf("Failed to backfill: %v", err)
+	}
+
+	upgraded, err := convert.TransparencyEntryFromStruct(attestation.VerificationMaterial.TransparencyEntries[0])
+	if err != nil {
+		t.Fatalf("Failed to convert entry: %v", err)
+	}
+
+	if !proto.Equal(original, upgraded) {
+		t.Errorf("Backfilled entry does not match original:\n%v\n%v", original, upgraded)
+	}
+}
+
+func TestGetEntryNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	client, err := NewClient(WithURL(srv.URL))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	if _, err := client.GetEntryByUUID(context.Background(), "abc"); err == nil {
+		t.Error("Expected error for missing entry")
+	}
+}
+
+func TestParseEntry(t *testing.T) {
+	attestation := loadTestAttestation(t)
+	entry, err := convert.TransparencyEntryFromStruct(attestation.VerificationMaterial.TransparencyEntries[0])
+	if err != nil {
+		t.Fatalf("Failed to convert entry: %v", err)
+	}
+
+	data, err := json.Marshal(testEntryResponse(entry))
+	if err != nil {
+		t.Fatalf("Failed to marshal entry: %v", err)
+	}
+	parsed, err := ParseEntry(data)
+	if err != nil {
+		t.Fatalf("Failed to parse entry: %v", err)
+	}
+	if !proto.Equal(parsed, entry) {
+		t.Error("Parsed entry does not match")
+	}
+
+	if _, err := ParseEntry([]byte(`{}`)); err == nil {
+		t.Error("Expected error for response without entries")
+	}
+}
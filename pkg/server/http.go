@@ -0,0 +1,326 @@
+package server
+
+import (
+	_ "embed"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/mediatype"
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// OpenAPI is the OpenAPI 3 document describing the HTTP API.
+//
+//go:embed openapi.yaml
+var OpenAPI []byte
+
+// Error codes of the JSON error envelope.
+const (
+	ErrorInvalidRequest  = "invalid_request"
+	ErrorTooLarge        = "request_too_large"
+	ErrorNotFound        = "not_found"
+	ErrorUpstream        = "upstream_error"
+	ErrorInternal        = "internal_error"
+	ErrorUnavailable     = "unavailable"
+	ErrorRequestCanceled = "canceled"
+)
+
+// ErrorResponse is the JSON envelope of the HTTP API errors.
+type ErrorResponse struct {
+	Error ErrorDetail `json:"error"`
+}
+
+// ErrorDetail describes an error of the HTTP API.
+type ErrorDetail struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// VerifyRequest is the body of POST /verify.
+type VerifyRequest struct {
+	// Attestation is the PEP 740 attestation JSON object.
+	Attestation json.RawMessage `json:"attestation"`
+	Filename    string          `json:"filename"`
+
+	// SHA256 is the hex encoded digest of the distribution.
+	SHA256 string `json:"sha256"`
+
+	Publishers []Publisher `json:"publishers,omitempty"`
+}
+
+// Publisher is a trusted publisher of a VerifyRequest.
+type Publisher struct {
+	Issuer     string `json:"issuer,omitempty"`
+	Repository string `json:"repository"`
+	Workflow   string `json:"workflow,omitempty"`
+}
+
+// VerifyResponse is the body of the POST /verify responses.
+type VerifyResponse struct {
+	Verified      bool    `json:"verified"`
+	Error         string  `json:"error,omitempty"`
+	Identity      string  `json:"identity,omitempty"`
+	Issuer        string  `json:"issuer,omitempty"`
+	Repository    string  `json:"repository,omitempty"`
+	Workflow      string  `json:"workflow,omitempty"`
+	PredicateType string  `json:"predicateType,omitempty"`
+	LogIndexes    []int64 `json:"logIndexes,omitempty"`
+}
+
+// ReleaseProvenance is the body of the GET provenance responses listing the
+// files of a release.
+type ReleaseProvenance struct {
+	Name    string           `json:"name"`
+	Version string           `json:"version"`
+	Files   []FileProvenance `json:"files"`
+}
+
+// FileProvenance is the provenance of a file of a release.
+type FileProvenance struct {
+	Filename   string          `json:"filename"`
+	SHA256     string          `json:"sha256"`
+	Provenance json.RawMessage `json:"provenance,omitempty"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// Handler returns the HTTP API of the server:
+//
+//	POST /convert?to=bundle|attestation
+//	POST /verify
+//	GET  /packages/{name}/{version}/provenance[?filename=FILE]
+//	GET  /openapi.yaml
+//
+// along with the operational endpoints of OpsHandler. Errors are written as
+// an ErrorResponse.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	routes := map[string]http.HandlerFunc{
+		"POST /convert": s.handleConvert,
+		"POST /verify":  s.handleVerify,
+		"GET /packages/{name}/{version}/provenance": s.handleProvenance,
+		"GET /openapi.yaml": func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/yaml")
+			w.Write(OpenAPI)
+		},
+	}
+	for pattern, h := range routes {
+		mux.HandleFunc(pattern, s.Options.Metrics.instrument(pattern, s.logRequests(pattern, h)))
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, ErrorNotFound, fmt.Sprintf("no route for %s %s", r.Method, r.URL.Path))
+	})
+	s.addOpsRoutes(mux)
+	return mux
+}
+
+// OpsHandler returns the operational endpoints of the server:
+//
+//	GET /metrics  Prometheus metrics
+//	GET /healthz  liveness, always ok while the process serves requests
+//	GET /readyz   readiness, ok once the trusted root is loaded
+func (s *Server) OpsHandler() http.Handler {
+	mux := http.NewServeMux()
+	s.addOpsRoutes(mux)
+	return mux
+}
+
+func (s *Server) addOpsRoutes(mux *http.ServeMux) {
+	mux.Handle("GET /metrics", s.Options.Metrics.Handler())
+	mux.HandleFunc("GET /healthz", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc("GET /readyz", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Ready(r.Context()); err != nil {
+			writeError(w, http.StatusServiceUnavailable, ErrorUnavailable, err.Error())
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// logRequests logs the requests of an HTTP route, at error level when they
+// fail with a server error.
+func (s *Server) logRequests(route string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		h(sw, r)
+		level := slog.LevelDebug
+		if sw.code >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		s.Options.Logger.Log(r.Context(), level, "request served",
+			"transport", "http", "method", route, "code", sw.code, "duration", time.Since(start))
+	}
+}
+
+func (s *Server) handleConvert(w http.ResponseWriter, r *http.Request) {
+	var to pb.Format
+	switch r.URL.Query().Get("to") {
+	case "":
+	case "bundle":
+		to = pb.Format_FORMAT_BUNDLE
+	case "attestation":
+		to = pb.Format_FORMAT_ATTESTATION
+	default:
+		writeError(w, http.StatusBadRequest, ErrorInvalidRequest, "to must be bundle or attestation")
+		return
+	}
+	data, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+
+	resp, err := s.Convert(r.Context(), &pb.ConvertRequest{Document: data, To: to})
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	contentType := mediatype.JSON
+	if resp.GetFormat() == pb.Format_FORMAT_ATTESTATION {
+		contentType = mediatype.Attestation
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(resp.GetDocument())
+}
+
+func (s *Server) handleVerify(w http.ResponseWriter, r *http.Request) {
+	data, ok := s.readBody(w, r)
+	if !ok {
+		return
+	}
+	req := VerifyRequest{}
+	if err := json.Unmarshal(data, &req); err != nil {
+		writeError(w, http.StatusBadRequest, ErrorInvalidRequest, fmt.Sprintf("parsing request: %v", err))
+		return
+	}
+	if len(req.Attestation) == 0 {
+		writeError(w, http.StatusBadRequest, ErrorInvalidRequest, "attestation is required")
+		return
+	}
+	attestation, err := convert.UnmarshalAttestation(req.Attestation)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorInvalidRequest, fmt.Sprintf("parsing attestation: %v", err))
+		return
+	}
+	digest, err := hex.DecodeString(req.SHA256)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, ErrorInvalidRequest, fmt.Sprintf("decoding sha256: %v", err))
+		return
+	}
+
+	preq := &pb.VerifyRequest{Attestation: attestation, Filename: req.Filename, Sha256: digest}
+	for _, p := range req.Publishers {
+		preq.Publishers = append(preq.Publishers, &pb.Publisher{Issuer: p.Issuer, Repository: p.Repository, Workflow: p.Workflow})
+	}
+	resp, err := s.Verify(r.Context(), preq)
+	if err != nil {
+		writeStatusError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, VerifyResponse{
+		Verified:      resp.GetVerified(),
+		Error:         resp.GetError(),
+		Identity:      resp.GetIdentity(),
+		Issuer:        resp.GetIssuer(),
+		Repository:    resp.GetRepository(),
+		Workflow:      resp.GetWorkflow(),
+		PredicateType: resp.GetPredicateType(),
+		LogIndexes:    resp.GetLogIndexes(),
+	})
+}
+
+func (s *Server) handleProvenance(w http.ResponseWriter, r *http.Request) {
+	name, version := r.PathValue("name"), r.PathValue("version")
+	client := s.Options.IndexClient
+
+	if filename := r.URL.Query().Get("filename"); filename != "" {
+		data, err := client.GetProvenance(r.Context(), name, version, filename)
+		if err != nil {
+			writeIndexError(w, err)
+			return
+		}
+		w.Header().Set("Content-Type", mediatype.Provenance)
+		w.Write(data)
+		return
+	}
+
+	results, err := client.FetchAll(r.Context(), []pypi.Package{{Name: name, Version: version}})
+	if err != nil {
+		writeIndexError(w, err)
+		return
+	}
+	if results[0].Error != nil {
+		writeIndexError(w, results[0].Error)
+		return
+	}
+	release := ReleaseProvenance{Name: name, Version: version, Files: []FileProvenance{}}
+	for _, f := range results[0].Files {
+		fp := FileProvenance{Filename: f.Filename, SHA256: f.SHA256, Provenance: f.Provenance}
+		if f.Error != nil {
+			fp.Provenance, fp.Error = nil, f.Error.Error()
+		}
+		release.Files = append(release.Files, fp)
+	}
+	writeJSON(w, http.StatusOK, release)
+}
+
+// readBody reads the request body up to the size limit, writing the error
+// response when it cannot.
+func (s *Server) readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.Options.MaxRequestSize))
+	if err != nil {
+		var mbe *http.MaxBytesError
+		if errors.As(err, &mbe) {
+			writeError(w, http.StatusRequestEntityTooLarge, ErrorTooLarge, fmt.Sprintf("request body exceeds %d bytes", mbe.Limit))
+			return nil, false
+		}
+		writeError(w, http.StatusBadRequest, ErrorInvalidRequest, fmt.Sprintf("reading request: %v", err))
+		return nil, false
+	}
+	return data, true
+}
+
+// writeStatusError writes the error returned by a service method.
+func writeStatusError(w http.ResponseWriter, err error) {
+	st := status.Convert(err)
+	switch st.Code() {
+	case codes.InvalidArgument:
+		writeError(w, http.StatusBadRequest, ErrorInvalidRequest, st.Message())
+	case codes.Unavailable:
+		writeError(w, http.StatusServiceUnavailable, ErrorUnavailable, st.Message())
+	case codes.Canceled, codes.DeadlineExceeded:
+		writeError(w, http.StatusServiceUnavailable, ErrorRequestCanceled, st.Message())
+	default:
+		writeError(w, http.StatusInternalServerError, ErrorInternal, st.Message())
+	}
+}
+
+// writeIndexError writes an error of the package index.
+func writeIndexError(w http.ResponseWriter, err error) {
+	if errors.Is(err, pypi.ErrNotFound) {
+		writeError(w, http.StatusNotFound, ErrorNotFound, err.Error())
+		return
+	}
+	writeError(w, http.StatusBadGateway, ErrorUpstream, err.Error())
+}
+
+func writeError(w http.ResponseWriter, code int, errCode, message string) {
+	writeJSON(w, code, ErrorResponse{Error: ErrorDetail{Code: errCode, Message: message}})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", mediatype.JSON)
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+const metricsNamespace = "pypi_attestations"
+
+// Metrics are the Prometheus metrics of the server. Besides the requests
+// served, they record the calls to the services the server depends on and
+// the hits of its caches, once the HTTP clients and caches are wrapped with
+// RoundTripper, VerificationCache and ResponseCache.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	verifications    *prometheus.CounterVec
+	externalDuration *prometheus.HistogramVec
+	cacheRequests    *prometheus.CounterVec
+}
+
+// NewMetrics returns the metrics of a server, registered with a new
+// registry along with the Go runtime and process collectors.
+func NewMetrics() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "requests_total",
+			Help:      "Requests served, by transport, method and status code.",
+		}, []string{"transport", "method", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "request_duration_seconds",
+			Help:      "Time to serve requests, by transport and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"transport", "method"}),
+		verifications: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "verifications_total",
+			Help:      "Attestations verified, by outcome.",
+		}, []string{"outcome"}),
+		externalDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "external_request_duration_seconds",
+			Help:      "Latency of the calls to external services, by service and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"service", "code"}),
+		cacheRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "cache_requests_total",
+			Help:      "Cache lookups, by cache and result (hit or miss).",
+		}, []string{"cache", "result"}),
+	}
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.requestDuration, m.verifications, m.externalDuration, m.cacheRequests,
+	)
+	return m
+}
+
+// Registry returns the registry of the metrics, to add collectors to it.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// observeRequest records a request served.
+func (m *Metrics) observeRequest(transport, method, code string, start time.Time) {
+	m.requests.WithLabelValues(transport, method, code).Inc()
+	m.requestDuration.WithLabelValues(transport, method).Observe(time.Since(start).Seconds())
+}
+
+// observeVerification records the outcome of a verification.
+func (m *Metrics) observeVerification(verified bool) {
+	outcome := "failed"
+	if verified {
+		outcome = "verified"
+	}
+	m.verifications.WithLabelValues(outcome).Inc()
+}
+
+// UnaryInterceptor records the requests of a gRPC server.
+func (m *Metrics) UnaryInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		m.observeRequest("grpc", info.FullMethod, status.Code(err).String(), start)
+		return resp, err
+	}
+}
+
+// instrument records the requests of an HTTP route.
+func (m *Metrics) instrument(route string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, code: http.StatusOK}
+		h(sw, r)
+		m.observeRequest("http", route, strconv.Itoa(sw.code), start)
+	}
+}
+
+// statusWriter captures the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.code = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// RoundTripper records the latency of the requests sent through next, or
+// http.DefaultTransport when nil, to the external service named service,
+// eg pypi or tuf.
+func (m *Metrics) RoundTripper(service string, next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		start := time.Now()
+		resp, err := next.RoundTrip(req)
+		code := "error"
+		if err == nil {
+			code = strconv.Itoa(resp.StatusCode)
+		}
+		m.externalDuration.WithLabelValues(service, code).Observe(time.Since(start).Seconds())
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// observeCache records a cache lookup.
+func (m *Metrics) observeCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+// VerificationCache records the lookups of a verification result cache.
+func (m *Metrics) VerificationCache(c verify.Cache) verify.Cache {
+	return &verificationCache{Cache: c, metrics: m}
+}
+
+type verificationCache struct {
+	verify.Cache
+	metrics *Metrics
+}
+
+func (c *verificationCache) Get(key string) (*verify.VerificationResult, bool) {
+	result, ok := c.Cache.Get(key)
+	c.metrics.observeCache("verification", ok)
+	return result, ok
+}
+
+// ResponseCache records the lookups of the response cache of an index
+// client.
+func (m *Metrics) ResponseCache(c pypi.ResponseCache) pypi.ResponseCache {
+	return &responseCache{ResponseCache: c, metrics: m}
+}
+
+type responseCache struct {
+	pypi.ResponseCache
+	metrics *Metrics
+}
+
+func (c *responseCache) Get(key string) (*pypi.CachedResponse, bool) {
+	resp, ok := c.ResponseCache.Get(key)
+	c.metrics.observeCache("index", ok)
+	return resp, ok
+}
@@ -0,0 +1,359 @@
+// Package server implements the AttestationService gRPC service and an
+// HTTP API, exposing the conversion, verification and inspection of PEP 740
+// attestations to programs not written in Go.
+package server
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/certinfo"
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	"github.com/carabiner-dev/pypi-attestations/pkg/statement"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// Options configures the Server.
+type Options struct {
+	// VerifyOptions configure the verifier, for example the trusted root.
+	// Policies set here are replaced by the publishers of each request.
+	VerifyOptions []verify.FnOption
+
+	// Policy lists the trusted publishers used when a Verify request has
+	// none. When nil, such requests are rejected.
+	Policy *verify.Policy
+
+	// IndexClient fetches the provenance served by the HTTP handler. When
+	// nil, a client of pypi.org is used.
+	IndexClient *pypi.Client
+
+	// MaxRequestSize caps the size of the HTTP request bodies.
+	MaxRequestSize int64
+
+	// Metrics records the requests and verifications of the server. When
+	// nil, a new set of metrics is created.
+	Metrics *Metrics
+
+	// Logger receives the requests served and their errors. It is also
+	// passed to the verifier unless VerifyOptions set another. When nil,
+	// slog.Default() is used.
+	Logger *slog.Logger
+}
+
+// DefaultMaxRequestSize is the default size limit of HTTP request bodies.
+const DefaultMaxRequestSize = 10 << 20
+
+var defaultOptions = Options{
+	MaxRequestSize: DefaultMaxRequestSize,
+}
+
+// FnOption is a functional option to configure the Server.
+type FnOption func(*Options) error
+
+// WithVerifyOptions adds options to the verifier.
+func WithVerifyOptions(funcs ...verify.FnOption) FnOption {
+	return func(o *Options) error {
+		o.VerifyOptions = append(o.VerifyOptions, funcs...)
+		return nil
+	}
+}
+
+// WithPolicy sets the trusted publishers used when a Verify request has
+// none.
+func WithPolicy(p *verify.Policy) FnOption {
+	return func(o *Options) error {
+		if p != nil {
+			if err := p.Validate(); err != nil {
+				return err
+			}
+		}
+		o.Policy = p
+		return nil
+	}
+}
+
+// WithIndexClient sets the client fetching provenance from the index.
+func WithIndexClient(c *pypi.Client) FnOption {
+	return func(o *Options) error {
+		o.IndexClient = c
+		return nil
+	}
+}
+
+// WithMaxRequestSize caps the size of the HTTP request bodies.
+func WithMaxRequestSize(n int64) FnOption {
+	return func(o *Options) error {
+		if n < 1 {
+			return fmt.Errorf("request size limit must be positive")
+		}
+		o.MaxRequestSize = n
+		return nil
+	}
+}
+
+// WithMetrics sets the metrics of the server, to share them with the HTTP
+// clients and caches it uses.
+func WithMetrics(m *Metrics) FnOption {
+	return func(o *Options) error {
+		o.Metrics = m
+		return nil
+	}
+}
+
+// WithLogger sets the logger of the server.
+func WithLogger(l *slog.Logger) FnOption {
+	return func(o *Options) error {
+		o.Logger = l
+		return nil
+	}
+}
+
+// Server implements the AttestationService.
+type Server struct {
+	pb.UnimplementedAttestationServiceServer
+
+	Options  Options
+	verifier *verify.Verifier
+}
+
+// New returns a Server configured with the given options.
+func New(funcs ...FnOption) (*Server, error) {
+	opts := defaultOptions
+	for _, fn := range funcs {
+		if err := fn(&opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.IndexClient == nil {
+		client, err := pypi.NewClient()
+		if err != nil {
+			return nil, err
+		}
+		opts.IndexClient = client
+	}
+	if opts.Metrics == nil {
+		opts.Metrics = NewMetrics()
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	opts.VerifyOptions = append([]verify.FnOption{verify.WithLogger(opts.Logger)}, opts.VerifyOptions...)
+	v, err := verify.New(opts.VerifyOptions...)
+	if err != nil {
+		return nil, err
+	}
+	return &Server{Options: opts, verifier: v}, nil
+}
+
+// Register registers the service on a gRPC server.
+func (s *Server) Register(gs *grpc.Server) {
+	pb.RegisterAttestationServiceServer(gs, s)
+}
+
+// NewGRPCServer returns a gRPC server of the service recording its
+// metrics and logging its requests, along with the standard health service
+// reporting it as serving.
+func (s *Server) NewGRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(s.Options.Metrics.UnaryInterceptor(), s.logInterceptor))
+	gs := grpc.NewServer(opts...)
+	s.Register(gs)
+
+	hs := health.NewServer()
+	hs.SetServingStatus(pb.AttestationService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(gs, hs)
+	return gs
+}
+
+// logInterceptor logs the gRPC requests, at error level when they fail
+// with an internal error.
+func (s *Server) logInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	code := status.Code(err)
+	level := slog.LevelDebug
+	if code == codes.Internal || code == codes.Unavailable {
+		level = slog.LevelError
+	}
+	attrs := []any{"transport", "grpc", "method", info.FullMethod, "code", code.String(), "duration", time.Since(start)}
+	if err != nil {
+		attrs = append(attrs, "error", status.Convert(err).Message())
+	}
+	s.Options.Logger.Log(ctx, level, "request served", attrs...)
+	return resp, err
+}
+
+// Ready returns an error when the server cannot verify attestations yet,
+// because the trusted root cannot be loaded.
+func (s *Server) Ready(ctx context.Context) error {
+	_, err := s.verifier.TrustedMaterial(ctx)
+	return err
+}
+
+// Convert converts an attestation to a Sigstore bundle, or a bundle to an
+// attestation.
+func (s *Server) Convert(_ context.Context, req *pb.ConvertRequest) (*pb.ConvertResponse, error) {
+	parsed, err := convert.Parse(req.GetDocument())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "parsing document: %v", err)
+	}
+
+	to := req.GetTo()
+	switch parsed.Kind {
+	case convert.KindAttestation:
+		if to == pb.Format_FORMAT_UNSPECIFIED {
+			to = pb.Format_FORMAT_BUNDLE
+		}
+	case convert.KindBundle:
+		if to == pb.Format_FORMAT_UNSPECIFIED {
+			to = pb.Format_FORMAT_ATTESTATION
+		}
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "cannot convert %s documents", parsed.Kind)
+	}
+
+	var data []byte
+	switch to {
+	case pb.Format_FORMAT_BUNDLE:
+		b := parsed.Bundle
+		if b == nil {
+			if b, err = convert.ToBundle(parsed.Attestation); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "converting attestation: %v", err)
+			}
+		}
+		data, err = convert.MarshalBundle(b)
+	case pb.Format_FORMAT_ATTESTATION:
+		attestation := parsed.Attestation
+		if attestation == nil {
+			if attestation, err = convert.FromBundle(parsed.Bundle); err != nil {
+				return nil, status.Errorf(codes.InvalidArgument, "converting bundle: %v", err)
+			}
+		}
+		data, err = convert.MarshalAttestation(attestation)
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown format %v", to)
+	}
+	if err != nil {
+		return nil, status.Errorf(codes.Internal, "marshaling %v: %v", to, err)
+	}
+	return &pb.ConvertResponse{Document: data, Format: to}, nil
+}
+
+// Verify verifies an attestation against the filename and digest of a
+// distribution. Failed verifications are reported in the response, errors
+// are returned for invalid requests.
+func (s *Server) Verify(ctx context.Context, req *pb.VerifyRequest) (*pb.VerifyResponse, error) {
+	switch {
+	case req.GetAttestation() == nil:
+		return nil, status.Error(codes.InvalidArgument, "attestation is required")
+	case req.GetFilename() == "":
+		return nil, status.Error(codes.InvalidArgument, "filename is required")
+	case len(req.GetSha256()) != sha256.Size:
+		return nil, status.Errorf(codes.InvalidArgument, "invalid sha256 digest length: %d", len(req.GetSha256()))
+	}
+
+	policy := s.Options.Policy
+	if len(req.GetPublishers()) > 0 {
+		policy = &verify.Policy{}
+		for _, p := range req.GetPublishers() {
+			policy.Publishers = append(policy.Publishers, verify.PublisherPolicy{
+				Issuer:     p.GetIssuer(),
+				Repository: p.GetRepository(),
+				Workflow:   p.GetWorkflow(),
+			})
+		}
+	}
+	if policy == nil {
+		return nil, status.Error(codes.InvalidArgument, "no trusted publishers in the request or the server configuration")
+	}
+
+	v, err := s.policyVerifier(ctx, policy)
+	if err != nil {
+		return nil, err
+	}
+	result, err := v.VerifyNamedDigest(ctx, req.GetAttestation(), req.GetFilename(), req.GetSha256())
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+		s.Options.Metrics.observeVerification(false)
+		return &pb.VerifyResponse{Error: err.Error()}, nil
+	}
+	s.Options.Metrics.observeVerification(true)
+
+	claims := verify.ClaimsFromResult(result)
+	resp := &pb.VerifyResponse{
+		Verified:      true,
+		Identity:      result.Identity,
+		Issuer:        result.Issuer,
+		Repository:    claims.Repository,
+		Workflow:      claims.Workflow,
+		PredicateType: result.PredicateType,
+	}
+	for _, entry := range result.LogEntries {
+		resp.LogIndexes = append(resp.LogIndexes, entry.LogIndex)
+	}
+	return resp, nil
+}
+
+// policyVerifier returns a verifier enforcing policy, sharing the trusted
+// material of the server verifier.
+func (s *Server) policyVerifier(ctx context.Context, policy *verify.Policy) (*verify.Verifier, error) {
+	tm, err := s.verifier.TrustedMaterial(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "loading trusted root: %v", err)
+	}
+	funcs := append([]verify.FnOption{}, s.Options.VerifyOptions...)
+	funcs = append(funcs, verify.WithTrustedMaterial(tm), verify.WithPolicy(policy))
+	v, err := verify.New(funcs...)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "%v", err)
+	}
+	return v, nil
+}
+
+// Inspect decodes the certificate, statement and log entries of an
+// attestation without verifying it.
+func (s *Server) Inspect(_ context.Context, req *pb.InspectRequest) (*pb.InspectResponse, error) {
+	attestation := req.GetAttestation()
+	if attestation == nil {
+		return nil, status.Error(codes.InvalidArgument, "attestation is required")
+	}
+	info, err := certinfo.Parse(attestation.GetVerificationMaterial().GetCertificate())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "parsing certificate: %v", err)
+	}
+	st, err := statement.ParseStatement(attestation.GetEnvelope().GetStatement())
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "parsing statement: %v", err)
+	}
+
+	claims := verify.ClaimsFromResult(&verify.VerificationResult{Issuer: info.Issuer(), Extensions: info.Fulcio})
+	resp := &pb.InspectResponse{
+		Identity:      info.SubjectAlternativeName,
+		Issuer:        info.Issuer(),
+		Repository:    claims.Repository,
+		Workflow:      claims.Workflow,
+		PredicateType: st.GetPredicateType(),
+	}
+	for _, subject := range st.GetSubject() {
+		resp.Subjects = append(resp.Subjects, &pb.Subject{Name: subject.GetName(), Digest: subject.GetDigest()})
+	}
+	for i, tle := range attestation.GetVerificationMaterial().GetTransparencyEntries() {
+		entry, err := convert.TransparencyEntryFromStruct(tle)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "transparency entry %d: %v", i, err)
+		}
+		resp.LogIndexes = append(resp.LogIndexes, entry.GetLogIndex())
+	}
+	return resp, nil
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+const testDigest = "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"
+
+// newClient serves s on an in-memory listener and returns a client of it.
+func newClient(t *testing.T, s *Server) pb.AttestationServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	gs := s.NewGRPCServer()
+	go func() { _ = gs.Serve(lis) }()
+	t.Cleanup(gs.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial server: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewAttestationServiceClient(conn)
+}
+
+func TestService(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	attestation, err := convert.UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	s, err := New(
+		WithVerifyOptions(verify.WithEmbeddedTrustedRoot(verify.InstanceProduction)),
+		WithPolicy(&verify.Policy{Publishers: []verify.PublisherPolicy{{Repository: "pypi/pypi-attestations"}}}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	client := newClient(t, s)
+	ctx := context.Background()
+
+	conv, err := client.Convert(ctx, &pb.ConvertRequest{Document: data})
+	if err != nil {
+		t.Fatalf("Convert failed: %v", err)
+	}
+	if conv.GetFormat() != pb.Format_FORMAT_BUNDLE {
+		t.Errorf("Expected a bundle, got %v", conv.GetFormat())
+	}
+	back, err := client.Convert(ctx, &pb.ConvertRequest{Document: conv.GetDocument()})
+	if err != nil || back.GetFormat() != pb.Format_FORMAT_ATTESTATION {
+		t.Fatalf("Expected the bundle to convert back: %v", err)
+	}
+	if _, err := client.Convert(ctx, &pb.ConvertRequest{Document: []byte("{}")}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument for an unknown document, got %v", err)
+	}
+
+	res, err := client.Verify(ctx, &pb.VerifyRequest{Attestation: attestation, Filename: "pypi_attestations-0.0.28.tar.gz", Sha256: digest})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if !res.GetVerified() || res.GetRepository() != "https://github.com/pypi/pypi-attestations" || res.GetWorkflow() != "release.yml" || len(res.GetLogIndexes()) != 1 {
+		t.Errorf("Unexpected verification: %v", res)
+	}
+
+	res, err = client.Verify(ctx, &pb.VerifyRequest{
+		Attestation: attestation,
+		Filename:    "pypi_attestations-0.0.28.tar.gz",
+		Sha256:      digest,
+		Publishers:  []*pb.Publisher{{Repository: "other/repository"}},
+	})
+	if err != nil {
+		t.Fatalf("Verify failed: %v", err)
+	}
+	if res.GetVerified() || res.GetError() == "" {
+		t.Errorf("Expected the request publishers to reject the attestation: %v", res)
+	}
+
+	for _, req := range []*pb.VerifyRequest{
+		{Filename: "pypi_attestations-0.0.28.tar.gz", Sha256: digest},
+		{Attestation: attestation, Sha256: digest},
+		{Attestation: attestation, Filename: "pypi_attestations-0.0.28.tar.gz", Sha256: digest[:4]},
+		{Attestation: attestation, Filename: "pypi_attestations-0.0.28.tar.gz", Sha256: digest, Publishers: []*pb.Publisher{{}}},
+	} {
+		if _, err := client.Verify(ctx, req); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("Expected InvalidArgument for %v, got %v", req, err)
+		}
+	}
+
+	ins, err := client.Inspect(ctx, &pb.InspectRequest{Attestation: attestation})
+	if err != nil {
+		t.Fatalf("Inspect failed: %v", err)
+	}
+	if ins.GetIssuer() != verify.GitHubIssuer || ins.GetWorkflow() != "release.yml" || len(ins.GetSubjects()) != 1 ||
+		ins.GetSubjects()[0].GetDigest()["sha256"] != testDigest {
+		t.Errorf("Unexpected inspection: %v", ins)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	provenance, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.provenance.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+	index := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/pypi/pypi-attestations/0.0.28/json":
+			fmt.Fprintf(w, `{"urls": [{"filename": "pypi_attestations-0.0.28.tar.gz", "digests": {"sha256": %q}}]}`, testDigest)
+		case "/integrity/pypi-attestations/0.0.28/pypi_attestations-0.0.28.tar.gz/provenance":
+			w.Write(provenance)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer index.Close()
+	metrics := NewMetrics()
+	client, err := pypi.NewClient(
+		pypi.WithURL(index.URL),
+		pypi.WithRetries(0),
+		pypi.WithRoundTripper(metrics.RoundTripper("pypi", nil)),
+		pypi.WithResponseCache(metrics.ResponseCache(pypi.NewMemoryResponseCache())),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create index client: %v", err)
+	}
+
+	s, err := New(
+		WithVerifyOptions(
+			verify.WithEmbeddedTrustedRoot(verify.InstanceProduction),
+			verify.WithCache(metrics.VerificationCache(verify.NewMemoryCache(time.Minute))),
+		),
+		WithIndexClient(client),
+		WithMetrics(metrics),
+		WithMaxRequestSize(int64(len(data))+1024),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	do := func(method, path string, body []byte, want int) []byte {
+		t.Helper()
+		req, err := http.NewRequest(method, srv.URL+path, bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s %s failed: %v", method, path, err)
+		}
+		defer resp.Body.Close()
+		out, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("%s %s: expected status %d, got %d: %s", method, path, want, resp.StatusCode, out)
+		}
+		return out
+	}
+	errorCode := func(body []byte) string {
+		var e ErrorResponse
+		if err := json.Unmarshal(body, &e); err != nil {
+			t.Errorf("Expected an error envelope, got %s", body)
+		}
+		return e.Error.Code
+	}
+
+	bundle := do(http.MethodPost, "/convert?to=bundle", data, http.StatusOK)
+	if !bytes.Contains(bundle, []byte("mediaType")) {
+		t.Errorf("Expected a bundle, got %s", bundle)
+	}
+	if code := errorCode(do(http.MethodPost, "/convert?to=cyclonedx", data, http.StatusBadRequest)); code != ErrorInvalidRequest {
+		t.Errorf("Unexpected error code %q", code)
+	}
+	if code := errorCode(do(http.MethodPost, "/convert", bytes.Repeat([]byte(" "), len(data)+2048), http.StatusRequestEntityTooLarge)); code != ErrorTooLarge {
+		t.Errorf("Unexpected error code %q", code)
+	}
+
+	body, err := json.Marshal(VerifyRequest{
+		Attestation: data,
+		Filename:    "pypi_attestations-0.0.28.tar.gz",
+		SHA256:      testDigest,
+		Publishers:  []Publisher{{Repository: "pypi/pypi-attestations", Workflow: "release.yml"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var res VerifyResponse
+	if err := json.Unmarshal(do(http.MethodPost, "/verify", body, http.StatusOK), &res); err != nil {
+		t.Fatal(err)
+	}
+	if !res.Verified || res.Workflow != "release.yml" {
+		t.Errorf("Unexpected verification: %+v", res)
+	}
+	noPolicy, err := json.Marshal(VerifyRequest{Attestation: data, Filename: "pypi_attestations-0.0.28.tar.gz", SHA256: testDigest})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if code := errorCode(do(http.MethodPost, "/verify", noPolicy, http.StatusBadRequest)); code != ErrorInvalidRequest {
+		t.Errorf("Unexpected error code %q", code)
+	}
+
+	var release ReleaseProvenance
+	if err := json.Unmarshal(do(http.MethodGet, "/packages/pypi-attestations/0.0.28/provenance", nil, http.StatusOK), &release); err != nil {
+		t.Fatal(err)
+	}
+	if len(release.Files) != 1 || release.Files[0].Error != "" || len(release.Files[0].Provenance) == 0 {
+		t.Errorf("Unexpected release provenance: %+v", release)
+	}
+	do(http.MethodGet, "/packages/pypi-attestations/0.0.28/provenance?filename=pypi_attestations-0.0.28.tar.gz", nil, http.StatusOK)
+	if code := errorCode(do(http.MethodGet, "/packages/missing/1.0/provenance", nil, http.StatusNotFound)); code != ErrorNotFound {
+		t.Errorf("Unexpected error code %q", code)
+	}
+	if code := errorCode(do(http.MethodGet, "/verify", nil, http.StatusNotFound)); code != ErrorNotFound {
+		t.Errorf("Unexpected error code %q", code)
+	}
+	if !bytes.HasPrefix(do(http.MethodGet, "/openapi.yaml", nil, http.StatusOK), []byte("openapi: 3.")) {
+		t.Error("Expected the OpenAPI document")
+	}
+
+	do(http.MethodGet, "/healthz", nil, http.StatusOK)
+	do(http.MethodGet, "/readyz", nil, http.StatusOK)
+	exposition := do(http.MethodGet, "/metrics", nil, http.StatusOK)
+	for _, line := range []string{
+		`pypi_attestations_requests_total{code="200",method="POST /verify",transport="http"} 1`,
+		`pypi_attestations_verifications_total{outcome="verified"} 1`,
+		`pypi_attestations_cache_requests_total{cache="verification",result="miss"} 1`,
+		`pypi_attestations_external_request_duration_seconds_count{code="200",service="pypi"}`,
+	} {
+		if !bytes.Contains(exposition, []byte(line)) {
+			t.Errorf("Expected the metrics to contain %s, got:\n%s", line, exposition)
+		}
+	}
+
+}
@@ -0,0 +1,86 @@
+package sign
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// Environment variables holding ambient identity credentials.
+const (
+	// IDTokenEnv holds an identity token set by the CI job, as the GitLab
+	// id_tokens keyword does.
+	IDTokenEnv = "SIGSTORE_ID_TOKEN"
+
+	// GitHub Actions variables to request a workflow identity token.
+	GitHubTokenRequestURLEnv   = "ACTIONS_ID_TOKEN_REQUEST_URL"
+	GitHubTokenRequestTokenEnv = "ACTIONS_ID_TOKEN_REQUEST_TOKEN"
+)
+
+// DefaultAudience is the audience of identity tokens exchanged with Fulcio.
+const DefaultAudience = "sigstore"
+
+// AmbientTokenSource returns a TokenSource getting the identity token of
+// the CI job from the environment: the SIGSTORE_ID_TOKEN variable or the
+// GitHub Actions OIDC endpoint, which is requested with client. ok is false
+// when the environment has no ambient credentials.
+func AmbientTokenSource(getenv func(string) string, client *http.Client) (ts TokenSource, ok bool) {
+	if token := getenv(IDTokenEnv); token != "" {
+		return TokenSourceFunc(func(context.Context) (string, error) {
+			return token, nil
+		}), true
+	}
+
+	requestURL, requestToken := getenv(GitHubTokenRequestURLEnv), getenv(GitHubTokenRequestTokenEnv)
+	if requestURL == "" || requestToken == "" {
+		return nil, false
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return TokenSourceFunc(func(ctx context.Context) (string, error) {
+		return githubIDToken(ctx, client, requestURL, requestToken)
+	}), true
+}
+
+// githubIDToken requests a workflow identity token from GitHub Actions.
+func githubIDToken(ctx context.Context, client *http.Client, requestURL, requestToken string) (string, error) {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "", fmt.Errorf("parsing token request URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("audience", DefaultAudience)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("requesting GitHub Actions identity token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", fmt.Errorf("requesting GitHub Actions identity token: %s: %s", resp.Status, body)
+	}
+
+	var token struct {
+		Value string `json:"value"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("decoding GitHub Actions identity token: %w", err)
+	}
+	if token.Value == "" {
+		return "", fmt.Errorf("GitHub Actions returned an empty identity token")
+	}
+	return token.Value, nil
+}
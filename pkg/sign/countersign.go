@@ -0,0 +1,36 @@
+package sign
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// Countersign signs the statement of an existing attestation using a Signer
+// configured with the passed options, see Signer.Countersign.
+func Countersign(ctx context.Context, attestation *pb.Attestation, funcs ...FnOption) (*pb.Attestation, error) {
+	s, err := New(funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return s.Countersign(ctx, attestation)
+}
+
+// Countersign wraps the statement of an existing attestation, unchanged, in
+// a new envelope signed by the identity of the signer. The original and the
+// countersigned attestations attest the same statement bytes, which links
+// them as a chain of custody, see verify.Verifier.VerifyCustody.
+//
+// The signature of the original envelope is checked before countersigning
+// so a tampered statement is never vouched for. Its trust is not verified.
+func (s *Signer) Countersign(ctx context.Context, attestation *pb.Attestation) (*pb.Attestation, error) {
+	if attestation == nil {
+		return nil, fmt.Errorf("attestation cannot be nil")
+	}
+	if err := verify.VerifySignature(attestation); err != nil {
+		return nil, fmt.Errorf("checking original signature: %w", err)
+	}
+	return s.SignStatement(ctx, attestation.GetEnvelope().GetStatement())
+}
@@ -0,0 +1,56 @@
+package sign
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/go-openapi/runtime"
+	httptransport "github.com/go-openapi/runtime/client"
+	"github.com/go-openapi/strfmt"
+	rekorclient "github.com/sigstore/rekor/pkg/generated/client"
+	"github.com/sigstore/rekor/pkg/util"
+)
+
+// Endpoints of the Sigstore staging instance, for testing signing flows
+// without polluting the public good log.
+const (
+	StagingFulcioURL  = "https://fulcio.sigstage.dev"
+	StagingRekorURL   = "https://rekor.sigstage.dev"
+	StagingOIDCIssuer = "https://oauth2.sigstage.dev/auth"
+)
+
+// headerRoundTripper adds headers, eg credentials, to every request.
+type headerRoundTripper struct {
+	base    http.RoundTripper
+	headers http.Header
+}
+
+func (rt *headerRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range rt.headers {
+		req.Header[k] = append([]string(nil), v...)
+	}
+	return rt.base.RoundTrip(req)
+}
+
+// newRekorClient returns a Rekor v1 API client using the HTTP client,
+// which sigstore-go cannot be configured with.
+func newRekorClient(baseURL string, client *http.Client) (*rekorclient.Rekor, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing rekor URL: %w", err)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = rekorclient.DefaultBasePath
+	}
+
+	rt := httptransport.NewWithClient(u.Host, u.Path, []string{u.Scheme}, client)
+	rt.Consumers["application/json"] = runtime.JSONConsumer()
+	rt.Consumers["application/x-pem-file"] = runtime.TextConsumer()
+	rt.Producers["application/json"] = runtime.JSONProducer()
+
+	registry := strfmt.Default
+	registry.Add("signedCheckpoint", &util.SignedNote{}, util.SignedCheckpointValidator)
+	return rekorclient.New(rt, registry), nil
+}
@@ -0,0 +1,194 @@
+package sign
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	sgsign "github.com/sigstore/sigstore-go/pkg/sign"
+	"github.com/sigstore/sigstore/pkg/cryptoutils"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// keyPair is a sigstore-go keypair backed by a long lived signer.
+type keyPair struct {
+	signer  crypto.Signer
+	details signature.AlgorithmDetails
+	hint    []byte
+}
+
+var _ sgsign.Keypair = (*keyPair)(nil)
+
+// newKeyPair wraps an ECDSA or Ed25519 signer.
+func newKeyPair(signer crypto.Signer) (*keyPair, error) {
+	var algorithm protocommon.PublicKeyDetails
+	switch pub := signer.Public().(type) {
+	case *ecdsa.PublicKey:
+		switch pub.Curve {
+		case elliptic.P256():
+			algorithm = protocommon.PublicKeyDetails_PKIX_ECDSA_P256_SHA_256
+		case elliptic.P384():
+			algorithm = protocommon.PublicKeyDetails_PKIX_ECDSA_P384_SHA_384
+		case elliptic.P521():
+			algorithm = protocommon.PublicKeyDetails_PKIX_ECDSA_P521_SHA_512
+		default:
+			return nil, fmt.Errorf("unsupported ECDSA curve %s", pub.Curve.Params().Name)
+		}
+	case ed25519.PublicKey:
+		algorithm = protocommon.PublicKeyDetails_PKIX_ED25519
+	default:
+		return nil, fmt.Errorf("unsupported key type %T, only ECDSA and Ed25519 keys are supported", pub)
+	}
+
+	details, err := signature.GetAlgorithmDetails(algorithm)
+	if err != nil {
+		return nil, err
+	}
+	der, err := x509.MarshalPKIXPublicKey(signer.Public())
+	if err != nil {
+		return nil, fmt.Errorf("marshaling public key: %w", err)
+	}
+	sum := sha256.Sum256(der)
+	return &keyPair{
+		signer:  signer,
+		details: details,
+		hint:    []byte(base64.StdEncoding.EncodeToString(sum[:])),
+	}, nil
+}
+
+func (k *keyPair) GetHashAlgorithm() protocommon.HashAlgorithm {
+	return k.details.GetProtoHashType()
+}
+
+func (k *keyPair) GetSigningAlgorithm() protocommon.PublicKeyDetails {
+	return k.details.GetSignatureAlgorithm()
+}
+
+func (k *keyPair) GetHint() []byte {
+	return k.hint
+}
+
+func (k *keyPair) GetKeyAlgorithm() string {
+	if k.details.GetKeyType() == signature.ED25519 {
+		return "ED25519"
+	}
+	return "ECDSA"
+}
+
+func (k *keyPair) GetPublicKey() crypto.PublicKey {
+	return k.signer.Public()
+}
+
+func (k *keyPair) GetPublicKeyPem() (string, error) {
+	data, err := cryptoutils.MarshalPublicKeyToPEM(k.signer.Public())
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// SignData signs the digest of data, or data itself for Ed25519, and
+// returns the signature and the signed bytes.
+func (k *keyPair) SignData(_ context.Context, data []byte) ([]byte, []byte, error) {
+	hf := k.details.GetHashType()
+	message := data
+	if hf != crypto.Hash(0) {
+		h := hf.New()
+		h.Write(data)
+		message = h.Sum(nil)
+	}
+	sig, err := k.signer.Sign(rand.Reader, message, hf)
+	if err != nil {
+		return nil, nil, fmt.Errorf("signing: %w", err)
+	}
+	return sig, message, nil
+}
+
+// staticCertificate provides a self managed certificate instead of a
+// Fulcio issued one.
+type staticCertificate struct {
+	der []byte
+}
+
+func (c staticCertificate) GetCertificate(_ context.Context, keypair sgsign.Keypair, _ *sgsign.CertificateProviderOptions) ([]byte, error) {
+	cert, err := x509.ParseCertificate(c.der)
+	if err != nil {
+		return nil, fmt.Errorf("parsing signing certificate: %w", err)
+	}
+	pub, ok := cert.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(keypair.GetPublicKey()) {
+		return nil, fmt.Errorf("signing certificate does not certify the signing key")
+	}
+	return c.der, nil
+}
+
+// parsePrivateKeyPEM parses a PKCS #8 or SEC 1 PEM encoded private key.
+func parsePrivateKeyPEM(data []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("no private key found in PEM data")
+		}
+
+		var key any
+		var err error
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", key)
+		}
+		return signer, nil
+	}
+}
+
+// parseCertificatesPEM returns the DER of the certificates in PEM data,
+// leaf first.
+func parseCertificatesPEM(data []byte) ([][]byte, error) {
+	var certs [][]byte
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, block.Bytes)
+		}
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificates found in PEM data")
+	}
+	return certs, nil
+}
+
+// readKeyFiles reads a PEM private key and certificate chain.
+func readKeyFiles(keyPath, certPath string) (keyPEM, certPEM []byte, err error) {
+	if keyPEM, err = os.ReadFile(keyPath); err != nil {
+		return nil, nil, fmt.Errorf("reading private key: %w", err)
+	}
+	if certPEM, err = os.ReadFile(certPath); err != nil {
+		return nil, nil, fmt.Errorf("reading certificate: %w", err)
+	}
+	return keyPEM, certPEM, nil
+}
@@ -0,0 +1,124 @@
+package sign
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"runtime"
+
+	"github.com/carabiner-dev/pypi-attestations/internal/ctxio"
+	"github.com/sigstore/sigstore/pkg/oauthflow"
+)
+
+// Defaults of the Sigstore public good OIDC provider.
+const (
+	DefaultOIDCIssuer   = "https://oauth2.sigstore.dev/auth"
+	DefaultOIDCClientID = "sigstore"
+)
+
+// TokenSource returns OIDC identity tokens to exchange for signing
+// certificates.
+type TokenSource interface {
+	IDToken(ctx context.Context) (string, error)
+}
+
+// TokenSourceFunc adapts a function to a TokenSource.
+type TokenSourceFunc func(ctx context.Context) (string, error)
+
+// IDToken calls f.
+func (f TokenSourceFunc) IDToken(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+// Flow is the interactive OAuth flow used to get an identity token.
+type Flow string
+
+const (
+	// FlowAuto uses the browser flow when a browser can be opened and the
+	// session is interactive, the device flow otherwise.
+	FlowAuto Flow = "auto"
+
+	// FlowBrowser opens the browser on the provider login page and gets
+	// the code on a local redirect listener.
+	FlowBrowser Flow = "browser"
+
+	// FlowDevice prints a code to enter on the provider page from any
+	// device.
+	FlowDevice Flow = "device"
+)
+
+// OIDCOptions configure the interactive OAuth flow.
+type OIDCOptions struct {
+	// Issuer is the OIDC provider URL, DefaultOIDCIssuer when empty.
+	Issuer string
+
+	// ClientID is the OAuth client ID, DefaultOIDCClientID when empty.
+	ClientID string
+
+	// RedirectURL is the redirect URL of the browser flow. When empty, a
+	// listener on a random localhost port is used.
+	RedirectURL string
+
+	// Flow selects the browser or device flow, FlowAuto when empty.
+	Flow Flow
+}
+
+// InteractiveTokenSource returns a TokenSource running an interactive OAuth
+// flow against the OIDC provider, as cosign does for local signing.
+func InteractiveTokenSource(opts OIDCOptions) TokenSource {
+	if opts.Issuer == "" {
+		opts.Issuer = DefaultOIDCIssuer
+	}
+	if opts.ClientID == "" {
+		opts.ClientID = DefaultOIDCClientID
+	}
+	return TokenSourceFunc(func(ctx context.Context) (string, error) {
+		var getter oauthflow.TokenGetter
+		switch flow := selectFlow(opts.Flow, isInteractive(), hasBrowser()); flow {
+		case FlowBrowser:
+			getter = oauthflow.DefaultIDTokenGetter
+		case FlowDevice:
+			getter = oauthflow.NewDeviceFlowTokenGetterForIssuer(opts.Issuer)
+		default:
+			return "", fmt.Errorf("unknown OAuth flow %q", flow)
+		}
+
+		// The OAuth flow takes no context, stop waiting for it on cancellation
+		token, err := ctxio.Run(ctx, func() (*oauthflow.OIDCIDToken, error) {
+			return oauthflow.OIDConnect(opts.Issuer, opts.ClientID, "", opts.RedirectURL, getter)
+		})
+		if err != nil {
+			return "", fmt.Errorf("getting identity token from %s: %w", opts.Issuer, err)
+		}
+		return token.RawString, nil
+	})
+}
+
+// selectFlow resolves FlowAuto to the browser flow when the session is
+// interactive and a browser is available, to the device flow otherwise.
+func selectFlow(flow Flow, interactive, browser bool) Flow {
+	if flow != "" && flow != FlowAuto {
+		return flow
+	}
+	if interactive && browser {
+		return FlowBrowser
+	}
+	return FlowDevice
+}
+
+// isInteractive reports whether stdin is a terminal.
+func isInteractive() bool {
+	fi, err := os.Stdin.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// hasBrowser reports whether a browser can likely be opened. On Linux and
+// BSDs it requires a graphical session.
+func hasBrowser() bool {
+	switch runtime.GOOS {
+	case "darwin", "windows":
+		return true
+	default:
+		return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	}
+}
@@ -0,0 +1,348 @@
+package sign
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/carabiner-dev/pypi-attestations/internal/transport"
+	"github.com/carabiner-dev/pypi-attestations/pkg/statement"
+
+	sgsign "github.com/sigstore/sigstore-go/pkg/sign"
+)
+
+// Endpoints of the Sigstore public good instance used by PyPI.
+const (
+	DefaultFulcioURL = "https://fulcio.sigstore.dev"
+	DefaultRekorURL  = "https://rekor.sigstore.dev"
+
+	// DefaultTimestampAuthorityURL is the Sigstore RFC 3161 timestamp
+	// authority.
+	DefaultTimestampAuthorityURL = "https://timestamp.sigstore.dev/api/v1/timestamp"
+)
+
+// Options controls how the Signer creates attestations.
+type Options struct {
+	// IDToken is the OIDC identity token exchanged with Fulcio for the
+	// signing certificate, eg the token of a trusted publishing workflow.
+	IDToken string
+
+	// TokenSource gets the identity token when IDToken is empty, eg
+	// through an interactive OAuth flow.
+	TokenSource TokenSource
+
+	// CertificateProvider issues the signing certificate. When nil, the
+	// public good Fulcio instance is used.
+	CertificateProvider sgsign.CertificateProvider
+
+	// Key signs the statement instead of an ephemeral key certified by
+	// Fulcio. Certificate must certify it, no identity token is needed.
+	// This enables private deployments without Fulcio, the attestations
+	// verify against a trusted root listing the issuing CA.
+	Key crypto.Signer
+
+	// Certificate is the DER encoded certificate of Key, followed by
+	// its intermediate certificates. Only the first one is recorded in
+	// the attestation, the trusted root must list the intermediates,
+	// see WithKey.
+	Certificate [][]byte
+
+	// TransparencyLog records the signed envelope. When nil, the public
+	// good Rekor instance is used.
+	TransparencyLog sgsign.Transparency
+
+	// FulcioURL is the Fulcio instance issuing certificates when no
+	// CertificateProvider is configured.
+	FulcioURL string
+
+	// RekorURL is the Rekor instance recording envelopes when no
+	// TransparencyLog is configured.
+	RekorURL string
+
+	// Proxy is the URL of the HTTP proxy. When empty, the proxy is read
+	// from the environment.
+	Proxy string
+
+	// RootCAs are the CAs trusted for TLS connections to Fulcio and
+	// Rekor. When nil, the system pool is used.
+	RootCAs *x509.CertPool
+
+	// RoundTripper replaces the HTTP transport used to talk to Fulcio and
+	// Rekor.
+	RoundTripper http.RoundTripper
+
+	// Provenance is the CI build environment described in the SLSA
+	// provenance attestation created by SignAttestations. When nil, only
+	// the publish attestation is created.
+	Provenance *statement.CIEnvironment
+
+	// Headers are added to the requests to Fulcio and Rekor, eg the
+	// credentials of a private instance behind an authenticating proxy.
+	Headers http.Header
+
+	// TimestampAuthority issues the RFC 3161 timestamps added by
+	// Timestamp. When nil, the authority at TimestampAuthorityURL is used.
+	TimestampAuthority TimestampAuthority
+
+	// TimestampAuthorityURL is the full URL of the RFC 3161 timestamp
+	// authority used when no TimestampAuthority is configured.
+	TimestampAuthorityURL string
+
+	// Logger receives the signing steps. When nil, slog.Default() is used.
+	Logger *slog.Logger
+}
+
+var defaultOptions = Options{
+	FulcioURL:             DefaultFulcioURL,
+	RekorURL:              DefaultRekorURL,
+	TimestampAuthorityURL: DefaultTimestampAuthorityURL,
+}
+
+// FnOption is a functional option to configure the Signer.
+type FnOption func(*Options) error
+
+// WithIDToken sets the OIDC identity token used to get the signing
+// certificate.
+func WithIDToken(token string) FnOption {
+	return func(o *Options) error {
+		o.IDToken = token
+		return nil
+	}
+}
+
+// WithCertificateProvider sets the provider of the signing certificate.
+func WithCertificateProvider(p sgsign.CertificateProvider) FnOption {
+	return func(o *Options) error {
+		o.CertificateProvider = p
+		return nil
+	}
+}
+
+// WithTransparencyLog sets the log recording the signed envelope.
+func WithTransparencyLog(t sgsign.Transparency) FnOption {
+	return func(o *Options) error {
+		o.TransparencyLog = t
+		return nil
+	}
+}
+
+// WithTokenSource sets the source of identity tokens used when no token is
+// set with WithIDToken.
+func WithTokenSource(ts TokenSource) FnOption {
+	return func(o *Options) error {
+		o.TokenSource = ts
+		return nil
+	}
+}
+
+// WithInteractiveFlow gets the identity token through an interactive OAuth
+// flow, opening the browser or printing a device code. It is meant for
+// maintainers signing from a workstation.
+func WithInteractiveFlow(opts OIDCOptions) FnOption {
+	return func(o *Options) error {
+		switch opts.Flow {
+		case "", FlowAuto, FlowBrowser, FlowDevice:
+		default:
+			return fmt.Errorf("unknown OAuth flow %q", opts.Flow)
+		}
+		o.TokenSource = InteractiveTokenSource(opts)
+		return nil
+	}
+}
+
+// WithKey signs with a long lived ECDSA or Ed25519 key and its self managed
+// certificate chain, DER encoded and leaf first, instead of Fulcio. The
+// leaf needs a Subject Alternative Name.
+//
+// PEP 740 records only the leaf certificate, the intermediates of the chain
+// are not part of the attestation and must be listed in the trusted root of
+// the verifiers. The leaf embeds no SCT, so verifiers must also turn off
+// verify.WithRequireSCT.
+func WithKey(key crypto.Signer, chain ...[]byte) FnOption {
+	return func(o *Options) error {
+		if key == nil {
+			return fmt.Errorf("signing key cannot be nil")
+		}
+		if len(chain) == 0 {
+			return fmt.Errorf("a certificate is required to sign with a key")
+		}
+		if _, err := newKeyPair(key); err != nil {
+			return err
+		}
+		o.Key = key
+		o.Certificate = chain
+		return nil
+	}
+}
+
+// WithKeyPEM signs with a PEM encoded PKCS #8 or SEC 1 private key and its
+// PEM certificate chain, see WithKey.
+func WithKeyPEM(keyPEM, certPEM []byte) FnOption {
+	return func(o *Options) error {
+		key, err := parsePrivateKeyPEM(keyPEM)
+		if err != nil {
+			return err
+		}
+		chain, err := parseCertificatesPEM(certPEM)
+		if err != nil {
+			return err
+		}
+		return WithKey(key, chain...)(o)
+	}
+}
+
+// WithKeyFile signs with the PEM private key and certificate chain read from
+// files, see WithKey.
+func WithKeyFile(keyPath, certPath string) FnOption {
+	return func(o *Options) error {
+		keyPEM, certPEM, err := readKeyFiles(keyPath, certPath)
+		if err != nil {
+			return err
+		}
+		return WithKeyPEM(keyPEM, certPEM)(o)
+	}
+}
+
+// WithFulcioURL sets the URL of the Fulcio instance issuing certificates.
+func WithFulcioURL(u string) FnOption {
+	return func(o *Options) error {
+		if _, err := url.Parse(u); err != nil {
+			return fmt.Errorf("parsing fulcio URL: %w", err)
+		}
+		o.FulcioURL = strings.TrimSuffix(u, "/")
+		return nil
+	}
+}
+
+// WithRekorURL sets the URL of the Rekor instance recording envelopes.
+func WithRekorURL(u string) FnOption {
+	return func(o *Options) error {
+		if _, err := url.Parse(u); err != nil {
+			return fmt.Errorf("parsing rekor URL: %w", err)
+		}
+		o.RekorURL = strings.TrimSuffix(u, "/")
+		return nil
+	}
+}
+
+// WithStaging signs against the Sigstore staging instance. Identity
+// tokens must be issued for it, see StagingOIDCIssuer, and the attestations
+// only verify against the staging trusted root.
+func WithStaging() FnOption {
+	return func(o *Options) error {
+		o.FulcioURL = StagingFulcioURL
+		o.RekorURL = StagingRekorURL
+		return nil
+	}
+}
+
+// WithProxy sets the URL of the HTTP proxy.
+func WithProxy(u string) FnOption {
+	return func(o *Options) error {
+		if _, err := url.Parse(u); err != nil {
+			return fmt.Errorf("parsing proxy URL: %w", err)
+		}
+		o.Proxy = u
+		return nil
+	}
+}
+
+// WithRootCAs sets the pool of CAs trusted for TLS connections.
+func WithRootCAs(pool *x509.CertPool) FnOption {
+	return func(o *Options) error {
+		o.RootCAs = pool
+		return nil
+	}
+}
+
+// WithCAFile trusts the PEM certificates in path in addition to the
+// system CAs.
+func WithCAFile(path string) FnOption {
+	return func(o *Options) error {
+		pool, err := transport.LoadCertPool(path)
+		if err != nil {
+			return err
+		}
+		o.RootCAs = pool
+		return nil
+	}
+}
+
+// WithRoundTripper replaces the HTTP transport used to talk to Fulcio and
+// Rekor.
+func WithRoundTripper(rt http.RoundTripper) FnOption {
+	return func(o *Options) error {
+		o.RoundTripper = rt
+		return nil
+	}
+}
+
+// WithHeader adds a header to the requests to Fulcio and Rekor.
+func WithHeader(key, value string) FnOption {
+	return func(o *Options) error {
+		if o.Headers == nil {
+			o.Headers = http.Header{}
+		}
+		o.Headers.Add(key, value)
+		return nil
+	}
+}
+
+// WithProvenance creates a SLSA provenance attestation describing the CI
+// build in SignAttestations.
+func WithProvenance(env *statement.CIEnvironment) FnOption {
+	return func(o *Options) error {
+		if env == nil {
+			return fmt.Errorf("CI environment cannot be nil")
+		}
+		o.Provenance = env
+		return nil
+	}
+}
+
+// WithCIProvenance creates a SLSA provenance attestation describing the CI
+// build detected from the environment variables. It fails outside of a
+// supported CI platform.
+func WithCIProvenance() FnOption {
+	return func(o *Options) error {
+		env, err := statement.DetectCI(os.Getenv)
+		if err != nil {
+			return err
+		}
+		o.Provenance = env
+		return nil
+	}
+}
+
+// WithTimestampAuthority sets the authority issuing RFC 3161 timestamps.
+func WithTimestampAuthority(ta TimestampAuthority) FnOption {
+	return func(o *Options) error {
+		o.TimestampAuthority = ta
+		return nil
+	}
+}
+
+// WithTimestampAuthorityURL sets the full URL of the RFC 3161 timestamp
+// authority.
+func WithTimestampAuthorityURL(u string) FnOption {
+	return func(o *Options) error {
+		if _, err := url.Parse(u); err != nil {
+			return fmt.Errorf("parsing timestamp authority URL: %w", err)
+		}
+		o.TimestampAuthorityURL = u
+		return nil
+	}
+}
+
+// WithLogger sets the logger of the signer.
+func WithLogger(l *slog.Logger) FnOption {
+	return func(o *Options) error {
+		o.Logger = l
+		return nil
+	}
+}
@@ -0,0 +1,134 @@
+package sign
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/distfile"
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// UploadOptions controls how SignAndUpload publishes distributions.
+type UploadOptions struct {
+	// Username and Password authenticate the uploads, see
+	// pypi.UploadRequest.
+	Username string
+	Password string
+
+	// Metadata holds core metadata form fields sent with every file, see
+	// pypi.UploadRequest.
+	Metadata map[string][]string
+
+	// DryRun only finds and checks the distributions. No request is made
+	// to Fulcio, Rekor or the index.
+	DryRun bool
+}
+
+// UploadResult is the outcome of publishing a distribution file.
+type UploadResult struct {
+	// Path of the distribution file.
+	Path string
+
+	// File is the parsed distribution filename.
+	File *distfile.File
+
+	// Attestations are the attestations created for the file, nil in dry
+	// runs.
+	Attestations []*pb.Attestation
+
+	// Uploaded is true when the file was uploaded to the index.
+	Uploaded bool
+}
+
+// FindDistributions returns the paths of the wheels and sdists in dir,
+// sorted by name. Other files, such as attestations, are ignored.
+func FindDistributions(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("reading distribution directory: %w", err)
+	}
+
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if _, err := distfile.Parse(e.Name()); err != nil {
+			continue
+		}
+		paths = append(paths, filepath.Join(dir, e.Name()))
+	}
+	sort.Strings(paths)
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no distributions found in %s", dir)
+	}
+	return paths, nil
+}
+
+// SignAndUpload signs every distribution in dir, eg dist/, and uploads the
+// files with their attestations through the legacy upload API of the index
+// client. All distributions are signed before the first upload, so signing
+// failures do not leave a partial release. The results of the files
+// processed are returned along with any error.
+func (s *Signer) SignAndUpload(ctx context.Context, dir string, client *pypi.Client, opts UploadOptions) ([]UploadResult, error) {
+	if client == nil && !opts.DryRun {
+		return nil, fmt.Errorf("an index client is required to upload")
+	}
+
+	paths, err := FindDistributions(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]UploadResult, len(paths))
+	for i, path := range paths {
+		f, err := distfile.Parse(filepath.Base(path))
+		if err != nil {
+			return nil, err
+		}
+		results[i] = UploadResult{Path: path, File: f}
+	}
+	if opts.DryRun {
+		return results, nil
+	}
+
+	for i := range results {
+		attestations, err := s.SignAttestations(ctx, results[i].Path)
+		if err != nil {
+			return results, fmt.Errorf("signing %s: %w", results[i].File.Filename, err)
+		}
+		results[i].Attestations = attestations
+	}
+
+	for i := range results {
+		if err := upload(ctx, client, &results[i], opts); err != nil {
+			return results, err
+		}
+		results[i].Uploaded = true
+	}
+	return results, nil
+}
+
+// upload publishes a signed distribution file.
+func upload(ctx context.Context, client *pypi.Client, r *UploadResult, opts UploadOptions) error {
+	content, err := os.Open(r.Path)
+	if err != nil {
+		return fmt.Errorf("opening distribution: %w", err)
+	}
+	defer content.Close()
+
+	return client.Upload(ctx, &pypi.UploadRequest{
+		Name:         r.File.Name,
+		Version:      r.File.Version,
+		Filename:     r.File.Filename,
+		Content:      content,
+		Metadata:     opts.Metadata,
+		Attestations: r.Attestations,
+		Username:     opts.Username,
+		Password:     opts.Password,
+	})
+}
@@ -0,0 +1,246 @@
+// Package sign creates PEP 740 attestations for Python distributions,
+// signing them keyless with a Fulcio certificate, or with a self managed
+// key, and recording them in Rekor.
+package sign
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/carabiner-dev/pypi-attestations/internal/transport"
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/mediatype"
+	"github.com/carabiner-dev/pypi-attestations/pkg/statement"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	sgsign "github.com/sigstore/sigstore-go/pkg/sign"
+)
+
+// Signer creates PEP 740 attestations.
+type Signer struct {
+	Options Options
+
+	// client talks to Fulcio and Rekor, nil to use the sigstore-go
+	// defaults.
+	client *http.Client
+}
+
+// New returns a new Signer configured with the passed options.
+func New(funcs ...FnOption) (*Signer, error) {
+	opts := defaultOptions
+	for _, fn := range funcs {
+		if err := fn(&opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+
+	tc := transport.Config{
+		Proxy:        opts.Proxy,
+		RootCAs:      opts.RootCAs,
+		RoundTripper: opts.RoundTripper,
+	}
+	client, err := tc.Client()
+	if err != nil {
+		return nil, err
+	}
+	if len(opts.Headers) > 0 {
+		if client == nil {
+			client = &http.Client{Transport: http.DefaultTransport}
+		}
+		client.Transport = &headerRoundTripper{base: client.Transport, headers: opts.Headers}
+	}
+	return &Signer{Options: opts, client: client}, nil
+}
+
+// Sign creates a publish attestation for the distribution file at distPath
+// using a Signer configured with the passed options.
+func Sign(ctx context.Context, distPath string, funcs ...FnOption) (*pb.Attestation, error) {
+	s, err := New(funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return s.Sign(ctx, distPath)
+}
+
+// SignAttestations creates the publish attestation, and the provenance
+// attestation when configured, for the distribution file at distPath using
+// a Signer configured with the passed options.
+func SignAttestations(ctx context.Context, distPath string, funcs ...FnOption) ([]*pb.Attestation, error) {
+	s, err := New(funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return s.SignAttestations(ctx, distPath)
+}
+
+// Sign creates a publish attestation for the distribution file at distPath.
+// The statement is signed with an ephemeral key certified by Fulcio for the
+// identity of the ID token, or with the configured key, and the envelope is
+// recorded in Rekor.
+func (s *Signer) Sign(ctx context.Context, distPath string) (*pb.Attestation, error) {
+	subject, err := statement.SubjectFromFileContext(ctx, distPath)
+	if err != nil {
+		return nil, err
+	}
+	data, err := statement.PublishStatement(subject)
+	if err != nil {
+		return nil, err
+	}
+	return s.SignStatement(ctx, data)
+}
+
+// SignAttestations creates the publish attestation for the distribution
+// file at distPath and, when a CI environment is configured, its SLSA
+// provenance attestation. The identity token is only requested once.
+func (s *Signer) SignAttestations(ctx context.Context, distPath string) ([]*pb.Attestation, error) {
+	subject, err := statement.SubjectFromFileContext(ctx, distPath)
+	if err != nil {
+		return nil, err
+	}
+	publish, err := statement.PublishStatement(subject)
+	if err != nil {
+		return nil, err
+	}
+	statements := [][]byte{publish}
+
+	if s.Options.Provenance != nil {
+		provenance, err := s.Options.Provenance.Provenance()
+		if err != nil {
+			return nil, err
+		}
+		data, err := statement.ProvenanceStatement(provenance, subject)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, data)
+	}
+	return s.signStatements(ctx, statements)
+}
+
+// SignStatement signs the JSON of an in-toto statement and returns the
+// attestation.
+func (s *Signer) SignStatement(ctx context.Context, data []byte) (*pb.Attestation, error) {
+	attestations, err := s.signStatements(ctx, [][]byte{data})
+	if err != nil {
+		return nil, err
+	}
+	return attestations[0], nil
+}
+
+// signStatements signs each statement in its own envelope. Keyless
+// signatures use a new ephemeral key for each statement.
+func (s *Signer) signStatements(ctx context.Context, statements [][]byte) ([]*pb.Attestation, error) {
+	for i, data := range statements {
+		if _, err := statement.ParseStatement(data); err != nil {
+			return nil, fmt.Errorf("statement %d: %w", i, err)
+		}
+	}
+
+	var attestations []*pb.Attestation
+	if s.Options.Key != nil {
+		for _, data := range statements {
+			attestation, err := s.signWithKey(ctx, data)
+			if err != nil {
+				return nil, err
+			}
+			attestations = append(attestations, attestation)
+		}
+		return attestations, nil
+	}
+
+	token := s.Options.IDToken
+	if token == "" && s.Options.TokenSource != nil {
+		s.Options.Logger.DebugContext(ctx, "requesting identity token")
+		var err error
+		if token, err = s.Options.TokenSource.IDToken(ctx); err != nil {
+			return nil, fmt.Errorf("getting identity token: %w", err)
+		}
+	}
+
+	provider := s.Options.CertificateProvider
+	if provider == nil {
+		if token == "" {
+			return nil, fmt.Errorf("an identity token is required to get a Fulcio certificate")
+		}
+		fulcio := &sgsign.FulcioOptions{BaseURL: s.Options.FulcioURL}
+		if s.client != nil {
+			fulcio.Transport = s.client.Transport
+		}
+		provider = sgsign.NewFulcio(fulcio)
+		s.Options.Logger.DebugContext(ctx, "using Fulcio certificate provider", "url", s.Options.FulcioURL)
+	}
+
+	for _, data := range statements {
+		keypair, err := sgsign.NewEphemeralKeypair(nil)
+		if err != nil {
+			return nil, fmt.Errorf("generating signing key: %w", err)
+		}
+		attestation, err := s.bundle(ctx, data, keypair, provider, token)
+		if err != nil {
+			return nil, err
+		}
+		attestations = append(attestations, attestation)
+	}
+	return attestations, nil
+}
+
+// signWithKey signs the statement with the configured key and certificate.
+func (s *Signer) signWithKey(ctx context.Context, data []byte) (*pb.Attestation, error) {
+	if len(s.Options.Certificate) == 0 {
+		return nil, fmt.Errorf("a certificate is required to sign with a key")
+	}
+	keypair, err := newKeyPair(s.Options.Key)
+	if err != nil {
+		return nil, err
+	}
+
+	// PEP 740 cannot carry the intermediate certificates, verifiers read
+	// them from their trusted root.
+	if n := len(s.Options.Certificate) - 1; n > 0 {
+		s.Options.Logger.DebugContext(ctx, "intermediate certificates not recorded in the attestation", "count", n)
+	}
+	return s.bundle(ctx, data, keypair, staticCertificate{der: s.Options.Certificate[0]}, "")
+}
+
+// bundle signs the statement, records it in the transparency log and
+// converts the resulting bundle to an attestation.
+func (s *Signer) bundle(ctx context.Context, data []byte, keypair sgsign.Keypair, provider sgsign.CertificateProvider, token string) (*pb.Attestation, error) {
+	tlog := s.Options.TransparencyLog
+	if tlog == nil {
+		rekor := &sgsign.RekorOptions{BaseURL: s.Options.RekorURL}
+		if s.client != nil {
+			client, err := newRekorClient(s.Options.RekorURL, s.client)
+			if err != nil {
+				return nil, err
+			}
+			rekor.Client = client.Entries
+		}
+		tlog = sgsign.NewRekor(rekor)
+		s.Options.Logger.DebugContext(ctx, "using Rekor transparency log", "url", s.Options.RekorURL)
+	}
+
+	pbBundle, err := sgsign.Bundle(
+		&sgsign.DSSEData{Data: data, PayloadType: mediatype.InToto},
+		keypair,
+		sgsign.BundleOptions{
+			CertificateProvider:        provider,
+			CertificateProviderOptions: &sgsign.CertificateProviderOptions{IDToken: token},
+			TransparencyLogs:           []sgsign.Transparency{tlog},
+			Context:                    ctx,
+		},
+	)
+	if err != nil {
+		return nil, fmt.Errorf("signing statement: %w", err)
+	}
+	for _, entry := range pbBundle.GetVerificationMaterial().GetTlogEntries() {
+		s.Options.Logger.InfoContext(ctx, "statement recorded in transparency log", "logIndex", entry.GetLogIndex())
+	}
+
+	return convert.FromBundle(&bundle.Bundle{Bundle: pbBundle})
+}
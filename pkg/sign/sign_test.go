@@ -0,0 +1,855 @@
+package sign
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/attestation"
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/pypi"
+	"github.com/carabiner-dev/pypi-attestations/pkg/statement"
+	"github.com/carabiner-dev/pypi-attestations/pkg/verify"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/cyberphone/json-canonicalization/go/src/webpki.org/jsoncanonicalizer"
+	"github.com/digitorus/timestamp"
+	protobundle "github.com/sigstore/protobuf-specs/gen/pb-go/bundle/v1"
+	protocommon "github.com/sigstore/protobuf-specs/gen/pb-go/common/v1"
+	protorekor "github.com/sigstore/protobuf-specs/gen/pb-go/rekor/v1"
+	"github.com/sigstore/rekor/pkg/util"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	sgsign "github.com/sigstore/sigstore-go/pkg/sign"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// fakeCA issues certificates for the signing keys from a local CA.
+type fakeCA struct {
+	key  *ecdsa.PrivateKey
+	cert *x509.Certificate
+
+	// token is the last identity token received.
+	token string
+}
+
+func newFakeCA(t *testing.T) *fakeCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate CA key: %v", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatalf("Failed to parse CA certificate: %v", err)
+	}
+	return &fakeCA{key: key, cert: cert}
+}
+
+func (ca *fakeCA) GetCertificate(_ context.Context, keypair sgsign.Keypair, opts *sgsign.CertificateProviderOptions) ([]byte, error) {
+	ca.token = opts.IDToken
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(2),
+		EmailAddresses: []string{"maintainer@example.com"},
+		NotBefore:      time.Now().Add(-time.Minute),
+		NotAfter:       time.Now().Add(10 * time.Minute),
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	return x509.CreateCertificate(rand.Reader, tmpl, ca.cert, keypair.GetPublicKey(), ca.key)
+}
+
+// fakeLog records dsse entries without a log.
+type fakeLog struct{}
+
+func (fakeLog) GetTransparencyLogEntry(_ context.Context, verifier []byte, b *protobundle.Bundle) error {
+	body, err := dsseEntryBody(verifier, b)
+	if err != nil {
+		return err
+	}
+	b.VerificationMaterial.TlogEntries = append(b.VerificationMaterial.TlogEntries, &protorekor.TransparencyLogEntry{
+		LogIndex:          1,
+		LogId:             &protocommon.LogId{KeyId: []byte("test log")},
+		KindVersion:       &protorekor.KindVersion{Kind: "dsse", Version: "0.0.1"},
+		IntegratedTime:    time.Now().Unix(),
+		InclusionPromise:  &protorekor.InclusionPromise{SignedEntryTimestamp: []byte("promise")},
+		CanonicalizedBody: body,
+	})
+	return nil
+}
+
+// dsseEntryBody returns the body of the Rekor dsse entry of the bundle.
+func dsseEntryBody(verifier []byte, b *protobundle.Bundle) ([]byte, error) {
+	envelope := b.GetDsseEnvelope()
+	payloadHash := sha256.Sum256(envelope.GetPayload())
+	envelopeJSON, err := json.Marshal(envelope)
+	if err != nil {
+		return nil, err
+	}
+	envelopeHash := sha256.Sum256(envelopeJSON)
+	return json.Marshal(map[string]any{
+		"apiVersion": "0.0.1",
+		"kind":       "dsse",
+		"spec": map[string]any{
+			"envelopeHash": map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(envelopeHash[:])},
+			"payloadHash":  map[string]string{"algorithm": "sha256", "value": hex.EncodeToString(payloadHash[:])},
+			"signatures": []map[string]string{{
+				"signature": base64.StdEncoding.EncodeToString(envelope.GetSignatures()[0].GetSig()),
+				"verifier":  base64.StdEncoding.EncodeToString(verifier),
+			}},
+		},
+	})
+}
+
+// signedLog records dsse entries with a signed entry timestamp and an
+// inclusion proof in a tree of one leaf, which verify against the log key.
+type signedLog struct {
+	key *ecdsa.PrivateKey
+	id  []byte
+}
+
+func newSignedLog(t *testing.T) *signedLog {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate log key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal log key: %v", err)
+	}
+	id := sha256.Sum256(der)
+	return &signedLog{key: key, id: id[:]}
+}
+
+func (l *signedLog) GetTransparencyLogEntry(_ context.Context, verifier []byte, b *protobundle.Bundle) error {
+	body, err := dsseEntryBody(verifier, b)
+	if err != nil {
+		return err
+	}
+	entry := &protorekor.TransparencyLogEntry{
+		LogIndex:          1,
+		LogId:             &protocommon.LogId{KeyId: l.id},
+		KindVersion:       &protorekor.KindVersion{Kind: "dsse", Version: "0.0.1"},
+		IntegratedTime:    time.Now().Unix(),
+		CanonicalizedBody: body,
+	}
+	payload, err := json.Marshal(tlog.RekorPayload{
+		Body:           base64.StdEncoding.EncodeToString(body),
+		IntegratedTime: entry.IntegratedTime,
+		LogIndex:       entry.LogIndex,
+		LogID:          hex.EncodeToString(l.id),
+	})
+	if err != nil {
+		return err
+	}
+	canonical, err := jsoncanonicalizer.Transform(payload)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(canonical)
+	set, err := ecdsa.SignASN1(rand.Reader, l.key, digest[:])
+	if err != nil {
+		return err
+	}
+	entry.InclusionPromise = &protorekor.InclusionPromise{SignedEntryTimestamp: set}
+
+	signer, err := signature.LoadECDSASignerVerifier(l.key, crypto.SHA256)
+	if err != nil {
+		return err
+	}
+	rootHash := sha256.Sum256(append([]byte{0}, body...))
+	checkpoint, err := util.CreateAndSignCheckpoint(context.Background(), "rekor.localhost", 1, 1, rootHash[:], signer)
+	if err != nil {
+		return err
+	}
+	entry.InclusionProof = &protorekor.InclusionProof{
+		LogIndex:   0,
+		TreeSize:   1,
+		RootHash:   rootHash[:],
+		Checkpoint: &protorekor.Checkpoint{Envelope: string(checkpoint)},
+	}
+	b.VerificationMaterial.TlogEntries = append(b.VerificationMaterial.TlogEntries, entry)
+	return nil
+}
+
+// trustedRoot returns a trusted root listing the CA and the log.
+func (l *signedLog) trustedRoot(t *testing.T, ca *fakeCA) []byte {
+	t.Helper()
+	tr, err := root.NewTrustedRoot(root.TrustedRootMediaType01,
+		[]root.CertificateAuthority{&root.FulcioCertificateAuthority{
+			Root:                ca.cert,
+			ValidityPeriodStart: ca.cert.NotBefore,
+		}},
+		nil, nil,
+		map[string]*root.TransparencyLog{hex.EncodeToString(l.id): {
+			ID:                  l.id,
+			ValidityPeriodStart: time.Now().Add(-time.Hour),
+			HashFunc:            crypto.SHA256,
+			PublicKey:           &l.key.PublicKey,
+			SignatureHashFunc:   crypto.SHA256,
+		}},
+	)
+	if err != nil {
+		t.Fatalf("Failed to create trusted root: %v", err)
+	}
+	data, err := tr.MarshalJSON()
+	if err != nil {
+		t.Fatalf("Failed to marshal trusted root: %v", err)
+	}
+	return data
+}
+
+func writeDist(t *testing.T, name string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte("distribution contents"), 0o600); err != nil {
+		t.Fatalf("Failed to write distribution: %v", err)
+	}
+	return path
+}
+
+func TestSign(t *testing.T) {
+	path := writeDist(t, "sampleproject-1.0.tar.gz")
+	att, err := Sign(context.Background(), path,
+		WithCertificateProvider(newFakeCA(t)), WithTransparencyLog(fakeLog{}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	if err := verify.VerifySignature(att); err != nil {
+		t.Errorf("Signature does not verify: %v", err)
+	}
+	if err := verify.CheckEntryConsistency(att); err != nil {
+		t.Errorf("Transparency entry does not match: %v", err)
+	}
+
+	a := attestation.New(att)
+	if pt, err := a.PredicateType(); err != nil || pt != statement.PublishPredicateType {
+		t.Errorf("Unexpected predicate type %q: %v", pt, err)
+	}
+	subjects, err := a.Subjects()
+	if err != nil {
+		t.Fatalf("Failed to read subjects: %v", err)
+	}
+	sum := sha256.Sum256([]byte("distribution contents"))
+	if len(subjects) != 1 || subjects[0].GetName() != "sampleproject-1.0.tar.gz" || subjects[0].GetDigest()["sha256"] != hex.EncodeToString(sum[:]) {
+		t.Errorf("Unexpected subjects: %v", subjects)
+	}
+}
+
+func TestSignErrors(t *testing.T) {
+	if _, err := Sign(context.Background(), writeDist(t, "sampleproject-1.0.tar.gz"), WithTransparencyLog(fakeLog{})); err == nil {
+		t.Error("Expected error without an identity token")
+	}
+	if _, err := Sign(context.Background(), writeDist(t, "not-a-dist.txt"),
+		WithCertificateProvider(newFakeCA(t)), WithTransparencyLog(fakeLog{}),
+	); err == nil {
+		t.Error("Expected error for a file that is not a distribution")
+	}
+}
+
+func TestTokenSource(t *testing.T) {
+	ca := newFakeCA(t)
+	calls := 0
+	source := TokenSourceFunc(func(context.Context) (string, error) {
+		calls++
+		return "interactive-token", nil
+	})
+
+	path := writeDist(t, "sampleproject-1.0.tar.gz")
+	if _, err := Sign(context.Background(), path, WithCertificateProvider(ca), WithTransparencyLog(fakeLog{}), WithTokenSource(source)); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if ca.token != "interactive-token" || calls != 1 {
+		t.Errorf("Expected the token source to be used once, got token %q after %d calls", ca.token, calls)
+	}
+
+	if _, err := Sign(context.Background(), path, WithCertificateProvider(ca), WithTransparencyLog(fakeLog{}),
+		WithTokenSource(source), WithIDToken("static-token"),
+	); err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if ca.token != "static-token" || calls != 1 {
+		t.Errorf("Expected the static token to take precedence, got %q after %d calls", ca.token, calls)
+	}
+
+	if _, err := New(WithInteractiveFlow(OIDCOptions{Flow: "carrier-pigeon"})); err == nil {
+		t.Error("Expected error for an unknown flow")
+	}
+}
+
+func TestSelectFlow(t *testing.T) {
+	for _, tc := range []struct {
+		flow        Flow
+		interactive bool
+		browser     bool
+		expected    Flow
+	}{
+		{"", true, true, FlowBrowser},
+		{FlowAuto, true, false, FlowDevice},
+		{FlowAuto, false, true, FlowDevice},
+		{FlowBrowser, false, false, FlowBrowser},
+		{FlowDevice, true, true, FlowDevice},
+	} {
+		if got := selectFlow(tc.flow, tc.interactive, tc.browser); got != tc.expected {
+			t.Errorf("selectFlow(%q, %v, %v): expected %s, got %s", tc.flow, tc.interactive, tc.browser, tc.expected, got)
+		}
+	}
+}
+
+// selfSigned returns a self signed certificate for the key.
+func selfSigned(t *testing.T, key crypto.Signer) []byte {
+	t.Helper()
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(3),
+		Subject:      pkix.Name{CommonName: "release signing"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return der
+}
+
+func TestSignWithKey(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	ecDER, err := x509.MarshalPKCS8PrivateKey(ecKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	ecKeyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: ecDER})
+	ecCertPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: selfSigned(t, ecKey)})
+
+	dir := t.TempDir()
+	keyPath, certPath := filepath.Join(dir, "key.pem"), filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(keyPath, ecKeyPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write key: %v", err)
+	}
+	if err := os.WriteFile(certPath, ecCertPEM, 0o600); err != nil {
+		t.Fatalf("Failed to write certificate: %v", err)
+	}
+
+	for name, opt := range map[string]FnOption{
+		"ed25519 signer": WithKey(edKey, selfSigned(t, edKey)),
+		"ecdsa pem":      WithKeyPEM(ecKeyPEM, ecCertPEM),
+		"ecdsa files":    WithKeyFile(keyPath, certPath),
+	} {
+		t.Run(name, func(t *testing.T) {
+			att, err := Sign(context.Background(), writeDist(t, "sampleproject-1.0-py3-none-any.whl"), opt, WithTransparencyLog(fakeLog{}))
+			if err != nil {
+				t.Fatalf("Failed to sign: %v", err)
+			}
+			if err := verify.VerifySignature(att); err != nil {
+				t.Errorf("Signature does not verify: %v", err)
+			}
+			if err := verify.CheckEntryConsistency(att); err != nil {
+				t.Errorf("Transparency entry does not match: %v", err)
+			}
+		})
+	}
+
+	if _, err := Sign(context.Background(), writeDist(t, "sampleproject-1.0.tar.gz"),
+		WithKey(edKey, selfSigned(t, ecKey)), WithTransparencyLog(fakeLog{}),
+	); err == nil {
+		t.Error("Expected error for a certificate of another key")
+	}
+	if _, err := New(WithKey(edKey)); err == nil {
+		t.Error("Expected error without a certificate")
+	}
+	if _, err := New(WithKeyPEM(ecCertPEM, ecCertPEM)); err == nil {
+		t.Error("Expected error for PEM without a private key")
+	}
+}
+
+func TestSignWithKeyChain(t *testing.T) {
+	ca := newFakeCA(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	kp, err := newKeyPair(key)
+	if err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+	leaf, err := ca.GetCertificate(context.Background(), kp, &sgsign.CertificateProviderOptions{})
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+
+	s, err := New(WithKey(key, leaf, ca.cert.Raw), WithTransparencyLog(fakeLog{}))
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "sampleproject-1.0.tar.gz")
+	if err := os.WriteFile(path, []byte("distribution contents"), 0o600); err != nil {
+		t.Fatalf("Failed to write distribution: %v", err)
+	}
+	att, err := s.Sign(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if !bytes.Equal(att.GetVerificationMaterial().GetCertificate(), leaf) {
+		t.Error("Expected the leaf certificate in the attestation")
+	}
+	if _, err := convert.MarshalAttestation(att); err != nil {
+		t.Errorf("Failed to marshal attestation: %v", err)
+	}
+
+	var uploaded int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.ParseMultipartForm(1<<20) == nil && r.FormValue("attestations") != "" {
+			uploaded++
+		}
+	}))
+	defer srv.Close()
+	client, err := pypi.NewClient(pypi.WithUploadURL(srv.URL))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := s.SignAndUpload(context.Background(), dir, client, UploadOptions{Password: "pypi-token"}); err != nil {
+		t.Fatalf("Failed to sign and upload: %v", err)
+	}
+	if uploaded != 1 {
+		t.Errorf("Expected one upload with attestations, got %d", uploaded)
+	}
+}
+
+func TestSignWithKeyVerifies(t *testing.T) {
+	ca := newFakeCA(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	kp, err := newKeyPair(key)
+	if err != nil {
+		t.Fatalf("Failed to load key: %v", err)
+	}
+	leaf, err := ca.GetCertificate(context.Background(), kp, &sgsign.CertificateProviderOptions{})
+	if err != nil {
+		t.Fatalf("Failed to issue certificate: %v", err)
+	}
+	log := newSignedLog(t)
+
+	dist := writeDist(t, "sampleproject-1.0.tar.gz")
+	att, err := Sign(context.Background(), dist, WithKey(key, leaf), WithTransparencyLog(log))
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	digest := sha256.Sum256([]byte("distribution contents"))
+
+	// The certificate does not come from Fulcio and embeds no SCT
+	v, err := verify.New(verify.WithTrustedRootJSON(log.trustedRoot(t, ca)), verify.WithRequireSCT(false))
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+	if _, err := v.VerifyDigest(context.Background(), att, digest[:]); err != nil {
+		t.Errorf("Expected the attestation to verify: %v", err)
+	}
+
+	v, err = verify.New(verify.WithTrustedRootJSON(log.trustedRoot(t, ca)))
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+	if _, err := v.VerifyDigest(context.Background(), att, digest[:]); err == nil {
+		t.Error("Expected verification to fail requiring an SCT")
+	}
+}
+
+// testToken is an unsigned JWT with a subject, enough for the Fulcio
+// client proof of possession.
+var testToken = "e30." + base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"maintainer@example.com","email":"maintainer@example.com","email_verified":true}`)) + ".c2ln"
+
+func TestCustomEndpoints(t *testing.T) {
+	ca := newFakeCA(t)
+	var fulcioHeader, rekorHeader, rekorPath string
+	fulcio := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fulcioHeader = r.Header.Get("X-Api-Key")
+		var req struct {
+			PublicKeyRequest struct {
+				PublicKey struct {
+					Content string `json:"content"`
+				} `json:"publicKey"`
+			} `json:"publicKeyRequest"`
+		}
+		if r.URL.Path != "/api/v2/signingCert" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		block, _ := pem.Decode([]byte(req.PublicKeyRequest.PublicKey.Content))
+		pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tmpl := &x509.Certificate{
+			SerialNumber: big.NewInt(4),
+			NotBefore:    time.Now().Add(-time.Minute),
+			NotAfter:     time.Now().Add(10 * time.Minute),
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		}
+		der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, pub, ca.key)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		leaf := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+		root := string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw}))
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"signedCertificateEmbeddedSct": map[string]any{"chain": map[string]any{"certificates": []string{leaf, root}}},
+		})
+	}))
+	defer fulcio.Close()
+	rekor := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rekorHeader, rekorPath = r.Header.Get("X-Api-Key"), r.URL.Path
+		http.Error(w, `{"code":400,"message":"rejected"}`, http.StatusBadRequest)
+	}))
+	defer rekor.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(fulcio.Certificate())
+	pool.AddCert(rekor.Certificate())
+	path := writeDist(t, "sampleproject-1.0.tar.gz")
+
+	att, err := Sign(context.Background(), path,
+		WithIDToken(testToken), WithFulcioURL(fulcio.URL+"/"), WithRootCAs(pool),
+		WithHeader("X-Api-Key", "secret"), WithTransparencyLog(fakeLog{}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to sign against the private Fulcio: %v", err)
+	}
+	if fulcioHeader != "secret" {
+		t.Errorf("Expected the header to reach Fulcio, got %q", fulcioHeader)
+	}
+	if err := verify.VerifySignature(att); err != nil {
+		t.Errorf("Signature does not verify: %v", err)
+	}
+
+	if _, err := Sign(context.Background(), path,
+		WithCertificateProvider(ca), WithRekorURL(rekor.URL), WithRootCAs(pool), WithHeader("X-Api-Key", "secret"),
+	); err == nil {
+		t.Error("Expected the private Rekor rejection to fail signing")
+	}
+	if rekorHeader != "secret" || rekorPath != "/api/v1/log/entries" {
+		t.Errorf("Expected the entry to be posted to the private Rekor, got %q with header %q", rekorPath, rekorHeader)
+	}
+
+	if _, err := Sign(context.Background(), path, WithIDToken(testToken), WithFulcioURL(fulcio.URL), WithTransparencyLog(fakeLog{})); err == nil {
+		t.Error("Expected TLS error without the private CA")
+	}
+
+	s, err := New(WithStaging())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if s.Options.FulcioURL != StagingFulcioURL || s.Options.RekorURL != StagingRekorURL {
+		t.Errorf("Unexpected staging endpoints: %s %s", s.Options.FulcioURL, s.Options.RekorURL)
+	}
+}
+
+func TestSignAttestations(t *testing.T) {
+	env, err := statement.DetectCI(func(k string) string {
+		return map[string]string{
+			"GITHUB_ACTIONS":    "true",
+			"GITHUB_SERVER_URL": "https://github.com",
+			"GITHUB_REPOSITORY": "example/sampleproject",
+			"GITHUB_REF":        "refs/tags/v1.0",
+			"GITHUB_SHA":        "0123456789abcdef0123456789abcdef01234567",
+			"GITHUB_RUN_ID":     "42",
+		}[k]
+	})
+	if err != nil {
+		t.Fatalf("Failed to detect CI: %v", err)
+	}
+
+	calls := 0
+	source := TokenSourceFunc(func(context.Context) (string, error) {
+		calls++
+		return "token", nil
+	})
+	atts, err := SignAttestations(context.Background(), writeDist(t, "sampleproject-1.0.tar.gz"),
+		WithCertificateProvider(newFakeCA(t)), WithTransparencyLog(fakeLog{}),
+		WithTokenSource(source), WithProvenance(env),
+	)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("Expected one token request, got %d", calls)
+	}
+	if len(atts) != 2 {
+		t.Fatalf("Expected 2 attestations, got %d", len(atts))
+	}
+	if err := attestation.Require(attestation.Wrap(atts),
+		attestation.Exactly(statement.PublishPredicateType, 1),
+		attestation.Exactly(statement.SLSAProvenancePredicateType, 1),
+	); err != nil {
+		t.Errorf("Unexpected attestations: %v", err)
+	}
+
+	s, err := attestation.New(atts[1]).Statement()
+	if err != nil {
+		t.Fatalf("Failed to parse provenance statement: %v", err)
+	}
+	prov, err := statement.DecodeSLSAProvenance(s)
+	if err != nil {
+		t.Fatalf("Failed to decode provenance: %v", err)
+	}
+	if prov.InvocationID != "https://github.com/example/sampleproject/actions/runs/42/attempts/1" {
+		t.Errorf("Unexpected invocation id: %s", prov.InvocationID)
+	}
+
+	atts, err = SignAttestations(context.Background(), writeDist(t, "sampleproject-1.0.tar.gz"),
+		WithCertificateProvider(newFakeCA(t)), WithTransparencyLog(fakeLog{}),
+	)
+	if err != nil || len(atts) != 1 {
+		t.Errorf("Expected only the publish attestation without provenance: %d, %v", len(atts), err)
+	}
+}
+
+func TestSignAndUpload(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"sampleproject-1.0.tar.gz", "sampleproject-1.0-py3-none-any.whl", "sampleproject-1.0.tar.gz.publish.attestation", "README.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(name), 0o600); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+	}
+
+	var uploaded []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pass, _ := r.BasicAuth(); pass != "pypi-token" {
+			http.Error(w, "unauthorized", http.StatusForbidden)
+			return
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		var attestations []json.RawMessage
+		if err := json.Unmarshal([]byte(r.FormValue("attestations")), &attestations); err != nil || len(attestations) != 1 {
+			http.Error(w, "invalid attestations", http.StatusBadRequest)
+			return
+		}
+		_, fh, err := r.FormFile("content")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		uploaded = append(uploaded, fh.Filename)
+	}))
+	defer srv.Close()
+
+	client, err := pypi.NewClient(pypi.WithUploadURL(srv.URL))
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	s, err := New(WithCertificateProvider(newFakeCA(t)), WithTransparencyLog(fakeLog{}))
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+
+	results, err := s.SignAndUpload(context.Background(), dir, nil, UploadOptions{DryRun: true})
+	if err != nil {
+		t.Fatalf("Dry run failed: %v", err)
+	}
+	if len(results) != 2 || results[0].Attestations != nil || results[0].Uploaded || len(uploaded) != 0 {
+		t.Errorf("Unexpected dry run results: %+v", results)
+	}
+
+	results, err = s.SignAndUpload(context.Background(), dir, client, UploadOptions{Password: "pypi-token"})
+	if err != nil {
+		t.Fatalf("Failed to sign and upload: %v", err)
+	}
+	if len(uploaded) != 2 || uploaded[0] != "sampleproject-1.0-py3-none-any.whl" || uploaded[1] != "sampleproject-1.0.tar.gz" {
+		t.Errorf("Unexpected uploads: %v", uploaded)
+	}
+	for _, r := range results {
+		if !r.Uploaded || len(r.Attestations) != 1 {
+			t.Errorf("Unexpected result for %s: %+v", r.Path, r)
+		}
+	}
+
+	results, err = s.SignAndUpload(context.Background(), dir, client, UploadOptions{Password: "wrong"})
+	if err == nil || len(results) != 2 || results[0].Uploaded {
+		t.Errorf("Expected the rejected upload to be reported: %v", err)
+	}
+	if _, err := s.SignAndUpload(context.Background(), t.TempDir(), client, UploadOptions{}); err == nil {
+		t.Error("Expected error for a directory without distributions")
+	}
+}
+
+func TestCountersign(t *testing.T) {
+	original, err := Sign(context.Background(), writeDist(t, "sampleproject-1.0.tar.gz"),
+		WithCertificateProvider(newFakeCA(t)), WithTransparencyLog(fakeLog{}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	approver := newFakeCA(t)
+	countersigned, err := Countersign(context.Background(), original,
+		WithCertificateProvider(approver), WithTransparencyLog(fakeLog{}), WithIDToken("approver"),
+	)
+	if err != nil {
+		t.Fatalf("Failed to countersign: %v", err)
+	}
+	if approver.token != "approver" {
+		t.Errorf("Expected the approver token to be used, got %q", approver.token)
+	}
+	if !bytes.Equal(countersigned.Envelope.Statement, original.Envelope.Statement) {
+		t.Error("Expected the countersigned statement to match the original")
+	}
+	if bytes.Equal(countersigned.Envelope.Signature, original.Envelope.Signature) {
+		t.Error("Expected a new signature")
+	}
+	if err := verify.VerifySignature(countersigned); err != nil {
+		t.Errorf("Countersignature does not verify: %v", err)
+	}
+
+	original.Envelope.Statement = append(original.Envelope.Statement, ' ')
+	if _, err := Countersign(context.Background(), original,
+		WithCertificateProvider(approver), WithTransparencyLog(fakeLog{}),
+	); err == nil {
+		t.Error("Expected error countersigning a tampered attestation")
+	}
+	if _, err := Countersign(context.Background(), nil); err == nil {
+		t.Error("Expected error for nil attestation")
+	}
+}
+
+// fakeTSA issues timestamps from a self signed authority, over the passed
+// digest when set.
+type fakeTSA struct {
+	key    *ecdsa.PrivateKey
+	cert   *x509.Certificate
+	digest []byte
+}
+
+func (tsa *fakeTSA) GetTimestamp(_ context.Context, signature []byte) ([]byte, error) {
+	digest := sha256.Sum256(signature)
+	ts := &timestamp.Timestamp{
+		HashAlgorithm: crypto.SHA256,
+		HashedMessage: digest[:],
+		Time:          time.Now(),
+		Policy:        asn1.ObjectIdentifier{1, 2, 3, 4},
+	}
+	if tsa.digest != nil {
+		ts.HashedMessage = tsa.digest
+	}
+	return ts.CreateResponse(tsa.cert, tsa.key)
+}
+
+func TestTimestamp(t *testing.T) {
+	original, err := Sign(context.Background(), writeDist(t, "sampleproject-1.0.tar.gz"),
+		WithCertificateProvider(newFakeCA(t)), WithTransparencyLog(fakeLog{}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to sign: %v", err)
+	}
+
+	ca := newFakeCA(t)
+	tsa := &fakeTSA{key: ca.key, cert: ca.cert}
+	timestamped, err := Timestamp(context.Background(), original, WithTimestampAuthority(tsa))
+	if err != nil {
+		t.Fatalf("Failed to timestamp: %v", err)
+	}
+	if len(original.VerificationMaterial.Rfc3161Timestamps) != 0 {
+		t.Error("Expected the original attestation to be left unchanged")
+	}
+	if n := len(timestamped.VerificationMaterial.Rfc3161Timestamps); n != 1 {
+		t.Errorf("Expected 1 timestamp, got %d", n)
+	}
+
+	tsa.digest = make([]byte, sha256.Size)
+	if _, err := Timestamp(context.Background(), original, WithTimestampAuthority(tsa)); err == nil {
+		t.Error("Expected error for a timestamp over another digest")
+	}
+	if _, err := Timestamp(context.Background(), &pb.Attestation{}, WithTimestampAuthority(tsa)); err == nil {
+		t.Error("Expected error for an attestation without signature")
+	}
+}
+
+func TestAmbientTokenSource(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" || r.URL.Query().Get("audience") != DefaultAudience {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, `{"value": "workflow-token"}`)
+	}))
+	defer srv.Close()
+
+	env := map[string]string{
+		GitHubTokenRequestURLEnv:   srv.URL + "/token?api-version=2.0",
+		GitHubTokenRequestTokenEnv: "request-token",
+	}
+	ts, ok := AmbientTokenSource(func(k string) string { return env[k] }, srv.Client())
+	if !ok {
+		t.Fatal("Expected GitHub Actions credentials to be found")
+	}
+	if token, err := ts.IDToken(context.Background()); err != nil || token != "workflow-token" {
+		t.Errorf("Unexpected token %q: %v", token, err)
+	}
+
+	env[GitHubTokenRequestTokenEnv] = "wrong"
+	ts, _ = AmbientTokenSource(func(k string) string { return env[k] }, srv.Client())
+	if _, err := ts.IDToken(context.Background()); err == nil {
+		t.Error("Expected error for a rejected token request")
+	}
+
+	env[IDTokenEnv] = "job-token"
+	ts, _ = AmbientTokenSource(func(k string) string { return env[k] }, nil)
+	if token, err := ts.IDToken(context.Background()); err != nil || token != "job-token" {
+		t.Errorf("Expected the token from the environment, got %q: %v", token, err)
+	}
+
+	if _, ok := AmbientTokenSource(func(string) string { return "" }, nil); ok {
+		t.Error("Expected no ambient credentials")
+	}
+}
@@ -0,0 +1,84 @@
+package sign
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/digitorus/timestamp"
+	sgsign "github.com/sigstore/sigstore-go/pkg/sign"
+	"google.golang.org/protobuf/proto"
+)
+
+// TimestampAuthority issues RFC 3161 timestamps over signatures. It is
+// implemented by the sigstore-go TimestampAuthority.
+type TimestampAuthority interface {
+	// GetTimestamp returns the DER timestamp response over the sha256
+	// digest of the signature.
+	GetTimestamp(ctx context.Context, signature []byte) ([]byte, error)
+}
+
+// Timestamp adds a RFC 3161 timestamp to an existing attestation using a
+// Signer configured with the passed options, see Signer.Timestamp.
+func Timestamp(ctx context.Context, attestation *pb.Attestation, funcs ...FnOption) (*pb.Attestation, error) {
+	s, err := New(funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return s.Timestamp(ctx, attestation)
+}
+
+// Timestamp obtains a RFC 3161 timestamp over the envelope signature of the
+// attestation and returns a copy of it recording the timestamp. The
+// timestamp proves the signature existed while the signing certificate was
+// valid, which keeps archived attestations verifiable independently of the
+// transparency log.
+//
+// PEP 740 JSON cannot carry timestamps, store the result as a Sigstore
+// bundle, see convert.ToBundle.
+func (s *Signer) Timestamp(ctx context.Context, attestation *pb.Attestation) (*pb.Attestation, error) {
+	if attestation.GetVerificationMaterial() == nil {
+		return nil, fmt.Errorf("attestation has no verification material")
+	}
+	sig := attestation.GetEnvelope().GetSignature()
+	if len(sig) == 0 {
+		return nil, fmt.Errorf("attestation has no signature to timestamp")
+	}
+
+	ta := s.Options.TimestampAuthority
+	if ta == nil {
+		opts := &sgsign.TimestampAuthorityOptions{URL: s.Options.TimestampAuthorityURL}
+		if s.client != nil {
+			opts.Transport = s.client.Transport
+		}
+		ta = sgsign.NewTimestampAuthority(opts)
+	}
+
+	resp, err := ta.GetTimestamp(ctx, sig)
+	if err != nil {
+		return nil, fmt.Errorf("getting timestamp: %w", err)
+	}
+	if err := checkTimestamp(resp, sig); err != nil {
+		return nil, err
+	}
+
+	timestamped := proto.Clone(attestation).(*pb.Attestation)
+	timestamped.VerificationMaterial.Rfc3161Timestamps = append(timestamped.VerificationMaterial.Rfc3161Timestamps, resp)
+	return timestamped, nil
+}
+
+// checkTimestamp ensures the timestamp response covers the signature.
+func checkTimestamp(resp, sig []byte) error {
+	ts, err := timestamp.ParseResponse(resp)
+	if err != nil {
+		return fmt.Errorf("parsing timestamp response: %w", err)
+	}
+	digest := sha256.Sum256(sig)
+	if ts.HashAlgorithm != crypto.SHA256 || !bytes.Equal(ts.HashedMessage, digest[:]) {
+		return fmt.Errorf("timestamp does not cover the attestation signature")
+	}
+	return nil
+}
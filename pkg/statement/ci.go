@@ -0,0 +1,228 @@
+package statement
+
+import (
+	"encoding/json"
+	"fmt"
+
+	slsa "github.com/in-toto/attestation/go/predicates/provenance/v1"
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// Build types of the provenance generated for the supported CI platforms.
+const (
+	GitHubBuildType = "https://actions.github.io/buildtypes/workflow/v1"
+	GitLabBuildType = "https://gitlab.com/gitlab-org/gitlab-runner/-/blob/v1/PROVENANCE.md"
+)
+
+// CIEnvironment describes the CI build producing a distribution, as read
+// from the environment variables of the CI platform.
+type CIEnvironment struct {
+	// Platform is the CI platform, eg github or gitlab.
+	Platform string
+
+	// BuilderID identifies the build platform in the provenance.
+	BuilderID string
+
+	// BuildType is the URI of the provenance build type.
+	BuildType string
+
+	// Repository is the URL of the source repository.
+	Repository string
+
+	// Ref is the git ref built, eg refs/tags/v1.0.
+	Ref string
+
+	// Commit is the git commit built.
+	Commit string
+
+	// InvocationID identifies the build run, eg the URL of the run.
+	InvocationID string
+
+	// ExternalParameters and InternalParameters are recorded as is in
+	// the build definition.
+	ExternalParameters map[string]any
+	InternalParameters map[string]any
+}
+
+// DetectCI reads the CI environment from the variables returned by getenv,
+// usually os.Getenv. GitHub Actions and GitLab CI are supported.
+func DetectCI(getenv func(string) string) (*CIEnvironment, error) {
+	switch {
+	case getenv("GITHUB_ACTIONS") == "true":
+		return gitHubEnvironment(getenv)
+	case getenv("GITLAB_CI") == "true":
+		return gitLabEnvironment(getenv)
+	default:
+		return nil, fmt.Errorf("no supported CI environment detected")
+	}
+}
+
+// gitHubEnvironment reads the environment of a GitHub Actions workflow.
+func gitHubEnvironment(getenv func(string) string) (*CIEnvironment, error) {
+	server, repo, runID := getenv("GITHUB_SERVER_URL"), getenv("GITHUB_REPOSITORY"), getenv("GITHUB_RUN_ID")
+	if server == "" || repo == "" || runID == "" {
+		return nil, fmt.Errorf("incomplete GitHub Actions environment")
+	}
+	attempt := getenv("GITHUB_RUN_ATTEMPT")
+	if attempt == "" {
+		attempt = "1"
+	}
+	runner := getenv("RUNNER_ENVIRONMENT")
+	if runner == "" {
+		runner = "github-hosted"
+	}
+
+	return &CIEnvironment{
+		Platform:     "github",
+		BuilderID:    server + "/actions/runner/" + runner,
+		BuildType:    GitHubBuildType,
+		Repository:   server + "/" + repo,
+		Ref:          getenv("GITHUB_REF"),
+		Commit:       getenv("GITHUB_SHA"),
+		InvocationID: server + "/" + repo + "/actions/runs/" + runID + "/attempts/" + attempt,
+		ExternalParameters: map[string]any{
+			"workflow": map[string]any{
+				"ref":        getenv("GITHUB_REF"),
+				"repository": server + "/" + repo,
+				"path":       getenv("GITHUB_WORKFLOW_REF"),
+			},
+		},
+		InternalParameters: map[string]any{
+			"github": map[string]any{
+				"event_name":          getenv("GITHUB_EVENT_NAME"),
+				"repository_id":       getenv("GITHUB_REPOSITORY_ID"),
+				"repository_owner_id": getenv("GITHUB_REPOSITORY_OWNER_ID"),
+				"runner_environment":  runner,
+			},
+		},
+	}, nil
+}
+
+// gitLabEnvironment reads the environment of a GitLab CI job.
+func gitLabEnvironment(getenv func(string) string) (*CIEnvironment, error) {
+	server, project, jobID := getenv("CI_SERVER_URL"), getenv("CI_PROJECT_PATH"), getenv("CI_JOB_ID")
+	if server == "" || project == "" || jobID == "" {
+		return nil, fmt.Errorf("incomplete GitLab CI environment")
+	}
+	ref := getenv("CI_COMMIT_REF_NAME")
+	if getenv("CI_COMMIT_TAG") != "" {
+		ref = "refs/tags/" + getenv("CI_COMMIT_TAG")
+	} else if ref != "" {
+		ref = "refs/heads/" + ref
+	}
+
+	return &CIEnvironment{
+		Platform:     "gitlab",
+		BuilderID:    server + "/" + project + "/-/runners/" + getenv("CI_RUNNER_ID"),
+		BuildType:    GitLabBuildType,
+		Repository:   server + "/" + project,
+		Ref:          ref,
+		Commit:       getenv("CI_COMMIT_SHA"),
+		InvocationID: server + "/" + project + "/-/jobs/" + jobID,
+		ExternalParameters: map[string]any{
+			"source":         server + "/" + project,
+			"entryPoint":     getenv("CI_JOB_NAME"),
+			"ref":            ref,
+			"configPath":     getenv("CI_CONFIG_PATH"),
+			"pipelineSource": getenv("CI_PIPELINE_SOURCE"),
+		},
+		InternalParameters: map[string]any{
+			"gitlab": map[string]any{
+				"pipeline_id": getenv("CI_PIPELINE_ID"),
+				"project_id":  getenv("CI_PROJECT_ID"),
+				"runner_id":   getenv("CI_RUNNER_ID"),
+			},
+		},
+	}, nil
+}
+
+// Provenance returns the SLSA v1 provenance predicate of the build.
+func (e *CIEnvironment) Provenance() (*slsa.Provenance, error) {
+	external, err := structpb.NewStruct(e.ExternalParameters)
+	if err != nil {
+		return nil, fmt.Errorf("encoding external parameters: %w", err)
+	}
+	internal, err := structpb.NewStruct(e.InternalParameters)
+	if err != nil {
+		return nil, fmt.Errorf("encoding internal parameters: %w", err)
+	}
+
+	def := &slsa.BuildDefinition{
+		BuildType:          e.BuildType,
+		ExternalParameters: external,
+		InternalParameters: internal,
+	}
+	if e.Repository != "" && e.Commit != "" {
+		uri := "git+" + e.Repository
+		if e.Ref != "" {
+			uri += "@" + e.Ref
+		}
+		def.ResolvedDependencies = []*intoto.ResourceDescriptor{{
+			Uri:    uri,
+			Digest: map[string]string{"gitCommit": e.Commit},
+		}}
+	}
+
+	return &slsa.Provenance{
+		BuildDefinition: def,
+		RunDetails: &slsa.RunDetails{
+			Builder:  &slsa.Builder{Id: e.BuilderID},
+			Metadata: &slsa.BuildMetadata{InvocationId: e.InvocationID},
+		},
+	}, nil
+}
+
+// ProvenanceStatement returns the JSON of an in-toto statement carrying the
+// SLSA v1 provenance predicate about the subjects.
+func ProvenanceStatement(provenance *slsa.Provenance, subjects ...*intoto.ResourceDescriptor) ([]byte, error) {
+	if len(subjects) == 0 {
+		return nil, fmt.Errorf("at least one subject is required")
+	}
+	predicate, err := protojson.Marshal(provenance)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling provenance: %w", err)
+	}
+	return marshalStatement(SLSAProvenancePredicateType, json.RawMessage(predicate), subjects)
+}
+
+// PublishStatement returns the JSON of a PyPI publish statement about the
+// subjects, as produced by PyPI trusted publishing.
+func PublishStatement(subjects ...*intoto.ResourceDescriptor) ([]byte, error) {
+	if len(subjects) == 0 {
+		return nil, fmt.Errorf("at least one subject is required")
+	}
+	return marshalStatement(PublishPredicateType, nil, subjects)
+}
+
+// jsonStatement is the JSON form of a statement. It is encoded with
+// encoding/json as protojson output is not stable.
+type jsonStatement struct {
+	Type          string        `json:"_type"`
+	Subject       []jsonSubject `json:"subject"`
+	PredicateType string        `json:"predicateType"`
+	Predicate     any           `json:"predicate"`
+}
+
+type jsonSubject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// marshalStatement encodes a statement with compact, stable JSON.
+func marshalStatement(predicateType string, predicate any, subjects []*intoto.ResourceDescriptor) ([]byte, error) {
+	s := jsonStatement{
+		Type:          intoto.StatementTypeUri,
+		PredicateType: predicateType,
+		Predicate:     predicate,
+	}
+	for _, subject := range subjects {
+		s.Subject = append(s.Subject, jsonSubject{Name: subject.GetName(), Digest: subject.GetDigest()})
+	}
+	data, err := json.Marshal(s)
+	if err != nil {
+		return nil, fmt.Errorf("marshaling statement: %w", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,46 @@
+package statement
+
+import (
+	"encoding/json"
+	"fmt"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// PublishPredicateType is the predicate type of PyPI publish attestations.
+const PublishPredicateType = "https://docs.pypi.org/attestations/publish/v1"
+
+// PublishPredicate is the predicate of a PyPI publish attestation. The
+// specification defines no fields, the predicate is null or an empty
+// object. Fields added by later revisions are kept in Extra.
+type PublishPredicate struct {
+	// Extra holds any fields present in the predicate.
+	Extra map[string]any
+}
+
+// IsPublish reports whether s is a PyPI publish attestation statement.
+func IsPublish(s *intoto.Statement) bool {
+	return s.GetPredicateType() == PublishPredicateType
+}
+
+// DecodePublishPredicate decodes the predicate of a PyPI publish
+// attestation statement. It fails if the statement is of another type.
+func DecodePublishPredicate(s *intoto.Statement) (*PublishPredicate, error) {
+	if !IsPublish(s) {
+		return nil, fmt.Errorf("predicate type is %q, not %q", s.GetPredicateType(), PublishPredicateType)
+	}
+
+	p := &PublishPredicate{}
+	if len(s.GetPredicate().GetFields()) == 0 {
+		return p, nil
+	}
+	data, err := protojson.Marshal(s.GetPredicate())
+	if err != nil {
+		return nil, fmt.Errorf("marshaling predicate: %w", err)
+	}
+	if err := json.Unmarshal(data, &p.Extra); err != nil {
+		return nil, fmt.Errorf("decoding publish predicate: %w", err)
+	}
+	return p, nil
+}
@@ -0,0 +1,95 @@
+package statement
+
+import (
+	"fmt"
+	"time"
+
+	slsa "github.com/in-toto/attestation/go/predicates/provenance/v1"
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// SLSAProvenancePredicateType is the predicate type of SLSA v1 provenance.
+const SLSAProvenancePredicateType = "https://slsa.dev/provenance/v1"
+
+// SLSAProvenance holds the commonly used fields of a SLSA v1 provenance
+// predicate.
+type SLSAProvenance struct {
+	// BuilderID identifies the platform that ran the build.
+	BuilderID string
+
+	// BuildType is the URI of the template describing the build.
+	BuildType string
+
+	// ExternalParameters are the build parameters under the control of
+	// the invoker, eg the workflow and ref.
+	ExternalParameters map[string]any
+
+	// InternalParameters are the build parameters set by the builder.
+	InternalParameters map[string]any
+
+	// ResolvedDependencies are the artifacts fetched during the build,
+	// such as the source repository at its commit.
+	ResolvedDependencies []*intoto.ResourceDescriptor
+
+	// InvocationID identifies the build run, eg a workflow run URL.
+	InvocationID string
+
+	// StartedOn and FinishedOn are the build times, zero when unset.
+	StartedOn  time.Time
+	FinishedOn time.Time
+
+	// Predicate is the full decoded predicate.
+	Predicate *slsa.Provenance
+}
+
+// IsSLSAProvenance reports whether s carries SLSA v1 provenance.
+func IsSLSAProvenance(s *intoto.Statement) bool {
+	return s.GetPredicateType() == SLSAProvenancePredicateType
+}
+
+// DecodeSLSAProvenance decodes the SLSA v1 provenance predicate of a
+// statement. It fails if the statement is of another type or the
+// predicate has no builder ID or build type.
+func DecodeSLSAProvenance(s *intoto.Statement) (*SLSAProvenance, error) {
+	if !IsSLSAProvenance(s) {
+		return nil, fmt.Errorf("predicate type is %q, not %q", s.GetPredicateType(), SLSAProvenancePredicateType)
+	}
+	if s.GetPredicate() == nil {
+		return nil, fmt.Errorf("provenance predicate is missing")
+	}
+
+	data, err := protojson.Marshal(s.GetPredicate())
+	if err != nil {
+		return nil, fmt.Errorf("marshaling predicate: %w", err)
+	}
+	p := &slsa.Provenance{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("decoding provenance predicate: %w", err)
+	}
+
+	def, run := p.GetBuildDefinition(), p.GetRunDetails()
+	if def.GetBuildType() == "" {
+		return nil, fmt.Errorf("provenance build type is missing")
+	}
+	if run.GetBuilder().GetId() == "" {
+		return nil, fmt.Errorf("provenance builder id is missing")
+	}
+
+	prov := &SLSAProvenance{
+		BuilderID:            run.GetBuilder().GetId(),
+		BuildType:            def.GetBuildType(),
+		ExternalParameters:   def.GetExternalParameters().AsMap(),
+		InternalParameters:   def.GetInternalParameters().AsMap(),
+		ResolvedDependencies: def.GetResolvedDependencies(),
+		InvocationID:         run.GetMetadata().GetInvocationId(),
+		Predicate:            p,
+	}
+	if t := run.GetMetadata().GetStartedOn(); t != nil {
+		prov.StartedOn = t.AsTime()
+	}
+	if t := run.GetMetadata().GetFinishedOn(); t != nil {
+		prov.FinishedOn = t.AsTime()
+	}
+	return prov, nil
+}
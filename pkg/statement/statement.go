@@ -0,0 +1,51 @@
+// Package statement parses the in-toto statements signed in PEP 740
+// attestations.
+package statement
+
+import (
+	"fmt"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// ParseStatement parses the JSON of an in-toto v1 statement, as found in
+// the envelope of an attestation, and checks its required fields.
+func ParseStatement(data []byte) (*intoto.Statement, error) {
+	if len(data) == 0 {
+		return nil, fmt.Errorf("statement is empty")
+	}
+
+	s := &intoto.Statement{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("parsing statement: %w", err)
+	}
+	if err := Validate(s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Validate checks the required fields of an in-toto v1 statement. Unlike
+// the in-toto library validation, the predicate is not required: PyPI
+// publish attestations have none.
+func Validate(s *intoto.Statement) error {
+	if s.GetType() != intoto.StatementTypeUri {
+		return fmt.Errorf("wrong statement type %q", s.GetType())
+	}
+	if s.GetPredicateType() == "" {
+		return fmt.Errorf("predicate type missing")
+	}
+	if len(s.GetSubject()) == 0 {
+		return fmt.Errorf("no subjects")
+	}
+	for i, subject := range s.GetSubject() {
+		if len(subject.GetDigest()) == 0 {
+			return fmt.Errorf("subject %d: no digests", i)
+		}
+		if err := subject.Validate(); err != nil {
+			return fmt.Errorf("subject %d: %w", i, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+package statement
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	intoto "github.com/in-toto/attestation/go/v1"
+)
+
+const digest = "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"
+
+func TestParseStatement(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		data    string
+		mustErr bool
+	}{
+		{"publish", `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"pkg-1.0.tar.gz","digest":{"sha256":"e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"}}],"predicateType":"https://docs.pypi.org/attestations/publish/v1","predicate":null}`, false},
+		{"predicate", `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"pkg-1.0.tar.gz","digest":{"sha256":"e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"}}],"predicateType":"https://slsa.dev/provenance/v1","predicate":{"buildDefinition":{}}}`, false},
+		{"empty", ``, true},
+		{"not json", `not json`, true},
+		{"wrong type", `{"_type":"https://in-toto.io/Statement/v0.1","subject":[{"name":"a","digest":{"sha256":"00"}}],"predicateType":"x"}`, true},
+		{"no subjects", `{"_type":"https://in-toto.io/Statement/v1","subject":[],"predicateType":"x"}`, true},
+		{"no predicate type", `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"a","digest":{"sha256":"00"}}]}`, true},
+		{"subject without digest", `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"a"}],"predicateType":"x"}`, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := ParseStatement([]byte(tc.data))
+			if tc.mustErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(s.GetSubject()) != 1 || s.GetSubject()[0].GetDigest()["sha256"] != digest {
+				t.Errorf("Unexpected subjects: %v", s.GetSubject())
+			}
+		})
+	}
+}
+
+func TestDecodePublishPredicate(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		data    string
+		extra   int
+		mustErr bool
+	}{
+		{"null", `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"a","digest":{"sha256":"` + digest + `"}}],"predicateType":"` + PublishPredicateType + `","predicate":null}`, 0, false},
+		{"empty", `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"a","digest":{"sha256":"` + digest + `"}}],"predicateType":"` + PublishPredicateType + `","predicate":{}}`, 0, false},
+		{"extra", `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"a","digest":{"sha256":"` + digest + `"}}],"predicateType":"` + PublishPredicateType + `","predicate":{"future":true}}`, 1, false},
+		{"other type", `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"a","digest":{"sha256":"` + digest + `"}}],"predicateType":"https://slsa.dev/provenance/v1","predicate":{}}`, 0, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := ParseStatement([]byte(tc.data))
+			if err != nil {
+				t.Fatalf("Failed to parse statement: %v", err)
+			}
+			p, err := DecodePublishPredicate(s)
+			if tc.mustErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(p.Extra) != tc.extra {
+				t.Errorf("Expected %d extra fields, got %d", tc.extra, len(p.Extra))
+			}
+		})
+	}
+}
+
+func TestDecodeSLSAProvenance(t *testing.T) {
+	const predicate = `{
+		"buildDefinition": {
+			"buildType": "https://actions.github.io/buildtypes/workflow/v1",
+			"externalParameters": {"workflow": {"ref": "refs/tags/v1.0", "path": ".github/workflows/release.yml"}},
+			"internalParameters": {"github": {"event_name": "push"}},
+			"resolvedDependencies": [{"uri": "git+https://github.com/example/pkg@refs/tags/v1.0", "digest": {"gitCommit": "0123456789abcdef0123456789abcdef01234567"}}]
+		},
+		"runDetails": {
+			"builder": {"id": "https://github.com/actions/runner/github-hosted"},
+			"metadata": {"invocationId": "https://github.com/example/pkg/actions/runs/1/attempts/1", "startedOn": "2025-01-02T03:04:05Z"}
+		}
+	}`
+	statement := func(predicateType, predicate string) string {
+		return `{"_type":"https://in-toto.io/Statement/v1","subject":[{"name":"a","digest":{"sha256":"` + digest + `"}}],"predicateType":"` + predicateType + `","predicate":` + predicate + `}`
+	}
+
+	s, err := ParseStatement([]byte(statement(SLSAProvenancePredicateType, predicate)))
+	if err != nil {
+		t.Fatalf("Failed to parse statement: %v", err)
+	}
+	p, err := DecodeSLSAProvenance(s)
+	if err != nil {
+		t.Fatalf("Failed to decode provenance: %v", err)
+	}
+	if p.BuilderID != "https://github.com/actions/runner/github-hosted" {
+		t.Errorf("Unexpected builder id: %s", p.BuilderID)
+	}
+	if p.BuildType != "https://actions.github.io/buildtypes/workflow/v1" {
+		t.Errorf("Unexpected build type: %s", p.BuildType)
+	}
+	if wf, ok := p.ExternalParameters["workflow"].(map[string]any); !ok || wf["ref"] != "refs/tags/v1.0" {
+		t.Errorf("Unexpected external parameters: %v", p.ExternalParameters)
+	}
+	if len(p.ResolvedDependencies) != 1 || p.ResolvedDependencies[0].GetDigest()["gitCommit"] == "" {
+		t.Errorf("Unexpected resolved dependencies: %v", p.ResolvedDependencies)
+	}
+	if p.InvocationID == "" || p.StartedOn.IsZero() || !p.FinishedOn.IsZero() {
+		t.Errorf("Unexpected run metadata: %q %v %v", p.InvocationID, p.StartedOn, p.FinishedOn)
+	}
+
+	for name, data := range map[string]string{
+		"other type":   statement(PublishPredicateType, predicate),
+		"no predicate": statement(SLSAProvenancePredicateType, "null"),
+		"no builder":   statement(SLSAProvenancePredicateType, `{"buildDefinition":{"buildType":"x"}}`),
+	} {
+		s, err := ParseStatement([]byte(data))
+		if err != nil {
+			t.Fatalf("%s: failed to parse statement: %v", name, err)
+		}
+		if _, err := DecodeSLSAProvenance(s); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestSubjectFromFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "pkg-1.0.tar.gz")
+	if err := os.WriteFile(path, []byte("hello"), 0o600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	subject, err := SubjectFromFile(path, WithAlgorithms(SHA512, Blake2b))
+	if err != nil {
+		t.Fatalf("Failed to build subject: %v", err)
+	}
+	if subject.GetName() != "pkg-1.0.tar.gz" {
+		t.Errorf("Unexpected subject name: %s", subject.GetName())
+	}
+	if subject.GetDigest()[SHA256] != "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824" {
+		t.Errorf("Unexpected sha256 digest: %s", subject.GetDigest()[SHA256])
+	}
+	if len(subject.GetDigest()[SHA512]) != 128 || len(subject.GetDigest()[Blake2b]) != 128 {
+		t.Errorf("Unexpected digests: %v", subject.GetDigest())
+	}
+	if err := subject.Validate(); err != nil {
+		t.Errorf("Subject does not validate: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := SubjectFromFileContext(ctx, path); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected hashing to be canceled, got %v", err)
+	}
+
+	if _, err := SubjectFromReader("pkg-1.0.tar.gz", strings.NewReader(""), WithAlgorithms("md5")); err == nil {
+		t.Error("Expected error for an unsupported algorithm")
+	}
+	for _, name := range []string{"pkg.tar.gz", "pkg-1.0.exe", "dir/pkg-1.0.tar.gz", "-1.0.zip"} {
+		if _, err := SubjectFromReader(name, strings.NewReader("")); err == nil {
+			t.Errorf("Expected error for %q", name)
+		}
+	}
+}
+
+func TestDetectCI(t *testing.T) {
+	env := func(vars map[string]string) func(string) string {
+		return func(k string) string { return vars[k] }
+	}
+
+	gh, err := DetectCI(env(map[string]string{
+		"GITHUB_ACTIONS":      "true",
+		"GITHUB_SERVER_URL":   "https://github.com",
+		"GITHUB_REPOSITORY":   "example/pkg",
+		"GITHUB_REF":          "refs/tags/v1.0",
+		"GITHUB_SHA":          "0123456789abcdef0123456789abcdef01234567",
+		"GITHUB_RUN_ID":       "42",
+		"GITHUB_RUN_ATTEMPT":  "2",
+		"GITHUB_WORKFLOW_REF": "example/pkg/.github/workflows/release.yml@refs/tags/v1.0",
+		"RUNNER_ENVIRONMENT":  "self-hosted",
+	}))
+	if err != nil {
+		t.Fatalf("Failed to detect GitHub Actions: %v", err)
+	}
+	if gh.BuilderID != "https://github.com/actions/runner/self-hosted" || gh.InvocationID != "https://github.com/example/pkg/actions/runs/42/attempts/2" {
+		t.Errorf("Unexpected GitHub environment: %+v", gh)
+	}
+
+	gl, err := DetectCI(env(map[string]string{
+		"GITLAB_CI":       "true",
+		"CI_SERVER_URL":   "https://gitlab.com",
+		"CI_PROJECT_PATH": "example/pkg",
+		"CI_JOB_ID":       "7",
+		"CI_COMMIT_TAG":   "v1.0",
+		"CI_COMMIT_SHA":   "0123456789abcdef0123456789abcdef01234567",
+		"CI_RUNNER_ID":    "3",
+	}))
+	if err != nil {
+		t.Fatalf("Failed to detect GitLab CI: %v", err)
+	}
+	if gl.Ref != "refs/tags/v1.0" || gl.InvocationID != "https://gitlab.com/example/pkg/-/jobs/7" {
+		t.Errorf("Unexpected GitLab environment: %+v", gl)
+	}
+
+	if _, err := DetectCI(env(nil)); err == nil {
+		t.Error("Expected error outside of CI")
+	}
+	if _, err := DetectCI(env(map[string]string{"GITHUB_ACTIONS": "true"})); err == nil {
+		t.Error("Expected error for an incomplete environment")
+	}
+
+	provenance, err := gh.Provenance()
+	if err != nil {
+		t.Fatalf("Failed to build provenance: %v", err)
+	}
+	data, err := ProvenanceStatement(provenance, &intoto.ResourceDescriptor{Name: "pkg-1.0.tar.gz", Digest: map[string]string{"sha256": digest}})
+	if err != nil {
+		t.Fatalf("Failed to build statement: %v", err)
+	}
+	s, err := ParseStatement(data)
+	if err != nil {
+		t.Fatalf("Failed to parse statement: %v", err)
+	}
+	p, err := DecodeSLSAProvenance(s)
+	if err != nil {
+		t.Fatalf("Failed to decode provenance: %v", err)
+	}
+	if p.BuildType != GitHubBuildType || len(p.ResolvedDependencies) != 1 || p.ResolvedDependencies[0].GetUri() != "git+https://github.com/example/pkg@refs/tags/v1.0" {
+		t.Errorf("Unexpected provenance: %+v", p)
+	}
+
+	if _, err := PublishStatement(); err == nil {
+		t.Error("Expected error without subjects")
+	}
+}
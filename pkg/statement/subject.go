@@ -0,0 +1,115 @@
+package statement
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/carabiner-dev/pypi-attestations/internal/ctxio"
+	"github.com/carabiner-dev/pypi-attestations/pkg/distfile"
+	intoto "github.com/in-toto/attestation/go/v1"
+	"golang.org/x/crypto/blake2b"
+)
+
+// Digest algorithms supported in subjects, named as in the in-toto digest
+// set.
+const (
+	SHA256  = "sha256"
+	SHA512  = "sha512"
+	Blake2b = "blake2b"
+)
+
+// SubjectOptions configure subject construction.
+type SubjectOptions struct {
+	// Algorithms are the digests computed for the subject. sha256 is
+	// always computed as PEP 740 requires it.
+	Algorithms []string
+}
+
+// SubjectOption is a function that configures subject construction.
+type SubjectOption func(*SubjectOptions)
+
+// WithAlgorithms computes additional digests, eg sha512 or blake2b.
+func WithAlgorithms(algorithms ...string) SubjectOption {
+	return func(o *SubjectOptions) {
+		o.Algorithms = append(o.Algorithms, algorithms...)
+	}
+}
+
+// newHash returns the hash of a supported digest algorithm.
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case SHA256:
+		return sha256.New(), nil
+	case SHA512:
+		return sha512.New(), nil
+	case Blake2b:
+		return blake2b.New512(nil)
+	default:
+		return nil, fmt.Errorf("unsupported digest algorithm %q", algorithm)
+	}
+}
+
+// SubjectFromFile returns the in-toto subject of a distribution file. The
+// subject name is the base name of the file, which must be a valid wheel or
+// sdist filename, see distfile.Parse.
+func SubjectFromFile(path string, funcs ...SubjectOption) (*intoto.ResourceDescriptor, error) {
+	return SubjectFromFileContext(context.Background(), path, funcs...)
+}
+
+// SubjectFromFileContext is SubjectFromFile stopping to hash the file when
+// ctx is done.
+func SubjectFromFileContext(ctx context.Context, path string, funcs ...SubjectOption) (*intoto.ResourceDescriptor, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening distribution file: %w", err)
+	}
+	defer f.Close()
+
+	return SubjectFromReader(filepath.Base(path), ctxio.NewReader(ctx, f), funcs...)
+}
+
+// SubjectFromReader returns the in-toto subject of the distribution file
+// named name with the contents read from r.
+func SubjectFromReader(name string, r io.Reader, funcs ...SubjectOption) (*intoto.ResourceDescriptor, error) {
+	opts := SubjectOptions{}
+	for _, fn := range funcs {
+		fn(&opts)
+	}
+
+	if _, err := distfile.Parse(name); err != nil {
+		return nil, err
+	}
+
+	hashes := map[string]hash.Hash{SHA256: sha256.New()}
+	for _, algorithm := range opts.Algorithms {
+		if _, ok := hashes[algorithm]; ok {
+			continue
+		}
+		h, err := newHash(algorithm)
+		if err != nil {
+			return nil, err
+		}
+		hashes[algorithm] = h
+	}
+
+	writers := make([]io.Writer, 0, len(hashes))
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+	if _, err := io.Copy(io.MultiWriter(writers...), r); err != nil {
+		return nil, fmt.Errorf("reading distribution file: %w", err)
+	}
+
+	digest := make(map[string]string, len(hashes))
+	for algorithm, h := range hashes {
+		digest[algorithm] = hex.EncodeToString(h.Sum(nil))
+	}
+	return &intoto.ResourceDescriptor{Name: name, Digest: digest}, nil
+}
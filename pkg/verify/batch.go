@@ -0,0 +1,108 @@
+package verify
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// Request is an attestation to verify as part of a batch. Either set the
+// Attestation and the Digest of its distribution, or the paths to the
+// attestation and distribution files.
+type Request struct {
+	// Attestation is the parsed attestation to verify.
+	Attestation *pb.Attestation
+
+	// Digest is the sha256 digest of the distribution file.
+	Digest []byte
+
+	// AttestationPath is the path to a PEP 740 attestation file.
+	AttestationPath string
+
+	// DistPath is the path to the distribution file.
+	DistPath string
+}
+
+// Result is the outcome of verifying a Request.
+type Result struct {
+	// Request is the verified request.
+	Request Request
+
+	// Verification holds the verification result when it passed.
+	Verification *VerificationResult
+
+	// Error is the reason verification failed, nil if it passed.
+	Error error
+}
+
+// Passed returns true if the request verified successfully.
+func (r *Result) Passed() bool {
+	return r.Error == nil
+}
+
+// VerifyAll verifies a batch of requests using a verifier configured with
+// funcs. See Verifier.VerifyAll for details.
+func VerifyAll(ctx context.Context, requests []Request, funcs ...FnOption) ([]Result, error) {
+	v, err := New(funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return v.VerifyAll(ctx, requests)
+}
+
+// VerifyAll verifies the requests concurrently using Options.Workers
+// workers. It does not stop on the first failure: the returned slice has
+// a result for every request, in the same order, recording whether it
+// passed and why it failed. The error is only set when the context is
+// canceled before all requests are processed.
+func (v *Verifier) VerifyAll(ctx context.Context, requests []Request) ([]Result, error) {
+	results := make([]Result, len(requests))
+
+	workers := v.Options.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = v.verifyRequest(ctx, requests[i])
+			}
+		}()
+	}
+
+	var err error
+	for i := range requests {
+		if err = ctx.Err(); err != nil {
+			for j := i; j < len(requests); j++ {
+				results[j] = Result{Request: requests[j], Error: err}
+			}
+			break
+		}
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results, err
+}
+
+// verifyRequest verifies a single batch request.
+func (v *Verifier) verifyRequest(ctx context.Context, req Request) Result {
+	result := Result{Request: req}
+	switch {
+	case req.Attestation != nil:
+		result.Verification, result.Error = v.VerifyDigest(ctx, req.Attestation, req.Digest)
+	case req.AttestationPath != "" && req.DistPath != "":
+		result.Verification, result.Error = v.VerifyFile(ctx, req.AttestationPath, req.DistPath)
+	default:
+		result.Error = fmt.Errorf("request must specify an attestation and digest or attestation and distribution paths")
+	}
+	return result
+}
@@ -0,0 +1,249 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"google.golang.org/protobuf/proto"
+)
+
+// Cache stores successful verification results so repeated verifications
+// of the same attestation and digest can be skipped. Keys are derived from
+// the attestation, the distribution digest, the fingerprint of the trusted
+// root, the verifier options and the publisher environment, so changing
+// any of them invalidates the cached result.
+//
+// Revocation checkers and policy engines are not part of the key, they run
+// again on every cached result.
+type Cache interface {
+	// Get returns the cached result for key, if any and not expired.
+	Get(key string) (*VerificationResult, bool)
+
+	// Set stores the result under key.
+	Set(key string, result *VerificationResult) error
+}
+
+// cacheEntry is the stored form of a cached result.
+type cacheEntry struct {
+	Expires time.Time       `json:"expires"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// newCacheEntry encodes the result with its expiration time.
+func newCacheEntry(result *VerificationResult, ttl time.Duration) (*cacheEntry, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("encoding result: %w", err)
+	}
+	return &cacheEntry{Expires: time.Now().Add(ttl), Result: data}, nil
+}
+
+// decode returns the result of the entry if it did not expire.
+func (e *cacheEntry) decode() (*VerificationResult, bool) {
+	if time.Now().After(e.Expires) {
+		return nil, false
+	}
+	result := &VerificationResult{}
+	if err := json.Unmarshal(e.Result, result); err != nil {
+		return nil, false
+	}
+	return result, true
+}
+
+// MemoryCache is an in-memory Cache with a fixed time to live.
+type MemoryCache struct {
+	ttl time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+}
+
+// NewMemoryCache returns an in-memory cache holding results for ttl.
+func NewMemoryCache(ttl time.Duration) *MemoryCache {
+	return &MemoryCache{ttl: ttl, entries: map[string]*cacheEntry{}}
+}
+
+// Get returns the cached result for key.
+func (c *MemoryCache) Get(key string) (*VerificationResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	result, ok := e.decode()
+	if !ok {
+		delete(c.entries, key)
+	}
+	return result, ok
+}
+
+// Set stores the result under key.
+func (c *MemoryCache) Set(key string, result *VerificationResult) error {
+	e, err := newCacheEntry(result, c.ttl)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = e
+	return nil
+}
+
+// FileCache is an on-disk Cache storing one JSON file per result.
+type FileCache struct {
+	ttl time.Duration
+	dir string
+}
+
+// NewFileCache returns a cache storing results in dir for ttl. The
+// directory is created if it does not exist.
+func NewFileCache(dir string, ttl time.Duration) (*FileCache, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("creating cache directory: %w", err)
+	}
+	return &FileCache{ttl: ttl, dir: dir}, nil
+}
+
+// Get returns the cached result for key.
+func (c *FileCache) Get(key string) (*VerificationResult, bool) {
+	data, err := os.ReadFile(c.path(key))
+	if err != nil {
+		return nil, false
+	}
+
+	e := &cacheEntry{}
+	if err := json.Unmarshal(data, e); err != nil {
+		return nil, false
+	}
+
+	result, ok := e.decode()
+	if !ok {
+		_ = os.Remove(c.path(key))
+	}
+	return result, ok
+}
+
+// Set stores the result under key.
+func (c *FileCache) Set(key string, result *VerificationResult) error {
+	e, err := newCacheEntry(result, c.ttl)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("encoding cache entry: %w", err)
+	}
+
+	// Write to a temporary file first so concurrent readers never see
+	// partial entries.
+	tmp, err := os.CreateTemp(c.dir, ".entry-*")
+	if err != nil {
+		return fmt.Errorf("creating cache entry: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("writing cache entry: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), c.path(key)); err != nil {
+		return fmt.Errorf("storing cache entry: %w", err)
+	}
+	return nil
+}
+
+// path returns the file holding the entry for key.
+func (c *FileCache) path(key string) string {
+	return filepath.Join(c.dir, key+".json")
+}
+
+// cacheKey derives the cache key of a verification.
+func (v *Verifier) cacheKey(attestation *pb.Attestation, digest []byte, environment string, tm root.TrustedMaterial) (string, error) {
+	att, err := proto.MarshalOptions{Deterministic: true}.Marshal(attestation)
+	if err != nil {
+		return "", fmt.Errorf("encoding attestation: %w", err)
+	}
+
+	fp, err := trustedMaterialFingerprint(tm)
+	if err != nil {
+		return "", err
+	}
+
+	config, err := json.Marshal(struct {
+		Identities             []IdentityPolicy
+		Policy                 *Policy
+		Offline                bool
+		VerificationTime       time.Time
+		RequireSignedTimestamp bool
+		RequireSCT             bool
+		Environment            string
+	}{
+		v.Options.Identities, v.Options.Policy, v.Options.Offline, v.Options.VerificationTime,
+		v.Options.RequireSignedTimestamp, v.Options.RequireSCT, environment,
+	})
+	if err != nil {
+		return "", fmt.Errorf("encoding options: %w", err)
+	}
+
+	h := sha256.New()
+	for _, part := range [][]byte{att, digest, fp, config} {
+		fmt.Fprintf(h, "%d:", len(part))
+		h.Write(part)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// trustedMaterialFingerprint returns a digest identifying the trusted
+// material. Trusted roots are hashed in their JSON form, other material
+// is identified by its certificate authorities and logs.
+func trustedMaterialFingerprint(tm root.TrustedMaterial) ([]byte, error) {
+	h := sha256.New()
+
+	if m, ok := tm.(json.Marshaler); ok {
+		data, err := m.MarshalJSON()
+		if err != nil {
+			return nil, fmt.Errorf("encoding trusted root: %w", err)
+		}
+		// protojson output varies in whitespace between runs
+		var buf bytes.Buffer
+		if err := json.Compact(&buf, data); err != nil {
+			return nil, fmt.Errorf("encoding trusted root: %w", err)
+		}
+		h.Write(buf.Bytes())
+		return h.Sum(nil), nil
+	}
+
+	for _, ca := range tm.FulcioCertificateAuthorities() {
+		if fca, ok := ca.(*root.FulcioCertificateAuthority); ok && fca.Root != nil {
+			h.Write(fca.Root.Raw)
+		}
+	}
+	for _, logs := range []map[string]*root.TransparencyLog{tm.RekorLogs(), tm.CTLogs()} {
+		ids := make([]string, 0, len(logs))
+		for id := range logs {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+		for _, id := range ids {
+			fmt.Fprintln(h, id)
+		}
+	}
+	return h.Sum(nil), nil
+}
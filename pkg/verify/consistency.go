@@ -0,0 +1,152 @@
+package verify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// ErrEntryMismatch is returned when a transparency log entry does not
+// record the envelope embedded in the attestation.
+var ErrEntryMismatch = errors.New("transparency entry does not match the envelope")
+
+// rekorHash is a hash recorded in a Rekor entry body.
+type rekorHash struct {
+	Algorithm string `json:"algorithm"`
+	Value     string `json:"value"`
+}
+
+// rekorV2Hash is a hash recorded in a Rekor v2 entry body, the digest is
+// base64 encoded.
+type rekorV2Hash struct {
+	Algorithm string `json:"algorithm"`
+	Digest    []byte `json:"digest"`
+}
+
+// rekorV2DSSE is the spec of Rekor v2 dsse entries.
+type rekorV2DSSE struct {
+	PayloadHash *rekorV2Hash `json:"payloadHash"`
+	Signatures  []struct {
+		Content []byte `json:"content"`
+	} `json:"signatures"`
+}
+
+// rekorDSSEBody is the subset of dsse and intoto Rekor entry bodies
+// recording the envelope.
+type rekorDSSEBody struct {
+	Kind string `json:"kind"`
+	Spec struct {
+		// Rekor v2 dsse entries.
+		DSSEV002 *rekorV2DSSE `json:"dsseV002"`
+
+		// dsse entries.
+		PayloadHash *rekorHash `json:"payloadHash"`
+		Signatures  []struct {
+			Signature string `json:"signature"`
+		} `json:"signatures"`
+
+		// intoto entries.
+		Content *struct {
+			PayloadHash *rekorHash `json:"payloadHash"`
+		} `json:"content"`
+	} `json:"spec"`
+}
+
+// CheckEntryConsistency checks that the transparency log entries of the
+// attestation record its envelope: the payload hash of each entry must be
+// the sha256 of the statement and, for dsse entries, the envelope signature
+// must be among the logged signatures. Both Rekor v1 and v2 bodies are
+// supported. It catches attestations spliced from
+// different envelopes and log entries without any network access or
+// signature verification, which is still required to trust the attestation.
+func CheckEntryConsistency(attestation *pb.Attestation) error {
+	if attestation.GetEnvelope() == nil {
+		return fmt.Errorf("attestation has no envelope")
+	}
+	entries := attestation.GetVerificationMaterial().GetTransparencyEntries()
+	if len(entries) == 0 {
+		return convert.ErrNoTransparencyEntries
+	}
+
+	payloadHash := sha256.Sum256(attestation.GetEnvelope().GetStatement())
+	for i, s := range entries {
+		entry, err := convert.TransparencyEntryFromStruct(s)
+		if err != nil {
+			return fmt.Errorf("transparency entry %d: %w", i, err)
+		}
+
+		body := rekorDSSEBody{}
+		if err := json.Unmarshal(entry.GetCanonicalizedBody(), &body); err != nil {
+			return fmt.Errorf("transparency entry %d: parsing body: %w", i, err)
+		}
+
+		var logged *rekorHash
+		switch body.Kind {
+		case "dsse":
+			if body.Spec.DSSEV002 != nil {
+				if err := checkDSSEV002(body.Spec.DSSEV002, payloadHash[:], attestation.GetEnvelope().GetSignature()); err != nil {
+					return fmt.Errorf("transparency entry %d: %w", i, err)
+				}
+				continue
+			}
+			logged = body.Spec.PayloadHash
+			if err := checkLoggedSignature(body, attestation.GetEnvelope().GetSignature()); err != nil {
+				return fmt.Errorf("transparency entry %d: %w", i, err)
+			}
+		case "intoto":
+			if body.Spec.Content != nil {
+				logged = body.Spec.Content.PayloadHash
+			}
+		default:
+			return fmt.Errorf("transparency entry %d: unsupported entry kind %q", i, body.Kind)
+		}
+
+		if logged == nil || !strings.EqualFold(logged.Algorithm, "sha256") {
+			return fmt.Errorf("transparency entry %d: no sha256 payload hash", i)
+		}
+		if !strings.EqualFold(logged.Value, hex.EncodeToString(payloadHash[:])) {
+			return fmt.Errorf("transparency entry %d: %w: payload hash %s, statement hash %x", i, ErrEntryMismatch, logged.Value, payloadHash)
+		}
+	}
+	return nil
+}
+
+// checkLoggedSignature fails if sig is not among the signatures of a dsse
+// entry body.
+func checkLoggedSignature(body rekorDSSEBody, sig []byte) error {
+	for _, s := range body.Spec.Signatures {
+		logged, err := base64.StdEncoding.DecodeString(s.Signature)
+		if err != nil {
+			return fmt.Errorf("decoding logged signature: %w", err)
+		}
+		if bytes.Equal(logged, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: envelope signature is not logged", ErrEntryMismatch)
+}
+
+// checkDSSEV002 checks that a Rekor v2 dsse entry records the payload hash
+// and the envelope signature.
+func checkDSSEV002(spec *rekorV2DSSE, payloadHash, sig []byte) error {
+	if spec.PayloadHash == nil || spec.PayloadHash.Algorithm != "SHA2_256" {
+		return fmt.Errorf("no SHA2_256 payload hash")
+	}
+	if !bytes.Equal(spec.PayloadHash.Digest, payloadHash) {
+		return fmt.Errorf("%w: payload hash %x, statement hash %x", ErrEntryMismatch, spec.PayloadHash.Digest, payloadHash)
+	}
+	for _, s := range spec.Signatures {
+		if bytes.Equal(s.Content, sig) {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: envelope signature is not logged", ErrEntryMismatch)
+}
@@ -0,0 +1,90 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// ErrCustodyBroken is returned when the attestations of a chain of custody
+// do not attest the same statement or were signed out of order.
+var ErrCustodyBroken = errors.New("chain of custody is broken")
+
+// CustodyLink is an attestation in a chain of custody and the identities
+// allowed to have signed it.
+type CustodyLink struct {
+	Attestation *pb.Attestation
+
+	// Identities replaces the identities of the verifier options for
+	// this link. When empty, the verifier identities apply.
+	Identities []IdentityPolicy
+}
+
+// VerifyCustody verifies a chain of custody: the original attestation of a
+// distribution followed by its countersignatures. Every link must verify
+// against the digest with its own identities, attest the same statement
+// bytes as the first link and be logged no earlier than the previous link.
+//
+// The verification results are returned in the order of the links.
+func (v *Verifier) VerifyCustody(ctx context.Context, links []CustodyLink, digest []byte) ([]*VerificationResult, error) {
+	if len(links) == 0 {
+		return nil, fmt.Errorf("chain of custody is empty")
+	}
+	for i, link := range links {
+		if link.Attestation == nil || link.Attestation.GetEnvelope() == nil {
+			return nil, fmt.Errorf("link %d: attestation cannot be nil", i)
+		}
+		if !bytes.Equal(link.Attestation.GetEnvelope().GetStatement(), links[0].Attestation.GetEnvelope().GetStatement()) {
+			return nil, fmt.Errorf("link %d: %w: statement differs from the original", i, ErrCustodyBroken)
+		}
+	}
+
+	tm, err := v.getTrustedMaterial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]*VerificationResult, len(links))
+	var previous time.Time
+	for i, link := range links {
+		opts := v.Options
+		opts.TrustedMaterial = tm
+		if len(link.Identities) > 0 {
+			opts.Identities = link.Identities
+		}
+		lv := &Verifier{Options: opts}
+
+		results[i], err = lv.VerifyDigest(ctx, link.Attestation, digest)
+		if err != nil {
+			return nil, fmt.Errorf("link %d: %w", i, err)
+		}
+		signed, err := earliestTime(results[i])
+		if err != nil {
+			return nil, fmt.Errorf("link %d: %w", i, err)
+		}
+		if signed.Before(previous) {
+			return nil, fmt.Errorf("link %d: %w: signed before the previous link", i, ErrCustodyBroken)
+		}
+		previous = signed
+	}
+	return results, nil
+}
+
+// earliestTime returns the earliest verified timestamp of the result. A
+// link without one cannot be ordered and breaks the chain.
+func earliestTime(result *VerificationResult) (time.Time, error) {
+	var t time.Time
+	for _, ts := range result.Timestamps {
+		if t.IsZero() || ts.Time.Before(t) {
+			t = ts.Time
+		}
+	}
+	if t.IsZero() {
+		return t, fmt.Errorf("%w: no verified timestamp to order the link", ErrCustodyBroken)
+	}
+	return t, nil
+}
@@ -0,0 +1,81 @@
+package verify
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"strconv"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/mediatype"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+)
+
+// InTotoPayloadType is the DSSE payload type of PEP 740 statements.
+const InTotoPayloadType = mediatype.InToto
+
+// PAE returns the DSSE Pre-Authentication Encoding of the payload, which is
+// the message actually signed in a DSSE envelope.
+func PAE(payloadType string, payload []byte) []byte {
+	pae := []byte("DSSEv1 ")
+	pae = strconv.AppendInt(pae, int64(len(payloadType)), 10)
+	pae = append(pae, ' ')
+	pae = append(pae, payloadType...)
+	pae = append(pae, ' ')
+	pae = strconv.AppendInt(pae, int64(len(payload)), 10)
+	pae = append(pae, ' ')
+	return append(pae, payload...)
+}
+
+// VerifySignature checks only the DSSE signature of the attestation envelope
+// using the public key of the embedded certificate. It performs no trust
+// checks: the certificate chain, transparency log and signer identity are
+// not verified. It is intended for consumers that handle trust policy on
+// their own.
+func VerifySignature(attestation *pb.Attestation) error {
+	if attestation == nil || attestation.VerificationMaterial == nil || attestation.Envelope == nil {
+		return fmt.Errorf("attestation cannot be nil")
+	}
+
+	cert, err := x509.ParseCertificate(attestation.VerificationMaterial.Certificate)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate: %w", err)
+	}
+
+	return verifyPAE(cert.PublicKey, PAE(InTotoPayloadType, attestation.Envelope.Statement), attestation.Envelope.Signature)
+}
+
+// verifyPAE verifies sig over the encoded message using the public key.
+func verifyPAE(pub crypto.PublicKey, message, sig []byte) error {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		hash := crypto.SHA256
+		switch key.Curve {
+		case elliptic.P384():
+			hash = crypto.SHA384
+		case elliptic.P521():
+			hash = crypto.SHA512
+		}
+		h := hash.New()
+		h.Write(message)
+		if !ecdsa.VerifyASN1(key, h.Sum(nil), sig) {
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(key, message, sig) {
+			return fmt.Errorf("invalid Ed25519 signature")
+		}
+	case *rsa.PublicKey:
+		h := crypto.SHA256.New()
+		h.Write(message)
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, h.Sum(nil), sig); err != nil {
+			return fmt.Errorf("invalid RSA signature: %w", err)
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+	return nil
+}
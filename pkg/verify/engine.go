@@ -0,0 +1,62 @@
+package verify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// PolicyEngine evaluates organization rules over a verified attestation.
+// Implementations typically wrap a CEL program or a Rego query and return
+// an error describing the violated rule when the input does not satisfy it.
+type PolicyEngine interface {
+	Evaluate(ctx context.Context, input *PolicyInput) error
+}
+
+// PolicyEngineFunc adapts a function to the PolicyEngine interface.
+type PolicyEngineFunc func(ctx context.Context, input *PolicyInput) error
+
+// Evaluate calls f(ctx, input).
+func (f PolicyEngineFunc) Evaluate(ctx context.Context, input *PolicyInput) error {
+	return f(ctx, input)
+}
+
+// PolicyInput is the document policy engines evaluate. Its JSON form is
+// suitable as the input of Rego queries or as a CEL activation.
+type PolicyInput struct {
+	// Result is the structured verification result.
+	Result *VerificationResult `json:"result"`
+
+	// Statement is the decoded in-toto statement of the attestation.
+	Statement map[string]any `json:"statement"`
+}
+
+// newPolicyInput builds the input for policy engines from the result and
+// the raw statement of the attestation.
+func newPolicyInput(result *VerificationResult, statement []byte) (*PolicyInput, error) {
+	input := &PolicyInput{Result: result}
+	if err := json.Unmarshal(statement, &input.Statement); err != nil {
+		return nil, fmt.Errorf("decoding statement: %w", err)
+	}
+	return input, nil
+}
+
+// evaluatePolicyEngines runs the configured policy engines, failing on the
+// first rejection.
+func (v *Verifier) evaluatePolicyEngines(ctx context.Context, result *VerificationResult, statement []byte) error {
+	if len(v.Options.PolicyEngines) == 0 {
+		return nil
+	}
+
+	input, err := newPolicyInput(result, statement)
+	if err != nil {
+		return err
+	}
+
+	for _, engine := range v.Options.PolicyEngines {
+		if err := engine.Evaluate(ctx, input); err != nil {
+			return fmt.Errorf("policy evaluation failed: %w", err)
+		}
+	}
+	return nil
+}
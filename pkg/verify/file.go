@@ -0,0 +1,105 @@
+package verify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	intoto "github.com/in-toto/attestation/go/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// VerifyFile verifies the PEP 740 attestation at attestationPath against the
+// distribution file at distPath using a verifier configured with funcs.
+func VerifyFile(ctx context.Context, attestationPath, distPath string, funcs ...FnOption) (*VerificationResult, error) {
+	v, err := New(funcs...)
+	if err != nil {
+		return nil, err
+	}
+	return v.VerifyFile(ctx, attestationPath, distPath)
+}
+
+// VerifyFile verifies the PEP 740 attestation at attestationPath against the
+// distribution file at distPath. In addition to the signature checks, it
+// ensures the statement subject matches the sha256 digest and the filename
+// of the distribution.
+func (v *Verifier) VerifyFile(ctx context.Context, attestationPath, distPath string) (*VerificationResult, error) {
+	data, err := os.ReadFile(attestationPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading attestation: %w", err)
+	}
+
+	attestation, err := convert.UnmarshalAttestation(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing attestation: %w", err)
+	}
+
+	f, err := os.Open(distPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening distribution: %w", err)
+	}
+	defer f.Close()
+
+	return v.VerifyNamed(ctx, attestation, filepath.Base(distPath), f)
+}
+
+// VerifyNamed verifies the attestation against the distribution file named
+// filename read from dist, which may be a stream such as standard input.
+// Like VerifyFile, it ensures the statement subject matches the filename and
+// the sha256 digest of the distribution.
+func (v *Verifier) VerifyNamed(ctx context.Context, attestation *pb.Attestation, filename string, dist io.Reader) (*VerificationResult, error) {
+	if attestation == nil {
+		return nil, fmt.Errorf("attestation cannot be nil")
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, dist); err != nil {
+		return nil, fmt.Errorf("hashing distribution: %w", err)
+	}
+	return v.VerifyNamedDigest(ctx, attestation, filename, h.Sum(nil))
+}
+
+// VerifyNamedDigest verifies the attestation against the distribution file
+// named filename with the given sha256 digest, for callers that do not hold
+// the file.
+func (v *Verifier) VerifyNamedDigest(ctx context.Context, attestation *pb.Attestation, filename string, digest []byte) (*VerificationResult, error) {
+	if attestation == nil {
+		return nil, fmt.Errorf("attestation cannot be nil")
+	}
+	v.Options.Logger.DebugContext(ctx, "checking attestation subject", "filename", filename)
+	if err := checkSubject(attestation.GetEnvelope().GetStatement(), filename, digest); err != nil {
+		v.Options.Logger.DebugContext(ctx, "verification failed", "filename", filename, "error", err)
+		return nil, err
+	}
+	return v.VerifyDigest(ctx, attestation, digest)
+}
+
+// checkSubject parses the in-toto statement and ensures it has exactly one
+// subject matching the distribution filename and sha256 digest. Project
+// names are compared after PEP 503 normalization.
+func checkSubject(statement []byte, filename string, digest []byte) error {
+	var s intoto.Statement
+	if err := protojson.Unmarshal(statement, &s); err != nil {
+		return fmt.Errorf("parsing statement: %w", err)
+	}
+
+	if len(s.GetSubject()) != 1 {
+		return fmt.Errorf("expected exactly one subject, got %d", len(s.GetSubject()))
+	}
+
+	subject := s.GetSubject()[0]
+	if err := convert.ValidateSubjectName(subject.GetName(), filename); err != nil {
+		return err
+	}
+
+	if got := subject.GetDigest()["sha256"]; got != hex.EncodeToString(digest) {
+		return fmt.Errorf("subject digest %q does not match distribution digest %q", got, hex.EncodeToString(digest))
+	}
+
+	return nil
+}
@@ -0,0 +1,41 @@
+package verify
+
+import (
+	"fmt"
+
+	sgverify "github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+// IdentityPolicy describes a signer identity accepted by the verifier. Each
+// of the issuer and the certificate Subject Alternative Name can be matched
+// exactly or using a regular expression. Regular expressions are not
+// anchored implicitly, use ^ and $ to match the whole value.
+type IdentityPolicy struct {
+	// Issuer is the expected OIDC issuer of the Fulcio certificate.
+	Issuer string
+
+	// IssuerRegexp is a regular expression matched against the issuer.
+	IssuerRegexp string
+
+	// SAN is the expected Subject Alternative Name of the certificate.
+	SAN string
+
+	// SANRegexp is a regular expression matched against the SAN.
+	SANRegexp string
+}
+
+// certificateIdentity converts the policy to a sigstore-go identity.
+func (p *IdentityPolicy) certificateIdentity() (sgverify.CertificateIdentity, error) {
+	if p.Issuer == "" && p.IssuerRegexp == "" {
+		return sgverify.CertificateIdentity{}, fmt.Errorf("identity policy must specify an issuer or issuer regexp")
+	}
+	if p.SAN == "" && p.SANRegexp == "" {
+		return sgverify.CertificateIdentity{}, fmt.Errorf("identity policy must specify a SAN or SAN regexp")
+	}
+
+	id, err := sgverify.NewShortCertificateIdentity(p.Issuer, p.IssuerRegexp, p.SAN, p.SANRegexp)
+	if err != nil {
+		return sgverify.CertificateIdentity{}, fmt.Errorf("building certificate identity: %w", err)
+	}
+	return id, nil
+}
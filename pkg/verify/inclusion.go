@@ -0,0 +1,100 @@
+package verify
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tlog"
+	"github.com/sigstore/sigstore/pkg/signature"
+)
+
+// VerifyInclusion checks the Merkle inclusion proof and the signed checkpoint
+// of every transparency entry embedded in the attestation. The log keys are
+// read from the trusted root, Rekor is never contacted.
+func (v *Verifier) VerifyInclusion(ctx context.Context, attestation *pb.Attestation) error {
+	if attestation == nil {
+		return fmt.Errorf("attestation cannot be nil")
+	}
+
+	// Intermediate certificates are read from the trusted root, any in
+	// the attestation are not needed to verify.
+	b, err := convert.ToBundle(attestation, convert.WithStrict(false))
+	if err != nil {
+		return fmt.Errorf("converting attestation to bundle: %w", err)
+	}
+
+	tm, err := v.getTrustedMaterial(ctx)
+	if err != nil {
+		return err
+	}
+
+	return verifyInclusion(b, tm)
+}
+
+// verifyInclusion checks the inclusion proofs of the bundle transparency log
+// entries against the logs in the trusted material.
+func verifyInclusion(b *bundle.Bundle, tm root.TrustedMaterial) error {
+	entries, err := b.TlogEntries()
+	if err != nil {
+		return fmt.Errorf("reading transparency log entries: %w", err)
+	}
+
+	if len(entries) == 0 {
+		return fmt.Errorf("no transparency entries found")
+	}
+
+	for i, entry := range entries {
+		if !entry.HasInclusionProof() {
+			return fmt.Errorf("transparency entry %d has no inclusion proof", i)
+		}
+
+		logID := hex.EncodeToString([]byte(entry.LogKeyID()))
+		tl, ok := tm.RekorLogs()[logID]
+		if !ok {
+			return fmt.Errorf("transparency entry %d: log %s not found in trusted root", i, logID)
+		}
+
+		verifier, err := signature.LoadVerifier(tl.PublicKey, tl.SignatureHashFunc)
+		if err != nil {
+			return fmt.Errorf("loading log key: %w", err)
+		}
+
+		if isRekorV1Checkpoint(entry.TransparencyLogEntry().GetInclusionProof().GetCheckpoint().GetEnvelope()) {
+			if err := tlog.VerifyInclusion(entry, verifier); err != nil {
+				return fmt.Errorf("verifying inclusion of transparency entry %d: %w", i, err)
+			}
+			continue
+		}
+
+		// Rekor v2 (tile based) logs sign checkpoints using the log
+		// hostname as the note origin.
+		u, err := url.Parse(tl.BaseURL)
+		if err != nil || u.Hostname() == "" {
+			return fmt.Errorf("transparency entry %d: cannot verify rekor v2 entry without log base URL", i)
+		}
+		if err := tlog.VerifyCheckpointAndInclusion(entry, verifier, u.Hostname()); err != nil {
+			return fmt.Errorf("verifying inclusion of transparency entry %d: %w", i, err)
+		}
+	}
+
+	return nil
+}
+
+// rekorV1Origin matches the origin line of Rekor v1 checkpoints, which ends
+// with the numeric tree ID.
+var rekorV1Origin = regexp.MustCompile(`^.* - [0-9]+$`)
+
+// isRekorV1Checkpoint returns true if the checkpoint note was produced by a
+// Rekor v1 log. Rekor v2 checkpoints use the bare log hostname as origin.
+func isRekorV1Checkpoint(checkpoint string) bool {
+	origin, _, _ := strings.Cut(checkpoint, "\n")
+	return rekorV1Origin.MatchString(origin)
+}
@@ -0,0 +1,274 @@
+package verify
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tuf"
+)
+
+// Options controls how the Verifier checks attestations.
+type Options struct {
+	// TUFOptions configures the TUF client used to fetch the Sigstore
+	// trusted root. When nil, the public good instance is used.
+	TUFOptions *tuf.Options
+
+	// HTTPClient is used to fetch the trusted root using TUF. It replaces
+	// the fetcher of TUFOptions, enabling proxies and custom CAs.
+	HTTPClient *http.Client
+
+	// TrustedMaterial is the trusted root used to verify attestations. When
+	// set, the verifier does not fetch the trusted root using TUF. This
+	// enables verification against private Sigstore instances.
+	TrustedMaterial root.TrustedMaterial
+
+	// Identities lists the signer identities accepted by the verifier. An
+	// attestation verifies if its certificate matches any of them. When
+	// empty, the signer identity is not checked.
+	Identities []IdentityPolicy
+
+	// Offline disables all network access during verification. Every
+	// transparency entry must carry an inclusion proof which is checked
+	// against the log keys in the trusted root. When no trusted material
+	// is configured, the embedded production root is used instead of TUF.
+	Offline bool
+
+	// VerificationTime is the point in time the attestation is verified
+	// as of. Attestations logged after it are rejected. When zero, the
+	// current time is used. Certificates are always checked against the
+	// time recorded by the transparency log, so attestations with long
+	// expired Fulcio certificates still verify.
+	VerificationTime time.Time
+
+	// RequireSignedTimestamp requires the attestation to carry a RFC 3161
+	// timestamp verified against the timestamp authorities of the trusted
+	// root. Timestamps are always verified when present.
+	RequireSignedTimestamp bool
+
+	// RequireSCT requires the Fulcio certificate to embed a Signed
+	// Certificate Timestamp verified against the CT logs of the trusted
+	// root. Enabled by default, it must be disabled for attestations
+	// signed with a key and a self managed certificate, which have none.
+	RequireSCT bool
+
+	// Workers is the number of concurrent workers used by VerifyAll.
+	Workers int
+
+	// RevocationCheckers are consulted after verification to ensure the
+	// signing certificate was not revoked.
+	RevocationCheckers []RevocationChecker
+
+	// Policy is an allowlist of trusted publishers the signing certificate
+	// must match. The matching entry is reported in the result.
+	Policy *Policy
+
+	// PolicyEngines evaluate custom rules over the verification result and
+	// the decoded statement after all other checks pass.
+	PolicyEngines []PolicyEngine
+
+	// Cache stores successful verification results. When set, verifying an
+	// attestation already in the cache returns the stored result.
+	Cache Cache
+
+	// Logger receives the verification steps and failures. When nil,
+	// slog.Default() is used.
+	Logger *slog.Logger
+}
+
+var defaultOptions = Options{
+	RequireSCT: true,
+	Workers:    4,
+}
+
+// FnOption is a functional option to configure the Verifier.
+type FnOption func(*Options) error
+
+// WithTUFOptions sets the options for the TUF client used to fetch the
+// trusted root.
+func WithTUFOptions(opts *tuf.Options) FnOption {
+	return func(o *Options) error {
+		o.TUFOptions = opts
+		return nil
+	}
+}
+
+// WithHTTPClient sets the HTTP client used to fetch the trusted root.
+func WithHTTPClient(c *http.Client) FnOption {
+	return func(o *Options) error {
+		o.HTTPClient = c
+		return nil
+	}
+}
+
+// WithTrustedMaterial sets the trusted material used to verify.
+func WithTrustedMaterial(tm root.TrustedMaterial) FnOption {
+	return func(o *Options) error {
+		if tm == nil {
+			return fmt.Errorf("trusted material cannot be nil")
+		}
+		o.TrustedMaterial = tm
+		return nil
+	}
+}
+
+// WithTrustedRootJSON parses a trusted_root.json document (protobuf
+// TrustedRoot) and uses it to verify.
+func WithTrustedRootJSON(data []byte) FnOption {
+	return func(o *Options) error {
+		tr, err := root.NewTrustedRootFromJSON(data)
+		if err != nil {
+			return fmt.Errorf("parsing trusted root: %w", err)
+		}
+		o.TrustedMaterial = tr
+		return nil
+	}
+}
+
+// WithTrustedRootPath reads a trusted_root.json file and uses it to verify.
+func WithTrustedRootPath(path string) FnOption {
+	return func(o *Options) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("reading trusted root: %w", err)
+		}
+		return WithTrustedRootJSON(data)(o)
+	}
+}
+
+// WithEmbeddedTrustedRoot uses the copy of the instance trusted root
+// embedded in the module. This allows verifying without network access.
+func WithEmbeddedTrustedRoot(instance Instance) FnOption {
+	return func(o *Options) error {
+		tr, err := EmbeddedTrustedRoot(instance)
+		if err != nil {
+			return err
+		}
+		o.TrustedMaterial = tr
+		return nil
+	}
+}
+
+// WithIdentity adds an accepted signer identity to the verifier.
+func WithIdentity(policy IdentityPolicy) FnOption {
+	return func(o *Options) error {
+		if _, err := policy.certificateIdentity(); err != nil {
+			return err
+		}
+		o.Identities = append(o.Identities, policy)
+		return nil
+	}
+}
+
+// WithOffline enables or disables offline verification.
+func WithOffline(offline bool) FnOption {
+	return func(o *Options) error {
+		o.Offline = offline
+		return nil
+	}
+}
+
+// WithVerificationTime verifies attestations as of the specified time
+// instead of the current time.
+func WithVerificationTime(t time.Time) FnOption {
+	return func(o *Options) error {
+		o.VerificationTime = t
+		return nil
+	}
+}
+
+// WithRequireSignedTimestamp requires a verified RFC 3161 timestamp.
+func WithRequireSignedTimestamp(require bool) FnOption {
+	return func(o *Options) error {
+		o.RequireSignedTimestamp = require
+		return nil
+	}
+}
+
+// WithRequireSCT enables or disables the verification of the Signed
+// Certificate Timestamp embedded in the Fulcio certificate.
+func WithRequireSCT(require bool) FnOption {
+	return func(o *Options) error {
+		o.RequireSCT = require
+		return nil
+	}
+}
+
+// WithWorkers sets the number of concurrent workers used by VerifyAll.
+func WithWorkers(n int) FnOption {
+	return func(o *Options) error {
+		if n < 1 {
+			return fmt.Errorf("number of workers must be at least 1")
+		}
+		o.Workers = n
+		return nil
+	}
+}
+
+// WithRevocationChecker adds a checker to ensure the signing certificate
+// was not revoked.
+func WithRevocationChecker(checker RevocationChecker) FnOption {
+	return func(o *Options) error {
+		if checker == nil {
+			return fmt.Errorf("revocation checker cannot be nil")
+		}
+		o.RevocationCheckers = append(o.RevocationCheckers, checker)
+		return nil
+	}
+}
+
+// WithPolicy sets the publisher allowlist attestations must satisfy.
+func WithPolicy(p *Policy) FnOption {
+	return func(o *Options) error {
+		if p == nil {
+			return fmt.Errorf("policy cannot be nil")
+		}
+		if err := p.Validate(); err != nil {
+			return err
+		}
+		o.Policy = p
+		return nil
+	}
+}
+
+// WithPolicyFile reads the publisher allowlist from a YAML or JSON file.
+func WithPolicyFile(path string) FnOption {
+	return func(o *Options) error {
+		p, err := LoadPolicy(path)
+		if err != nil {
+			return err
+		}
+		o.Policy = p
+		return nil
+	}
+}
+
+// WithPolicyEngine adds a policy engine evaluated over verified attestations.
+func WithPolicyEngine(engine PolicyEngine) FnOption {
+	return func(o *Options) error {
+		if engine == nil {
+			return fmt.Errorf("policy engine cannot be nil")
+		}
+		o.PolicyEngines = append(o.PolicyEngines, engine)
+		return nil
+	}
+}
+
+// WithCache sets the cache used to skip repeated verifications.
+func WithCache(c Cache) FnOption {
+	return func(o *Options) error {
+		o.Cache = c
+		return nil
+	}
+}
+
+// WithLogger sets the logger of the verifier.
+func WithLogger(l *slog.Logger) FnOption {
+	return func(o *Options) error {
+		o.Logger = l
+		return nil
+	}
+}
@@ -0,0 +1,163 @@
+package verify
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/publisher"
+	"gopkg.in/yaml.v3"
+)
+
+// GitHubIssuer is the OIDC issuer of GitHub Actions workflows, used when a
+// publisher policy does not specify one.
+const GitHubIssuer = "https://token.actions.githubusercontent.com"
+
+// Policy is an allowlist of trusted publishers. An attestation satisfies the
+// policy when its signing certificate matches any of the publishers.
+type Policy struct {
+	Publishers []PublisherPolicy `json:"publishers" yaml:"publishers"`
+}
+
+// PublisherPolicy describes a trusted publisher, mirroring the trusted
+// publisher configuration of PyPI.
+type PublisherPolicy struct {
+	// Name is an optional label to identify the entry in results.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+
+	// Issuer is the OIDC issuer of the publisher. Defaults to GitHubIssuer.
+	Issuer string `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+
+	// Repository is the repository path (owner/name) or the full URL of
+	// the source repository. A URL also pins the host of the repository,
+	// a path matches it on any host of the issuer.
+	Repository string `json:"repository" yaml:"repository"`
+
+	// Workflow is the filename of the build workflow, for example
+	// "release.yml". When empty, any workflow of the repository matches.
+	Workflow string `json:"workflow,omitempty" yaml:"workflow,omitempty"`
+
+	// Environment is the deployment environment of the publisher. Fulcio
+	// certificates do not record it, so entries setting an environment
+	// only match attestations verified along the publisher of their
+	// provenance, see Verifier.VerifyDigestWithPublisher. That publisher
+	// is the unsigned data served by the index, not part of the signed
+	// certificate: the environment is only as trustworthy as the index.
+	Environment string `json:"environment,omitempty" yaml:"environment,omitempty"`
+}
+
+// PublisherClaims are the publisher facts an attestation is evaluated on.
+type PublisherClaims struct {
+	Issuer      string
+	Repository  string
+	Workflow    string
+	Environment string
+}
+
+// ClaimsFromResult extracts the publisher claims from the certificate data
+// of a verification result. The environment is not part of the certificate
+// and left empty, see ClaimsFromPublisher.
+func ClaimsFromResult(result *VerificationResult) PublisherClaims {
+	workflow, _, _ := strings.Cut(result.Extensions.BuildConfigURI, "@")
+	if workflow != "" {
+		workflow = path.Base(workflow)
+	}
+	return PublisherClaims{
+		Issuer:     result.Issuer,
+		Repository: result.Extensions.SourceRepositoryURI,
+		Workflow:   workflow,
+	}
+}
+
+// ClaimsFromPublisher extracts the publisher claims from the certificate
+// data of a verification result, taking the environment from pub, the
+// Trusted Publisher listed by the index provenance. pub may be nil.
+func ClaimsFromPublisher(result *VerificationResult, pub publisher.Publisher) PublisherClaims {
+	claims := ClaimsFromResult(result)
+	claims.Environment = publisher.Environment(pub)
+	return claims
+}
+
+// LoadPolicy reads a YAML or JSON policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading policy: %w", err)
+	}
+	return ParsePolicy(data)
+}
+
+// ParsePolicy parses a YAML or JSON policy document.
+func ParsePolicy(data []byte) (*Policy, error) {
+	p := &Policy{}
+	if err := yaml.Unmarshal(data, p); err != nil {
+		return nil, fmt.Errorf("parsing policy: %w", err)
+	}
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Validate checks the policy has publishers and they all name a repository.
+func (p *Policy) Validate() error {
+	if len(p.Publishers) == 0 {
+		return fmt.Errorf("policy has no publishers")
+	}
+	for i := range p.Publishers {
+		if p.Publishers[i].Repository == "" {
+			return fmt.Errorf("policy publisher %d has no repository", i)
+		}
+	}
+	return nil
+}
+
+// Match returns the first publisher entry matching the claims or an error
+// if none matches.
+func (p *Policy) Match(claims PublisherClaims) (*PublisherPolicy, error) {
+	for i := range p.Publishers {
+		if p.Publishers[i].matches(claims) {
+			return &p.Publishers[i], nil
+		}
+	}
+	return nil, fmt.Errorf("publisher %s (%s) is not allowed by policy", claims.Repository, claims.Workflow)
+}
+
+// matches returns true if the claims satisfy the publisher entry.
+func (pp *PublisherPolicy) matches(claims PublisherClaims) bool {
+	issuer := pp.Issuer
+	if issuer == "" {
+		issuer = GitHubIssuer
+	}
+	if issuer != claims.Issuer {
+		return false
+	}
+
+	host, repo := splitRepository(pp.Repository)
+	claimsHost, claimsRepo := splitRepository(claims.Repository)
+	if repo != claimsRepo || (host != "" && host != claimsHost) {
+		return false
+	}
+
+	if pp.Workflow != "" && pp.Workflow != claims.Workflow {
+		return false
+	}
+
+	if pp.Environment != "" && !strings.EqualFold(pp.Environment, claims.Environment) {
+		return false
+	}
+
+	return true
+}
+
+// splitRepository returns the lowercased host and owner/name path of a
+// repository specified either as a path or as a URL. The host is empty for
+// a path.
+func splitRepository(repo string) (host, path string) {
+	if u, err := url.Parse(repo); err == nil && u.Host != "" {
+		host, repo = strings.ToLower(u.Host), u.Path
+	}
+	return host, strings.ToLower(strings.Trim(repo, "/"))
+}
@@ -0,0 +1,175 @@
+package verify
+
+import (
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/x509util"
+	"github.com/sigstore/sigstore-go/pkg/bundle"
+	"github.com/sigstore/sigstore-go/pkg/fulcio/certificate"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	sgverify "github.com/sigstore/sigstore-go/pkg/verify"
+)
+
+// VerificationResult captures the verified facts about an attestation so
+// callers can render reports or apply further policy after verification.
+type VerificationResult struct {
+	// Identity is the Subject Alternative Name of the signing certificate.
+	Identity string `json:"identity"`
+
+	// Issuer is the OIDC issuer that authenticated the signer.
+	Issuer string `json:"issuer"`
+
+	// Extensions holds the Fulcio extensions of the signing certificate
+	// (source repository, workflow, ref, run invocation, etc).
+	Extensions certificate.Extensions `json:"extensions"`
+
+	// SCTs lists the Signed Certificate Timestamps embedded in the signing
+	// certificate.
+	SCTs []SCT `json:"scts"`
+
+	// SCTVerified is true when at least one SCT was verified against the
+	// CT logs of the trusted root.
+	SCTVerified bool `json:"sctVerified"`
+
+	// RevocationChecked is true when the revocation status of the signing
+	// certificate was checked.
+	RevocationChecked bool `json:"revocationChecked"`
+
+	// Policy is the publisher policy entry matched by the signing
+	// certificate, nil when no policy was configured.
+	Policy *PublisherPolicy `json:"policy,omitempty"`
+
+	// LogEntries lists the transparency log entries of the attestation.
+	LogEntries []LogEntry `json:"logEntries"`
+
+	// Timestamps lists the verified observer timestamps, both the log
+	// integrated times and RFC 3161 signed timestamps.
+	Timestamps []Timestamp `json:"timestamps"`
+
+	// PredicateType is the predicate type of the attested statement.
+	PredicateType string `json:"predicateType"`
+
+	// Subjects lists the subjects of the attested statement.
+	Subjects []Subject `json:"subjects"`
+
+	// Certificate is the parsed signing certificate, for further identity
+	// checks without parsing it again. It is not serialized.
+	Certificate *x509.Certificate `json:"-"`
+}
+
+// LogEntry describes a transparency log entry of a verified attestation.
+type LogEntry struct {
+	// LogID is the hex encoded ID of the log holding the entry.
+	LogID string `json:"logId"`
+
+	// LogIndex is the index of the entry in the log.
+	LogIndex int64 `json:"logIndex"`
+
+	// IntegratedTime is the time when the entry was added to the log.
+	IntegratedTime time.Time `json:"integratedTime"`
+}
+
+// SCT describes a Signed Certificate Timestamp embedded in the certificate.
+type SCT struct {
+	// LogID is the hex encoded ID of the CT log that issued the SCT.
+	LogID string `json:"logId"`
+
+	// LogURL is the URL of the CT log when it is known to the trusted root.
+	LogURL string `json:"logUrl,omitempty"`
+
+	// Timestamp is the time the CT log recorded for the certificate.
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Timestamp is a verified time observation of the signature.
+type Timestamp struct {
+	// Type is the kind of timestamp, "Tlog" for log integrated times or
+	// "TimestampAuthority" for RFC 3161 timestamps.
+	Type string `json:"type"`
+
+	// URI identifies the log or timestamp authority.
+	URI string `json:"uri"`
+
+	// Time is the observed time.
+	Time time.Time `json:"time"`
+}
+
+// Subject is a subject of the attested in-toto statement.
+type Subject struct {
+	// Name of the subject, the distribution filename in PEP 740.
+	Name string `json:"name"`
+
+	// Digest maps algorithm names to hex encoded digests.
+	Digest map[string]string `json:"digest"`
+}
+
+// newVerificationResult builds a VerificationResult from the verified bundle
+// and the result returned by sigstore-go.
+func newVerificationResult(b *bundle.Bundle, res *sgverify.VerificationResult) (*VerificationResult, error) {
+	result := &VerificationResult{}
+
+	if res.Signature != nil && res.Signature.Certificate != nil {
+		result.Identity = res.Signature.Certificate.SubjectAlternativeName
+		result.Issuer = res.Signature.Certificate.Issuer
+		result.Extensions = res.Signature.Certificate.Extensions
+	}
+
+	entries, err := b.TlogEntries()
+	if err != nil {
+		return nil, fmt.Errorf("reading transparency log entries: %w", err)
+	}
+	for _, entry := range entries {
+		result.LogEntries = append(result.LogEntries, LogEntry{
+			LogID:          hex.EncodeToString([]byte(entry.LogKeyID())),
+			LogIndex:       entry.LogIndex(),
+			IntegratedTime: entry.IntegratedTime(),
+		})
+	}
+
+	for _, ts := range res.VerifiedTimestamps {
+		result.Timestamps = append(result.Timestamps, Timestamp{
+			Type: ts.Type,
+			URI:  ts.URI,
+			Time: ts.Timestamp,
+		})
+	}
+
+	if res.Statement != nil {
+		result.PredicateType = res.Statement.GetPredicateType()
+		for _, s := range res.Statement.GetSubject() {
+			result.Subjects = append(result.Subjects, Subject{
+				Name:   s.GetName(),
+				Digest: s.GetDigest(),
+			})
+		}
+	}
+
+	return result, nil
+}
+
+// certificateSCTs extracts the SCTs embedded in the certificate and
+// resolves their logs using the trusted material.
+func certificateSCTs(cert *x509.Certificate, tm root.TrustedMaterial) ([]SCT, error) {
+	scts, err := x509util.ParseSCTsFromCertificate(cert.Raw)
+	if err != nil {
+		return nil, fmt.Errorf("parsing certificate SCTs: %w", err)
+	}
+
+	ctlogs := tm.CTLogs()
+	ret := make([]SCT, 0, len(scts))
+	for _, sct := range scts {
+		s := SCT{
+			LogID:     hex.EncodeToString(sct.LogID.KeyID[:]),
+			Timestamp: ct.TimestampToTime(sct.Timestamp),
+		}
+		if tl, ok := ctlogs[s.LogID]; ok {
+			s.LogURL = tl.BaseURL
+		}
+		ret = append(ret, s)
+	}
+	return ret, nil
+}
@@ -0,0 +1,208 @@
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+)
+
+// RevocationChecker reports whether a signing certificate was revoked.
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, cert *x509.Certificate) (bool, error)
+}
+
+// Denylist is a RevocationChecker holding known-revoked certificates
+// identified by their hex encoded SHA-256 fingerprint or serial number.
+type Denylist struct {
+	fingerprints map[string]struct{}
+	serials      map[string]struct{}
+}
+
+// NewDenylist returns an empty Denylist.
+func NewDenylist() *Denylist {
+	return &Denylist{
+		fingerprints: map[string]struct{}{},
+		serials:      map[string]struct{}{},
+	}
+}
+
+// LoadDenylist reads a denylist file. Each line holds an entry in the form
+// "sha256:<hex fingerprint>" or "serial:<hex serial number>". Blank lines
+// and lines starting with # are ignored.
+func LoadDenylist(path string) (*Denylist, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading denylist: %w", err)
+	}
+
+	d := NewDenylist()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	n := 0
+	for scanner.Scan() {
+		n++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		kind, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("denylist line %d: missing entry type", n)
+		}
+		switch kind {
+		case "sha256":
+			err = d.AddFingerprint(value)
+		case "serial":
+			err = d.AddSerial(value)
+		default:
+			err = fmt.Errorf("unknown entry type %q", kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("denylist line %d: %w", n, err)
+		}
+	}
+	return d, scanner.Err()
+}
+
+// AddFingerprint adds a hex encoded SHA-256 certificate fingerprint.
+func (d *Denylist) AddFingerprint(fingerprint string) error {
+	fp := strings.ToLower(strings.ReplaceAll(fingerprint, ":", ""))
+	if b, err := hex.DecodeString(fp); err != nil || len(b) != sha256.Size {
+		return fmt.Errorf("invalid sha256 fingerprint %q", fingerprint)
+	}
+	d.fingerprints[fp] = struct{}{}
+	return nil
+}
+
+// AddSerial adds a hex encoded certificate serial number.
+func (d *Denylist) AddSerial(serial string) error {
+	n, ok := new(big.Int).SetString(strings.ReplaceAll(serial, ":", ""), 16)
+	if !ok {
+		return fmt.Errorf("invalid serial number %q", serial)
+	}
+	d.serials[n.Text(16)] = struct{}{}
+	return nil
+}
+
+// IsRevoked returns true if the certificate is in the denylist.
+func (d *Denylist) IsRevoked(_ context.Context, cert *x509.Certificate) (bool, error) {
+	fp := sha256.Sum256(cert.Raw)
+	if _, ok := d.fingerprints[hex.EncodeToString(fp[:])]; ok {
+		return true, nil
+	}
+	_, ok := d.serials[cert.SerialNumber.Text(16)]
+	return ok, nil
+}
+
+// CRLChecker is a RevocationChecker backed by certificate revocation lists.
+// A CRL is only trusted when it is signed by a certificate authority of
+// TrustedMaterial, and the checks fail once it is past its next update.
+type CRLChecker struct {
+	CRLs []*x509.RevocationList
+
+	// TrustedMaterial holds the certificate authorities that sign the
+	// CRLs, usually the trusted root of the verifier.
+	TrustedMaterial root.TrustedMaterial
+}
+
+// LoadCRL parses a DER or PEM encoded CRL and returns a CRLChecker for it.
+// The CRL must be signed by a certificate authority of tm.
+func LoadCRL(data []byte, tm root.TrustedMaterial) (*CRLChecker, error) {
+	if tm == nil {
+		return nil, fmt.Errorf("trusted material cannot be nil")
+	}
+	crl, err := x509.ParseRevocationList(pemOrDER(data))
+	if err != nil {
+		return nil, fmt.Errorf("parsing CRL: %w", err)
+	}
+	c := &CRLChecker{CRLs: []*x509.RevocationList{crl}, TrustedMaterial: tm}
+	if err := c.checkCRL(crl, time.Now()); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// IsRevoked returns true if the certificate serial is listed in a CRL
+// issued by the certificate issuer. It fails if that CRL is not signed by
+// the issuer or is past its next update.
+func (c *CRLChecker) IsRevoked(_ context.Context, cert *x509.Certificate) (bool, error) {
+	now := time.Now()
+	for _, crl := range c.CRLs {
+		if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) {
+			continue
+		}
+		if err := c.checkCRL(crl, now); err != nil {
+			return false, err
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// checkCRL checks that the CRL is current and signed by a certificate
+// authority of the trusted material.
+func (c *CRLChecker) checkCRL(crl *x509.RevocationList, now time.Time) error {
+	if !crl.NextUpdate.IsZero() && now.After(crl.NextUpdate) {
+		return fmt.Errorf("CRL expired at %s", crl.NextUpdate.Format(time.RFC3339))
+	}
+	if c.TrustedMaterial == nil {
+		return fmt.Errorf("no trusted material to check the CRL signature")
+	}
+	for _, ca := range c.TrustedMaterial.FulcioCertificateAuthorities() {
+		fca, ok := ca.(*root.FulcioCertificateAuthority)
+		if !ok {
+			continue
+		}
+		for _, issuer := range append([]*x509.Certificate{fca.Root}, fca.Intermediates...) {
+			if issuer == nil || !bytes.Equal(issuer.RawSubject, crl.RawIssuer) {
+				continue
+			}
+			if crl.CheckSignatureFrom(issuer) == nil {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("CRL is not signed by a certificate authority of the trusted root")
+}
+
+// pemOrDER returns the DER bytes of the first PEM block in data or data
+// itself when it is not PEM encoded.
+func pemOrDER(data []byte) []byte {
+	if block, _ := pem.Decode(data); block != nil {
+		return block.Bytes
+	}
+	return data
+}
+
+// checkRevocation runs the configured revocation checkers on the signing
+// certificate. It returns true if any checker ran.
+func (v *Verifier) checkRevocation(ctx context.Context, cert *x509.Certificate) (bool, error) {
+	if len(v.Options.RevocationCheckers) == 0 {
+		return false, nil
+	}
+
+	for _, checker := range v.Options.RevocationCheckers {
+		revoked, err := checker.IsRevoked(ctx, cert)
+		if err != nil {
+			return false, fmt.Errorf("checking certificate revocation: %w", err)
+		}
+		if revoked {
+			return false, fmt.Errorf("signing certificate %s is revoked", cert.SerialNumber.Text(16))
+		}
+	}
+	return true, nil
+}
@@ -0,0 +1,54 @@
+package verify
+
+import (
+	"embed"
+	"fmt"
+
+	"github.com/sigstore/sigstore-go/pkg/root"
+)
+
+// Instance identifies a Sigstore deployment with an embedded trusted root.
+type Instance string
+
+const (
+	// InstanceProduction is the Sigstore public good instance.
+	InstanceProduction Instance = "production"
+
+	// InstanceStaging is the Sigstore staging instance, the one the
+	// signer uses WithStaging. Its root is embedded from
+	// trustedroots/staging.json, written by go generate.
+	InstanceStaging Instance = "staging"
+)
+
+// EmbeddedRootsVersion is the version of the trusted roots embedded in the
+// module. It is bumped every time the embedded copies are refreshed.
+const EmbeddedRootsVersion = "1"
+
+//go:generate go run ../../internal/genroots -out trustedroots
+
+//go:embed trustedroots/*.json
+var embeddedRoots embed.FS
+
+// EmbeddedTrustedRootJSON returns the raw trusted_root.json embedded for
+// the specified instance.
+func EmbeddedTrustedRootJSON(instance Instance) ([]byte, error) {
+	data, err := embeddedRoots.ReadFile(fmt.Sprintf("trustedroots/%s.json", instance))
+	if err != nil {
+		return nil, fmt.Errorf("no embedded trusted root for instance %q", instance)
+	}
+	return data, nil
+}
+
+// EmbeddedTrustedRoot returns the parsed trusted root embedded for the
+// specified instance.
+func EmbeddedTrustedRoot(instance Instance) (*root.TrustedRoot, error) {
+	data, err := EmbeddedTrustedRootJSON(instance)
+	if err != nil {
+		return nil, err
+	}
+	tr, err := root.NewTrustedRootFromJSON(data)
+	if err != nil {
+		return nil, fmt.Errorf("parsing embedded trusted root: %w", err)
+	}
+	return tr, nil
+}
@@ -0,0 +1,327 @@
+// Package verify performs Sigstore verification of PyPI attestations (PEP 740).
+package verify
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/internal/ctxio"
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/publisher"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"github.com/sigstore/sigstore-go/pkg/tuf"
+	sgverify "github.com/sigstore/sigstore-go/pkg/verify"
+	"github.com/theupdateframework/go-tuf/v2/metadata/fetcher"
+)
+
+// Verifier checks PEP 740 attestations against a Sigstore trusted root.
+//
+// Verification covers the certificate chain up to the Fulcio root, the
+// Signed Certificate Timestamp embedded in the leaf, the Rekor inclusion
+// of the transparency entry and the DSSE signature over the statement.
+type Verifier struct {
+	Options Options
+
+	mu              sync.Mutex
+	trustedMaterial root.TrustedMaterial
+}
+
+// New returns a new Verifier configured with the passed options.
+func New(funcs ...FnOption) (*Verifier, error) {
+	opts := defaultOptions
+	for _, fn := range funcs {
+		if err := fn(&opts); err != nil {
+			return nil, err
+		}
+	}
+	if opts.Logger == nil {
+		opts.Logger = slog.Default()
+	}
+	return &Verifier{Options: opts}, nil
+}
+
+// Verify checks the attestation and ensures its statement subject matches
+// the distribution file read from dist.
+func (v *Verifier) Verify(ctx context.Context, attestation *pb.Attestation, dist io.Reader) (*VerificationResult, error) {
+	if dist == nil {
+		return nil, fmt.Errorf("distribution reader cannot be nil")
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, dist); err != nil {
+		return nil, fmt.Errorf("hashing distribution: %w", err)
+	}
+	return v.VerifyDigest(ctx, attestation, h.Sum(nil))
+}
+
+// VerifyDigest checks the attestation and ensures its statement subject
+// matches the sha256 digest of the distribution file.
+func (v *Verifier) VerifyDigest(ctx context.Context, attestation *pb.Attestation, digest []byte) (*VerificationResult, error) {
+	return v.VerifyDigestWithPublisher(ctx, attestation, digest, nil)
+}
+
+// VerifyDigestWithPublisher checks the attestation like VerifyDigest. pub is
+// the Trusted Publisher the index lists for the attestation in its
+// provenance, its environment is matched against the publisher policy as
+// signing certificates do not record it. pub may be nil.
+func (v *Verifier) VerifyDigestWithPublisher(ctx context.Context, attestation *pb.Attestation, digest []byte, pub publisher.Publisher) (*VerificationResult, error) {
+	log := v.Options.Logger.With("sha256", hex.EncodeToString(digest))
+	log.DebugContext(ctx, "verifying attestation")
+	result, err := v.verifyDigest(ctx, log, attestation, digest, pub)
+	if err != nil {
+		log.DebugContext(ctx, "verification failed", "error", err)
+		return nil, err
+	}
+	log.DebugContext(ctx, "attestation verified", "identity", result.Identity, "issuer", result.Issuer)
+	return result, nil
+}
+
+func (v *Verifier) verifyDigest(
+	ctx context.Context, log *slog.Logger, attestation *pb.Attestation, digest []byte, pub publisher.Publisher,
+) (*VerificationResult, error) {
+	if attestation == nil {
+		return nil, fmt.Errorf("attestation cannot be nil")
+	}
+	if len(digest) != sha256.Size {
+		return nil, fmt.Errorf("invalid sha256 digest length: %d", len(digest))
+	}
+
+	if err := CheckEntryConsistency(attestation); err != nil {
+		return nil, err
+	}
+
+	// The certificate is parsed once for the conversion and the checks
+	cert, err := x509.ParseCertificate(attestation.GetVerificationMaterial().GetCertificate())
+	if err != nil {
+		return nil, fmt.Errorf("parsing signing certificate: %w", err)
+	}
+
+	// Intermediate certificates are read from the trusted root, any in
+	// the attestation are not needed to verify.
+	b, err := convert.ToBundle(attestation, convert.WithStrict(false), convert.WithCertificate(cert))
+	if err != nil {
+		return nil, fmt.Errorf("converting attestation to bundle: %w", err)
+	}
+
+	tm, err := v.getTrustedMaterial(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var cacheKey string
+	if v.Options.Cache != nil {
+		cacheKey, err = v.cacheKey(attestation, digest, publisher.Environment(pub), tm)
+		if err != nil {
+			return nil, err
+		}
+		if result, ok := v.Options.Cache.Get(cacheKey); ok {
+			log.DebugContext(ctx, "using cached verification result")
+			// Cached results are shared and may come from a persistent
+			// cache, which does not store the certificate
+			cached := *result
+			cached.Certificate = cert
+			if err := v.recheck(ctx, &cached, attestation.Envelope.Statement); err != nil {
+				return nil, err
+			}
+			return &cached, nil
+		}
+	}
+
+	if v.Options.Offline {
+		log.DebugContext(ctx, "checking inclusion proofs offline")
+		if err := verifyInclusion(b, tm); err != nil {
+			return nil, err
+		}
+	}
+
+	sv, err := sgverify.NewVerifier(tm, v.verifierOptions()...)
+	if err != nil {
+		return nil, fmt.Errorf("creating sigstore verifier: %w", err)
+	}
+
+	policyOpts, err := v.policyOptions()
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := sv.Verify(b, sgverify.NewPolicy(
+		sgverify.WithArtifactDigest("sha256", digest), policyOpts...,
+	))
+	if err != nil {
+		return nil, fmt.Errorf("verifying attestation: %w", err)
+	}
+
+	result, err := newVerificationResult(b, res)
+	if err != nil {
+		return nil, err
+	}
+
+	result.Certificate = cert
+
+	result.SCTs, err = certificateSCTs(cert, tm)
+	if err != nil {
+		return nil, err
+	}
+	result.SCTVerified = v.Options.RequireSCT
+
+	if err := v.checkVerificationTime(result); err != nil {
+		return nil, err
+	}
+
+	if v.Options.Policy != nil {
+		result.Policy, err = v.Options.Policy.Match(ClaimsFromPublisher(result, pub))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := v.recheck(ctx, result, attestation.Envelope.Statement); err != nil {
+		return nil, err
+	}
+
+	if cacheKey != "" {
+		// Failing to store the result does not invalidate the verification
+		_ = v.Options.Cache.Set(cacheKey, result)
+	}
+
+	return result, nil
+}
+
+// recheck runs the checks that are repeated on cached results: revocation
+// and policy engines are not part of the cache key and their outcome can
+// change after the result was cached.
+func (v *Verifier) recheck(ctx context.Context, result *VerificationResult, statement []byte) error {
+	var err error
+	result.RevocationChecked, err = v.checkRevocation(ctx, result.Certificate)
+	if err != nil {
+		return err
+	}
+	return v.evaluatePolicyEngines(ctx, result, statement)
+}
+
+// checkVerificationTime ensures the attestation was logged before the time
+// it is being verified as of.
+func (v *Verifier) checkVerificationTime(result *VerificationResult) error {
+	t := v.Options.VerificationTime
+	if t.IsZero() {
+		t = time.Now()
+	}
+
+	for _, entry := range result.LogEntries {
+		if entry.IntegratedTime.After(t) {
+			return fmt.Errorf(
+				"log entry %d integrated at %s, after verification time %s",
+				entry.LogIndex, entry.IntegratedTime.UTC().Format(time.RFC3339), t.UTC().Format(time.RFC3339),
+			)
+		}
+	}
+	return nil
+}
+
+// verifierOptions returns the sigstore-go verifier configuration.
+func (v *Verifier) verifierOptions() []sgverify.VerifierOption {
+	opts := []sgverify.VerifierOption{
+		sgverify.WithTransparencyLog(1),
+	}
+
+	if v.Options.RequireSCT {
+		opts = append(opts, sgverify.WithSignedCertificateTimestamps(1))
+	}
+
+	if v.Options.RequireSignedTimestamp {
+		opts = append(opts, sgverify.WithSignedTimestamps(1))
+	} else {
+		// Rekor v2 entries carry no integrated time, accept either the
+		// log integrated time or a signed timestamp.
+		opts = append(opts, sgverify.WithObserverTimestamps(1))
+	}
+	return opts
+}
+
+// policyOptions returns the sigstore-go policy options that enforce the
+// configured signer identities.
+func (v *Verifier) policyOptions() ([]sgverify.PolicyOption, error) {
+	if len(v.Options.Identities) == 0 {
+		return []sgverify.PolicyOption{sgverify.WithoutIdentitiesUnsafe()}, nil
+	}
+
+	opts := make([]sgverify.PolicyOption, 0, len(v.Options.Identities))
+	for i := range v.Options.Identities {
+		id, err := v.Options.Identities[i].certificateIdentity()
+		if err != nil {
+			return nil, fmt.Errorf("identity policy %d: %w", i, err)
+		}
+		opts = append(opts, sgverify.WithCertificateIdentity(id))
+	}
+	return opts, nil
+}
+
+// TrustedMaterial returns the trusted material used to verify, fetching it
+// the first time it is needed. It can be passed to WithTrustedMaterial to
+// share it between verifiers.
+func (v *Verifier) TrustedMaterial(ctx context.Context) (root.TrustedMaterial, error) {
+	return v.getTrustedMaterial(ctx)
+}
+
+// getTrustedMaterial returns the trusted material used to verify, fetching
+// it the first time it is needed.
+func (v *Verifier) getTrustedMaterial(ctx context.Context) (root.TrustedMaterial, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.Options.TrustedMaterial != nil {
+		return v.Options.TrustedMaterial, nil
+	}
+
+	if v.trustedMaterial != nil {
+		return v.trustedMaterial, nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if v.Options.Offline {
+		v.Options.Logger.DebugContext(ctx, "using embedded trusted root", "instance", InstanceProduction)
+		tr, err := EmbeddedTrustedRoot(InstanceProduction)
+		if err != nil {
+			return nil, err
+		}
+		v.trustedMaterial = tr
+		return tr, nil
+	}
+
+	tufOpts := v.Options.TUFOptions
+	if tufOpts == nil {
+		tufOpts = tuf.DefaultOptions()
+	}
+
+	if v.Options.HTTPClient != nil {
+		f := fetcher.NewDefaultFetcher()
+		f.SetHTTPClient(v.Options.HTTPClient)
+		o := *tufOpts
+		o.Fetcher = f
+		tufOpts = &o
+	}
+
+	v.Options.Logger.DebugContext(ctx, "fetching trusted root", "repository", tufOpts.RepositoryBaseURL)
+	start := time.Now()
+	// The TUF client takes no context, stop waiting for it on cancellation
+	tr, err := ctxio.Run(ctx, func() (*root.TrustedRoot, error) {
+		return root.FetchTrustedRootWithOptions(tufOpts)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("fetching trusted root: %w", err)
+	}
+	v.Options.Logger.DebugContext(ctx, "fetched trusted root", "duration", time.Since(start))
+
+	v.trustedMaterial = tr
+	return tr, nil
+}
@@ -0,0 +1,1102 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/carabiner-dev/pypi-attestations/pkg/convert"
+	"github.com/carabiner-dev/pypi-attestations/pkg/publisher"
+	pb "github.com/carabiner-dev/pypi-attestations/proto"
+	"github.com/sigstore/sigstore-go/pkg/root"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+// testDigest is the sha256 of the distribution attested in the test data.
+const testDigest = "e5e75beaddbb674c390ed1a43cb32b7274990da6be7190c812a530b18db6137f"
+
+func loadTestAttestation(t *testing.T) *pb.Attestation {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.attestation.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+
+	attestation, err := convert.UnmarshalAttestation(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal attestation: %v", err)
+	}
+	return attestation
+}
+
+// rekorV2TrustedRoot is the trusted root of the Rekor v2 test data, a
+// test Fulcio and timestamp authority along a Rekor v2 log.
+var rekorV2TrustedRoot = filepath.Join("..", "..", "testdata", "trusted_root.rekor-v2.json")
+
+// loadRekorV2Attestation reads the attestation logged to Rekor v2 in the
+// test data. It is stored as a bundle as PEP 740 JSON cannot carry the
+// timestamp needed to verify entries with no integrated time.
+func loadRekorV2Attestation(t *testing.T) *pb.Attestation {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("..", "..", "testdata", "pypi.rekor-v2.sigstore.json"))
+	if err != nil {
+		t.Fatalf("Failed to read test data: %v", err)
+	}
+
+	b, err := convert.UnmarshalBundle(data)
+	if err != nil {
+		t.Fatalf("Failed to unmarshal bundle: %v", err)
+	}
+	attestation, err := convert.FromBundle(b)
+	if err != nil {
+		t.Fatalf("Failed to convert bundle: %v", err)
+	}
+	return attestation
+}
+
+func TestVerifyInvalidInputs(t *testing.T) {
+	v, err := New()
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	t.Run("nil attestation", func(t *testing.T) {
+		if _, err := v.VerifyDigest(context.Background(), nil, make([]byte, 32)); err == nil {
+			t.Error("Expected error for nil attestation")
+		}
+	})
+
+	t.Run("invalid digest length", func(t *testing.T) {
+		if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), []byte{0x01}); err == nil {
+			t.Error("Expected error for invalid digest")
+		}
+	})
+
+	t.Run("nil distribution reader", func(t *testing.T) {
+		if _, err := v.Verify(context.Background(), loadTestAttestation(t), nil); err == nil {
+			t.Error("Expected error for nil reader")
+		}
+	})
+}
+
+func TestVerifyDigestCustomTrustedRoot(t *testing.T) {
+	v, err := New(WithTrustedRootPath(filepath.Join("..", "..", "testdata", "trusted_root.json")))
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err != nil {
+		t.Errorf("Expected attestation to verify: %v", err)
+	}
+
+	digest[0] ^= 0xff
+	if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err == nil {
+		t.Error("Expected error for mismatched digest")
+	}
+}
+
+func TestInvalidTrustedRoot(t *testing.T) {
+	if _, err := New(WithTrustedRootJSON([]byte("{"))); err == nil {
+		t.Error("Expected error for invalid trusted root")
+	}
+}
+
+func TestEmbeddedTrustedRoot(t *testing.T) {
+	if _, err := EmbeddedTrustedRoot(InstanceProduction); err != nil {
+		t.Fatalf("Failed to load embedded production root: %v", err)
+	}
+	t.Run("staging", func(t *testing.T) {
+		if _, err := EmbeddedTrustedRootJSON(InstanceStaging); err != nil {
+			t.Skip("staging root not embedded yet, run go generate ./pkg/verify")
+		}
+		if _, err := EmbeddedTrustedRoot(InstanceStaging); err != nil {
+			t.Errorf("Failed to load embedded staging root: %v", err)
+		}
+	})
+
+	if _, err := EmbeddedTrustedRoot(Instance("nonexistent")); err == nil {
+		t.Error("Expected error for unknown instance")
+	}
+
+	v, err := New(WithEmbeddedTrustedRoot(InstanceProduction))
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err != nil {
+		t.Errorf("Expected attestation to verify with embedded root: %v", err)
+	}
+}
+
+func TestVerifyIdentityPolicy(t *testing.T) {
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		policy  IdentityPolicy
+		mustErr bool
+	}{
+		{
+			name: "exact match",
+			policy: IdentityPolicy{
+				Issuer: "https://token.actions.githubusercontent.com",
+				SAN:    "https://github.com/pypi/pypi-attestations/.github/workflows/release.yml@refs/tags/v0.0.28",
+			},
+		},
+		{
+			name: "regexp match",
+			policy: IdentityPolicy{
+				Issuer:    "https://token.actions.githubusercontent.com",
+				SANRegexp: `^https://github\.com/pypi/pypi-attestations/\.github/workflows/release\.yml@.*$`,
+			},
+		},
+		{
+			name: "issuer mismatch",
+			policy: IdentityPolicy{
+				Issuer:    "https://gitlab.com",
+				SANRegexp: ".*",
+			},
+			mustErr: true,
+		},
+		{
+			name: "SAN mismatch",
+			policy: IdentityPolicy{
+				IssuerRegexp: ".*",
+				SANRegexp:    `^https://github\.com/myorg/myrepo/`,
+			},
+			mustErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := New(WithEmbeddedTrustedRoot(InstanceProduction), WithIdentity(tc.policy))
+			if err != nil {
+				t.Fatalf("Failed to create verifier: %v", err)
+			}
+			_, err = v.VerifyDigest(context.Background(), loadTestAttestation(t), digest)
+			if tc.mustErr && err == nil {
+				t.Error("Expected identity verification to fail")
+			} else if !tc.mustErr && err != nil {
+				t.Errorf("Expected identity verification to pass: %v", err)
+			}
+		})
+	}
+
+	t.Run("invalid policy", func(t *testing.T) {
+		if _, err := New(WithIdentity(IdentityPolicy{SAN: "x"})); err == nil {
+			t.Error("Expected error for policy without issuer")
+		}
+	})
+}
+
+func TestCheckSubject(t *testing.T) {
+	attestation := loadTestAttestation(t)
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	if err := checkSubject(attestation.Envelope.Statement, "pypi_attestations-0.0.28.tar.gz", digest); err != nil {
+		t.Errorf("Expected subject to match: %v", err)
+	}
+
+	if err := checkSubject(attestation.Envelope.Statement, "PyPI.Attestations-0.0.28.tar.gz", digest); err != nil {
+		t.Errorf("Expected normalized subject to match: %v", err)
+	}
+
+	if err := checkSubject(attestation.Envelope.Statement, "other-0.0.28.tar.gz", digest); err == nil {
+		t.Error("Expected error for mismatched filename")
+	}
+
+	if err := checkSubject(attestation.Envelope.Statement, "pypi_attestations-0.0.28.tar.gz", make([]byte, 32)); err == nil {
+		t.Error("Expected error for mismatched digest")
+	}
+}
+
+func TestVerifyFileDigestMismatch(t *testing.T) {
+	distPath := filepath.Join(t.TempDir(), "pypi_attestations-0.0.28.tar.gz")
+	if err := os.WriteFile(distPath, []byte("not the real sdist"), 0o600); err != nil {
+		t.Fatalf("Failed to write distribution: %v", err)
+	}
+
+	_, err := VerifyFile(
+		context.Background(),
+		filepath.Join("..", "..", "testdata", "pypi.attestation.json"),
+		distPath,
+		WithEmbeddedTrustedRoot(InstanceProduction),
+	)
+	if err == nil {
+		t.Error("Expected error for tampered distribution")
+	}
+}
+
+func TestVerifyNamed(t *testing.T) {
+	v, err := New(WithEmbeddedTrustedRoot(InstanceProduction))
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+	attestation := loadTestAttestation(t)
+
+	if _, err := v.VerifyNamed(context.Background(), attestation, "other-0.0.28.tar.gz", strings.NewReader("")); err == nil {
+		t.Error("Expected error for mismatched filename")
+	}
+	if _, err := v.VerifyNamed(context.Background(), attestation, "pypi_attestations-0.0.28.tar.gz", strings.NewReader("not the real sdist")); err == nil {
+		t.Error("Expected error for tampered distribution")
+	}
+	if _, err := v.VerifyNamed(context.Background(), nil, "pypi_attestations-0.0.28.tar.gz", strings.NewReader("")); err == nil {
+		t.Error("Expected error for nil attestation")
+	}
+
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+	if _, err := v.VerifyNamedDigest(context.Background(), attestation, "pypi_attestations-0.0.28.tar.gz", digest); err != nil {
+		t.Errorf("Expected the digest to verify: %v", err)
+	}
+	if _, err := v.VerifyNamedDigest(context.Background(), attestation, "other-0.0.28.tar.gz", digest); err == nil {
+		t.Error("Expected error for mismatched filename")
+	}
+}
+
+func TestVerificationResult(t *testing.T) {
+	v, err := New(WithEmbeddedTrustedRoot(InstanceProduction))
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	res, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest)
+	if err != nil {
+		t.Fatalf("Failed to verify: %v", err)
+	}
+
+	if res.Issuer != "https://token.actions.githubusercontent.com" {
+		t.Errorf("Unexpected issuer: %s", res.Issuer)
+	}
+
+	if res.Identity != "https://github.com/pypi/pypi-attestations/.github/workflows/release.yml@refs/tags/v0.0.28" {
+		t.Errorf("Unexpected identity: %s", res.Identity)
+	}
+
+	if res.Extensions.SourceRepositoryURI != "https://github.com/pypi/pypi-attestations" {
+		t.Errorf("Unexpected source repository: %s", res.Extensions.SourceRepositoryURI)
+	}
+
+	if len(res.LogEntries) != 1 || res.LogEntries[0].LogIndex != 613501255 {
+		t.Errorf("Unexpected log entries: %+v", res.LogEntries)
+	}
+
+	if res.PredicateType != "https://docs.pypi.org/attestations/publish/v1" {
+		t.Errorf("Unexpected predicate type: %s", res.PredicateType)
+	}
+
+	if len(res.Subjects) != 1 || res.Subjects[0].Digest["sha256"] != testDigest {
+		t.Errorf("Unexpected subjects: %+v", res.Subjects)
+	}
+
+	if res.Certificate == nil || !bytes.Equal(res.Certificate.Raw, loadTestAttestation(t).GetVerificationMaterial().GetCertificate()) {
+		t.Error("Expected the parsed signing certificate in the result")
+	}
+}
+
+func TestVerifyInclusionOffline(t *testing.T) {
+	v, err := New(WithOffline(true))
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	if err := v.VerifyInclusion(context.Background(), loadTestAttestation(t)); err != nil {
+		t.Errorf("Expected inclusion proof to verify: %v", err)
+	}
+
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err != nil {
+		t.Errorf("Expected offline verification to pass: %v", err)
+	}
+
+	t.Run("tampered proof", func(t *testing.T) {
+		attestation := loadTestAttestation(t)
+		proof := attestation.VerificationMaterial.TransparencyEntries[0].Fields["inclusionProof"].GetStructValue()
+		proof.Fields["logIndex"] = structpb.NewStringValue("1")
+		if err := v.VerifyInclusion(context.Background(), attestation); err == nil {
+			t.Error("Expected error for tampered inclusion proof")
+		}
+	})
+
+	t.Run("missing proof", func(t *testing.T) {
+		attestation := loadTestAttestation(t)
+		delete(attestation.VerificationMaterial.TransparencyEntries[0].Fields, "inclusionProof")
+		if err := v.VerifyInclusion(context.Background(), attestation); err == nil {
+			t.Error("Expected error for entry without inclusion proof")
+		}
+	})
+}
+
+func TestIsRekorV1Checkpoint(t *testing.T) {
+	for checkpoint, expected := range map[string]bool{
+		"rekor.sigstore.dev - 1193050959916656506\n491597006\nANY5k+7/9vUebiySUjUcOTVl6wHGW6HpjLwDyPkQR78=\n": true,
+		"log2025-1.rekor.sigstore.dev\n1234\nANY5k+7/9vUebiySUjUcOTVl6wHGW6HpjLwDyPkQR78=\n":                  false,
+		"": false,
+	} {
+		if got := isRekorV1Checkpoint(checkpoint); got != expected {
+			t.Errorf("isRekorV1Checkpoint(%q) = %v, expected %v", checkpoint, got, expected)
+		}
+	}
+}
+
+func TestVerificationTime(t *testing.T) {
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	// The test attestation was logged at 2025-10-16T16:58:04Z
+	for _, tc := range []struct {
+		name    string
+		time    time.Time
+		mustErr bool
+	}{
+		{"current time", time.Time{}, false},
+		{"years after expiry", time.Date(2035, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"before logging", time.Date(2025, 10, 16, 16, 0, 0, 0, time.UTC), true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := New(WithEmbeddedTrustedRoot(InstanceProduction), WithVerificationTime(tc.time))
+			if err != nil {
+				t.Fatalf("Failed to create verifier: %v", err)
+			}
+			_, err = v.VerifyDigest(context.Background(), loadTestAttestation(t), digest)
+			if tc.mustErr && err == nil {
+				t.Error("Expected verification to fail")
+			} else if !tc.mustErr && err != nil {
+				t.Errorf("Expected verification to pass: %v", err)
+			}
+		})
+	}
+}
+
+func TestRequireSignedTimestamp(t *testing.T) {
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	v, err := New(WithEmbeddedTrustedRoot(InstanceProduction), WithRequireSignedTimestamp(true))
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+
+	// The test attestation has no RFC 3161 timestamps
+	if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err == nil {
+		t.Error("Expected error when signed timestamp is required")
+	}
+}
+
+func TestSCTReporting(t *testing.T) {
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	for _, require := range []bool{true, false} {
+		v, err := New(WithEmbeddedTrustedRoot(InstanceProduction), WithRequireSCT(require))
+		if err != nil {
+			t.Fatalf("Failed to create verifier: %v", err)
+		}
+
+		res, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest)
+		if err != nil {
+			t.Fatalf("Failed to verify: %v", err)
+		}
+
+		if res.SCTVerified != require {
+			t.Errorf("Expected SCTVerified to be %v", require)
+		}
+
+		if len(res.SCTs) != 1 {
+			t.Fatalf("Expected 1 SCT, got %d", len(res.SCTs))
+		}
+
+		if res.SCTs[0].LogURL != "https://ctfe.sigstore.dev/2022" {
+			t.Errorf("Unexpected CT log URL: %q", res.SCTs[0].LogURL)
+		}
+
+		if res.SCTs[0].Timestamp.IsZero() {
+			t.Error("SCT timestamp is zero")
+		}
+	}
+}
+
+func TestVerifyAll(t *testing.T) {
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	requests := []Request{
+		{Attestation: loadTestAttestation(t), Digest: digest},
+		{Attestation: loadTestAttestation(t), Digest: make([]byte, 32)},
+		{},
+		{Attestation: loadTestAttestation(t), Digest: digest},
+	}
+
+	results, err := VerifyAll(
+		context.Background(), requests,
+		WithEmbeddedTrustedRoot(InstanceProduction), WithWorkers(2),
+	)
+	if err != nil {
+		t.Fatalf("VerifyAll failed: %v", err)
+	}
+
+	if len(results) != len(requests) {
+		t.Fatalf("Expected %d results, got %d", len(requests), len(results))
+	}
+
+	for i, expected := range []bool{true, false, false, true} {
+		if results[i].Passed() != expected {
+			t.Errorf("Result %d: expected passed=%v, got error: %v", i, expected, results[i].Error)
+		}
+	}
+
+	if results[0].Verification == nil {
+		t.Error("Expected verification result for passing request")
+	}
+}
+
+func TestVerifySignature(t *testing.T) {
+	if err := VerifySignature(loadTestAttestation(t)); err != nil {
+		t.Errorf("Expected signature to verify: %v", err)
+	}
+
+	attestation := loadTestAttestation(t)
+	attestation.Envelope.Statement = append(attestation.Envelope.Statement, ' ')
+	if err := VerifySignature(attestation); err == nil {
+		t.Error("Expected error for tampered statement")
+	}
+
+	if err := VerifySignature(nil); err == nil {
+		t.Error("Expected error for nil attestation")
+	}
+}
+
+func TestCheckEntryConsistency(t *testing.T) {
+	if err := CheckEntryConsistency(loadTestAttestation(t)); err != nil {
+		t.Errorf("Expected entries to be consistent: %v", err)
+	}
+
+	attestation := loadTestAttestation(t)
+	attestation.Envelope.Statement = append(attestation.Envelope.Statement, ' ')
+	if err := CheckEntryConsistency(attestation); !errors.Is(err, ErrEntryMismatch) {
+		t.Errorf("Expected mismatch for a spliced statement, got %v", err)
+	}
+
+	attestation = loadTestAttestation(t)
+	attestation.Envelope.Signature[len(attestation.Envelope.Signature)-1] ^= 0xff
+	if err := CheckEntryConsistency(attestation); !errors.Is(err, ErrEntryMismatch) {
+		t.Errorf("Expected mismatch for a spliced signature, got %v", err)
+	}
+
+	attestation = loadTestAttestation(t)
+	attestation.VerificationMaterial.TransparencyEntries = nil
+	if err := CheckEntryConsistency(attestation); err == nil {
+		t.Error("Expected error without transparency entries")
+	}
+
+	t.Run("rekor v2", func(t *testing.T) {
+		if err := CheckEntryConsistency(loadRekorV2Attestation(t)); err != nil {
+			t.Errorf("Expected entries to be consistent: %v", err)
+		}
+
+		attestation := loadRekorV2Attestation(t)
+		attestation.Envelope.Statement = append(attestation.Envelope.Statement, ' ')
+		if err := CheckEntryConsistency(attestation); !errors.Is(err, ErrEntryMismatch) {
+			t.Errorf("Expected mismatch for a spliced statement, got %v", err)
+		}
+
+		attestation = loadRekorV2Attestation(t)
+		attestation.Envelope.Signature[len(attestation.Envelope.Signature)-1] ^= 0xff
+		if err := CheckEntryConsistency(attestation); !errors.Is(err, ErrEntryMismatch) {
+			t.Errorf("Expected mismatch for a spliced signature, got %v", err)
+		}
+	})
+}
+
+func TestVerifyRekorV2(t *testing.T) {
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	for _, offline := range []bool{false, true} {
+		t.Run(fmt.Sprintf("offline=%v", offline), func(t *testing.T) {
+			// The test Fulcio does not embed SCTs
+			v, err := New(WithTrustedRootPath(rekorV2TrustedRoot), WithRequireSCT(false), WithOffline(offline))
+			if err != nil {
+				t.Fatalf("Failed to create verifier: %v", err)
+			}
+
+			if err := v.VerifyInclusion(context.Background(), loadRekorV2Attestation(t)); err != nil {
+				t.Errorf("Expected inclusion proof to verify: %v", err)
+			}
+
+			res, err := v.VerifyDigest(context.Background(), loadRekorV2Attestation(t), digest)
+			if err != nil {
+				t.Fatalf("Expected Rekor v2 attestation to verify: %v", err)
+			}
+			if len(res.LogEntries) != 1 || !res.LogEntries[0].IntegratedTime.IsZero() {
+				t.Errorf("Unexpected log entries: %+v", res.LogEntries)
+			}
+			if len(res.Timestamps) != 1 || res.Timestamps[0].Type != "TimestampAuthority" {
+				t.Errorf("Expected a signed timestamp, got %+v", res.Timestamps)
+			}
+
+			attestation := loadRekorV2Attestation(t)
+			attestation.VerificationMaterial.TransparencyEntries[0].Fields["logIndex"] = structpb.NewStringValue("0")
+			if _, err := v.VerifyDigest(context.Background(), attestation, digest); err == nil {
+				t.Error("Expected error for tampered inclusion proof")
+			}
+
+			attestation = loadRekorV2Attestation(t)
+			attestation.VerificationMaterial.Rfc3161Timestamps = nil
+			if _, err := v.VerifyDigest(context.Background(), attestation, digest); err == nil {
+				t.Error("Expected error without a timestamp for an entry with no integrated time")
+			}
+		})
+	}
+}
+
+func TestPAE(t *testing.T) {
+	got := string(PAE("http://example.com/HelloWorld", []byte("hello world")))
+	expected := "DSSEv1 29 http://example.com/HelloWorld 11 hello world"
+	if got != expected {
+		t.Errorf("PAE mismatch:\n got: %q\nwant: %q", got, expected)
+	}
+}
+
+func TestRevocation(t *testing.T) {
+	attestation := loadTestAttestation(t)
+	cert, err := x509.ParseCertificate(attestation.VerificationMaterial.Certificate)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	fp := sha256.Sum256(cert.Raw)
+
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+	rootPath := filepath.Join("..", "..", "testdata", "trusted_root.json")
+
+	t.Run("not checked", func(t *testing.T) {
+		v, err := New(WithTrustedRootPath(rootPath))
+		if err != nil {
+			t.Fatalf("Failed to create verifier: %v", err)
+		}
+		result, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest)
+		if err != nil {
+			t.Fatalf("Expected attestation to verify: %v", err)
+		}
+		if result.RevocationChecked {
+			t.Error("Expected revocation not to be checked")
+		}
+	})
+
+	t.Run("not revoked", func(t *testing.T) {
+		denylist := NewDenylist()
+		if err := denylist.AddSerial("01"); err != nil {
+			t.Fatalf("Failed to add serial: %v", err)
+		}
+		v, err := New(WithTrustedRootPath(rootPath), WithRevocationChecker(denylist))
+		if err != nil {
+			t.Fatalf("Failed to create verifier: %v", err)
+		}
+		result, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest)
+		if err != nil {
+			t.Fatalf("Expected attestation to verify: %v", err)
+		}
+		if !result.RevocationChecked {
+			t.Error("Expected revocation to be checked")
+		}
+	})
+
+	for name, entry := range map[string]string{
+		"fingerprint": "sha256:" + hex.EncodeToString(fp[:]),
+		"serial":      "serial:" + cert.SerialNumber.Text(16),
+	} {
+		t.Run("revoked "+name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "denylist.txt")
+			if err := os.WriteFile(path, []byte("# revoked\n"+entry+"\n"), 0o600); err != nil {
+				t.Fatalf("Failed to write denylist: %v", err)
+			}
+			denylist, err := LoadDenylist(path)
+			if err != nil {
+				t.Fatalf("Failed to load denylist: %v", err)
+			}
+			v, err := New(WithTrustedRootPath(rootPath), WithRevocationChecker(denylist))
+			if err != nil {
+				t.Fatalf("Failed to create verifier: %v", err)
+			}
+			if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err == nil {
+				t.Error("Expected error for revoked certificate")
+			}
+		})
+	}
+
+	t.Run("CRL", func(t *testing.T) {
+		caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		caTmpl := &x509.Certificate{
+			SerialNumber:          big.NewInt(1),
+			Subject:               pkix.Name{CommonName: "test ca"},
+			NotBefore:             time.Now().Add(-time.Hour),
+			NotAfter:              time.Now().Add(time.Hour),
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		}
+		caDER, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+		if err != nil {
+			t.Fatalf("Failed to create CA certificate: %v", err)
+		}
+		ca, err := x509.ParseCertificate(caDER)
+		if err != nil {
+			t.Fatalf("Failed to parse CA certificate: %v", err)
+		}
+		tm, err := root.NewTrustedRoot(root.TrustedRootMediaType01,
+			[]root.CertificateAuthority{&root.FulcioCertificateAuthority{Root: ca}}, nil, nil, nil)
+		if err != nil {
+			t.Fatalf("Failed to create trusted root: %v", err)
+		}
+		leaf := &x509.Certificate{SerialNumber: big.NewInt(42), RawIssuer: ca.RawSubject}
+
+		newCRL := func(t *testing.T, key *ecdsa.PrivateKey, next time.Time) []byte {
+			t.Helper()
+			der, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+				Number:                    big.NewInt(1),
+				ThisUpdate:                time.Now().Add(-time.Hour),
+				NextUpdate:                next,
+				RevokedCertificateEntries: []x509.RevocationListEntry{{SerialNumber: leaf.SerialNumber, RevocationTime: time.Now()}},
+			}, ca, key)
+			if err != nil {
+				t.Fatalf("Failed to create CRL: %v", err)
+			}
+			return pem.EncodeToMemory(&pem.Block{Type: "X509 CRL", Bytes: der})
+		}
+
+		checker, err := LoadCRL(newCRL(t, caKey, time.Now().Add(time.Hour)), tm)
+		if err != nil {
+			t.Fatalf("Failed to load CRL: %v", err)
+		}
+		revoked, err := checker.IsRevoked(context.Background(), leaf)
+		if err != nil {
+			t.Fatalf("Failed to check CRL: %v", err)
+		}
+		if !revoked {
+			t.Error("Expected certificate to be revoked")
+		}
+		if revoked, err := checker.IsRevoked(context.Background(), cert); err != nil || revoked {
+			t.Errorf("Expected a certificate of another issuer not to be revoked, got %v, %v", revoked, err)
+		}
+
+		otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		if _, err := LoadCRL(newCRL(t, otherKey, time.Now().Add(time.Hour)), tm); err == nil {
+			t.Error("Expected error for a CRL not signed by the CA")
+		}
+		if _, err := LoadCRL(newCRL(t, caKey, time.Now().Add(-time.Minute)), tm); err == nil {
+			t.Error("Expected error for a CRL past its next update")
+		}
+
+		// CRLs set directly are checked too
+		expired, err := x509.ParseRevocationList(pemOrDER(newCRL(t, caKey, time.Now().Add(-time.Minute))))
+		if err != nil {
+			t.Fatalf("Failed to parse CRL: %v", err)
+		}
+		checker = &CRLChecker{CRLs: []*x509.RevocationList{expired}, TrustedMaterial: tm}
+		if _, err := checker.IsRevoked(context.Background(), leaf); err == nil {
+			t.Error("Expected error checking an expired CRL")
+		}
+	})
+}
+
+func TestPolicy(t *testing.T) {
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		policy   string
+		expected string
+		mustErr  bool
+	}{
+		{
+			name: "yaml repository and workflow",
+			policy: `publishers:
+  - name: other
+    repository: example/other
+  - name: release
+    repository: PyPI/pypi-attestations
+    workflow: release.yml
+`,
+			expected: "release",
+		},
+		{
+			name:     "json repository url",
+			policy:   `{"publishers": [{"name": "url", "repository": "https://github.com/pypi/pypi-attestations"}]}`,
+			expected: "url",
+		},
+		{
+			name:    "repository url on another host",
+			policy:  "publishers:\n  - repository: https://gitlab.example/pypi/pypi-attestations\n",
+			mustErr: true,
+		},
+		{
+			name:    "workflow mismatch",
+			policy:  "publishers:\n  - repository: pypi/pypi-attestations\n    workflow: ci.yml\n",
+			mustErr: true,
+		},
+		{
+			name:    "environment not in certificate",
+			policy:  "publishers:\n  - repository: pypi/pypi-attestations\n    environment: pypi\n",
+			mustErr: true,
+		},
+		{
+			name:    "issuer mismatch",
+			policy:  "publishers:\n  - repository: pypi/pypi-attestations\n    issuer: https://gitlab.com\n",
+			mustErr: true,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "policy.yaml")
+			if err := os.WriteFile(path, []byte(tc.policy), 0o600); err != nil {
+				t.Fatalf("Failed to write policy: %v", err)
+			}
+			v, err := New(WithEmbeddedTrustedRoot(InstanceProduction), WithPolicyFile(path))
+			if err != nil {
+				t.Fatalf("Failed to create verifier: %v", err)
+			}
+			result, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest)
+			if tc.mustErr {
+				if err == nil {
+					t.Error("Expected policy evaluation to fail")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected policy evaluation to pass: %v", err)
+			}
+			if result.Policy == nil || result.Policy.Name != tc.expected {
+				t.Errorf("Unexpected matched policy: %+v", result.Policy)
+			}
+		})
+	}
+
+	t.Run("environment from publisher", func(t *testing.T) {
+		policy, err := ParsePolicy([]byte("publishers:\n  - name: pypi\n    repository: pypi/pypi-attestations\n    environment: pypi\n"))
+		if err != nil {
+			t.Fatalf("Failed to parse policy: %v", err)
+		}
+		v, err := New(WithEmbeddedTrustedRoot(InstanceProduction), WithPolicy(policy), WithCache(NewMemoryCache(time.Hour)))
+		if err != nil {
+			t.Fatalf("Failed to create verifier: %v", err)
+		}
+
+		pub := &publisher.GitHubPublisher{Repository: "pypi/pypi-attestations", Workflow: "release.yml", Environment: "PyPI"}
+		result, err := v.VerifyDigestWithPublisher(context.Background(), loadTestAttestation(t), digest, pub)
+		if err != nil {
+			t.Fatalf("Expected the publisher environment to match: %v", err)
+		}
+		if result.Policy == nil || result.Policy.Name != "pypi" {
+			t.Errorf("Unexpected matched policy: %+v", result.Policy)
+		}
+
+		// The cached result must not be reused for other environments
+		pub.Environment = "staging"
+		if _, err := v.VerifyDigestWithPublisher(context.Background(), loadTestAttestation(t), digest, pub); err == nil {
+			t.Error("Expected policy evaluation to fail for another environment")
+		}
+		if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err == nil {
+			t.Error("Expected policy evaluation to fail without a publisher")
+		}
+	})
+
+	t.Run("invalid policy", func(t *testing.T) {
+		if _, err := ParsePolicy([]byte("publishers:\n  - workflow: release.yml\n")); err == nil {
+			t.Error("Expected error for publisher without repository")
+		}
+		if _, err := ParsePolicy([]byte("publishers: []\n")); err == nil {
+			t.Error("Expected error for empty policy")
+		}
+	})
+}
+
+func TestPolicyEngine(t *testing.T) {
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	// official requires the attestation to be built by a workflow of the
+	// pypi organization and carry the PyPI publish predicate.
+	official := PolicyEngineFunc(func(_ context.Context, input *PolicyInput) error {
+		if input.Result.Extensions.SourceRepositoryOwnerURI != "https://github.com/pypi" {
+			return fmt.Errorf("unexpected owner %q", input.Result.Extensions.SourceRepositoryOwnerURI)
+		}
+		if input.Statement["predicateType"] != "https://docs.pypi.org/attestations/publish/v1" {
+			return fmt.Errorf("unexpected predicate type %v", input.Statement["predicateType"])
+		}
+		return nil
+	})
+
+	reject := PolicyEngineFunc(func(context.Context, *PolicyInput) error {
+		return fmt.Errorf("rejected")
+	})
+
+	v, err := New(WithEmbeddedTrustedRoot(InstanceProduction), WithPolicyEngine(official))
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+	if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err != nil {
+		t.Errorf("Expected policy engine to pass: %v", err)
+	}
+
+	v, err = New(WithEmbeddedTrustedRoot(InstanceProduction), WithPolicyEngine(official), WithPolicyEngine(reject))
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+	if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err == nil {
+		t.Error("Expected policy engine to reject attestation")
+	}
+}
+
+// countingCache wraps a Cache counting the hits.
+type countingCache struct {
+	Cache
+	hits int
+}
+
+func (c *countingCache) Get(key string) (*VerificationResult, bool) {
+	result, ok := c.Cache.Get(key)
+	if ok {
+		c.hits++
+	}
+	return result, ok
+}
+
+func TestCache(t *testing.T) {
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+
+	fileCache, err := NewFileCache(filepath.Join(t.TempDir(), "cache"), time.Hour)
+	if err != nil {
+		t.Fatalf("Failed to create file cache: %v", err)
+	}
+
+	for name, backend := range map[string]Cache{
+		"memory": NewMemoryCache(time.Hour),
+		"file":   fileCache,
+	} {
+		t.Run(name, func(t *testing.T) {
+			cache := &countingCache{Cache: backend}
+			v, err := New(WithEmbeddedTrustedRoot(InstanceProduction), WithCache(cache))
+			if err != nil {
+				t.Fatalf("Failed to create verifier: %v", err)
+			}
+
+			first, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest)
+			if err != nil {
+				t.Fatalf("Failed to verify: %v", err)
+			}
+			second, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest)
+			if err != nil {
+				t.Fatalf("Failed to verify: %v", err)
+			}
+			if cache.hits != 1 {
+				t.Errorf("Expected one cache hit, got %d", cache.hits)
+			}
+			if second.Identity != first.Identity || len(second.LogEntries) != len(first.LogEntries) {
+				t.Errorf("Cached result differs: %+v", second)
+			}
+			if second.Certificate == nil || !second.Certificate.Equal(first.Certificate) {
+				t.Error("Expected the signing certificate in the cached result")
+			}
+
+			// Different options must not reuse the cached result
+			v, err = New(WithEmbeddedTrustedRoot(InstanceProduction), WithRequireSCT(false), WithCache(cache))
+			if err != nil {
+				t.Fatalf("Failed to create verifier: %v", err)
+			}
+			if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err != nil {
+				t.Fatalf("Failed to verify: %v", err)
+			}
+
+			// Nor must a different digest
+			other := make([]byte, len(digest))
+			copy(other, digest)
+			other[0] ^= 0xff
+			if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), other); err == nil {
+				t.Error("Expected error for mismatched digest")
+			}
+
+			if cache.hits != 1 {
+				t.Errorf("Expected one cache hit, got %d", cache.hits)
+			}
+		})
+	}
+
+	t.Run("rechecked", func(t *testing.T) {
+		cert, err := x509.ParseCertificate(loadTestAttestation(t).VerificationMaterial.Certificate)
+		if err != nil {
+			t.Fatalf("Failed to parse certificate: %v", err)
+		}
+
+		var reject bool
+		engine := PolicyEngineFunc(func(context.Context, *PolicyInput) error {
+			if reject {
+				return fmt.Errorf("rejected")
+			}
+			return nil
+		})
+		denylist := NewDenylist()
+		cache := &countingCache{Cache: NewMemoryCache(time.Hour)}
+		v, err := New(
+			WithEmbeddedTrustedRoot(InstanceProduction), WithCache(cache),
+			WithRevocationChecker(denylist), WithPolicyEngine(engine),
+		)
+		if err != nil {
+			t.Fatalf("Failed to create verifier: %v", err)
+		}
+
+		if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err != nil {
+			t.Fatalf("Failed to verify: %v", err)
+		}
+		result, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest)
+		if err != nil {
+			t.Fatalf("Failed to verify: %v", err)
+		}
+		if cache.hits != 1 || !result.RevocationChecked {
+			t.Errorf("Expected a cached result checked for revocation, hits %d", cache.hits)
+		}
+
+		reject = true
+		if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err == nil {
+			t.Error("Expected policy engine to reject the cached result")
+		}
+
+		reject = false
+		if err := denylist.AddSerial(cert.SerialNumber.Text(16)); err != nil {
+			t.Fatalf("Failed to add serial: %v", err)
+		}
+		if _, err := v.VerifyDigest(context.Background(), loadTestAttestation(t), digest); err == nil {
+			t.Error("Expected the certificate revoked after caching to be rejected")
+		}
+		if cache.hits != 3 {
+			t.Errorf("Expected three cache hits, got %d", cache.hits)
+		}
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		cache := NewMemoryCache(-time.Second)
+		if err := cache.Set("key", &VerificationResult{Identity: "x"}); err != nil {
+			t.Fatalf("Failed to store result: %v", err)
+		}
+		if _, ok := cache.Get("key"); ok {
+			t.Error("Expected expired entry to be discarded")
+		}
+	})
+}
+
+func TestVerifyCustody(t *testing.T) {
+	digest, err := hex.DecodeString(testDigest)
+	if err != nil {
+		t.Fatalf("Failed to decode digest: %v", err)
+	}
+	v, err := New(WithEmbeddedTrustedRoot(InstanceProduction))
+	if err != nil {
+		t.Fatalf("Failed to create verifier: %v", err)
+	}
+	publisher := []IdentityPolicy{{
+		Issuer:    "https://token.actions.githubusercontent.com",
+		SANRegexp: `^https://github\.com/pypi/pypi-attestations/`,
+	}}
+
+	results, err := v.VerifyCustody(context.Background(), []CustodyLink{
+		{Attestation: loadTestAttestation(t), Identities: publisher},
+		{Attestation: loadTestAttestation(t)},
+	}, digest)
+	if err != nil {
+		t.Fatalf("Expected chain of custody to verify: %v", err)
+	}
+	if len(results) != 2 {
+		t.Errorf("Expected 2 results, got %d", len(results))
+	}
+
+	_, err = v.VerifyCustody(context.Background(), []CustodyLink{
+		{Attestation: loadTestAttestation(t)},
+		{Attestation: loadTestAttestation(t), Identities: []IdentityPolicy{{Issuer: "https://gitlab.com", SANRegexp: ".*"}}},
+	}, digest)
+	if err == nil {
+		t.Error("Expected error for a link signed by another identity")
+	}
+
+	other := loadTestAttestation(t)
+	other.Envelope.Statement = append(other.Envelope.Statement, ' ')
+	_, err = v.VerifyCustody(context.Background(), []CustodyLink{
+		{Attestation: loadTestAttestation(t)}, {Attestation: other},
+	}, digest)
+	if !errors.Is(err, ErrCustodyBroken) {
+		t.Errorf("Expected ErrCustodyBroken for a different statement, got %v", err)
+	}
+
+	if _, err := v.VerifyCustody(context.Background(), nil, digest); err == nil {
+		t.Error("Expected error for an empty chain")
+	}
+
+	if _, err := earliestTime(&VerificationResult{}); !errors.Is(err, ErrCustodyBroken) {
+		t.Errorf("Expected ErrCustodyBroken for a link without timestamps, got %v", err)
+	}
+}
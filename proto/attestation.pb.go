@@ -99,8 +99,21 @@ type VerificationMaterial struct {
 	// and certificate. Each entry is a structured object containing transparency
 	// log metadata.
 	TransparencyEntries []*structpb.Struct `protobuf:"bytes,2,rep,name=transparency_entries,json=transparencyEntries,proto3" json:"transparency_entries,omitempty"`
-	unknownFields       protoimpl.UnknownFields
-	sizeCache           protoimpl.SizeCache
+	// RFC 3161 signed timestamps over the signature, as DER-encoded bytes.
+	//
+	// This field is not part of PEP 740. It carries the timestamp
+	// verification data of Sigstore bundles so that converting between
+	// formats does not drop it. It is not written to the PEP 740 JSON form.
+	Rfc3161Timestamps [][]byte `protobuf:"bytes,3,rep,name=rfc3161_timestamps,json=rfc3161Timestamps,proto3" json:"rfc3161_timestamps,omitempty"`
+	// Intermediate certificates of the signing certificate chain, as
+	// DER-encoded bytes, ordered from the leaf issuer towards the root.
+	//
+	// This field is not part of PEP 740. It preserves the X.509 certificate
+	// chain of Sigstore bundles before v0.3. It is not written to the PEP 740
+	// JSON form.
+	IntermediateCertificates [][]byte `protobuf:"bytes,4,rep,name=intermediate_certificates,json=intermediateCertificates,proto3" json:"intermediate_certificates,omitempty"`
+	unknownFields            protoimpl.UnknownFields
+	sizeCache                protoimpl.SizeCache
 }
 
 func (x *VerificationMaterial) Reset() {
@@ -147,6 +160,20 @@ func (x *VerificationMaterial) GetTransparencyEntries() []*structpb.Struct {
 	return nil
 }
 
+func (x *VerificationMaterial) GetRfc3161Timestamps() [][]byte {
+	if x != nil {
+		return x.Rfc3161Timestamps
+	}
+	return nil
+}
+
+func (x *VerificationMaterial) GetIntermediateCertificates() [][]byte {
+	if x != nil {
+		return x.IntermediateCertificates
+	}
+	return nil
+}
+
 // The attestation envelope, containing the attested-for payload and its signature.
 type Envelope struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -158,9 +185,15 @@ type Envelope struct {
 	Statement []byte `protobuf:"bytes,1,opt,name=statement,proto3" json:"statement,omitempty"`
 	// A signature for the above statement.
 	// In the JSON representation, this is base64-encoded.
-	Signature     []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
-	unknownFields protoimpl.UnknownFields
-	sizeCache     protoimpl.SizeCache
+	Signature []byte `protobuf:"bytes,2,opt,name=signature,proto3" json:"signature,omitempty"`
+	// Further signatures over the statement.
+	//
+	// This field is not part of PEP 740. It carries the additional
+	// signatures of multi-signature DSSE envelopes so that converting between
+	// formats does not drop them. It is not written to the PEP 740 JSON form.
+	AdditionalSignatures []*Signature `protobuf:"bytes,3,rep,name=additional_signatures,json=additionalSignatures,proto3" json:"additional_signatures,omitempty"`
+	unknownFields        protoimpl.UnknownFields
+	sizeCache            protoimpl.SizeCache
 }
 
 func (x *Envelope) Reset() {
@@ -207,6 +240,183 @@ func (x *Envelope) GetSignature() []byte {
 	return nil
 }
 
+func (x *Envelope) GetAdditionalSignatures() []*Signature {
+	if x != nil {
+		return x.AdditionalSignatures
+	}
+	return nil
+}
+
+// A DSSE signature that is not part of PEP 740.
+type Signature struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The signature bytes.
+	Sig []byte `protobuf:"bytes,1,opt,name=sig,proto3" json:"sig,omitempty"`
+	// The optional ID of the key that produced the signature.
+	Keyid         string `protobuf:"bytes,2,opt,name=keyid,proto3" json:"keyid,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Signature) Reset() {
+	*x = Signature{}
+	mi := &file_proto_attestation_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Signature) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Signature) ProtoMessage() {}
+
+func (x *Signature) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_attestation_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Signature.ProtoReflect.Descriptor instead.
+func (*Signature) Descriptor() ([]byte, []int) {
+	return file_proto_attestation_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Signature) GetSig() []byte {
+	if x != nil {
+		return x.Sig
+	}
+	return nil
+}
+
+func (x *Signature) GetKeyid() string {
+	if x != nil {
+		return x.Keyid
+	}
+	return ""
+}
+
+// Provenance object as defined in PEP 740.
+//
+// Groups the attestations of a distribution file by the Trusted Publisher
+// identity that produced them. This is the object served by the index
+// Integrity API.
+type Provenance struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The provenance object's version, which is always 1.
+	Version uint32 `protobuf:"varint,1,opt,name=version,proto3" json:"version,omitempty"`
+	// One or more attestation bundles.
+	AttestationBundles []*AttestationBundle `protobuf:"bytes,2,rep,name=attestation_bundles,json=attestationBundles,proto3" json:"attestation_bundles,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *Provenance) Reset() {
+	*x = Provenance{}
+	mi := &file_proto_attestation_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Provenance) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Provenance) ProtoMessage() {}
+
+func (x *Provenance) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_attestation_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Provenance.ProtoReflect.Descriptor instead.
+func (*Provenance) Descriptor() ([]byte, []int) {
+	return file_proto_attestation_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Provenance) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Provenance) GetAttestationBundles() []*AttestationBundle {
+	if x != nil {
+		return x.AttestationBundles
+	}
+	return nil
+}
+
+// A group of attestations produced by the same publisher.
+type AttestationBundle struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// The Trusted Publisher claims for the attestations. The "kind" key
+	// identifies the publisher type, the remaining keys are specific to it.
+	Publisher *structpb.Struct `protobuf:"bytes,1,opt,name=publisher,proto3" json:"publisher,omitempty"`
+	// One or more attestations produced by the publisher.
+	Attestations  []*Attestation `protobuf:"bytes,2,rep,name=attestations,proto3" json:"attestations,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *AttestationBundle) Reset() {
+	*x = AttestationBundle{}
+	mi := &file_proto_attestation_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *AttestationBundle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AttestationBundle) ProtoMessage() {}
+
+func (x *AttestationBundle) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_attestation_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AttestationBundle.ProtoReflect.Descriptor instead.
+func (*AttestationBundle) Descriptor() ([]byte, []int) {
+	return file_proto_attestation_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *AttestationBundle) GetPublisher() *structpb.Struct {
+	if x != nil {
+		return x.Publisher
+	}
+	return nil
+}
+
+func (x *AttestationBundle) GetAttestations() []*Attestation {
+	if x != nil {
+		return x.Attestations
+	}
+	return nil
+}
+
 var File_proto_attestation_proto protoreflect.FileDescriptor
 
 const file_proto_attestation_proto_rawDesc = "" +
@@ -215,13 +425,26 @@ const file_proto_attestation_proto_rawDesc = "" +
 	"\vAttestation\x12\x18\n" +
 	"\aversion\x18\x01 \x01(\rR\aversion\x12\\\n" +
 	"\x15verification_material\x18\x02 \x01(\v2'.pypi.attestations.VerificationMaterialR\x14verificationMaterial\x127\n" +
-	"\benvelope\x18\x03 \x01(\v2\x1b.pypi.attestations.EnvelopeR\benvelope\"\x84\x01\n" +
+	"\benvelope\x18\x03 \x01(\v2\x1b.pypi.attestations.EnvelopeR\benvelope\"\xf0\x01\n" +
 	"\x14VerificationMaterial\x12 \n" +
 	"\vcertificate\x18\x01 \x01(\fR\vcertificate\x12J\n" +
-	"\x14transparency_entries\x18\x02 \x03(\v2\x17.google.protobuf.StructR\x13transparencyEntries\"F\n" +
+	"\x14transparency_entries\x18\x02 \x03(\v2\x17.google.protobuf.StructR\x13transparencyEntries\x12-\n" +
+	"\x12rfc3161_timestamps\x18\x03 \x03(\fR\x11rfc3161Timestamps\x12;\n" +
+	"\x19intermediate_certificates\x18\x04 \x03(\fR\x18intermediateCertificates\"\x99\x01\n" +
 	"\bEnvelope\x12\x1c\n" +
 	"\tstatement\x18\x01 \x01(\fR\tstatement\x12\x1c\n" +
-	"\tsignature\x18\x02 \x01(\fR\tsignatureB/Z-github.com/pypi/pypi-attestations/proto/pb;pbb\x06proto3"
+	"\tsignature\x18\x02 \x01(\fR\tsignature\x12Q\n" +
+	"\x15additional_signatures\x18\x03 \x03(\v2\x1c.pypi.attestations.SignatureR\x14additionalSignatures\"3\n" +
+	"\tSignature\x12\x10\n" +
+	"\x03sig\x18\x01 \x01(\fR\x03sig\x12\x14\n" +
+	"\x05keyid\x18\x02 \x01(\tR\x05keyid\"}\n" +
+	"\n" +
+	"Provenance\x12\x18\n" +
+	"\aversion\x18\x01 \x01(\rR\aversion\x12U\n" +
+	"\x13attestation_bundles\x18\x02 \x03(\v2$.pypi.attestations.AttestationBundleR\x12attestationBundles\"\x8e\x01\n" +
+	"\x11AttestationBundle\x125\n" +
+	"\tpublisher\x18\x01 \x01(\v2\x17.google.protobuf.StructR\tpublisher\x12B\n" +
+	"\fattestations\x18\x02 \x03(\v2\x1e.pypi.attestations.AttestationR\fattestationsB8Z6github.com/carabiner-dev/pypi-attestations/proto/pb;pbb\x06proto3"
 
 var (
 	file_proto_attestation_proto_rawDescOnce sync.Once
@@ -235,22 +458,29 @@ func file_proto_attestation_proto_rawDescGZIP() []byte {
 	return file_proto_attestation_proto_rawDescData
 }
 
-var file_proto_attestation_proto_msgTypes = make([]protoimpl.MessageInfo, 3)
+var file_proto_attestation_proto_msgTypes = make([]protoimpl.MessageInfo, 6)
 var file_proto_attestation_proto_goTypes = []any{
 	(*Attestation)(nil),          // 0: pypi.attestations.Attestation
 	(*VerificationMaterial)(nil), // 1: pypi.attestations.VerificationMaterial
 	(*Envelope)(nil),             // 2: pypi.attestations.Envelope
-	(*structpb.Struct)(nil),      // 3: google.protobuf.Struct
+	(*Signature)(nil),            // 3: pypi.attestations.Signature
+	(*Provenance)(nil),           // 4: pypi.attestations.Provenance
+	(*AttestationBundle)(nil),    // 5: pypi.attestations.AttestationBundle
+	(*structpb.Struct)(nil),      // 6: google.protobuf.Struct
 }
 var file_proto_attestation_proto_depIdxs = []int32{
 	1, // 0: pypi.attestations.Attestation.verification_material:type_name -> pypi.attestations.VerificationMaterial
 	2, // 1: pypi.attestations.Attestation.envelope:type_name -> pypi.attestations.Envelope
-	3, // 2: pypi.attestations.VerificationMaterial.transparency_entries:type_name -> google.protobuf.Struct
-	3, // [3:3] is the sub-list for method output_type
-	3, // [3:3] is the sub-list for method input_type
-	3, // [3:3] is the sub-list for extension type_name
-	3, // [3:3] is the sub-list for extension extendee
-	0, // [0:3] is the sub-list for field type_name
+	6, // 2: pypi.attestations.VerificationMaterial.transparency_entries:type_name -> google.protobuf.Struct
+	3, // 3: pypi.attestations.Envelope.additional_signatures:type_name -> pypi.attestations.Signature
+	5, // 4: pypi.attestations.Provenance.attestation_bundles:type_name -> pypi.attestations.AttestationBundle
+	6, // 5: pypi.attestations.AttestationBundle.publisher:type_name -> google.protobuf.Struct
+	0, // 6: pypi.attestations.AttestationBundle.attestations:type_name -> pypi.attestations.Attestation
+	7, // [7:7] is the sub-list for method output_type
+	7, // [7:7] is the sub-list for method input_type
+	7, // [7:7] is the sub-list for extension type_name
+	7, // [7:7] is the sub-list for extension extendee
+	0, // [0:7] is the sub-list for field type_name
 }
 
 func init() { file_proto_attestation_proto_init() }
@@ -264,7 +494,7 @@ func file_proto_attestation_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_proto_attestation_proto_rawDesc), len(file_proto_attestation_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   3,
+			NumMessages:   6,
 			NumExtensions: 0,
 			NumServices:   0,
 		},